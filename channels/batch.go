@@ -0,0 +1,57 @@
+package channels
+
+import (
+	"time"
+)
+
+// BatchConsumer processes deliveries in groups, so that work like database
+// writes can be done once per batch rather than once per delivery. Each
+// delivery in a batch must still be acked, rejected or returned individually.
+type BatchConsumer interface {
+	ConsumeBatch([]Delivery)
+}
+
+// BatchingConsumer is a Consumer that collects deliveries and passes them to
+// a BatchConsumer, either when `size` deliveries have arrived or when
+// `window` has passed since the first delivery of the batch, whichever comes
+// first. Batches are handed over one at a time; while a batch is being
+// consumed, Consume blocks, which holds back the channel feeding it.
+type BatchingConsumer struct {
+	consumer   BatchConsumer
+	size       int
+	window     time.Duration
+	deliveries chan Delivery
+}
+
+// NewBatchingConsumer returns a BatchingConsumer passing batches of up to
+// `size` deliveries to `consumer`, waiting at most `window` to fill each one.
+func NewBatchingConsumer(consumer BatchConsumer, size int, window time.Duration) *BatchingConsumer {
+	if size < 1 {
+		size = 1
+	}
+	batching := &BatchingConsumer{consumer, size, window, make(chan Delivery)}
+	go batching.run()
+	return batching
+}
+
+func (batching *BatchingConsumer) Consume(delivery Delivery) {
+	batching.deliveries <- delivery
+}
+
+func (batching *BatchingConsumer) run() {
+	for delivery := range batching.deliveries {
+		batch := []Delivery{delivery}
+		timer := time.NewTimer(batching.window)
+	collect:
+		for len(batch) < batching.size {
+			select {
+			case delivery := <-batching.deliveries:
+				batch = append(batch, delivery)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+		batching.consumer.ConsumeBatch(batch)
+	}
+}
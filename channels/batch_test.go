@@ -0,0 +1,67 @@
+package channels_test
+
+import (
+	"github.com/notegio/openrelay/channels"
+	"testing"
+	"time"
+)
+
+type testBatchConsumer struct {
+	batches chan []channels.Delivery
+}
+
+func (consumer *testBatchConsumer) ConsumeBatch(deliveries []channels.Delivery) {
+	consumer.batches <- deliveries
+}
+
+func TestBatchingConsumerSize(t *testing.T) {
+	name := memName("batch_size")
+	consumerChannel := channels.NewMemQueueConsumerChannel(name)
+	defer consumerChannel.StopConsuming()
+	batches := make(chan []channels.Delivery)
+	consumerChannel.AddConsumer(channels.NewBatchingConsumer(&testBatchConsumer{batches}, 3, time.Minute))
+	consumerChannel.StartConsuming()
+	publisher := channels.NewMemQueuePublisher(name)
+	for _, payload := range []string{"a", "b", "c", "d"} {
+		publisher.Publish(payload)
+	}
+	batch := <-batches
+	if len(batch) != 3 || batch[0].Payload() != "a" || batch[2].Payload() != "c" {
+		t.Fatalf("Unexpected batch %v", batch)
+	}
+	batch[0].Ack()
+	batch[1].Reject()
+	batch[2].Ack()
+	counts, _ := consumerChannel.(channels.Inspector).Counts()
+	if counts[channels.RejectedList] != 1 || counts[channels.UnackedList] != 1 {
+		t.Errorf("Unexpected counts %v", counts)
+	}
+	select {
+	case batch := <-batches:
+		t.Errorf("Batch of %v should wait for the window", len(batch))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestBatchingConsumerWindow(t *testing.T) {
+	name := memName("batch_window")
+	consumerChannel := channels.NewMemQueueConsumerChannel(name)
+	defer consumerChannel.StopConsuming()
+	batches := make(chan []channels.Delivery)
+	consumerChannel.AddConsumer(channels.NewBatchingConsumer(&testBatchConsumer{batches}, 100, 20*time.Millisecond))
+	consumerChannel.StartConsuming()
+	publisher := channels.NewMemQueuePublisher(name)
+	publisher.Publish("a")
+	publisher.Publish("b")
+	select {
+	case batch := <-batches:
+		if len(batch) != 2 {
+			t.Errorf("Expected batch of 2, got %v", len(batch))
+		}
+		for _, delivery := range batch {
+			delivery.Ack()
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Partial batch was not flushed after the window")
+	}
+}
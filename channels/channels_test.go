@@ -216,6 +216,42 @@ func TestRedisQueueReject(t *testing.T) {
 	defer redisCleanup(redisClient, consumerChannel)
 	RejectTest(publisher, consumerChannel, 0, t)
 }
+func TestRedisQueuePurgeRepeatedRejection(t *testing.T) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		t.Errorf("Please set the REDIS_URL environment variable")
+		return
+	}
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisURL,
+	})
+	publisher := channels.NewRedisQueuePublisher("test_queue_purge", redisClient)
+	consumerChannel := channels.NewQueueConsumerChannel("test_queue_purge", redisClient)
+	defer consumerChannel.StopConsuming()
+	consumerChannel.PurgeRejected()
+	deliveries := make(chan channels.Delivery)
+	consumerChannel.AddConsumer(&deliveryConsumer{deliveries})
+	consumerChannel.StartConsuming()
+	publisher.Publish("test")
+	(<-deliveries).Reject()
+	// Rejection times are tracked to the second, so make sure the second
+	// rejection lands after the cutoff.
+	time.Sleep(1100 * time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(1100 * time.Millisecond)
+	publisher.Publish("test")
+	(<-deliveries).Reject()
+	inspector := consumerChannel.(channels.Inspector)
+	if count, err := inspector.PurgeRejectedBefore(cutoff); err != nil || count != 1 {
+		t.Errorf("Expected 1 purged message, got %v (%v)", count, err)
+	}
+	if counts, _ := inspector.Counts(); counts[channels.RejectedList] != 1 {
+		t.Errorf("Expected 1 rejected message to remain, got %v", counts[channels.RejectedList])
+	}
+	if count, _ := inspector.PurgeRejectedBefore(time.Now().Add(time.Second)); count != 1 {
+		t.Errorf("Expected 1 purged message, got %v", count)
+	}
+}
 
 func TestRedisTopicChannelSend(t *testing.T) {
 	redisURL := os.Getenv("REDIS_URL")
@@ -0,0 +1,146 @@
+package channels
+
+import (
+	"errors"
+	"gopkg.in/redis.v3"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// defaultBlockTime is used to convert a delay in blocks to a duration when a
+// delay URI doesn't specify its own block time.
+const defaultBlockTime = 15 * time.Second
+
+// ParseDelay reads the delay of a delay:// URI, eg.
+//
+//    delay://recheck?after=5m
+//    delay://recheck?blocks=2&blocktime=15s
+//
+// A delay in blocks is converted to a duration using the expected block
+// time, so it is only approximate. A URI with neither option has no default
+// delay, and messages are only held back if they carry a due time.
+func ParseDelay(query url.Values) (time.Duration, error) {
+	var after time.Duration
+	var err error
+	if value := query.Get("after"); value != "" {
+		if after, err = time.ParseDuration(value); err != nil {
+			return 0, err
+		}
+	}
+	if value := query.Get("blocks"); value != "" {
+		if after != 0 {
+			return 0, errors.New("Delay URIs may only specify one of after or blocks")
+		}
+		blocks, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, err
+		}
+		blockTime := defaultBlockTime
+		if value := query.Get("blocktime"); value != "" {
+			if blockTime, err = time.ParseDuration(value); err != nil {
+				return 0, err
+			}
+		}
+		after = time.Duration(blocks) * blockTime
+	}
+	if after < 0 {
+		return 0, errors.New("Delay must not be negative")
+	}
+	return after, nil
+}
+
+// dueTime returns when a message published to a delay queue should be
+// delivered: the time in its due header if it has one, or `after` from now.
+// Relays pass headers on, so a message delayed a second time still carries
+// the due time of its first delay. Due times that have already passed are
+// ignored so that the message is held back again.
+func dueTime(payload string, after time.Duration) time.Time {
+	headers, _ := Unwrap(payload)
+	if value, ok := headers[HeaderDue]; ok {
+		due, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			log.Printf("Ignoring invalid due time '%v'", value)
+		} else if due.After(time.Now()) {
+			return due
+		}
+	}
+	return time.Now().Add(after)
+}
+
+// PublishAt publishes `body` with `headers`, asking for it to be delivered
+// at `due`. Delay queues hold the message back until then; other publishers
+// deliver it straight away.
+func PublishAt(publisher Publisher, headers Headers, body string, due time.Time) bool {
+	dueHeaders := Headers{}
+	for key, value := range headers {
+		dueHeaders[key] = value
+	}
+	dueHeaders[HeaderDue] = due.UTC().Format(time.RFC3339Nano)
+	return publisher.Publish(Wrap(dueHeaders, body))
+}
+
+type redisDelayQueuePublisher struct {
+	key         string
+	after       time.Duration
+	redisClient *redis.Client
+}
+
+// NewRedisDelayQueuePublisher returns a Publisher that schedules messages on
+// the queue `key`, to be delivered `after` from when they are published, or
+// at the time given by their due header. Scheduled messages are kept in a
+// sorted set until a delay queue consumer moves them onto the queue.
+// Publishing the same payload again while it is waiting reschedules it.
+func NewRedisDelayQueuePublisher(key string, after time.Duration, client *redis.Client) Publisher {
+	return &redisDelayQueuePublisher{key, after, client}
+}
+
+func (publisher *redisDelayQueuePublisher) String() string {
+	return "delay://" + publisher.key
+}
+
+func (publisher *redisDelayQueuePublisher) Publish(payload string) bool {
+	if len(payload) == 0 {
+		log.Printf("Trying to publish empty message. Skipping")
+		return false
+	}
+	due := float64(dueTime(payload, publisher.after).UnixNano())
+	return countPublish(publisher.String(), publisher.redisClient.ZAdd(publisher.key+"::delayed", redis.Z{Score: due, Member: payload}).Err() == nil)
+}
+
+// NewDelayQueueConsumerChannel returns a queue based ConsumerChannel that
+// delivers messages scheduled by NewRedisDelayQueuePublisher once they are
+// due. Apart from that it behaves like NewRetryQueueConsumerChannel, and
+// messages pushed straight onto the queue are delivered immediately.
+func NewDelayQueueConsumerChannel(channelName string, redisClient *redis.Client, retry RetryPolicy) ConsumerChannel {
+	queue := NewRetryQueueConsumerChannel(channelName, redisClient, retry).(*queueConsumerChannel)
+	queue.scheduled = true
+	return queue
+}
+
+type memDelayQueuePublisher struct {
+	queue *memQueue
+	after time.Duration
+}
+
+// NewMemDelayQueuePublisher returns a Publisher that schedules messages on
+// the in-process queue named `name`, like NewRedisDelayQueuePublisher.
+// In-process queues always deliver scheduled messages once they are due, so
+// any mem:// consumer channel can consume them.
+func NewMemDelayQueuePublisher(name string, after time.Duration) Publisher {
+	return &memDelayQueuePublisher{registry.queue(name), after}
+}
+
+func (publisher *memDelayQueuePublisher) String() string {
+	return "mem+delay://" + publisher.queue.name
+}
+
+func (publisher *memDelayQueuePublisher) Publish(payload string) bool {
+	if len(payload) == 0 {
+		log.Printf("Trying to publish empty message. Skipping")
+		return false
+	}
+	publisher.queue.schedule(payload, dueTime(payload, publisher.after))
+	return countPublish(publisher.String(), true)
+}
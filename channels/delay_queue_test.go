@@ -0,0 +1,132 @@
+package channels_test
+
+import (
+	"github.com/notegio/openrelay/channels"
+	"gopkg.in/redis.v3"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+// expectDelayed publishes through `publish` and checks that the message is
+// held back until roughly `delay` has passed.
+func expectDelayed(t *testing.T, consumerChannel channels.ConsumerChannel, delay, tolerance time.Duration, publish func()) {
+	deliveries := make(chan channels.Delivery, 1)
+	consumerChannel.AddConsumer(&deliveryConsumer{deliveries})
+	consumerChannel.StartConsuming()
+	start := time.Now()
+	publish()
+	select {
+	case delivery := <-deliveries:
+		if elapsed := time.Since(start); elapsed < delay {
+			t.Errorf("Delivery arrived after %v, expected at least %v", elapsed, delay)
+		}
+		if delivery.Payload() != "recheck" {
+			t.Errorf("Unexpected payload '%v'", delivery.Payload())
+		}
+		delivery.Ack()
+	case <-time.After(delay + tolerance):
+		t.Fatalf("Delivery did not arrive within %v", delay+tolerance)
+	}
+}
+
+func TestMemDelayQueue(t *testing.T) {
+	name := memName("mem_delay")
+	publisher, err := channels.MemPublisherFromURI("mem+delay://" + name + "?after=100ms")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	consumerChannel, err := channels.MemConsumerFromURI("mem+delay://" + name)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer consumerChannel.StopConsuming()
+	expectDelayed(t, consumerChannel, 100*time.Millisecond, time.Second, func() {
+		publisher.Publish("recheck")
+	})
+}
+
+func TestMemDelayQueuePublishAt(t *testing.T) {
+	name := memName("mem_delay_at")
+	publisher, _ := channels.MemPublisherFromURI("mem+delay://" + name + "?after=1h")
+	consumerChannel, _ := channels.MemConsumerFromURI("mem+delay://" + name)
+	defer consumerChannel.StopConsuming()
+	expectDelayed(t, consumerChannel, 100*time.Millisecond, time.Second, func() {
+		channels.PublishAt(publisher, nil, "recheck", time.Now().Add(100*time.Millisecond))
+	})
+}
+
+func TestMemDelayQueueTwice(t *testing.T) {
+	name := memName("mem_delay_twice")
+	publisher, _ := channels.MemPublisherFromURI("mem+delay://" + name + "?after=100ms")
+	consumerChannel, _ := channels.MemConsumerFromURI("mem+delay://" + name)
+	defer consumerChannel.StopConsuming()
+	deliveries := make(chan channels.Delivery, 1)
+	consumerChannel.AddConsumer(&deliveryConsumer{deliveries})
+	consumerChannel.StartConsuming()
+	channels.PublishAt(publisher, nil, "recheck", time.Now().Add(50*time.Millisecond))
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		select {
+		case delivery := <-deliveries:
+			if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+				t.Errorf("Delivery %v arrived after %v", i, elapsed)
+			}
+			// Delay it again, as a relay would, keeping its old due time
+			if i == 0 && !channels.Republish(publisher, delivery) {
+				t.Fatalf("Failed to republish")
+			}
+			delivery.Ack()
+		case <-time.After(time.Second):
+			t.Fatalf("Delivery %v did not arrive", i)
+		}
+	}
+}
+
+func TestParseDelay(t *testing.T) {
+	for query, expected := range map[string]time.Duration{
+		"":                       0,
+		"after=5m":               5 * time.Minute,
+		"blocks=2":               30 * time.Second,
+		"blocks=3&blocktime=4s":  12 * time.Second,
+		"after=1s&maxattempts=3": time.Second,
+	} {
+		values, _ := url.ParseQuery(query)
+		delay, err := channels.ParseDelay(values)
+		if err != nil {
+			t.Errorf("Unexpected error parsing '%v': %v", query, err.Error())
+		} else if delay != expected {
+			t.Errorf("Expected %v for '%v', got %v", expected, query, delay)
+		}
+	}
+	for _, query := range []string{"after=5", "blocks=x", "after=1m&blocks=2", "after=-1s"} {
+		values, _ := url.ParseQuery(query)
+		if _, err := channels.ParseDelay(values); err == nil {
+			t.Errorf("Expected an error parsing '%v'", query)
+		}
+	}
+}
+
+func TestRedisDelayQueue(t *testing.T) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		t.Errorf("Please set the REDIS_URL environment variable")
+		return
+	}
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisURL,
+	})
+	publisher, err := channels.PublisherFromURI("delay://test_delay?after=1s", redisClient)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	consumerChannel, err := channels.ConsumerFromURI("delay://test_delay", redisClient)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer consumerChannel.StopConsuming()
+	expectDelayed(t, consumerChannel, time.Second, 3*time.Second, func() {
+		publisher.Publish("recheck")
+	})
+}
@@ -2,7 +2,9 @@ package channels
 
 import (
 	"github.com/notegio/openrelay/common"
+	"log"
 	"sync"
+	"time"
 )
 
 type DelayRelay struct {
@@ -13,9 +15,22 @@ type DelayRelay struct {
 	once            *sync.Once
 }
 
+// publishSentinel publishes the sentinel to the source channel, retrying
+// until it succeeds. If the sentinel were lost, the relay would stop pausing
+// and the next Flush would block forever.
+func publishSentinel(sourcePublisher Publisher, sentinel string) {
+	for delay := 100 * time.Millisecond; !sourcePublisher.Publish(sentinel); delay *= 2 {
+		if delay > 10*time.Second {
+			delay = 10 * time.Second
+		}
+		log.Printf("Failed to publish sentinel. Retrying in %v", delay)
+		time.Sleep(delay)
+	}
+}
+
 func (relay *DelayRelay) Flush() {
 	relay.once.Do(func() {
-		relay.sourcePublisher.Publish(relay.sentinel)
+		publishSentinel(relay.sourcePublisher, relay.sentinel)
 		relay.delayChan <- true
 		relay.once = &sync.Once{}
 	})
@@ -53,6 +68,9 @@ func NewDelayRelay(sourcePublisher Publisher, channel ConsumerChannel, publisher
 			[]Publisher{publisher},
 			&DelayRelayFilter{sentinel, delayChan},
 			make(common.Semaphore, 1), // DelayRelays can't handle concurrency > 1
+			NewInFlight(),
+			nil,
+			nil,
 		},
 		sourcePublisher,
 		sentinel,
@@ -60,6 +78,28 @@ func NewDelayRelay(sourcePublisher Publisher, channel ConsumerChannel, publisher
 		&sync.Once{},
 	}
 	relay.consumerChannel.AddConsumer(&RelayConsumer{relay.Relay})
-	sourcePublisher.Publish(sentinel)
+	publishSentinel(sourcePublisher, sentinel)
 	return relay
 }
+
+// DelayConsumer Flushes the DelayRelay every time it receives a message. If
+// the publisher is specified, it will also pass the message it received to the
+// publisher.
+type DelayConsumer struct {
+	relay     *DelayRelay
+	publisher Publisher
+}
+
+// NewDelayConsumer returns a DelayConsumer that flushes `relay`, passing
+// messages on to `publisher` if it is not nil.
+func NewDelayConsumer(relay *DelayRelay, publisher Publisher) *DelayConsumer {
+	return &DelayConsumer{relay, publisher}
+}
+
+func (consumer *DelayConsumer) Consume(delivery Delivery) {
+	consumer.relay.Flush()
+	if consumer.publisher != nil {
+		Republish(consumer.publisher, delivery)
+	}
+	delivery.Ack()
+}
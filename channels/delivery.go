@@ -2,24 +2,24 @@ package channels
 
 import (
 	"gopkg.in/redis.v3"
+	"log"
+	"strconv"
+	"time"
 )
 
 type Delivery interface {
 	Payload() string
+	Headers() Headers
 	Ack() bool
 	Reject() bool
 	Return() bool
 }
 
 type topicDelivery struct {
-	payload     string
+	envelope
 	redisClient *redis.Client
 }
 
-func (delivery *topicDelivery) Payload() string {
-	return delivery.payload
-}
-
 func (delivery *topicDelivery) Ack() bool {
 	// Topics can't actually be Ack'd, but we want the interface to be the same
 	return true
@@ -36,23 +36,35 @@ func (delivery *topicDelivery) Return() bool {
 }
 
 func newTopicDelivery(payload string, client *redis.Client) *topicDelivery {
-	return &topicDelivery{payload, client}
+	return &topicDelivery{openEnvelope(payload), client}
 }
 
 type queueDelivery struct {
+	envelope
 	payload     string
 	unackedKey  string
 	rejectedKey string
 	sourceKey   string
 	redisClient *redis.Client
 	ackChan     chan bool
+	retry       RetryPolicy
+	attempts    int
 }
 
-func (delivery *queueDelivery) Payload() string {
-	return delivery.payload
+func (delivery *queueDelivery) Attempts() int {
+	return delivery.attempts
+}
+
+// clearAttempts forgets the attempt count for a delivery that has been
+// settled
+func (delivery *queueDelivery) clearAttempts() {
+	if delivery.retry.Enabled() {
+		delivery.redisClient.HDel(delivery.sourceKey+"::attempts", delivery.payload)
+	}
 }
 
 func (delivery *queueDelivery) Ack() bool {
+	delivery.clearAttempts()
 	result := delivery.redisClient.LRem(delivery.unackedKey, 1, delivery.payload)
 	if redisErrIsNil(result) {
 		return false
@@ -62,11 +74,22 @@ func (delivery *queueDelivery) Ack() bool {
 }
 
 func (delivery *queueDelivery) Reject() bool {
+	delivery.clearAttempts()
 	delivery.ackChan <- false
+	recordRejection(delivery.redisClient, delivery.rejectedKey, delivery.payload)
 	return delivery.move(delivery.rejectedKey)
 }
 
 func (delivery *queueDelivery) Return() bool {
+	if delivery.retry.Enabled() {
+		return delivery.retryLater()
+	}
+	return delivery.Release()
+}
+
+// Release puts the delivery back on the ready list to be delivered next,
+// without counting it as a failed attempt.
+func (delivery *queueDelivery) Release() bool {
 	if redisErrIsNil(delivery.redisClient.RPush(delivery.sourceKey, delivery.payload)) {
 		return false
 	}
@@ -79,6 +102,28 @@ func (delivery *queueDelivery) Return() bool {
 	return true
 }
 
+// retryLater records a failed attempt, and either rejects the delivery if it
+// has run out of attempts or schedules it to be redelivered after a backoff.
+func (delivery *queueDelivery) retryLater() bool {
+	if delivery.retry.Exhausted(delivery.attempts) {
+		log.Printf("Rejecting delivery on %v after %v attempts", delivery.sourceKey, delivery.attempts)
+		return delivery.Reject()
+	}
+	delivery.redisClient.HSet(delivery.sourceKey+"::attempts", delivery.payload, strconv.Itoa(delivery.attempts))
+	delay := delivery.retry.Delay(delivery.attempts)
+	if delay == 0 {
+		if redisErrIsNil(delivery.redisClient.RPush(delivery.sourceKey, delivery.payload)) {
+			return false
+		}
+	} else {
+		due := float64(time.Now().Add(delay).UnixNano())
+		if redisErrIsNil(delivery.redisClient.ZAdd(delivery.sourceKey+"::delayed", redis.Z{Score: due, Member: delivery.payload})) {
+			return false
+		}
+	}
+	return !redisErrIsNil(delivery.redisClient.LRem(delivery.unackedKey, 1, delivery.payload))
+}
+
 func (delivery *queueDelivery) move(key string) bool {
 	if redisErrIsNil(delivery.redisClient.LPush(key, delivery.payload)) {
 		return false
@@ -92,6 +137,6 @@ func (delivery *queueDelivery) move(key string) bool {
 	return true
 }
 
-func newQueueDelivery(payload, unackedKey, rejectedKey, sourceKey string, client *redis.Client, ackChan chan bool) *queueDelivery {
-	return &queueDelivery{payload, unackedKey, rejectedKey, sourceKey, client, ackChan}
+func newQueueDelivery(payload, unackedKey, rejectedKey, sourceKey string, client *redis.Client, ackChan chan bool, retry RetryPolicy, attempts int) *queueDelivery {
+	return &queueDelivery{openEnvelope(payload), payload, unackedKey, rejectedKey, sourceKey, client, ackChan, retry, attempts}
 }
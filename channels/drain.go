@@ -0,0 +1,187 @@
+package channels
+
+import (
+	"context"
+	"sync"
+)
+
+// Drainer is implemented by consumers that process deliveries in the
+// background. Drain stops them from taking on new deliveries and waits for
+// the ones in progress to finish until `ctx` is done, at which point any
+// deliveries that are still unsettled are returned. It returns the number of
+// deliveries that had to be returned.
+type Drainer interface {
+	Drain(ctx context.Context) int
+}
+
+// Releaser is implemented by deliveries that can be put back on their queue
+// without counting as a failed attempt, for use when a consumer shuts down
+// before it gets to them.
+type Releaser interface {
+	Release() bool
+}
+
+// ReleaseDelivery puts a delivery back on its queue without counting a failed
+// attempt if it supports that, or Returns it otherwise.
+func ReleaseDelivery(delivery Delivery) bool {
+	if releaser, ok := delivery.(Releaser); ok {
+		return releaser.Release()
+	}
+	return delivery.Return()
+}
+
+// releaseBuffered releases deliveries that were fetched from a queue but
+// never handed to a consumer, once the queue has stopped consuming.
+func releaseBuffered(deliveryChan chan Delivery) int {
+	count := 0
+	for {
+		select {
+		case delivery := <-deliveryChan:
+			ReleaseDelivery(delivery)
+			count++
+		default:
+			return count
+		}
+	}
+}
+
+// InFlight keeps track of the deliveries a consumer is working on, so that
+// on shutdown it can wait for them to finish and return the ones that don't,
+// rather than leaving them stranded on the unacked list.
+type InFlight struct {
+	mu       sync.Mutex
+	pending  map[*inFlightDelivery]struct{}
+	draining bool
+	idle     chan struct{}
+}
+
+// NewInFlight returns an empty InFlight tracker
+func NewInFlight() *InFlight {
+	return &InFlight{pending: make(map[*inFlightDelivery]struct{})}
+}
+
+// Track registers `delivery` as in progress, returning a Delivery to use in
+// its place that stops being tracked once it is settled. If the tracker is
+// draining, the delivery is released back to its channel immediately and
+// Track returns false.
+func (inFlight *InFlight) Track(delivery Delivery) (Delivery, bool) {
+	inFlight.mu.Lock()
+	defer inFlight.mu.Unlock()
+	if inFlight.draining {
+		ReleaseDelivery(delivery)
+		return delivery, false
+	}
+	tracked := &inFlightDelivery{Delivery: delivery, inFlight: inFlight}
+	inFlight.pending[tracked] = struct{}{}
+	return tracked, true
+}
+
+// settle stops tracking `delivery`, returning false if it had already been
+// settled (or returned by Drain).
+func (inFlight *InFlight) settle(delivery *inFlightDelivery) bool {
+	inFlight.mu.Lock()
+	defer inFlight.mu.Unlock()
+	if _, ok := inFlight.pending[delivery]; !ok {
+		return false
+	}
+	delete(inFlight.pending, delivery)
+	if len(inFlight.pending) == 0 && inFlight.idle != nil {
+		close(inFlight.idle)
+		inFlight.idle = nil
+	}
+	return true
+}
+
+// Drain stops accepting new deliveries, then waits until every tracked
+// delivery has been settled or `ctx` is done. Deliveries that are still in
+// progress when `ctx` is done are released back to their channel, and any
+// attempt to settle them afterwards is ignored.
+func (inFlight *InFlight) Drain(ctx context.Context) int {
+	inFlight.mu.Lock()
+	inFlight.draining = true
+	if len(inFlight.pending) == 0 {
+		inFlight.mu.Unlock()
+		return 0
+	}
+	idle := make(chan struct{})
+	inFlight.idle = idle
+	inFlight.mu.Unlock()
+	select {
+	case <-idle:
+		return 0
+	case <-ctx.Done():
+	}
+	inFlight.mu.Lock()
+	remaining := []*inFlightDelivery{}
+	for delivery := range inFlight.pending {
+		remaining = append(remaining, delivery)
+	}
+	inFlight.pending = make(map[*inFlightDelivery]struct{})
+	inFlight.idle = nil
+	inFlight.mu.Unlock()
+	for _, delivery := range remaining {
+		ReleaseDelivery(delivery.Delivery)
+	}
+	return len(remaining)
+}
+
+type inFlightDelivery struct {
+	Delivery
+	inFlight *InFlight
+}
+
+func (delivery *inFlightDelivery) Ack() bool {
+	return delivery.inFlight.settle(delivery) && delivery.Delivery.Ack()
+}
+
+func (delivery *inFlightDelivery) Reject() bool {
+	return delivery.inFlight.settle(delivery) && delivery.Delivery.Reject()
+}
+
+func (delivery *inFlightDelivery) Return() bool {
+	return delivery.inFlight.settle(delivery) && delivery.Delivery.Return()
+}
+
+func (delivery *inFlightDelivery) Release() bool {
+	return delivery.inFlight.settle(delivery) && ReleaseDelivery(delivery.Delivery)
+}
+
+func (delivery *inFlightDelivery) Attempts() int {
+	return DeliveryAttempts(delivery.Delivery)
+}
+
+// Shutdown stops `consumerChannel` from fetching new deliveries, then drains
+// each of `drainers`, returning the total number of deliveries that were
+// returned because they didn't finish before `ctx` was done.
+func Shutdown(ctx context.Context, consumerChannel ConsumerChannel, drainers ...Drainer) int {
+	consumerChannel.StopConsuming()
+	returned := 0
+	for _, drainer := range drainers {
+		returned += drainer.Drain(ctx)
+	}
+	return returned
+}
+
+// DrainingConsumer wraps a Consumer, tracking the deliveries it is working on
+// so that they can be drained on shutdown. This works for consumers that
+// process deliveries synchronously and for those that hand them off to
+// goroutines, since deliveries are only untracked once they are settled.
+type DrainingConsumer struct {
+	consumer Consumer
+	inFlight *InFlight
+}
+
+// NewDrainingConsumer wraps `consumer` so that it can be drained
+func NewDrainingConsumer(consumer Consumer) *DrainingConsumer {
+	return &DrainingConsumer{consumer, NewInFlight()}
+}
+
+func (consumer *DrainingConsumer) Consume(delivery Delivery) {
+	if tracked, ok := consumer.inFlight.Track(delivery); ok {
+		consumer.consumer.Consume(tracked)
+	}
+}
+
+func (consumer *DrainingConsumer) Drain(ctx context.Context) int {
+	return consumer.inFlight.Drain(ctx)
+}
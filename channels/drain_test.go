@@ -0,0 +1,95 @@
+package channels_test
+
+import (
+	"context"
+	"github.com/notegio/openrelay/channels"
+	"testing"
+	"time"
+)
+
+func TestDrainWaitsForInFlight(t *testing.T) {
+	name := memName("drain_wait")
+	consumerChannel := channels.NewMemQueueConsumerChannel(name)
+	deliveries := make(chan channels.Delivery)
+	drainingConsumer := channels.NewDrainingConsumer(&deliveryConsumer{deliveries})
+	consumerChannel.AddConsumer(drainingConsumer)
+	consumerChannel.StartConsuming()
+	channels.NewMemQueuePublisher(name).Publish("a")
+	delivery := <-deliveries
+	returned := make(chan int)
+	go func() {
+		returned <- channels.Shutdown(context.Background(), consumerChannel, drainingConsumer)
+	}()
+	select {
+	case <-returned:
+		t.Fatalf("Shutdown should wait for the delivery to be settled")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if !delivery.Ack() {
+		t.Errorf("Ack should succeed while draining")
+	}
+	if count := <-returned; count != 0 {
+		t.Errorf("Expected no returned deliveries, got %v", count)
+	}
+	counts, _ := consumerChannel.(channels.Inspector).Counts()
+	if counts[channels.ReadyList] != 0 || counts[channels.UnackedList] != 0 {
+		t.Errorf("Unexpected counts %v", counts)
+	}
+}
+
+func TestDrainTimeoutReleases(t *testing.T) {
+	name := memName("drain_timeout")
+	policy := channels.RetryPolicy{MaxAttempts: 5, Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	consumerChannel := channels.NewMemRetryQueueConsumerChannel(name, policy)
+	deliveries := make(chan channels.Delivery)
+	drainingConsumer := channels.NewDrainingConsumer(&deliveryConsumer{deliveries})
+	consumerChannel.AddConsumer(drainingConsumer)
+	consumerChannel.StartConsuming()
+	channels.NewMemQueuePublisher(name).Publish("a")
+	delivery := <-deliveries
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if count := channels.Shutdown(ctx, consumerChannel, drainingConsumer); count != 1 {
+		t.Errorf("Expected 1 returned delivery, got %v", count)
+	}
+	if delivery.Ack() {
+		t.Errorf("Acking a delivery after it has been released should fail")
+	}
+	counts, _ := consumerChannel.(channels.Inspector).Counts()
+	if counts[channels.ReadyList] != 1 || counts[channels.UnackedList] != 0 {
+		t.Errorf("Unexpected counts %v", counts)
+	}
+	// A fresh consumer should get the message back without a failed attempt
+	// having been counted against it.
+	consumerChannel = channels.NewMemRetryQueueConsumerChannel(name, policy)
+	defer consumerChannel.StopConsuming()
+	consumerChannel.AddConsumer(&deliveryConsumer{deliveries})
+	consumerChannel.StartConsuming()
+	delivery = <-deliveries
+	if attempts := channels.DeliveryAttempts(delivery); attempts != 1 {
+		t.Errorf("Expected attempt 1, got %v", attempts)
+	}
+	delivery.Ack()
+}
+
+func TestInFlightRefusesWhileDraining(t *testing.T) {
+	name := memName("drain_refuse")
+	consumerChannel := channels.NewMemQueueConsumerChannel(name)
+	defer consumerChannel.StopConsuming()
+	deliveries := make(chan channels.Delivery)
+	consumerChannel.AddConsumer(&deliveryConsumer{deliveries})
+	consumerChannel.StartConsuming()
+	channels.NewMemQueuePublisher(name).Publish("a")
+	delivery := <-deliveries
+	inFlight := channels.NewInFlight()
+	inFlight.Drain(context.Background())
+	if _, ok := inFlight.Track(delivery); ok {
+		t.Errorf("Track should refuse deliveries while draining")
+	}
+	// The refused delivery goes back on the queue for someone else
+	delivery = <-deliveries
+	if delivery.Payload() != "a" {
+		t.Errorf("Unexpected value '%v'", delivery.Payload())
+	}
+	delivery.Ack()
+}
@@ -0,0 +1,103 @@
+package channels
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"github.com/pborman/uuid"
+	"strings"
+	"time"
+)
+
+// Headers carry metadata about a message, such as where and when it
+// originated, alongside its payload.
+type Headers map[string]string
+
+// Well known header names
+const (
+	HeaderTimestamp     = "timestamp"
+	HeaderOrigin        = "origin"
+	HeaderSchemaVersion = "schema-version"
+	HeaderCorrelationID = "correlation-id"
+	HeaderSequence      = "sequence"
+	HeaderDue           = "due"
+)
+
+// envelopePrefix marks a payload as an envelope. Legacy payloads are encoded
+// orders (versioned or RLP) or JSON objects, none of which can start with a
+// null byte, so envelopes and legacy payloads can safely share a channel.
+const envelopePrefix = "\x00ORE1"
+
+// NewHeaders returns Headers for a new message originating from `origin`,
+// with a timestamp and a fresh correlation ID.
+func NewHeaders(origin string) Headers {
+	return Headers{
+		HeaderTimestamp:     time.Now().UTC().Format(time.RFC3339Nano),
+		HeaderOrigin:        origin,
+		HeaderCorrelationID: uuid.New(),
+	}
+}
+
+// Wrap encodes headers and a body into a single payload. If there are no
+// headers, the body is returned unchanged so that consumers that don't
+// understand envelopes can still read it.
+func Wrap(headers Headers, body string) string {
+	if len(headers) == 0 {
+		return body
+	}
+	headerBytes, err := json.Marshal(headers)
+	if err != nil {
+		return body
+	}
+	lengthBytes := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(lengthBytes, uint64(len(headerBytes)))
+	return envelopePrefix + string(lengthBytes[:n]) + string(headerBytes) + body
+}
+
+// Unwrap splits a payload into its headers and body. Payloads that are not
+// envelopes are returned as the body, with nil headers.
+func Unwrap(payload string) (Headers, string) {
+	if !strings.HasPrefix(payload, envelopePrefix) {
+		return nil, payload
+	}
+	rest := payload[len(envelopePrefix):]
+	length, n := binary.Uvarint([]byte(rest))
+	if n <= 0 || uint64(len(rest)-n) < length {
+		return nil, payload
+	}
+	headers := Headers{}
+	if err := json.Unmarshal([]byte(rest[n:n+int(length)]), &headers); err != nil {
+		return nil, payload
+	}
+	return headers, rest[n+int(length):]
+}
+
+// PublishWithHeaders publishes `body` wrapped in an envelope with `headers`
+func PublishWithHeaders(publisher Publisher, headers Headers, body string) bool {
+	return publisher.Publish(Wrap(headers, body))
+}
+
+// Republish passes a delivery on to another publisher, preserving its
+// headers.
+func Republish(publisher Publisher, delivery Delivery) bool {
+	return publisher.Publish(Wrap(delivery.Headers(), delivery.Payload()))
+}
+
+// envelope provides the Payload and Headers methods of a Delivery from a raw
+// payload, so deliveries can keep the raw payload around for acking.
+type envelope struct {
+	headers Headers
+	body    string
+}
+
+func openEnvelope(payload string) envelope {
+	headers, body := Unwrap(payload)
+	return envelope{headers, body}
+}
+
+func (env envelope) Payload() string {
+	return env.body
+}
+
+func (env envelope) Headers() Headers {
+	return env.headers
+}
@@ -0,0 +1,62 @@
+package channels_test
+
+import (
+	"github.com/notegio/openrelay/channels"
+	"testing"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	headers := channels.NewHeaders("test")
+	headers[channels.HeaderSchemaVersion] = "1"
+	decodedHeaders, body := channels.Unwrap(channels.Wrap(headers, "{\"bloom\": \"0x00\"}"))
+	if body != "{\"bloom\": \"0x00\"}" {
+		t.Errorf("Unexpected body: %v", body)
+	}
+	for key, value := range headers {
+		if decodedHeaders[key] != value {
+			t.Errorf("Header %v: expected '%v', got '%v'", key, value, decodedHeaders[key])
+		}
+	}
+	if decodedHeaders[channels.HeaderCorrelationID] == "" {
+		t.Errorf("Expected a correlation ID")
+	}
+}
+
+func TestEnvelopeLegacyPayload(t *testing.T) {
+	for _, payload := range []string{"", "test", "\xf9\x01\x00", "{\"orderHash\": \"0x00\"}", "\x00ORE1\xff"} {
+		headers, body := channels.Unwrap(payload)
+		if headers != nil {
+			t.Errorf("Expected no headers for %#x", payload)
+		}
+		if body != payload {
+			t.Errorf("Expected %#x, got %#x", payload, body)
+		}
+	}
+	if payload := channels.Wrap(nil, "test"); payload != "test" {
+		t.Errorf("Expected bare payload without headers, got %#x", payload)
+	}
+}
+
+func TestRelayPreservesHeaders(t *testing.T) {
+	sourceName := memName("envelope_source")
+	destName := memName("envelope_dest")
+	sourceChannel := channels.NewMemQueueConsumerChannel(sourceName)
+	destChannel := channels.NewMemQueueConsumerChannel(destName)
+	defer destChannel.StopConsuming()
+	deliveries := make(chan channels.Delivery)
+	destChannel.AddConsumer(&deliveryConsumer{deliveries})
+	destChannel.StartConsuming()
+	relay := channels.NewRelay(sourceChannel, []channels.Publisher{channels.NewMemQueuePublisher(destName)}, &channels.IncludeAll{}, 1)
+	relay.Start()
+	defer relay.Stop()
+	headers := channels.NewHeaders("test")
+	channels.PublishWithHeaders(channels.NewMemQueuePublisher(sourceName), headers, "test")
+	delivery := <-deliveries
+	delivery.Ack()
+	if delivery.Payload() != "test" {
+		t.Errorf("Unexpected payload: %v", delivery.Payload())
+	}
+	if delivery.Headers()[channels.HeaderCorrelationID] != headers[channels.HeaderCorrelationID] {
+		t.Errorf("Correlation ID was not preserved")
+	}
+}
@@ -0,0 +1,161 @@
+package channels
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryOptions configures a replayable topic. Publishers to a replayable
+// topic number each message with a sequence and keep the most recent
+// messages, so that a consumer that loses its subscription can catch up on
+// what it missed rather than silently dropping it.
+type HistoryOptions struct {
+	// Length is the number of messages to keep. 0 keeps messages regardless of
+	// how many there are.
+	Length int64
+	// Age is how long to keep messages. 0 keeps messages regardless of their
+	// age.
+	Age time.Duration
+}
+
+// Enabled returns true if the topic keeps a history at all
+func (options HistoryOptions) Enabled() bool {
+	return options.Length > 0 || options.Age > 0
+}
+
+// ParseHistoryOptions reads the history options of a topic URI, eg.
+//
+//    topic://name?history=1000&historyage=10m
+//
+// Topics with neither option keep no history.
+func ParseHistoryOptions(query url.Values) (HistoryOptions, error) {
+	options := HistoryOptions{}
+	var err error
+	if length := query.Get("history"); length != "" {
+		if options.Length, err = strconv.ParseInt(length, 10, 64); err != nil {
+			return options, err
+		}
+	}
+	if age := query.Get("historyage"); age != "" {
+		if options.Age, err = time.ParseDuration(age); err != nil {
+			return options, err
+		}
+	}
+	if options.Length < 0 || options.Age < 0 {
+		return options, errors.New("Topic history must not be negative")
+	}
+	return options, nil
+}
+
+// HistoryTruncatedError is returned by TopicHistory.Since when some of the
+// messages after the requested sequence have already been trimmed from the
+// history, so the messages returned with it don't cover everything that was
+// missed. A consumer that gets one has to resynchronize some other way.
+type HistoryTruncatedError struct {
+	Topic string
+	// After is the sequence messages were requested after
+	After int64
+	// Oldest is the sequence of the oldest message still available
+	Oldest int64
+}
+
+func (err *HistoryTruncatedError) Error() string {
+	return fmt.Sprintf("History of %v is truncated: messages %v to %v are no longer available", err.Topic, err.After+1, err.Oldest-1)
+}
+
+// checkTruncated returns a *HistoryTruncatedError if messages after `after`
+// are missing from a history. `first` is the sequence of the first message
+// after `after` still in the history, or 0 if there is none, and `latest` is
+// the last sequence assigned before the history was read.
+func checkTruncated(topic string, after, first, latest int64) error {
+	if first == 0 && latest > after {
+		return &HistoryTruncatedError{topic, after, latest + 1}
+	}
+	if first > after+1 {
+		return &HistoryTruncatedError{topic, after, first}
+	}
+	return nil
+}
+
+// TopicHistory is implemented by consumer channels for replayable topics.
+type TopicHistory interface {
+	// Since returns the messages still in the topic's history with a sequence
+	// greater than `sequence`, oldest first. If some of those messages have
+	// already been trimmed from the history, it returns the rest along with
+	// a *HistoryTruncatedError.
+	Since(sequence int64) ([]Delivery, error)
+	// ResumeFrom makes the next StartConsuming replay every message after
+	// `sequence` before delivering new ones. It must be called before
+	// StartConsuming.
+	ResumeFrom(sequence int64)
+	// HistoryEnabled returns false if the topic was configured without a
+	// history, in which case Since always fails.
+	HistoryEnabled() bool
+}
+
+// Sequence returns the sequence number a replayable topic assigned to
+// `delivery`. The second return value is false if the delivery has no
+// sequence, because it did not come from a replayable topic.
+func Sequence(delivery Delivery) (int64, bool) {
+	value, ok := delivery.Headers()[HeaderSequence]
+	if !ok {
+		return 0, false
+	}
+	sequence, err := strconv.ParseInt(value, 10, 64)
+	return sequence, err == nil
+}
+
+// withSequence stamps `payload` with `sequence`, keeping any headers it
+// already has.
+func withSequence(payload string, sequence int64) string {
+	headers, body := Unwrap(payload)
+	stamped := Headers{}
+	for key, value := range headers {
+		stamped[key] = value
+	}
+	stamped[HeaderSequence] = strconv.FormatInt(sequence, 10)
+	return Wrap(stamped, body)
+}
+
+// sequencePlaceholder stands in for the sequence in sequenceTemplate
+const sequencePlaceholder = "${sequence}"
+
+// sequenceTemplate returns the JSON headers withSequence would give
+// `payload`, split around the quoted sequence value, along with the body.
+// This lets Redis fill in a sequence it assigns itself.
+func sequenceTemplate(payload string) (string, string, string) {
+	headers, body := Unwrap(payload)
+	stamped := Headers{}
+	for key, value := range headers {
+		stamped[key] = value
+	}
+	stamped[HeaderSequence] = sequencePlaceholder
+	headerBytes, _ := json.Marshal(stamped)
+	parts := strings.SplitN(string(headerBytes), `"`+sequencePlaceholder+`"`, 2)
+	return parts[0], parts[1], body
+}
+
+// historyEntry prefixes a payload with the time it was published, so that
+// entries can be trimmed by age without a second index.
+func historyEntry(published time.Time, payload string) string {
+	return strconv.FormatInt(published.UnixNano(), 10) + " " + payload
+}
+
+// parseHistoryEntry splits an entry made by historyEntry into its publish
+// time and payload.
+func parseHistoryEntry(entry string) (time.Time, string) {
+	parts := strings.SplitN(entry, " ", 2)
+	if len(parts) != 2 {
+		return time.Time{}, entry
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, entry
+	}
+	return time.Unix(0, nanos), parts[1]
+}
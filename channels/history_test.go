@@ -0,0 +1,241 @@
+package channels_test
+
+import (
+	"github.com/notegio/openrelay/channels"
+	"gopkg.in/redis.v3"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sequenceConsumer struct {
+	sequences chan int64
+}
+
+func (consumer *sequenceConsumer) Consume(delivery channels.Delivery) {
+	sequence, _ := channels.Sequence(delivery)
+	consumer.sequences <- sequence
+}
+
+// expectSequences waits for `count` deliveries and checks that they have the
+// expected sequences. Topic deliveries are consumed concurrently, so they may
+// arrive in any order.
+func expectSequences(t *testing.T, sequences chan int64, expected ...int64) {
+	received := []int64{}
+	for range expected {
+		select {
+		case sequence := <-sequences:
+			received = append(received, sequence)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected sequences %v, only got %v", expected, received)
+		}
+	}
+	sort.Slice(received, func(i, j int) bool { return received[i] < received[j] })
+	for i := range expected {
+		if received[i] != expected[i] {
+			t.Fatalf("Expected sequences %v, got %v", expected, received)
+		}
+	}
+	select {
+	case sequence := <-sequences:
+		t.Errorf("Unexpected extra sequence %v", sequence)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func testTopicResume(t *testing.T, publisher channels.Publisher, consumerChannel channels.ConsumerChannel, delay time.Duration) {
+	consumer := &sequenceConsumer{make(chan int64, 10)}
+	consumerChannel.AddConsumer(consumer)
+	consumerChannel.StartConsuming()
+	time.Sleep(delay)
+	publisher.Publish("1")
+	expectSequences(t, consumer.sequences, 1)
+	history, ok := consumerChannel.(channels.TopicHistory)
+	if !ok {
+		t.Fatalf("Expected consumer channel to implement TopicHistory")
+	}
+	publisher.Publish("2")
+	publisher.Publish("3")
+	publisher.Publish("4")
+	expectSequences(t, consumer.sequences, 2, 3, 4)
+	deliveries, err := history.Since(2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(deliveries) != 2 {
+		t.Fatalf("Expected 2 deliveries, got %v", len(deliveries))
+	}
+	if deliveries[0].Payload() != "3" || deliveries[1].Payload() != "4" {
+		t.Errorf("Unexpected payloads '%v', '%v'", deliveries[0].Payload(), deliveries[1].Payload())
+	}
+	if sequence, _ := channels.Sequence(deliveries[1]); sequence != 4 {
+		t.Errorf("Expected sequence 4, got %v", sequence)
+	}
+	// The history only holds 3 messages, so the first has been dropped
+	deliveries, err = history.Since(0)
+	if truncated, ok := err.(*channels.HistoryTruncatedError); !ok || truncated.Oldest != 2 {
+		t.Errorf("Expected the history to be truncated before sequence 2, got %v", err)
+	}
+	if len(deliveries) != 3 {
+		t.Errorf("Expected 3 deliveries, got %v", len(deliveries))
+	}
+	if _, err := history.Since(1); err != nil {
+		t.Errorf("Unexpected error reading history since 1: %v", err.Error())
+	}
+	if _, err := history.Since(4); err != nil {
+		t.Errorf("Unexpected error reading history since 4: %v", err.Error())
+	}
+}
+
+func TestMemTopicHistory(t *testing.T) {
+	uri := "mem+topic://" + memName("mem_topic_history") + "?history=3"
+	publisher, err := channels.MemPublisherFromURI(uri)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	consumerChannel, err := channels.MemConsumerFromURI(uri)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer consumerChannel.StopConsuming()
+	testTopicResume(t, publisher, consumerChannel, 0)
+}
+
+func TestMemTopicResumeAfterStop(t *testing.T) {
+	uri := "mem+topic://" + memName("mem_topic_resume") + "?history=10"
+	publisher, _ := channels.MemPublisherFromURI(uri)
+	consumerChannel, _ := channels.MemConsumerFromURI(uri)
+	consumer := &sequenceConsumer{make(chan int64, 10)}
+	consumerChannel.AddConsumer(consumer)
+	consumerChannel.StartConsuming()
+	publisher.Publish("1")
+	expectSequences(t, consumer.sequences, 1)
+	consumerChannel.StopConsuming()
+	publisher.Publish("2")
+	publisher.Publish("3")
+	consumerChannel.StartConsuming()
+	defer consumerChannel.StopConsuming()
+	expectSequences(t, consumer.sequences, 2, 3)
+	publisher.Publish("4")
+	expectSequences(t, consumer.sequences, 4)
+}
+
+func TestMemTopicResumeFrom(t *testing.T) {
+	uri := "mem+topic://" + memName("mem_topic_resume_from") + "?historyage=1m"
+	publisher, _ := channels.MemPublisherFromURI(uri)
+	for i := 0; i < 3; i++ {
+		publisher.Publish("x")
+	}
+	consumerChannel, _ := channels.MemConsumerFromURI(uri)
+	consumerChannel.(channels.TopicHistory).ResumeFrom(1)
+	consumer := &sequenceConsumer{make(chan int64, 10)}
+	consumerChannel.AddConsumer(consumer)
+	consumerChannel.StartConsuming()
+	defer consumerChannel.StopConsuming()
+	expectSequences(t, consumer.sequences, 2, 3)
+}
+
+func TestMemTopicResumeTruncated(t *testing.T) {
+	uri := "mem+topic://" + memName("mem_topic_resume_truncated") + "?history=1"
+	publisher, _ := channels.MemPublisherFromURI(uri)
+	for i := 0; i < 3; i++ {
+		publisher.Publish("x")
+	}
+	consumerChannel, _ := channels.MemConsumerFromURI(uri)
+	history := consumerChannel.(channels.TopicHistory)
+	deliveries, err := history.Since(1)
+	if truncated, ok := err.(*channels.HistoryTruncatedError); !ok || truncated.After != 1 || truncated.Oldest != 3 {
+		t.Errorf("Expected the history to be truncated, got %v", err)
+	}
+	if len(deliveries) != 1 {
+		t.Errorf("Expected 1 delivery, got %v", len(deliveries))
+	}
+	// Consumers still get what's left
+	history.ResumeFrom(1)
+	consumer := &sequenceConsumer{make(chan int64, 10)}
+	consumerChannel.AddConsumer(consumer)
+	consumerChannel.StartConsuming()
+	defer consumerChannel.StopConsuming()
+	expectSequences(t, consumer.sequences, 3)
+}
+
+func TestMemTopicWithoutHistory(t *testing.T) {
+	consumerChannel, _ := channels.MemConsumerFromURI("mem+topic://" + memName("mem_topic_no_history"))
+	if _, err := consumerChannel.(channels.TopicHistory).Since(0); err == nil {
+		t.Errorf("Expected an error reading history from a topic without one")
+	}
+}
+
+func TestParseHistoryOptions(t *testing.T) {
+	query, _ := url.ParseQuery("history=100&historyage=5m")
+	options, err := channels.ParseHistoryOptions(query)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if options.Length != 100 || options.Age != 5*time.Minute {
+		t.Errorf("Unexpected options %#v", options)
+	}
+	for _, invalid := range []string{"history=x", "historyage=5", "history=-1"} {
+		query, _ := url.ParseQuery(invalid)
+		if _, err := channels.ParseHistoryOptions(query); err == nil {
+			t.Errorf("Expected an error parsing '%v'", invalid)
+		}
+	}
+}
+
+func TestRedisTopicHistory(t *testing.T) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		t.Errorf("Please set the REDIS_URL environment variable")
+		return
+	}
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisURL,
+	})
+	redisClient.Del("test_history_topic::sequence", "test_history_topic::history")
+	history := channels.HistoryOptions{Length: 3}
+	publisher := channels.NewReplayableTopicPublisher("test_history_topic", redisClient, history)
+	consumerChannel := channels.NewReplayableTopicConsumerChannel("test_history_topic", redisClient, history)
+	defer consumerChannel.StopConsuming()
+	testTopicResume(t, publisher, consumerChannel, 1*time.Second)
+}
+
+func TestRedisTopicHistoryHeaders(t *testing.T) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		t.Errorf("Please set the REDIS_URL environment variable")
+		return
+	}
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisURL,
+	})
+	redisClient.Del("test_history_headers::sequence", "test_history_headers::history")
+	history := channels.HistoryOptions{Length: 10}
+	publisher := channels.NewReplayableTopicPublisher("test_history_headers", redisClient, history)
+	consumerChannel := channels.NewReplayableTopicConsumerChannel("test_history_headers", redisClient, history)
+	// Long enough that the envelope's header length takes two varint bytes
+	origin := strings.Repeat("x", 200)
+	for i := 0; i < 2; i++ {
+		if !channels.PublishWithHeaders(publisher, channels.Headers{channels.HeaderOrigin: origin}, "body") {
+			t.Fatalf("Failed to publish")
+		}
+	}
+	deliveries, err := consumerChannel.(channels.TopicHistory).Since(0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(deliveries) != 2 {
+		t.Fatalf("Expected 2 deliveries, got %v", len(deliveries))
+	}
+	for i, delivery := range deliveries {
+		if sequence, _ := channels.Sequence(delivery); sequence != int64(i+1) {
+			t.Errorf("Expected sequence %v, got %v", i+1, sequence)
+		}
+		if delivery.Headers()[channels.HeaderOrigin] != origin || delivery.Payload() != "body" {
+			t.Errorf("Unexpected delivery %v '%v'", delivery.Headers(), delivery.Payload())
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package channels
+
+import (
+	"fmt"
+	"gopkg.in/redis.v3"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Names of the lists that make up a queue, for use with Inspector.Peek
+const (
+	ReadyList    = "ready"
+	UnackedList  = "unacked"
+	RejectedList = "rejected"
+)
+
+// Inspector provides administrative access to the messages held by a queue,
+// so that poison messages can be examined and dealt with without touching
+// the underlying store directly.
+type Inspector interface {
+	// Counts returns the number of messages on each of the ready, unacked
+	// and rejected lists.
+	Counts() (map[string]int64, error)
+	// Peek returns up to `count` messages from the named list without
+	// removing them.
+	Peek(list string, count int64) ([]string, error)
+	// RequeueRejected moves every rejected message for which `match` returns
+	// true back to the ready list, returning the number of messages moved.
+	RequeueRejected(match func(payload string) bool) (int, error)
+	// PurgeRejectedBefore removes messages that were rejected before `cutoff`,
+	// returning the number of messages removed. Messages rejected before
+	// rejection times were recorded are left alone.
+	PurgeRejectedBefore(cutoff time.Time) (int, error)
+}
+
+// Rehomer is implemented by channels that track which consumer owns each
+// unacked delivery. Rehome returns the deliveries owned by `consumer` to the
+// ready list, so that a consumer that has died doesn't hold on to them.
+type Rehomer interface {
+	Rehome(consumer string) (int, error)
+}
+
+// ListQueues returns the URIs of every Redis queue and stream that has
+// unacked or rejected messages. Streams keep their rejected messages under
+// the same key suffix as queues, so each one is identified by the type of
+// its key.
+func ListQueues(redisClient *redis.Client) ([]string, error) {
+	names := make(map[string]struct{})
+	for _, suffix := range []string{"::unacked", "::rejected"} {
+		var cursor int64
+		for {
+			var keys []string
+			var err error
+			cursor, keys, err = redisClient.Scan(cursor, "*"+suffix, purgeBatchSize).Result()
+			if err != nil {
+				return nil, err
+			}
+			for _, key := range keys {
+				names[strings.TrimSuffix(key, suffix)] = struct{}{}
+			}
+			if cursor == 0 {
+				break
+			}
+		}
+	}
+	queues := []string{}
+	for name := range names {
+		keyType, err := redisClient.Type(name).Result()
+		if err != nil {
+			return nil, err
+		}
+		if keyType == "stream" {
+			queues = append(queues, "stream://"+name)
+		} else {
+			queues = append(queues, "queue://"+name)
+		}
+	}
+	sort.Strings(queues)
+	return queues, nil
+}
+
+func rejectedAtKey(rejectedKey string) string {
+	return rejectedKey + "::at"
+}
+
+// recordRejection notes when a payload was rejected, so that rejected
+// messages can later be purged by age. Each rejection gets its own entry,
+// prefixed with the time as in historyEntry, so that copies of a payload
+// rejected at different times keep their own times.
+func recordRejection(redisClient *redis.Client, rejectedKey, payload string) {
+	now := time.Now()
+	redisClient.ZAdd(rejectedAtKey(rejectedKey), redis.Z{Score: float64(now.Unix()), Member: historyEntry(now, payload)})
+}
+
+// rejectionEntries returns the entries recordRejection made for each payload,
+// newest first.
+func rejectionEntries(redisClient *redis.Client, rejectedKey string) (map[string][]string, error) {
+	entries, err := redisClient.ZRevRange(rejectedAtKey(rejectedKey), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	byPayload := make(map[string][]string)
+	for _, entry := range entries {
+		_, payload := parseHistoryEntry(entry)
+		byPayload[payload] = append(byPayload[payload], entry)
+	}
+	return byPayload, nil
+}
+
+// requeueRejected moves matching payloads from the rejected list at
+// rejectedKey, using `push` to return them to the ready list.
+func requeueRejected(redisClient *redis.Client, rejectedKey string, push func(string) bool, match func(string) bool) (int, error) {
+	payloads, err := redisClient.LRange(rejectedKey, 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+	rejections, err := rejectionEntries(redisClient, rejectedKey)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, payload := range payloads {
+		if !match(payload) {
+			continue
+		}
+		if !push(payload) {
+			return count, fmt.Errorf("Failed to requeue message from %v", rejectedKey)
+		}
+		// The list is newest first, so this removes the newest copy, along
+		// with the newest rejection entry for it.
+		if err := redisClient.LRem(rejectedKey, 1, payload).Err(); err != nil {
+			return count, err
+		}
+		if entries := rejections[payload]; len(entries) > 0 {
+			redisClient.ZRem(rejectedAtKey(rejectedKey), entries[0])
+			rejections[payload] = entries[1:]
+		}
+		count++
+	}
+	return count, nil
+}
+
+func purgeRejectedBefore(redisClient *redis.Client, rejectedKey string, cutoff time.Time) (int, error) {
+	atKey := rejectedAtKey(rejectedKey)
+	max := fmt.Sprintf("(%v", cutoff.Unix())
+	entries, err := redisClient.ZRangeByScore(atKey, redis.ZRangeByScore{Min: "-inf", Max: max}).Result()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, entry := range entries {
+		// Only remove one copy per rejection, starting from the oldest end of
+		// the list, so that copies of the payload rejected since the cutoff are
+		// kept.
+		_, payload := parseHistoryEntry(entry)
+		removed, err := redisClient.LRem(rejectedKey, -1, payload).Result()
+		if err != nil {
+			return count, err
+		}
+		count += int(removed)
+		if err := redisClient.ZRem(atKey, entry).Err(); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+func (queue *queueConsumerChannel) Counts() (map[string]int64, error) {
+	counts := make(map[string]int64)
+	for list, key := range map[string]string{
+		ReadyList:    queue.readyKey,
+		UnackedList:  queue.unackedKey,
+		RejectedList: queue.rejectedKey,
+	} {
+		count, err := queue.redisClient.LLen(key).Result()
+		if err != nil {
+			return nil, err
+		}
+		counts[list] = count
+	}
+	return counts, nil
+}
+
+func (queue *queueConsumerChannel) Peek(list string, count int64) ([]string, error) {
+	switch list {
+	case ReadyList:
+		// Messages are consumed from the right hand side of the ready list, so
+		// peek from there to show them in the order they'll be delivered.
+		payloads, err := queue.redisClient.LRange(queue.readyKey, -count, -1).Result()
+		for i, j := 0, len(payloads)-1; i < j; i, j = i+1, j-1 {
+			payloads[i], payloads[j] = payloads[j], payloads[i]
+		}
+		return payloads, err
+	case UnackedList:
+		return queue.redisClient.LRange(queue.unackedKey, 0, count-1).Result()
+	case RejectedList:
+		return queue.redisClient.LRange(queue.rejectedKey, 0, count-1).Result()
+	default:
+		return nil, fmt.Errorf("Unknown list '%v'", list)
+	}
+}
+
+func (queue *queueConsumerChannel) RequeueRejected(match func(string) bool) (int, error) {
+	return requeueRejected(queue.redisClient, queue.rejectedKey, func(payload string) bool {
+		return redisSucceeded(queue.redisClient.RPush(queue.readyKey, payload))
+	}, match)
+}
+
+func (queue *queueConsumerChannel) PurgeRejectedBefore(cutoff time.Time) (int, error) {
+	return purgeRejectedBefore(queue.redisClient, queue.rejectedKey, cutoff)
+}
+
+func (stream *streamConsumerChannel) Counts() (map[string]int64, error) {
+	counts := make(map[string]int64)
+	length, err := stream.redisClient.LLen(stream.rejectedKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	counts[RejectedList] = length
+	// XPENDING's summary form returns [count, lowest id, highest id, consumers]
+	cmd := redis.NewCmd("XPENDING", stream.streamKey, stream.options.Group)
+	stream.redisClient.Process(cmd)
+	if err := cmd.Err(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	if summary, ok := cmd.Val().([]interface{}); ok && len(summary) > 0 {
+		counts[UnackedList], _ = summary[0].(int64)
+	}
+	// XINFO GROUPS reports how many entries each group has yet to read where
+	// supported, but for compatibility we report the length of the stream,
+	// which is an upper bound on the messages that are ready.
+	cmd = redis.NewCmd("XLEN", stream.streamKey)
+	stream.redisClient.Process(cmd)
+	if err := cmd.Err(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	counts[ReadyList], _ = cmd.Val().(int64)
+	return counts, nil
+}
+
+func (stream *streamConsumerChannel) Peek(list string, count int64) ([]string, error) {
+	switch list {
+	case ReadyList:
+		cmd := redis.NewCmd("XRANGE", stream.streamKey, "-", "+", "COUNT", count)
+		stream.redisClient.Process(cmd)
+		if err := cmd.Err(); err != nil && err != redis.Nil {
+			return nil, err
+		}
+		payloads := []string{}
+		for _, message := range parseStreamMessages(cmd.Val()) {
+			payloads = append(payloads, message.payload)
+		}
+		return payloads, nil
+	case UnackedList:
+		ids, err := stream.pending("", int(count), 0)
+		if err != nil {
+			return nil, err
+		}
+		payloads := []string{}
+		for _, id := range ids {
+			cmd := redis.NewCmd("XRANGE", stream.streamKey, id, id)
+			stream.redisClient.Process(cmd)
+			for _, message := range parseStreamMessages(cmd.Val()) {
+				payloads = append(payloads, message.payload)
+			}
+		}
+		return payloads, nil
+	case RejectedList:
+		return stream.redisClient.LRange(stream.rejectedKey, 0, count-1).Result()
+	default:
+		return nil, fmt.Errorf("Unknown list '%v'", list)
+	}
+}
+
+func (stream *streamConsumerChannel) RequeueRejected(match func(string) bool) (int, error) {
+	return requeueRejected(stream.redisClient, stream.rejectedKey, func(payload string) bool {
+		return redisSucceeded(streamAdd(stream.redisClient, stream.streamKey, stream.options.MaxLen, payload))
+	}, match)
+}
+
+func (stream *streamConsumerChannel) PurgeRejectedBefore(cutoff time.Time) (int, error) {
+	return purgeRejectedBefore(stream.redisClient, stream.rejectedKey, cutoff)
+}
+
+func (stream *streamConsumerChannel) Rehome(consumer string) (int, error) {
+	return stream.returnPending(consumer)
+}
+
+func (channel *memQueueConsumerChannel) Counts() (map[string]int64, error) {
+	channel.queue.mu.Lock()
+	defer channel.queue.mu.Unlock()
+	return map[string]int64{
+		ReadyList:    int64(len(channel.queue.ready)),
+		UnackedList:  int64(len(channel.queue.unacked)),
+		RejectedList: int64(len(channel.queue.rejected)),
+	}, nil
+}
+
+func (channel *memQueueConsumerChannel) Peek(list string, count int64) ([]string, error) {
+	channel.queue.mu.Lock()
+	defer channel.queue.mu.Unlock()
+	var payloads []string
+	switch list {
+	case ReadyList:
+		payloads = channel.queue.ready
+	case UnackedList:
+		payloads = channel.queue.unacked
+	case RejectedList:
+		for _, rejection := range channel.queue.rejected {
+			payloads = append(payloads, rejection.payload)
+		}
+	default:
+		return nil, fmt.Errorf("Unknown list '%v'", list)
+	}
+	if int64(len(payloads)) > count {
+		payloads = payloads[:count]
+	}
+	return append([]string{}, payloads...), nil
+}
+
+func (channel *memQueueConsumerChannel) RequeueRejected(match func(string) bool) (int, error) {
+	queue := channel.queue
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	remaining := []memRejection{}
+	count := 0
+	for _, rejection := range queue.rejected {
+		if match(rejection.payload) {
+			queue.ready = append([]string{rejection.payload}, queue.ready...)
+			count++
+		} else {
+			remaining = append(remaining, rejection)
+		}
+	}
+	queue.rejected = remaining
+	if count > 0 {
+		queue.notify()
+	}
+	return count, nil
+}
+
+func (channel *memQueueConsumerChannel) PurgeRejectedBefore(cutoff time.Time) (int, error) {
+	queue := channel.queue
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	remaining := []memRejection{}
+	for _, rejection := range queue.rejected {
+		if !rejection.at.Before(cutoff) {
+			remaining = append(remaining, rejection)
+		}
+	}
+	count := len(queue.rejected) - len(remaining)
+	queue.rejected = remaining
+	return count, nil
+}
@@ -0,0 +1,89 @@
+package channels
+
+import (
+	"fmt"
+	"github.com/notegio/openrelay/types"
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// KeyFunc picks the key a delivery is ordered by. Deliveries with the same
+// key are processed one at a time, in the order they were consumed.
+// Deliveries with an empty key have no ordering requirements.
+type KeyFunc func(Delivery) string
+
+// OrderHashKey orders deliveries by the hash of the order they carry, so
+// that updates to the same order are applied in order
+func OrderHashKey(delivery Delivery) string {
+	order, err := types.OrderFromBytes([]byte(delivery.Payload()))
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%#x", order.Hash())
+}
+
+// MakerKey orders deliveries by the maker of the order they carry, so that
+// all of a maker's orders are processed in order
+func MakerKey(delivery Delivery) string {
+	order, err := types.OrderFromBytes([]byte(delivery.Payload()))
+	if err != nil || order.Maker == nil {
+		return ""
+	}
+	return order.Maker.String()
+}
+
+// KeyFuncByName returns the KeyFunc called `name`, which is "hash" for
+// OrderHashKey or "maker" for MakerKey. An empty name returns nil, meaning
+// deliveries are not partitioned.
+func KeyFuncByName(name string) (KeyFunc, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "hash":
+		return OrderHashKey, nil
+	case "maker":
+		return MakerKey, nil
+	default:
+		return nil, fmt.Errorf("Unknown partition key '%v', expected 'hash' or 'maker'", name)
+	}
+}
+
+// Lanes runs work on a fixed number of serial worker lanes. Work for the same
+// key always runs on the same lane, so it runs in the order it was submitted,
+// while work for different keys can run concurrently on other lanes.
+type Lanes struct {
+	lanes []chan func()
+	next  uint32
+}
+
+// NewLanes starts `count` worker lanes
+func NewLanes(count int) *Lanes {
+	if count < 1 {
+		count = 1
+	}
+	lanes := &Lanes{lanes: make([]chan func(), count)}
+	for i := range lanes.lanes {
+		lane := make(chan func())
+		lanes.lanes[i] = lane
+		go func() {
+			for work := range lane {
+				work()
+			}
+		}()
+	}
+	return lanes
+}
+
+// Run queues `work` on the lane for `key`, blocking until the lane is free
+// to take it. Work with an empty key is spread across the lanes in turn.
+func (lanes *Lanes) Run(key string, work func()) {
+	var index uint32
+	if key == "" {
+		index = atomic.AddUint32(&lanes.next, 1)
+	} else {
+		hash := fnv.New32a()
+		hash.Write([]byte(key))
+		index = hash.Sum32()
+	}
+	lanes.lanes[index%uint32(len(lanes.lanes))] <- work
+}
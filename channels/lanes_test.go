@@ -0,0 +1,121 @@
+package channels_test
+
+import (
+	"fmt"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/types"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLanesPreserveKeyOrder(t *testing.T) {
+	lanes := channels.NewLanes(4)
+	mu := &sync.Mutex{}
+	seen := make(map[string][]int)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		for _, key := range []string{"a", "b", "c"} {
+			wg.Add(1)
+			key, i := key, i
+			lanes.Run(key, func() {
+				defer wg.Done()
+				if i%7 == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				mu.Lock()
+				seen[key] = append(seen[key], i)
+				mu.Unlock()
+			})
+		}
+	}
+	wg.Wait()
+	for key, values := range seen {
+		for i, value := range values {
+			if value != i {
+				t.Fatalf("Work for key %v ran out of order: %v", key, values)
+			}
+		}
+	}
+}
+
+// slowFirstFilter holds up the first message for each key, so that without
+// lanes the second would overtake it.
+type slowFirstFilter struct{}
+
+func (filter *slowFirstFilter) Filter(delivery channels.Delivery) bool {
+	if strings.HasSuffix(delivery.Payload(), "-1") {
+		time.Sleep(50 * time.Millisecond)
+	}
+	return true
+}
+
+func TestKeyedRelayOrdering(t *testing.T) {
+	sourceName := memName("keyed_source")
+	destName := memName("keyed_dest")
+	key := func(delivery channels.Delivery) string {
+		return strings.SplitN(delivery.Payload(), "-", 2)[0]
+	}
+	relay := channels.NewKeyedRelay(
+		channels.NewMemQueueConsumerChannel(sourceName),
+		[]channels.Publisher{channels.NewMemQueuePublisher(destName)},
+		&slowFirstFilter{},
+		4,
+		key,
+	)
+	relay.Start()
+	defer relay.Stop()
+	destChannel := channels.NewMemQueueConsumerChannel(destName)
+	deliveries := make(chan channels.Delivery, 6)
+	destChannel.AddConsumer(&deliveryConsumer{deliveries})
+	destChannel.StartConsuming()
+	defer destChannel.StopConsuming()
+	publisher := channels.NewMemQueuePublisher(sourceName)
+	for _, payload := range []string{"a-1", "a-2", "a-3", "b-1", "b-2", "b-3"} {
+		publisher.Publish(payload)
+	}
+	last := make(map[string]string)
+	for i := 0; i < 6; i++ {
+		select {
+		case delivery := <-deliveries:
+			parts := strings.SplitN(delivery.Payload(), "-", 2)
+			if parts[1] <= last[parts[0]] {
+				t.Errorf("%v arrived after %v-%v", delivery.Payload(), parts[0], last[parts[0]])
+			}
+			last[parts[0]] = parts[1]
+			delivery.Ack()
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Only got %v deliveries", i)
+		}
+	}
+}
+
+func TestKeyFuncs(t *testing.T) {
+	order := &types.Order{}
+	order.Initialize()
+	order.Maker[19] = 1
+	delivery := &deliveryWithPayload{string(order.Bytes())}
+	if key := channels.OrderHashKey(delivery); key != fmt.Sprintf("%#x", order.Hash()) {
+		t.Errorf("Unexpected hash key %v", key)
+	}
+	if key := channels.MakerKey(delivery); key != "0x0000000000000000000000000000000000000001" {
+		t.Errorf("Unexpected maker key %v", key)
+	}
+	if key := channels.OrderHashKey(&deliveryWithPayload{"not an order"}); key != "" {
+		t.Errorf("Expected an empty key for an invalid order, got %v", key)
+	}
+	if _, err := channels.KeyFuncByName("taker"); err == nil {
+		t.Errorf("Expected an error for an unknown key")
+	}
+}
+
+type deliveryWithPayload struct {
+	payload string
+}
+
+func (delivery *deliveryWithPayload) Payload() string           { return delivery.payload }
+func (delivery *deliveryWithPayload) Headers() channels.Headers { return nil }
+func (delivery *deliveryWithPayload) Ack() bool                 { return true }
+func (delivery *deliveryWithPayload) Reject() bool              { return true }
+func (delivery *deliveryWithPayload) Return() bool              { return true }
@@ -0,0 +1,622 @@
+package channels
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memRegistry holds every in-process queue and topic, keyed by name, so that
+// publishers and consumers created independently from the same URI share
+// state the same way they would through Redis.
+type memRegistry struct {
+	mu     sync.Mutex
+	queues map[string]*memQueue
+	topics map[string]*memTopic
+}
+
+var registry = &memRegistry{
+	queues: make(map[string]*memQueue),
+	topics: make(map[string]*memTopic),
+}
+
+func (reg *memRegistry) queue(name string) *memQueue {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	queue, ok := reg.queues[name]
+	if !ok {
+		queue = &memQueue{name: name, attempts: make(map[string]int), changed: make(chan struct{})}
+		reg.queues[name] = queue
+	}
+	return queue
+}
+
+func (reg *memRegistry) topic(name string) *memTopic {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	topic, ok := reg.topics[name]
+	if !ok {
+		topic = &memTopic{name: name, subscribers: make(map[*memTopicConsumerChannel]struct{})}
+		reg.topics[name] = topic
+	}
+	return topic
+}
+
+// memQueue mirrors the ready / unacked / rejected lists a Redis queue keeps.
+// Ready messages are consumed from the front of the slice.
+type memQueue struct {
+	name     string
+	mu       sync.Mutex
+	ready    []string
+	unacked  []string
+	rejected []memRejection
+	delayed  []memRejection
+	attempts map[string]int
+	changed  chan struct{}
+}
+
+// memRejection records a payload along with when it was rejected, or for
+// delayed deliveries, when it is due.
+type memRejection struct {
+	payload string
+	at      time.Time
+}
+
+// notify wakes up every consumer waiting for a message. Must be called with
+// the lock held.
+func (queue *memQueue) notify() {
+	close(queue.changed)
+	queue.changed = make(chan struct{})
+}
+
+func (queue *memQueue) push(payload string) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	queue.ready = append(queue.ready, payload)
+	queue.notify()
+}
+
+// promoteDelayed moves delayed deliveries that are due to the front of the
+// ready list, and returns how long until the next delayed delivery is due, or
+// 0 if there are none. Must be called with the lock held.
+func (queue *memQueue) promoteDelayed(now time.Time) time.Duration {
+	remaining := []memRejection{}
+	var next time.Duration
+	for _, delayed := range queue.delayed {
+		if !delayed.at.After(now) {
+			queue.ready = append([]string{delayed.payload}, queue.ready...)
+			continue
+		}
+		remaining = append(remaining, delayed)
+		if wait := delayed.at.Sub(now); next == 0 || wait < next {
+			next = wait
+		}
+	}
+	queue.delayed = remaining
+	return next
+}
+
+// pop moves the next ready message to the unacked list, returning its
+// attempt number. If no message is available it returns false along with a
+// channel that will be closed when the queue changes, and how long until a
+// delayed message is due, if any are waiting.
+func (queue *memQueue) pop() (string, int, bool, chan struct{}, time.Duration) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	wait := queue.promoteDelayed(time.Now())
+	if len(queue.ready) == 0 {
+		return "", 0, false, queue.changed, wait
+	}
+	payload := queue.ready[0]
+	queue.ready = queue.ready[1:]
+	queue.unacked = append(queue.unacked, payload)
+	return payload, queue.attempts[payload] + 1, true, nil, 0
+}
+
+func removeOne(list []string, payload string) ([]string, bool) {
+	for i, value := range list {
+		if value == payload {
+			return append(list[:i:i], list[i+1:]...), true
+		}
+	}
+	return list, false
+}
+
+func (queue *memQueue) ack(payload string) bool {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	delete(queue.attempts, payload)
+	var ok bool
+	queue.unacked, ok = removeOne(queue.unacked, payload)
+	return ok
+}
+
+func (queue *memQueue) reject(payload string) bool {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	delete(queue.attempts, payload)
+	var ok bool
+	if queue.unacked, ok = removeOne(queue.unacked, payload); ok {
+		queue.rejected = append(queue.rejected, memRejection{payload, time.Now()})
+	}
+	return ok
+}
+
+func (queue *memQueue) requeue(payload string) bool {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	var ok bool
+	if queue.unacked, ok = removeOne(queue.unacked, payload); ok {
+		queue.ready = append([]string{payload}, queue.ready...)
+		queue.notify()
+	}
+	return ok
+}
+
+// retryLater records a failed attempt and either rejects the payload, or
+// holds it back until its backoff has elapsed.
+func (queue *memQueue) retryLater(payload string, attempts int, retry RetryPolicy) bool {
+	if retry.Exhausted(attempts) {
+		log.Printf("Rejecting delivery after %v attempts", attempts)
+		return queue.reject(payload)
+	}
+	delay := retry.Delay(attempts)
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	var ok bool
+	if queue.unacked, ok = removeOne(queue.unacked, payload); !ok {
+		return false
+	}
+	queue.attempts[payload] = attempts
+	if delay == 0 {
+		queue.ready = append([]string{payload}, queue.ready...)
+	} else {
+		queue.delayed = append(queue.delayed, memRejection{payload, time.Now().Add(delay)})
+	}
+	queue.notify()
+	return true
+}
+
+// schedule holds `payload` back until `due`, replacing any earlier schedule
+// for the same payload.
+func (queue *memQueue) schedule(payload string, due time.Time) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	delayed := []memRejection{}
+	for _, entry := range queue.delayed {
+		if entry.payload != payload {
+			delayed = append(delayed, entry)
+		}
+	}
+	queue.delayed = append(delayed, memRejection{payload, due})
+	queue.notify()
+}
+
+func (queue *memQueue) returnAllUnacked() int {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	count := len(queue.unacked)
+	if count > 0 {
+		queue.ready = append(queue.unacked, queue.ready...)
+		queue.unacked = nil
+		queue.notify()
+	}
+	return count
+}
+
+func (queue *memQueue) purgeRejected() int {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	count := len(queue.rejected)
+	queue.rejected = nil
+	return count
+}
+
+type memQueueDelivery struct {
+	envelope
+	payload  string
+	queue    *memQueue
+	retry    RetryPolicy
+	attempts int
+}
+
+func (delivery *memQueueDelivery) Attempts() int {
+	return delivery.attempts
+}
+
+func (delivery *memQueueDelivery) Ack() bool {
+	return delivery.queue.ack(delivery.payload)
+}
+
+func (delivery *memQueueDelivery) Reject() bool {
+	return delivery.queue.reject(delivery.payload)
+}
+
+func (delivery *memQueueDelivery) Return() bool {
+	if delivery.retry.Enabled() {
+		return delivery.queue.retryLater(delivery.payload, delivery.attempts, delivery.retry)
+	}
+	return delivery.Release()
+}
+
+func (delivery *memQueueDelivery) Release() bool {
+	return delivery.queue.requeue(delivery.payload)
+}
+
+type memQueuePublisher struct {
+	queue *memQueue
+}
+
+// NewMemQueuePublisher returns a Publisher that pushes messages onto the
+// in-process queue named `name`.
+func NewMemQueuePublisher(name string) Publisher {
+	return &memQueuePublisher{registry.queue(name)}
+}
+
+func (publisher *memQueuePublisher) String() string {
+	return "mem://" + publisher.queue.name
+}
+
+func (publisher *memQueuePublisher) Publish(payload string) bool {
+	if len(payload) == 0 {
+		log.Printf("Trying to publish empty message. Skipping")
+		return false
+	}
+	publisher.queue.push(payload)
+	return countPublish(publisher.String(), true)
+}
+
+type memQueueConsumerChannel struct {
+	queue        *memQueue
+	channelName  string
+	deliveryChan chan Delivery
+	retry        RetryPolicy
+	stop         chan struct{}
+	consuming    bool
+	mu           sync.Mutex
+}
+
+// NewMemQueueConsumerChannel returns a ConsumerChannel backed by an
+// in-process queue. It has the same delivery semantics as
+// NewQueueConsumerChannel, but messages never leave the current process, so
+// it is only useful for tests and single binary deployments.
+func NewMemQueueConsumerChannel(channelName string) ConsumerChannel {
+	return NewMemRetryQueueConsumerChannel(channelName, RetryPolicy{})
+}
+
+// NewMemRetryQueueConsumerChannel returns an in-process queue based
+// ConsumerChannel where Returned deliveries are retried according to `retry`.
+func NewMemRetryQueueConsumerChannel(channelName string, retry RetryPolicy) ConsumerChannel {
+	concurrency, err := strconv.Atoi(os.Getenv("CONCURRENCY"))
+	if err != nil {
+		concurrency = prefetchLimit
+	}
+	return &memQueueConsumerChannel{
+		queue:        registry.queue(channelName),
+		channelName:  channelName,
+		deliveryChan: make(chan Delivery, concurrency),
+		retry:        retry,
+		stop:         make(chan struct{}),
+	}
+}
+
+func (channel *memQueueConsumerChannel) AddConsumer(consumer Consumer) bool {
+	go func() {
+		for delivery := range channel.deliveryChan {
+			consumer.Consume(countDelivery("mem://"+channel.channelName, delivery))
+		}
+	}()
+	return true
+}
+
+func (channel *memQueueConsumerChannel) StartConsuming() bool {
+	channel.mu.Lock()
+	defer channel.mu.Unlock()
+	if channel.consuming {
+		return false
+	}
+	channel.consuming = true
+	watchDepth("mem://"+channel.channelName, channel)
+	go channel.consume(channel.stop)
+	return true
+}
+
+func (channel *memQueueConsumerChannel) consume(stop chan struct{}) {
+	for {
+		payload, attempts, ok, changed, wait := channel.queue.pop()
+		if ok {
+			select {
+			case channel.deliveryChan <- &memQueueDelivery{openEnvelope(payload), payload, channel.queue, channel.retry, attempts}:
+			case <-stop:
+				channel.queue.requeue(payload)
+				return
+			}
+			continue
+		}
+		var due <-chan time.Time
+		if wait > 0 {
+			due = time.After(wait)
+		}
+		select {
+		case <-changed:
+		case <-due:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (channel *memQueueConsumerChannel) StopConsuming() bool {
+	channel.mu.Lock()
+	defer channel.mu.Unlock()
+	if !channel.consuming {
+		return false
+	}
+	channel.consuming = false
+	unwatchDepth("mem://" + channel.channelName)
+	close(channel.stop)
+	channel.stop = make(chan struct{})
+	releaseBuffered(channel.deliveryChan)
+	return true
+}
+
+func (channel *memQueueConsumerChannel) ReturnAllUnacked() int {
+	return channel.queue.returnAllUnacked()
+}
+
+func (channel *memQueueConsumerChannel) PurgeRejected() int {
+	return channel.queue.purgeRejected()
+}
+
+func (channel *memQueueConsumerChannel) Publisher() Publisher {
+	return &memQueuePublisher{channel.queue}
+}
+
+// memTopic fans each message out to every consumer channel subscribed at the
+// time it was published, like Redis PubSub. Replayable publishers also
+// number each message and record it in the topic's history.
+type memTopic struct {
+	name        string
+	mu          sync.Mutex
+	subscribers map[*memTopicConsumerChannel]struct{}
+	sequence    int64
+	history     []memHistoryEntry
+}
+
+type memHistoryEntry struct {
+	sequence  int64
+	published time.Time
+	payload   string
+}
+
+func (topic *memTopic) subscribe(channel *memTopicConsumerChannel) bool {
+	topic.mu.Lock()
+	defer topic.mu.Unlock()
+	if _, ok := topic.subscribers[channel]; ok {
+		return false
+	}
+	if channel.history.Enabled() {
+		if channel.resuming {
+			if err := topic.truncated(channel.lastSeen); err != nil {
+				log.Printf("Missed messages on mem+topic://%v: %v", channel.channelName, err.Error())
+			}
+			for _, entry := range topic.since(channel.lastSeen) {
+				channel.deliver(entry.sequence, entry.payload)
+			}
+		} else {
+			channel.resuming = true
+			channel.lastSeen = topic.sequence
+		}
+	}
+	topic.subscribers[channel] = struct{}{}
+	return true
+}
+
+func (topic *memTopic) unsubscribe(channel *memTopicConsumerChannel) bool {
+	topic.mu.Lock()
+	defer topic.mu.Unlock()
+	if _, ok := topic.subscribers[channel]; !ok {
+		return false
+	}
+	delete(topic.subscribers, channel)
+	return true
+}
+
+func (topic *memTopic) publish(payload string, history HistoryOptions) {
+	topic.mu.Lock()
+	defer topic.mu.Unlock()
+	sequence := int64(0)
+	if history.Enabled() {
+		topic.sequence++
+		sequence = topic.sequence
+		payload = withSequence(payload, sequence)
+		now := time.Now()
+		topic.history = append(topic.history, memHistoryEntry{sequence, now, payload})
+		topic.trim(history, now)
+	}
+	for channel := range topic.subscribers {
+		channel.deliver(sequence, payload)
+	}
+}
+
+// trim drops history entries beyond the length or age of `history`. Must be
+// called with the lock held.
+func (topic *memTopic) trim(history HistoryOptions, now time.Time) {
+	drop := 0
+	if history.Length > 0 && int64(len(topic.history)) > history.Length {
+		drop = len(topic.history) - int(history.Length)
+	}
+	if history.Age > 0 {
+		cutoff := now.Add(-history.Age)
+		for drop < len(topic.history) && !topic.history[drop].published.After(cutoff) {
+			drop++
+		}
+	}
+	topic.history = topic.history[drop:]
+}
+
+// since returns the history entries after `sequence`. Must be called with
+// the lock held.
+func (topic *memTopic) since(sequence int64) []memHistoryEntry {
+	entries := []memHistoryEntry{}
+	for _, entry := range topic.history {
+		if entry.sequence > sequence {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// truncated returns a *HistoryTruncatedError if some of the messages after
+// `sequence` have been trimmed from the history. Must be called with the lock
+// held.
+func (topic *memTopic) truncated(sequence int64) error {
+	first := int64(0)
+	for _, entry := range topic.history {
+		if entry.sequence > sequence {
+			first = entry.sequence
+			break
+		}
+	}
+	return checkTruncated("mem+topic://"+topic.name, sequence, first, topic.sequence)
+}
+
+type memTopicPublisher struct {
+	topic   *memTopic
+	history HistoryOptions
+}
+
+// NewMemTopicPublisher returns a Publisher that broadcasts messages to every
+// consumer of the in-process topic named `name`.
+func NewMemTopicPublisher(name string) Publisher {
+	return &memTopicPublisher{registry.topic(name), HistoryOptions{}}
+}
+
+// NewMemReplayableTopicPublisher returns a Publisher for an in-process topic
+// that keeps a history of recent messages, like NewReplayableTopicPublisher.
+func NewMemReplayableTopicPublisher(name string, history HistoryOptions) Publisher {
+	return &memTopicPublisher{registry.topic(name), history}
+}
+
+func (publisher *memTopicPublisher) String() string {
+	return "mem+topic://" + publisher.topic.name
+}
+
+func (publisher *memTopicPublisher) Publish(payload string) bool {
+	publisher.topic.publish(payload, publisher.history)
+	return countPublish(publisher.String(), true)
+}
+
+type memTopicConsumerChannel struct {
+	topic       *memTopic
+	channelName string
+	consumers   []Consumer
+	mu          sync.Mutex
+	history     HistoryOptions
+	// lastSeen and resuming are guarded by the topic's lock
+	lastSeen int64
+	resuming bool
+}
+
+// NewMemTopicConsumerChannel returns a ConsumerChannel backed by an
+// in-process topic. As with NewTopicConsumerChannel, messages published
+// before StartConsuming is called are not delivered, and Ack / Reject are
+// no-ops.
+func NewMemTopicConsumerChannel(channelName string) ConsumerChannel {
+	return &memTopicConsumerChannel{
+		topic:       registry.topic(channelName),
+		channelName: channelName,
+	}
+}
+
+// NewMemReplayableTopicConsumerChannel returns a ConsumerChannel for an
+// in-process replayable topic. When it is stopped and started again, it
+// replays the messages it missed in the meantime from the topic's history.
+// The returned channel also implements TopicHistory.
+func NewMemReplayableTopicConsumerChannel(channelName string, history HistoryOptions) ConsumerChannel {
+	return &memTopicConsumerChannel{
+		topic:       registry.topic(channelName),
+		channelName: channelName,
+		history:     history,
+	}
+}
+
+func (channel *memTopicConsumerChannel) getConsumers() []Consumer {
+	channel.mu.Lock()
+	defer channel.mu.Unlock()
+	return channel.consumers
+}
+
+// deliver passes a message to every consumer. Must be called with the
+// topic's lock held.
+func (channel *memTopicConsumerChannel) deliver(sequence int64, payload string) {
+	if sequence > channel.lastSeen {
+		channel.lastSeen = sequence
+	}
+	for _, consumer := range channel.getConsumers() {
+		go consumer.Consume(countDelivery("mem+topic://"+channel.channelName, newTopicDelivery(payload, nil)))
+	}
+}
+
+func (channel *memTopicConsumerChannel) AddConsumer(consumer Consumer) bool {
+	channel.mu.Lock()
+	defer channel.mu.Unlock()
+	channel.consumers = append(channel.consumers, consumer)
+	return true
+}
+
+func (channel *memTopicConsumerChannel) StartConsuming() bool {
+	return channel.topic.subscribe(channel)
+}
+
+func (channel *memTopicConsumerChannel) StopConsuming() bool {
+	return channel.topic.unsubscribe(channel)
+}
+
+// Since returns the messages in the topic's history after `sequence`
+func (channel *memTopicConsumerChannel) Since(sequence int64) ([]Delivery, error) {
+	if !channel.history.Enabled() {
+		return nil, errors.New("mem+topic://" + channel.channelName + " does not keep a history")
+	}
+	channel.topic.mu.Lock()
+	defer channel.topic.mu.Unlock()
+	entries := channel.topic.since(sequence)
+	deliveries := make([]Delivery, len(entries))
+	for i, entry := range entries {
+		deliveries[i] = newTopicDelivery(entry.payload, nil)
+	}
+	return deliveries, channel.topic.truncated(sequence)
+}
+
+// ResumeFrom sets the sequence of the last message this channel has seen, so
+// that it will replay anything published after it once subscribed.
+func (channel *memTopicConsumerChannel) ResumeFrom(sequence int64) {
+	channel.topic.mu.Lock()
+	defer channel.topic.mu.Unlock()
+	channel.lastSeen = sequence
+	channel.resuming = true
+}
+
+// HistoryEnabled returns true if the topic keeps a history
+func (channel *memTopicConsumerChannel) HistoryEnabled() bool {
+	return channel.history.Enabled()
+}
+
+// ReturnAllUnacked is just here for API Compatibility with queues. It does
+// nothing
+func (channel *memTopicConsumerChannel) ReturnAllUnacked() int {
+	return 0
+}
+
+// PurgeRejected is just here for API Compatibility with queues. It does
+// nothing
+func (channel *memTopicConsumerChannel) PurgeRejected() int {
+	return 0
+}
+
+func (channel *memTopicConsumerChannel) Publisher() Publisher {
+	return &memTopicPublisher{channel.topic, channel.history}
+}
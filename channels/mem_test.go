@@ -0,0 +1,166 @@
+package channels_test
+
+import (
+	"fmt"
+	"github.com/notegio/openrelay/channels"
+	"testing"
+	"time"
+)
+
+// memName returns a unique channel name, since mem:// channels are shared
+// for the life of the process.
+func memName(prefix string) string {
+	return fmt.Sprintf("%v-%v", prefix, time.Now().UnixNano())
+}
+
+func TestMemQueueChannelSend(t *testing.T) {
+	name := memName("mem_queue_send")
+	publisher := channels.NewMemQueuePublisher(name)
+	consumerChannel := channels.NewMemQueueConsumerChannel(name)
+	defer consumerChannel.StopConsuming()
+	ChannelSendTest(publisher, consumerChannel, 0, t)
+}
+func TestMemQueueReturnUnacked(t *testing.T) {
+	name := memName("mem_queue_unacked")
+	publisher := channels.NewMemQueuePublisher(name)
+	consumerChannel := channels.NewMemQueueConsumerChannel(name)
+	defer consumerChannel.StopConsuming()
+	ReturnUnackedTest(publisher, consumerChannel, 0, t)
+}
+func TestMemQueueAck(t *testing.T) {
+	name := memName("mem_queue_ack")
+	publisher := channels.NewMemQueuePublisher(name)
+	consumerChannel := channels.NewMemQueueConsumerChannel(name)
+	defer consumerChannel.StopConsuming()
+	AckTest(publisher, consumerChannel, 0, t)
+}
+func TestMemQueueReject(t *testing.T) {
+	name := memName("mem_queue_reject")
+	publisher := channels.NewMemQueuePublisher(name)
+	consumerChannel := channels.NewMemQueueConsumerChannel(name)
+	defer consumerChannel.StopConsuming()
+	RejectTest(publisher, consumerChannel, 0, t)
+}
+
+func TestMemQueueReturn(t *testing.T) {
+	name := memName("mem_queue_return")
+	publisher := channels.NewMemQueuePublisher(name)
+	consumerChannel := channels.NewMemQueueConsumerChannel(name)
+	defer consumerChannel.StopConsuming()
+	deliveries := make(chan channels.Delivery)
+	consumerChannel.AddConsumer(&deliveryConsumer{deliveries})
+	consumerChannel.StartConsuming()
+	publisher.Publish("test")
+	delivery := <-deliveries
+	if !delivery.Return() {
+		t.Fatalf("Return failed")
+	}
+	delivery = <-deliveries
+	if delivery.Payload() != "test" {
+		t.Errorf("Unexpected value '%v'", delivery.Payload())
+	}
+	if !delivery.Ack() {
+		t.Errorf("Ack failed")
+	}
+	if unackedCount := consumerChannel.ReturnAllUnacked(); unackedCount != 0 {
+		t.Errorf("Expected 0 unacked value, got '%v'", unackedCount)
+	}
+}
+
+func TestMemTopicChannelSend(t *testing.T) {
+	name := memName("mem_topic_send")
+	publisher := channels.NewMemTopicPublisher(name)
+	consumerChannel := channels.NewMemTopicConsumerChannel(name)
+	defer consumerChannel.StopConsuming()
+	ChannelSendTest(publisher, consumerChannel, 0, t)
+}
+func TestMemTopicAck(t *testing.T) {
+	name := memName("mem_topic_ack")
+	publisher := channels.NewMemTopicPublisher(name)
+	consumerChannel := channels.NewMemTopicConsumerChannel(name)
+	defer consumerChannel.StopConsuming()
+	AckTest(publisher, consumerChannel, 0, t)
+}
+
+func TestMemURITranslator(t *testing.T) {
+	name := memName("mem_translated")
+	translator := channels.NewMemURITranslator()
+	sourceChannel, err := translator.ConsumerFromURI("queue://" + name)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	destPublisher, err := translator.PublisherFromURI("mem://" + name + "_dest")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	destChannel, err := channels.ConsumerFromURI("mem://" + name + "_dest", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	testConsumer := testConsumer{make(chan string), make(chan bool), make(chan bool)}
+	destChannel.AddConsumer(&testConsumer)
+	destChannel.StartConsuming()
+	defer destChannel.StopConsuming()
+	relay := channels.NewRelay(sourceChannel, []channels.Publisher{destPublisher}, &channels.IncludeAll{}, 1)
+	relay.Start()
+	defer relay.Stop()
+	sourcePublisher, err := channels.PublisherFromURI("mem://" + name, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sourcePublisher.Publish("test")
+	if message := <-testConsumer.channel; message != "test" {
+		t.Errorf("Message did not get relayed")
+	}
+}
+
+type deliveryConsumer struct {
+	deliveries chan channels.Delivery
+}
+
+func (consumer *deliveryConsumer) Consume(delivery channels.Delivery) {
+	consumer.deliveries <- delivery
+}
+
+func TestMemQueueInspector(t *testing.T) {
+	name := memName("mem_queue_inspect")
+	publisher := channels.NewMemQueuePublisher(name)
+	consumerChannel := channels.NewMemQueueConsumerChannel(name)
+	defer consumerChannel.StopConsuming()
+	deliveries := make(chan channels.Delivery)
+	consumerChannel.AddConsumer(&deliveryConsumer{deliveries})
+	consumerChannel.StartConsuming()
+	publisher.Publish("a")
+	publisher.Publish("b")
+	(<-deliveries).Reject()
+	(<-deliveries).Reject()
+	inspector := consumerChannel.(channels.Inspector)
+	counts, err := inspector.Counts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if counts[channels.RejectedList] != 2 || counts[channels.UnackedList] != 0 {
+		t.Errorf("Unexpected counts %v", counts)
+	}
+	rejected, err := inspector.Peek(channels.RejectedList, 10)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(rejected) != 2 || rejected[0] != "a" || rejected[1] != "b" {
+		t.Errorf("Unexpected rejected messages %v", rejected)
+	}
+	if count, _ := inspector.RequeueRejected(func(payload string) bool { return payload == "b" }); count != 1 {
+		t.Errorf("Expected 1 requeued message, got %v", count)
+	}
+	if delivery := <-deliveries; delivery.Payload() != "b" {
+		t.Errorf("Unexpected value '%v'", delivery.Payload())
+	} else {
+		delivery.Ack()
+	}
+	if count, _ := inspector.PurgeRejectedBefore(time.Now().Add(-time.Hour)); count != 0 {
+		t.Errorf("Expected 0 purged messages, got %v", count)
+	}
+	if count, _ := inspector.PurgeRejectedBefore(time.Now().Add(time.Second)); count != 1 {
+		t.Errorf("Expected 1 purged message, got %v", count)
+	}
+}
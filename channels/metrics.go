@@ -0,0 +1,91 @@
+package channels
+
+import (
+	"github.com/notegio/openrelay/metrics"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	publishedCounter = metrics.NewCounter("openrelay_channel_published_total", "Messages published, by channel", "channel")
+	publishFailures  = metrics.NewCounter("openrelay_channel_publish_failures_total", "Messages that could not be published, by channel", "channel")
+	consumedCounter  = metrics.NewCounter("openrelay_channel_consumed_total", "Messages delivered to consumers, by channel", "channel")
+	ackedCounter     = metrics.NewCounter("openrelay_channel_acked_total", "Deliveries acked by consumers, by channel", "channel")
+	rejectedCounter  = metrics.NewCounter("openrelay_channel_rejected_total", "Deliveries rejected by consumers, by channel", "channel")
+	returnedCounter  = metrics.NewCounter("openrelay_channel_returned_total", "Deliveries returned by consumers for another attempt, by channel", "channel")
+	processingTime   = metrics.NewHistogram("openrelay_channel_processing_seconds", "Time from a message being delivered to it being acked, rejected or returned", metrics.DefBuckets, "channel")
+	queueDepth       = metrics.NewGauge("openrelay_channel_depth", "Messages on the ready, unacked and rejected lists of consumed queues", "channel", "list")
+)
+
+// countPublish records the result of publishing to `channel`, returning
+// `ok` so publishers can wrap their return value.
+func countPublish(channel string, ok bool) bool {
+	if ok {
+		publishedCounter.Inc(channel)
+	} else {
+		publishFailures.Inc(channel)
+	}
+	return ok
+}
+
+// countedDelivery tracks what a consumer does with a delivery, and how long
+// it takes to do it.
+type countedDelivery struct {
+	Delivery
+	channel string
+	start   time.Time
+	settled int32
+}
+
+// countDelivery records that `delivery` was consumed from `channel`, and
+// wraps it so that acks, rejections and returns are counted.
+func countDelivery(channel string, delivery Delivery) Delivery {
+	consumedCounter.Inc(channel)
+	return &countedDelivery{delivery, channel, time.Now(), 0}
+}
+
+func (delivery *countedDelivery) settle(counter *metrics.Counter, ok bool) bool {
+	if ok && atomic.CompareAndSwapInt32(&delivery.settled, 0, 1) {
+		counter.Inc(delivery.channel)
+		processingTime.Since(delivery.start, delivery.channel)
+	}
+	return ok
+}
+
+func (delivery *countedDelivery) Ack() bool {
+	return delivery.settle(ackedCounter, delivery.Delivery.Ack())
+}
+
+func (delivery *countedDelivery) Reject() bool {
+	return delivery.settle(rejectedCounter, delivery.Delivery.Reject())
+}
+
+func (delivery *countedDelivery) Return() bool {
+	return delivery.settle(returnedCounter, delivery.Delivery.Return())
+}
+
+func (delivery *countedDelivery) Release() bool {
+	return delivery.settle(returnedCounter, ReleaseDelivery(delivery.Delivery))
+}
+
+func (delivery *countedDelivery) Attempts() int {
+	return DeliveryAttempts(delivery.Delivery)
+}
+
+// watchDepth reports the length of each of a queue's lists whenever metrics
+// are collected.
+func watchDepth(channel string, inspector Inspector) {
+	metrics.AddCollector("depth:"+channel, func() {
+		counts, err := inspector.Counts()
+		if err != nil {
+			return
+		}
+		for list, count := range counts {
+			queueDepth.Set(float64(count), channel, list)
+		}
+	})
+}
+
+func unwatchDepth(channel string) {
+	metrics.RemoveCollector("depth:" + channel)
+}
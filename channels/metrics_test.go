@@ -0,0 +1,45 @@
+package channels_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/metrics"
+	"strings"
+	"testing"
+)
+
+func TestMemQueueMetrics(t *testing.T) {
+	name := memName("mem_queue_metrics")
+	consumerChannel := channels.NewMemQueueConsumerChannel(name)
+	defer consumerChannel.StopConsuming()
+	deliveries := make(chan channels.Delivery)
+	consumerChannel.AddConsumer(&deliveryConsumer{deliveries})
+	consumerChannel.StartConsuming()
+	publisher := channels.NewMemQueuePublisher(name)
+	publisher.Publish("ack")
+	publisher.Publish("reject")
+	publisher.Publish("pending")
+	(<-deliveries).Ack()
+	delivery := <-deliveries
+	delivery.Reject()
+	// Settling a delivery a second time shouldn't count twice
+	delivery.Reject()
+	<-deliveries
+	buffer := &bytes.Buffer{}
+	metrics.Write(buffer)
+	output := buffer.String()
+	for _, line := range []string{
+		fmt.Sprintf("openrelay_channel_published_total{channel=\"mem://%v\"} 3", name),
+		fmt.Sprintf("openrelay_channel_consumed_total{channel=\"mem://%v\"} 3", name),
+		fmt.Sprintf("openrelay_channel_acked_total{channel=\"mem://%v\"} 1", name),
+		fmt.Sprintf("openrelay_channel_rejected_total{channel=\"mem://%v\"} 1", name),
+		fmt.Sprintf("openrelay_channel_processing_seconds_count{channel=\"mem://%v\"} 2", name),
+		fmt.Sprintf("openrelay_channel_depth{channel=\"mem://%v\",list=\"unacked\"} 1", name),
+		fmt.Sprintf("openrelay_channel_depth{channel=\"mem://%v\",list=\"rejected\"} 1", name),
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected line '%v'", line)
+		}
+	}
+}
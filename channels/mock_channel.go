@@ -69,7 +69,7 @@ type mockPublisher struct {
 }
 
 func (mock *mockPublisher) Publish(payload string) bool {
-	mock.channel <- &mockDelivery{payload, mock.unacked, mock.rejected}
+	mock.channel <- &mockDelivery{openEnvelope(payload), mock.unacked, mock.rejected}
 	return true
 }
 
@@ -78,15 +78,11 @@ type deliveries struct {
 }
 
 type mockDelivery struct {
-	payload  string
+	envelope
 	unacked  *deliveries
 	rejected *deliveries
 }
 
-func (mock *mockDelivery) Payload() string {
-	return mock.payload
-}
-
 func (mock *mockDelivery) Ack() bool {
 	for i, value := range mock.unacked.deliveries {
 		if value == mock {
@@ -0,0 +1,557 @@
+package channels
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// pgQueueNotifyChannel is the Postgres notification channel used to wake
+// queue consumers when a message is enqueued. The payload is the queue name.
+const pgQueueNotifyChannel = "channel_messages"
+
+// pgSchema creates the table queues are stored in. Messages are claimed by
+// setting claimed_until, so a message whose claim has expired is picked up
+// again by another consumer.
+const pgSchema = `
+CREATE TABLE IF NOT EXISTS channel_messages (
+	id BIGSERIAL PRIMARY KEY,
+	queue TEXT NOT NULL,
+	payload BYTEA NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	available_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	claimed_until TIMESTAMP WITH TIME ZONE,
+	rejected_at TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX IF NOT EXISTS channel_messages_queue_idx ON channel_messages (queue, id);
+`
+
+// PgBackend holds the Postgres connection that pg:// and pg+topic://
+// channels are stored in.
+type PgBackend struct {
+	db               *sql.DB
+	connectionString string
+}
+
+// NewPgBackend connects to Postgres with `connectionString`, which may be any
+// connection string lib/pq accepts, and creates the channel_messages table if
+// it doesn't exist yet.
+func NewPgBackend(connectionString string) (*PgBackend, error) {
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(pgSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &PgBackend{db, connectionString}, nil
+}
+
+var defaultPg = struct {
+	sync.Mutex
+	backend *PgBackend
+}{}
+
+// defaultPgBackend returns the backend used by ConsumerFromURI and
+// PublisherFromURI, connecting with the CHANNELS_POSTGRES environment
+// variable the first time it is called.
+func defaultPgBackend() (*PgBackend, error) {
+	defaultPg.Lock()
+	defer defaultPg.Unlock()
+	if defaultPg.backend == nil {
+		connectionString := os.Getenv("CHANNELS_POSTGRES")
+		if connectionString == "" {
+			return nil, errors.New("Please set CHANNELS_POSTGRES to use pg:// channels")
+		}
+		backend, err := NewPgBackend(connectionString)
+		if err != nil {
+			return nil, err
+		}
+		defaultPg.backend = backend
+	}
+	return defaultPg.backend, nil
+}
+
+// PgExecer is satisfied by *sql.DB and *sql.Tx, as well as the CommonDB() of
+// a gorm transaction.
+type PgExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// PgEnqueue adds `payload` to the pg:// queue `queue` using `execer`. When
+// `execer` is a transaction the message only becomes visible to consumers if
+// the transaction commits, so an order can be enqueued in the same
+// transaction that saves it.
+func PgEnqueue(execer PgExecer, queue, payload string) error {
+	_, err := execer.Exec(
+		`WITH message AS (INSERT INTO channel_messages (queue, payload) VALUES ($1, $2) RETURNING queue)
+		SELECT pg_notify($3, queue) FROM message`,
+		queue,
+		[]byte(payload),
+		pgQueueNotifyChannel,
+	)
+	return err
+}
+
+// ParsePgClaimTimeout reads the claim parameter of a pg:// URI, which is how
+// long a delivery may go unacknowledged before it is redelivered.
+func ParsePgClaimTimeout(query url.Values) (time.Duration, error) {
+	if claim := query.Get("claim"); claim != "" {
+		timeout, err := time.ParseDuration(claim)
+		if err == nil && timeout <= 0 {
+			err = errors.New("Claim timeout must be positive")
+		}
+		return timeout, err
+	}
+	return defaultClaimTimeout, nil
+}
+
+type pgQueuePublisher struct {
+	backend *PgBackend
+	queue   string
+}
+
+// NewPgQueuePublisher returns a Publisher that adds messages to the queue
+// `queue` in the channel_messages table.
+func NewPgQueuePublisher(queue string, backend *PgBackend) Publisher {
+	return &pgQueuePublisher{backend, queue}
+}
+
+func (publisher *pgQueuePublisher) String() string {
+	return "pg://" + publisher.queue
+}
+
+func (publisher *pgQueuePublisher) Publish(payload string) bool {
+	if len(payload) == 0 {
+		log.Printf("Trying to publish empty message. Skipping")
+		return false
+	}
+	if err := PgEnqueue(publisher.backend.db, publisher.queue, payload); err != nil {
+		log.Printf("Error publishing to %v: %v", publisher.String(), err.Error())
+		return countPublish(publisher.String(), false)
+	}
+	return countPublish(publisher.String(), true)
+}
+
+type pgTopicPublisher struct {
+	backend *PgBackend
+	topic   string
+}
+
+// NewPgTopicPublisher returns a Publisher that sends messages to every
+// consumer listening on the topic `topic`, using Postgres NOTIFY. Payloads
+// are base64 encoded, as notifications must be text, so they are limited to
+// about 6000 bytes.
+func NewPgTopicPublisher(topic string, backend *PgBackend) Publisher {
+	return &pgTopicPublisher{backend, topic}
+}
+
+func (publisher *pgTopicPublisher) String() string {
+	return "pg+topic://" + publisher.topic
+}
+
+func (publisher *pgTopicPublisher) Publish(payload string) bool {
+	if len(payload) == 0 {
+		log.Printf("Trying to publish empty message. Skipping")
+		return false
+	}
+	_, err := publisher.backend.db.Exec("SELECT pg_notify($1, $2)", publisher.topic, base64.StdEncoding.EncodeToString([]byte(payload)))
+	if err != nil {
+		log.Printf("Error publishing to %v: %v", publisher.String(), err.Error())
+		return countPublish(publisher.String(), false)
+	}
+	return countPublish(publisher.String(), true)
+}
+
+type pgDelivery struct {
+	envelope
+	id       int64
+	attempts int
+	channel  *pgQueueConsumerChannel
+}
+
+func (delivery *pgDelivery) Attempts() int {
+	return delivery.attempts
+}
+
+func (delivery *pgDelivery) Ack() bool {
+	return delivery.channel.update("DELETE FROM channel_messages WHERE id = $1", delivery.id)
+}
+
+func (delivery *pgDelivery) Reject() bool {
+	return delivery.channel.update("UPDATE channel_messages SET rejected_at = now(), claimed_until = NULL WHERE id = $1", delivery.id)
+}
+
+func (delivery *pgDelivery) Return() bool {
+	retry := delivery.channel.retry
+	if !retry.Enabled() {
+		return delivery.Release()
+	}
+	if retry.Exhausted(delivery.attempts) {
+		log.Printf("Rejecting delivery on pg://%v after %v attempts", delivery.channel.queue, delivery.attempts)
+		return delivery.Reject()
+	}
+	return delivery.channel.update(
+		"UPDATE channel_messages SET claimed_until = NULL, available_at = now() + $2::float8 * interval '1 millisecond' WHERE id = $1",
+		delivery.id,
+		int64(retry.Delay(delivery.attempts)/time.Millisecond),
+	)
+}
+
+// Release makes the delivery available again straight away, without counting
+// it as a failed attempt.
+func (delivery *pgDelivery) Release() bool {
+	return delivery.channel.update("UPDATE channel_messages SET claimed_until = NULL, attempts = attempts - 1 WHERE id = $1", delivery.id)
+}
+
+type pgQueueConsumerChannel struct {
+	backend          *PgBackend
+	queue            string
+	retry            RetryPolicy
+	claimTimeout     time.Duration
+	consumingStopped chan bool
+	deliveryChan     chan Delivery
+}
+
+// NewPgQueueConsumerChannel returns a ConsumerChannel for a queue stored in
+// Postgres. Each message is delivered to a single consumer, which claims it
+// with SELECT ... FOR UPDATE SKIP LOCKED so that any number of consumers can
+// share the queue. A message that isn't acknowledged within `claimTimeout`
+// is delivered again, so messages are delivered at least once. Returned
+// deliveries are retried according to `retry`.
+func NewPgQueueConsumerChannel(queue string, backend *PgBackend, retry RetryPolicy, claimTimeout time.Duration) ConsumerChannel {
+	if claimTimeout == 0 {
+		claimTimeout = defaultClaimTimeout
+	}
+	return &pgQueueConsumerChannel{
+		backend,
+		queue,
+		retry,
+		claimTimeout,
+		nil,
+		nil,
+	}
+}
+
+// update runs a statement affecting a single message, returning true if it
+// did.
+func (queue *pgQueueConsumerChannel) update(query string, args ...interface{}) bool {
+	result, err := queue.backend.db.Exec(query, args...)
+	if err != nil {
+		log.Printf("Error updating pg://%v: %v", queue.queue, err.Error())
+		return false
+	}
+	affected, err := result.RowsAffected()
+	return err == nil && affected == 1
+}
+
+// claim takes the next available message off the queue, returning nil if
+// there isn't one.
+func (queue *pgQueueConsumerChannel) claim() *pgDelivery {
+	delivery := &pgDelivery{channel: queue}
+	var payload []byte
+	err := queue.backend.db.QueryRow(
+		`UPDATE channel_messages SET claimed_until = now() + $2::float8 * interval '1 millisecond', attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM channel_messages
+			WHERE queue = $1 AND rejected_at IS NULL AND available_at <= now() AND (claimed_until IS NULL OR claimed_until <= now())
+			ORDER BY id LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, payload, attempts`,
+		queue.queue,
+		int64(queue.claimTimeout/time.Millisecond),
+	).Scan(&delivery.id, &payload, &delivery.attempts)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		log.Printf("Error claiming from pg://%v: %v", queue.queue, err.Error())
+		return nil
+	}
+	delivery.envelope = openEnvelope(string(payload))
+	return delivery
+}
+
+// ReturnAllUnacked makes every claimed message on the queue available again,
+// returning the number of returned deliveries
+func (queue *pgQueueConsumerChannel) ReturnAllUnacked() int {
+	result, err := queue.backend.db.Exec(
+		"UPDATE channel_messages SET claimed_until = NULL, attempts = attempts - 1 WHERE queue = $1 AND rejected_at IS NULL AND claimed_until > now()",
+		queue.queue,
+	)
+	if err != nil {
+		log.Printf("Error returning unacked deliveries on pg://%v: %v", queue.queue, err.Error())
+		return 0
+	}
+	affected, _ := result.RowsAffected()
+	return int(affected)
+}
+
+// PurgeRejected removes all rejected deliveries from the queue and returns
+// the number of purged deliveries
+func (queue *pgQueueConsumerChannel) PurgeRejected() int {
+	count, err := queue.PurgeRejectedBefore(time.Now().Add(time.Hour))
+	if err != nil {
+		log.Printf("Error purging rejected deliveries on pg://%v: %v", queue.queue, err.Error())
+	}
+	return count
+}
+
+func (queue *pgQueueConsumerChannel) AddConsumer(consumer Consumer) bool {
+	go func() {
+		for queue.deliveryChan == nil {
+			// StartConsuming hasn't been called yet, so we need to wait until the
+			// deliveryChan appears
+			time.Sleep(100 * time.Millisecond)
+		}
+		for delivery := range queue.deliveryChan {
+			consumer.Consume(countDelivery("pg://"+queue.queue, delivery))
+		}
+	}()
+	return true
+}
+
+func (queue *pgQueueConsumerChannel) StartConsuming() bool {
+	if queue.deliveryChan != nil {
+		return false // already consuming
+	}
+	concurrency, err := strconv.Atoi(os.Getenv("CONCURRENCY"))
+	if err != nil {
+		concurrency = prefetchLimit
+	}
+	queue.deliveryChan = make(chan Delivery, concurrency)
+	watchDepth("pg://"+queue.queue, queue)
+	go queue.consume()
+	return true
+}
+
+func (queue *pgQueueConsumerChannel) consume() {
+	listener := pq.NewListener(queue.backend.connectionString, 10*time.Second, time.Minute, nil)
+	defer listener.Close()
+	go func() {
+		// Listen blocks until the listener has connected. Until then we'll
+		// still pick messages up by polling, just not as quickly.
+		if err := listener.Listen(pgQueueNotifyChannel); err != nil {
+			log.Printf("Error listening for messages on pg://%v: %v", queue.queue, err.Error())
+		}
+	}()
+	for {
+		for queue.consumingStopped == nil {
+			delivery := queue.claim()
+			if delivery == nil {
+				break
+			}
+			queue.deliveryChan <- delivery
+		}
+		// Wait to be told about new messages, but check periodically for
+		// retries that have come due and claims that have expired.
+		select {
+		case <-listener.Notify:
+		case <-time.After(time.Second):
+		}
+		if queue.consumingStopped != nil {
+			queue.consumingStopped <- true
+			return
+		}
+	}
+}
+
+func (queue *pgQueueConsumerChannel) StopConsuming() bool {
+	if queue.deliveryChan != nil && queue.consumingStopped == nil {
+		queue.consumingStopped = make(chan bool)
+		unwatchDepth("pg://" + queue.queue)
+		stopped := <-queue.consumingStopped
+		releaseBuffered(queue.deliveryChan)
+		return stopped
+	}
+	return false
+}
+
+func (queue *pgQueueConsumerChannel) Publisher() Publisher {
+	return NewPgQueuePublisher(queue.queue, queue.backend)
+}
+
+func (queue *pgQueueConsumerChannel) Counts() (map[string]int64, error) {
+	counts := make(map[string]int64)
+	var ready, unacked, rejected int64
+	err := queue.backend.db.QueryRow(
+		`SELECT
+			count(*) FILTER (WHERE rejected_at IS NULL AND (claimed_until IS NULL OR claimed_until <= now())),
+			count(*) FILTER (WHERE rejected_at IS NULL AND claimed_until > now()),
+			count(*) FILTER (WHERE rejected_at IS NOT NULL)
+		FROM channel_messages WHERE queue = $1`,
+		queue.queue,
+	).Scan(&ready, &unacked, &rejected)
+	if err != nil {
+		return nil, err
+	}
+	counts[ReadyList] = ready
+	counts[UnackedList] = unacked
+	counts[RejectedList] = rejected
+	return counts, nil
+}
+
+func (queue *pgQueueConsumerChannel) Peek(list string, count int64) ([]string, error) {
+	var condition string
+	switch list {
+	case ReadyList:
+		condition = "rejected_at IS NULL AND (claimed_until IS NULL OR claimed_until <= now())"
+	case UnackedList:
+		condition = "rejected_at IS NULL AND claimed_until > now()"
+	case RejectedList:
+		condition = "rejected_at IS NOT NULL"
+	default:
+		return nil, fmt.Errorf("Unknown list '%v'", list)
+	}
+	rows, err := queue.backend.db.Query("SELECT payload FROM channel_messages WHERE queue = $1 AND "+condition+" ORDER BY id LIMIT $2", queue.queue, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	payloads := []string{}
+	for rows.Next() {
+		var payload []byte
+		if err := rows.Scan(&payload); err != nil {
+			return payloads, err
+		}
+		payloads = append(payloads, string(payload))
+	}
+	return payloads, rows.Err()
+}
+
+func (queue *pgQueueConsumerChannel) RequeueRejected(match func(string) bool) (int, error) {
+	rows, err := queue.backend.db.Query("SELECT id, payload FROM channel_messages WHERE queue = $1 AND rejected_at IS NOT NULL ORDER BY id", queue.queue)
+	if err != nil {
+		return 0, err
+	}
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		var payload []byte
+		if err := rows.Scan(&id, &payload); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if match(string(payload)) {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	count := 0
+	for _, id := range ids {
+		if !queue.update("UPDATE channel_messages SET rejected_at = NULL, attempts = 0, available_at = now() WHERE id = $1", id) {
+			return count, fmt.Errorf("Failed to requeue message from pg://%v", queue.queue)
+		}
+		count++
+	}
+	if count > 0 {
+		queue.backend.db.Exec("SELECT pg_notify($1, $2)", pgQueueNotifyChannel, queue.queue)
+	}
+	return count, nil
+}
+
+func (queue *pgQueueConsumerChannel) PurgeRejectedBefore(cutoff time.Time) (int, error) {
+	result, err := queue.backend.db.Exec("DELETE FROM channel_messages WHERE queue = $1 AND rejected_at < $2", queue.queue, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+type pgTopicConsumerChannel struct {
+	backend   *PgBackend
+	topic     string
+	consumers []Consumer
+	listener  *pq.Listener
+	stopped   chan bool
+}
+
+// NewPgTopicConsumerChannel returns a ConsumerChannel that receives every
+// message published to `topic` with NewPgTopicPublisher while it is
+// listening, using Postgres LISTEN. As with Redis topics, messages published
+// while the consumer is disconnected are lost.
+func NewPgTopicConsumerChannel(topic string, backend *PgBackend) ConsumerChannel {
+	return &pgTopicConsumerChannel{backend: backend, topic: topic, consumers: []Consumer{}}
+}
+
+// ReturnAllUnacked is just here for API Compatibility with topics. It does
+// nothing
+func (topic *pgTopicConsumerChannel) ReturnAllUnacked() int {
+	return 0
+}
+
+// PurgeRejected is just here for API Compatibility with topics. It does
+// nothing
+func (topic *pgTopicConsumerChannel) PurgeRejected() int {
+	return 0
+}
+
+func (topic *pgTopicConsumerChannel) AddConsumer(consumer Consumer) bool {
+	topic.consumers = append(topic.consumers, consumer)
+	return true
+}
+
+func (topic *pgTopicConsumerChannel) StartConsuming() bool {
+	if topic.listener != nil {
+		// Already consuming
+		return false
+	}
+	listener := pq.NewListener(topic.backend.connectionString, 10*time.Second, time.Minute, nil)
+	if err := listener.Listen(topic.topic); err != nil {
+		log.Printf("Error listening on pg+topic://%v: %v", topic.topic, err.Error())
+		listener.Close()
+		return false
+	}
+	topic.listener = listener
+	topic.stopped = make(chan bool)
+	go topic.consume()
+	return true
+}
+
+func (topic *pgTopicConsumerChannel) consume() {
+	for {
+		var notification *pq.Notification
+		select {
+		case notification = <-topic.listener.Notify:
+		case <-topic.stopped:
+			return
+		}
+		if notification == nil {
+			// The connection was re-established, and anything published while
+			// it was down has been missed.
+			log.Printf("Reconnected to pg+topic://%v", topic.topic)
+			continue
+		}
+		payload, err := base64.StdEncoding.DecodeString(notification.Extra)
+		if err != nil {
+			log.Printf("Ignoring invalid message on pg+topic://%v: %v", topic.topic, err.Error())
+			continue
+		}
+		delivery := newTopicDelivery(string(payload), nil)
+		for _, consumer := range topic.consumers {
+			go consumer.Consume(countDelivery("pg+topic://"+topic.topic, delivery))
+		}
+	}
+}
+
+func (topic *pgTopicConsumerChannel) StopConsuming() bool {
+	if topic.listener != nil && topic.listener.Close() == nil {
+		close(topic.stopped)
+		return true
+	}
+	return false
+}
+
+func (topic *pgTopicConsumerChannel) Publisher() Publisher {
+	return NewPgTopicPublisher(topic.topic, topic.backend)
+}
@@ -0,0 +1,138 @@
+package channels_test
+
+import (
+	"fmt"
+	"github.com/notegio/openrelay/channels"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func getPgBackend(t *testing.T) *channels.PgBackend {
+	if os.Getenv("POSTGRES_HOST") == "" {
+		t.Fatalf("Please set the POSTGRES_HOST environment variable")
+	}
+	backend, err := channels.NewPgBackend(fmt.Sprintf(
+		"host=%v user=%v password=%v dbname=postgres sslmode=disable",
+		os.Getenv("POSTGRES_HOST"),
+		os.Getenv("POSTGRES_USER"),
+		os.Getenv("POSTGRES_PASSWORD"),
+	))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return backend
+}
+
+func TestParsePgClaimTimeout(t *testing.T) {
+	for query, expected := range map[string]time.Duration{
+		"":                      5 * time.Minute,
+		"claim=30s":             30 * time.Second,
+		"claim=1m&maxattempts=": time.Minute,
+	} {
+		values, _ := url.ParseQuery(query)
+		timeout, err := channels.ParsePgClaimTimeout(values)
+		if err != nil {
+			t.Errorf("Unexpected error parsing '%v': %v", query, err.Error())
+		} else if timeout != expected {
+			t.Errorf("Expected %v for '%v', got %v", expected, query, timeout)
+		}
+	}
+	for _, query := range []string{"claim=5", "claim=0s", "claim=-1m"} {
+		values, _ := url.ParseQuery(query)
+		if _, err := channels.ParsePgClaimTimeout(values); err == nil {
+			t.Errorf("Expected an error parsing '%v'", query)
+		}
+	}
+}
+
+func TestPgURIs(t *testing.T) {
+	if _, err := channels.PgConsumerFromURI("pg://queue?claim=x", nil); err == nil {
+		t.Errorf("Expected an error for an invalid claim timeout")
+	}
+	if _, err := channels.PgConsumerFromURI("queue://queue", nil); err == nil {
+		t.Errorf("Expected an error for a non-pg URI")
+	}
+	publisher, err := channels.PgPublisherFromURI("pg+topic://broadcast", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if fmt.Sprintf("%v", publisher) != "pg+topic://broadcast" {
+		t.Errorf("Unexpected publisher %v", publisher)
+	}
+	// The in-memory translator treats pg:// channels as mem:// channels
+	name := memName("pg_mem")
+	translator := channels.NewMemURITranslator()
+	consumerChannel, err := translator.ConsumerFromURI("pg://" + name)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer consumerChannel.StopConsuming()
+	deliveries := make(chan channels.Delivery, 1)
+	consumerChannel.AddConsumer(&deliveryConsumer{deliveries})
+	consumerChannel.StartConsuming()
+	memPublisher, _ := translator.PublisherFromURI("pg://" + name)
+	memPublisher.Publish("hello")
+	select {
+	case delivery := <-deliveries:
+		delivery.Ack()
+	case <-time.After(time.Second):
+		t.Errorf("Delivery did not arrive")
+	}
+}
+
+func TestPgQueue(t *testing.T) {
+	backend := getPgBackend(t)
+	consumerChannel, err := channels.PgConsumerFromURI("pg://test_pg_queue?maxattempts=2", backend)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	consumerChannel.PurgeRejected()
+	deliveries := make(chan channels.Delivery, 1)
+	consumerChannel.AddConsumer(&deliveryConsumer{deliveries})
+	consumerChannel.StartConsuming()
+	defer consumerChannel.StopConsuming()
+	consumerChannel.Publisher().Publish("test\x00payload")
+	for attempt := 1; attempt <= 2; attempt++ {
+		select {
+		case delivery := <-deliveries:
+			if delivery.Payload() != "test\x00payload" {
+				t.Errorf("Unexpected payload %#v", delivery.Payload())
+			}
+			if channels.DeliveryAttempts(delivery) != attempt {
+				t.Errorf("Expected attempt %v, got %v", attempt, channels.DeliveryAttempts(delivery))
+			}
+			delivery.Return()
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Attempt %v did not arrive", attempt)
+		}
+	}
+	counts, err := consumerChannel.(channels.Inspector).Counts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if counts[channels.RejectedList] != 1 {
+		t.Errorf("Expected the message to be rejected after 2 attempts, got %v", counts)
+	}
+}
+
+func TestPgTopic(t *testing.T) {
+	backend := getPgBackend(t)
+	consumerChannel := channels.NewPgTopicConsumerChannel("test_pg_topic", backend)
+	deliveries := make(chan channels.Delivery, 1)
+	consumerChannel.AddConsumer(&deliveryConsumer{deliveries})
+	if !consumerChannel.StartConsuming() {
+		t.Fatalf("Failed to start consuming")
+	}
+	defer consumerChannel.StopConsuming()
+	channels.NewPgTopicPublisher("test_pg_topic", backend).Publish("test\x00payload")
+	select {
+	case delivery := <-deliveries:
+		if delivery.Payload() != "test\x00payload" {
+			t.Errorf("Unexpected payload %#v", delivery.Payload())
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Delivery did not arrive")
+	}
+}
@@ -1,8 +1,12 @@
 package channels
 
 import (
+	"fmt"
 	"gopkg.in/redis.v3"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Publisher interface {
@@ -18,34 +22,149 @@ func NewRedisQueuePublisher(key string, client *redis.Client) Publisher {
 	return &redisQueuePublisher{key, client}
 }
 
+func (publisher *redisQueuePublisher) String() string {
+	return "queue://" + publisher.key
+}
+
 func (publisher *redisQueuePublisher) Publish(payload string) bool {
 	if len(payload) == 0 {
 		log.Printf("Trying to publish empty message. Skipping")
 		return false
 	}
-	return !redisErrIsNil(publisher.redisClient.LPush(publisher.key, payload))
+	return countPublish(publisher.String(), redisSucceeded(publisher.redisClient.LPush(publisher.key, payload)))
 }
 
 type redisTopicPublisher struct {
 	key         string
 	redisClient *redis.Client
+	history     HistoryOptions
 }
 
 func NewRedisTopicPublisher(key string, client *redis.Client) Publisher {
-	return &redisTopicPublisher{key, client}
+	return &redisTopicPublisher{key, client, HistoryOptions{}}
+}
+
+// NewReplayableTopicPublisher returns a Publisher for a Redis PubSub topic
+// that numbers each message and keeps a history of recent messages, as
+// described by `history`, for consumers to catch up from.
+func NewReplayableTopicPublisher(key string, client *redis.Client, history HistoryOptions) Publisher {
+	return &redisTopicPublisher{key, client, history}
+}
+
+func (publisher *redisTopicPublisher) String() string {
+	return "topic://" + publisher.key
 }
 
+// publishSequencedScript numbers a message, records it in the topic's
+// history and publishes it in one step, so that messages are published in
+// the order of their sequences even with several publishers. Otherwise a
+// consumer could see sequence N+1 before N and never replay N. The message's
+// envelope is assembled here as Wrap would, with the sequence header filled
+// in around the split header JSON from sequenceTemplate.
+var publishSequencedScript = redis.NewScript(`
+local sequence = redis.call('INCR', KEYS[1])
+local headers = ARGV[2] .. '"' .. sequence .. '"' .. ARGV[3]
+local length = string.len(headers)
+local varint = ''
+while length >= 128 do
+	varint = varint .. string.char(length % 128 + 128)
+	length = math.floor(length / 128)
+end
+local payload = ARGV[1] .. varint .. string.char(length) .. headers .. ARGV[4]
+redis.call('ZADD', KEYS[2], sequence, ARGV[5] .. payload)
+redis.call('PUBLISH', KEYS[3], payload)
+return sequence
+`)
+
 func (publisher *redisTopicPublisher) Publish(payload string) bool {
-	return !redisErrIsNil(publisher.redisClient.Publish(publisher.key, payload))
+	if !publisher.history.Enabled() {
+		return countPublish(publisher.String(), redisSucceeded(publisher.redisClient.Publish(publisher.key, payload)))
+	}
+	now := time.Now()
+	before, after, body := sequenceTemplate(payload)
+	result, err := publishSequencedScript.Run(
+		publisher.redisClient,
+		[]string{publisher.key + "::sequence", publisher.key + "::history", publisher.key},
+		[]string{envelopePrefix, before, after, body, historyEntry(now, "")},
+	).Result()
+	if err != nil {
+		log.Printf("Error publishing to %v: %v", publisher.String(), err.Error())
+		return countPublish(publisher.String(), false)
+	}
+	if sequence, ok := result.(int64); ok {
+		publisher.trimHistory(sequence, now)
+	}
+	return countPublish(publisher.String(), true)
+}
+
+// trimHistory drops messages that are beyond the history's length or age.
+// Entries are removed by score rather than rank, so publishers trimming the
+// same history concurrently can't remove more than they mean to.
+func (publisher *redisTopicPublisher) trimHistory(sequence int64, now time.Time) {
+	historyKey := publisher.key + "::history"
+	if publisher.history.Length > 0 && sequence > publisher.history.Length {
+		publisher.redisClient.ZRemRangeByScore(historyKey, "-inf", strconv.FormatInt(sequence-publisher.history.Length, 10))
+	}
+	if publisher.history.Age > 0 {
+		// Only the oldest entries need checking. Anything missed here will be
+		// caught by a later publish.
+		entries, err := publisher.redisClient.ZRangeWithScores(historyKey, 0, 99).Result()
+		if err != nil {
+			return
+		}
+		cutoff := now.Add(-publisher.history.Age)
+		stale := ""
+		for _, entry := range entries {
+			member, _ := entry.Member.(string)
+			if published, _ := parseHistoryEntry(member); published.After(cutoff) {
+				break
+			}
+			stale = strconv.FormatFloat(entry.Score, 'f', 0, 64)
+		}
+		if stale != "" {
+			publisher.redisClient.ZRemRangeByScore(historyKey, "-inf", stale)
+		}
+	}
 }
 
+// PublishError reports which of several publishers failed to publish a
+// message. Publishers that implement fmt.Stringer are identified by name,
+// others by their position.
+type PublishError struct {
+	Failed []string
+	Total  int
+}
+
+func (err *PublishError) Error() string {
+	return fmt.Sprintf("Failed to publish to %v of %v publishers: %v", len(err.Failed), err.Total, strings.Join(err.Failed, ", "))
+}
 
 type MultiPublisher []Publisher
 
+// PublishAll publishes `payload` to every publisher, carrying on past any
+// failures so that one bad publisher doesn't starve the rest. If any
+// publisher fails, the returned error is a *PublishError listing them.
+func (mp MultiPublisher) PublishAll(payload string) error {
+	failed := []string{}
+	for i, publisher := range mp {
+		if !publisher.Publish(payload) {
+			if name, ok := publisher.(fmt.Stringer); ok {
+				failed = append(failed, name.String())
+			} else {
+				failed = append(failed, fmt.Sprintf("publisher %v", i))
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return &PublishError{failed, len(mp)}
+	}
+	return nil
+}
+
 func (mp MultiPublisher) Publish(payload string) bool {
-	success := true
-	for _, publisher := range mp {
-		success = success && publisher.Publish(payload)
+	if err := mp.PublishAll(payload); err != nil {
+		log.Print(err.Error())
+		return false
 	}
-	return success
+	return true
 }
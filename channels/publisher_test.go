@@ -0,0 +1,39 @@
+package channels_test
+
+import (
+	"github.com/notegio/openrelay/channels"
+	"gopkg.in/redis.v3"
+	"net"
+	"testing"
+	"time"
+)
+
+// closedRedisClient returns a client for an address nothing is listening on
+func closedRedisClient(t *testing.T) *redis.Client {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return redis.NewClient(&redis.Options{
+		Addr:        addr,
+		DialTimeout: 100 * time.Millisecond,
+		MaxRetries:  0,
+	})
+}
+
+func TestRedisPublishUnreachable(t *testing.T) {
+	redisClient := closedRedisClient(t)
+	defer redisClient.Close()
+	for _, publisher := range []channels.Publisher{
+		channels.NewRedisQueuePublisher("test_queue", redisClient),
+		channels.NewRedisTopicPublisher("test_topic", redisClient),
+		channels.NewReplayableTopicPublisher("test_topic", redisClient, channels.HistoryOptions{Length: 10}),
+		channels.NewRedisStreamPublisher("test_stream", 0, redisClient),
+	} {
+		if publisher.Publish("payload") {
+			t.Errorf("Expected publish to %v to fail", publisher)
+		}
+	}
+}
@@ -41,24 +41,40 @@ type queueConsumerChannel struct {
 	readyKey         string
 	unackedKey       string
 	rejectedKey      string
+	attemptsKey      string
+	delayedKey       string
+	retry            RetryPolicy
 	consumingStopped chan bool
 	deliveryChan     chan Delivery
 	ackChan          chan bool
+	scheduled        bool
 }
 
 // NewQueueConsumerChannel returns a ConsumerChannel that uses Redis queues for
 // communication. Each message delivered through this ConsumerChannel will be
 // delivered to only one consumer, assuming the consumer Acks the message.
 func NewQueueConsumerChannel(channelName string, redisClient *redis.Client) ConsumerChannel {
+	return NewRetryQueueConsumerChannel(channelName, redisClient, RetryPolicy{})
+}
+
+// NewRetryQueueConsumerChannel returns a queue based ConsumerChannel where
+// Returned deliveries are retried according to `retry`. Delayed deliveries
+// are held in a sorted set until they are due, and the number of attempts
+// for each message is tracked in a hash.
+func NewRetryQueueConsumerChannel(channelName string, redisClient *redis.Client, retry RetryPolicy) ConsumerChannel {
 	return &queueConsumerChannel{
 		redisClient,
 		channelName,
 		channelName,
 		channelName + "::unacked",
 		channelName + "::rejected",
+		channelName + "::attempts",
+		channelName + "::delayed",
+		retry,
 		nil,
 		nil,
 		nil,
+		false,
 	}
 }
 
@@ -83,31 +99,8 @@ func (queue *queueConsumerChannel) ReturnAllUnacked() int {
 
 // PurgeRejected removes all rejected deliveries from the queue and returns the number of purged deliveries
 func (queue *queueConsumerChannel) PurgeRejected() int {
-	return queue.deleteRedisList(queue.rejectedKey)
-}
-
-// return number of deleted list items
-// https://www.redisgreen.net/blog/deleting-large-lists
-func (queue *queueConsumerChannel) deleteRedisList(key string) int {
-	llenResult := queue.redisClient.LLen(key)
-	total := int(llenResult.Val())
-	if total == 0 {
-		return 0 // nothing to do
-	}
-
-	// delete elements without blocking
-	for todo := total; todo > 0; todo -= purgeBatchSize {
-		// minimum of purgeBatchSize and todo
-		batchSize := purgeBatchSize
-		if batchSize > todo {
-			batchSize = todo
-		}
-
-		// remove one batch
-		queue.redisClient.LTrim(key, 0, int64(-1-batchSize))
-	}
-
-	return total
+	queue.redisClient.Del(rejectedAtKey(queue.rejectedKey))
+	return deleteRedisList(queue.redisClient, queue.rejectedKey)
 }
 
 func (queue *queueConsumerChannel) AddConsumer(consumer Consumer) bool {
@@ -118,7 +111,7 @@ func (queue *queueConsumerChannel) AddConsumer(consumer Consumer) bool {
 			time.Sleep(100 * time.Millisecond)
 		}
 		for delivery := range queue.deliveryChan {
-			consumer.Consume(delivery)
+			consumer.Consume(countDelivery("queue://"+queue.channelName, delivery))
 		}
 	}()
 	return true
@@ -134,6 +127,7 @@ func (queue *queueConsumerChannel) StartConsuming() bool {
 	}
 	queue.deliveryChan = make(chan Delivery, concurrency)
 	queue.ackChan = make(chan bool, concurrency)
+	watchDepth("queue://"+queue.channelName, queue)
 	go queue.consume()
 	go func(ackChan chan bool) {
 		lastTime := time.Now()
@@ -150,11 +144,67 @@ func (queue *queueConsumerChannel) StartConsuming() bool {
 	return true
 }
 
+// promoteDelayedScript moves a delayed delivery from the sorted set in KEYS[1]
+// to the ready list in KEYS[2] in one step, so a crash or error between the
+// two can't lose it. Only the caller that removes the delivery from the
+// sorted set pushes it, so consumers promoting at once don't duplicate it. If
+// the push fails the delivery is put back with its original due time.
+var promoteDelayedScript = redis.NewScript(`
+local due = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not due or redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+local pushed = redis.pcall('RPUSH', KEYS[2], ARGV[1])
+if type(pushed) == 'table' and pushed.err then
+	redis.call('ZADD', KEYS[1], due, ARGV[1])
+	return pushed
+end
+return 1
+`)
+
+// promoteDelayed moves delayed deliveries that are due back onto the ready
+// list.
+func (queue *queueConsumerChannel) promoteDelayed() {
+	due, err := queue.redisClient.ZRangeByScore(queue.delayedKey, redis.ZRangeByScore{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		Count: purgeBatchSize,
+	}).Result()
+	if err != nil {
+		log.Printf("Error checking delayed deliveries on %v: %v", queue.readyKey, err.Error())
+		return
+	}
+	for _, payload := range due {
+		if err := promoteDelayedScript.Run(queue.redisClient, []string{queue.delayedKey, queue.readyKey}, []string{payload}).Err(); err != nil {
+			log.Printf("Error promoting delayed delivery on %v: %v", queue.readyKey, err.Error())
+			return
+		}
+	}
+}
+
+// attempts returns the attempt number for a payload about to be delivered
+func (queue *queueConsumerChannel) attempts(payload string) int {
+	if !queue.retry.Enabled() {
+		return 1
+	}
+	previous, err := queue.redisClient.HGet(queue.attemptsKey, payload).Int64()
+	if err != nil {
+		return 1
+	}
+	return int(previous) + 1
+}
+
 func (queue *queueConsumerChannel) consume() {
+	lastPromoted := time.Time{}
 	for {
+		if (queue.retry.Backoff > 0 || queue.scheduled) && time.Since(lastPromoted) >= time.Second {
+			queue.promoteDelayed()
+			lastPromoted = time.Now()
+		}
 		result := queue.redisClient.BRPopLPush(queue.readyKey, queue.unackedKey, time.Second)
 		if !redisErrIsNil(result) {
-			queue.deliveryChan <- newQueueDelivery(result.Val(), queue.unackedKey, queue.rejectedKey, queue.readyKey, queue.redisClient, queue.ackChan)
+			payload := result.Val()
+			queue.deliveryChan <- newQueueDelivery(payload, queue.unackedKey, queue.rejectedKey, queue.readyKey, queue.redisClient, queue.ackChan, queue.retry, queue.attempts(payload))
 		}
 		if queue.consumingStopped != nil {
 			queue.consumingStopped <- true
@@ -166,7 +216,10 @@ func (queue *queueConsumerChannel) consume() {
 func (queue *queueConsumerChannel) StopConsuming() bool {
 	if queue.deliveryChan != nil && queue.consumingStopped == nil {
 		queue.consumingStopped = make(chan bool)
-		return <-queue.consumingStopped
+		unwatchDepth("queue://" + queue.channelName)
+		stopped := <-queue.consumingStopped
+		releaseBuffered(queue.deliveryChan)
+		return stopped
 	}
 	return false
 }
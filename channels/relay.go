@@ -1,6 +1,7 @@
 package channels
 
 import (
+	"context"
 	"log"
 	"github.com/notegio/openrelay/common"
 )
@@ -34,6 +35,9 @@ type Relay struct {
 	publishers      []Publisher
 	filter          RelayFilter
 	s               common.Semaphore
+	inFlight        *InFlight
+	lanes           *Lanes
+	key             KeyFunc
 }
 
 func (relay *Relay) Start() bool {
@@ -44,11 +48,55 @@ func (relay *Relay) Stop() bool {
 	return relay.consumerChannel.StopConsuming()
 }
 
+// Drain waits for deliveries the relay is working on to be published, until
+// `ctx` is done, then releases any that are left back to the source channel.
+func (relay *Relay) Drain(ctx context.Context) int {
+	return relay.inFlight.Drain(ctx)
+}
+
+// Shutdown stops consuming new deliveries and drains the relay
+func (relay *Relay) Shutdown(ctx context.Context) int {
+	return Shutdown(ctx, relay.consumerChannel, relay)
+}
+
 type RelayConsumer struct {
 	relay *Relay
 }
 
+// relayDelivery wraps the deliveries handed to a RelayFilter, keeping track
+// of whether the filter has already settled the delivery. This lets a filter
+// Return a delivery it couldn't process (for example, after an RPC error) so
+// it gets retried according to the channel's RetryPolicy, rather than having
+// the relay Ack it regardless.
+type relayDelivery struct {
+	Delivery
+	settled bool
+}
+
+func (delivery *relayDelivery) Ack() bool {
+	delivery.settled = true
+	return delivery.Delivery.Ack()
+}
+
+func (delivery *relayDelivery) Reject() bool {
+	delivery.settled = true
+	return delivery.Delivery.Reject()
+}
+
+func (delivery *relayDelivery) Return() bool {
+	delivery.settled = true
+	return delivery.Delivery.Return()
+}
+
+func (delivery *relayDelivery) Attempts() int {
+	return DeliveryAttempts(delivery.Delivery)
+}
+
 func (consumer *RelayConsumer) Consume(delivery Delivery) {
+	delivery, ok := consumer.relay.inFlight.Track(delivery)
+	if !ok {
+		return
+	}
 	defer func() {
 		if r := recover(); r != nil {
 			// Something panicked. Return in-flight messages before continuing.
@@ -56,16 +104,43 @@ func (consumer *RelayConsumer) Consume(delivery Delivery) {
 			panic(r)
 		}
 	}()
+	if consumer.relay.lanes != nil {
+		consumer.relay.lanes.Run(consumer.relay.key(delivery), func() {
+			consumer.relay.process(delivery)
+		})
+		return
+	}
 	consumer.relay.s.Acquire()
 	go func() {
 		defer consumer.relay.s.Release()
-		if consumer.relay.filter.Filter(delivery) {
-			for _, publisher := range consumer.relay.publishers {
-				go publisher.Publish(delivery.Payload())
+		consumer.relay.process(delivery)
+	}()
+}
+
+// process filters a delivery and publishes it if it passes, then settles it
+func (relay *Relay) process(delivery Delivery) {
+	tracked := &relayDelivery{Delivery: delivery}
+	if relay.filter.Filter(tracked) && !tracked.settled {
+		// Only ack once every publisher has the message. If any of them
+		// failed, return the delivery so it gets retried; publishers that
+		// succeeded will see the message again, but nothing gets lost.
+		if err := relay.publish(delivery); err != nil {
+			log.Printf("Returning delivery (attempt %v): %v", DeliveryAttempts(delivery), err.Error())
+			if !delivery.Return() {
+				log.Printf("Failed to return delivery")
 			}
+			return
 		}
-		delivery.Ack()
-	}()
+	}
+	if !tracked.settled && !delivery.Ack() {
+		log.Printf("Failed to ack delivery")
+	}
+}
+
+// publish sends a delivery to each of the relay's publishers, preserving its
+// headers, and returns a *PublishError if any of them failed.
+func (relay *Relay) publish(delivery Delivery) error {
+	return MultiPublisher(relay.publishers).PublishAll(Wrap(delivery.Headers(), delivery.Payload()))
 }
 
 func NewRelay(channel ConsumerChannel, publishers []Publisher, filter RelayFilter, concurrency int) Relay {
@@ -74,6 +149,33 @@ func NewRelay(channel ConsumerChannel, publishers []Publisher, filter RelayFilte
 		publishers,
 		filter,
 		make(common.Semaphore, concurrency),
+		NewInFlight(),
+		nil,
+		nil,
+	}
+	relay.consumerChannel.AddConsumer(&RelayConsumer{&relay})
+	return relay
+}
+
+// NewKeyedRelay returns a Relay that processes deliveries on `concurrency`
+// serial lanes, picking the lane for each delivery from its key. Deliveries
+// with the same key are filtered and published in the order they were
+// consumed, which a plain Relay doesn't guarantee, while deliveries with
+// different keys are still processed concurrently. Ordering is only as good
+// as the source channel's: queues hand deliveries over in order, topics
+// don't. If `key` is nil, this is the same as NewRelay.
+func NewKeyedRelay(channel ConsumerChannel, publishers []Publisher, filter RelayFilter, concurrency int, key KeyFunc) Relay {
+	if key == nil {
+		return NewRelay(channel, publishers, filter, concurrency)
+	}
+	relay := Relay{
+		channel,
+		publishers,
+		filter,
+		make(common.Semaphore, concurrency),
+		NewInFlight(),
+		NewLanes(concurrency),
+		key,
 	}
 	relay.consumerChannel.AddConsumer(&RelayConsumer{&relay})
 	return relay
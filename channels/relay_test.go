@@ -3,6 +3,7 @@ package channels_test
 import (
 	"github.com/notegio/openrelay/channels"
 	"testing"
+	"time"
 )
 
 type TestFilter struct{}
@@ -43,3 +44,57 @@ func TestInvertFilter(t *testing.T) {
 		t.Errorf("Message did not get relayed")
 	}
 }
+
+type failingPublisher struct {
+	attempts chan string
+}
+
+func (publisher *failingPublisher) Publish(payload string) bool {
+	publisher.attempts <- payload
+	return false
+}
+
+func TestMultiPublisherPartialFailure(t *testing.T) {
+	name := memName("multi_publisher")
+	consumerChannel := channels.NewMemQueueConsumerChannel(name)
+	failing := &failingPublisher{make(chan string, 1)}
+	err := channels.MultiPublisher{failing, channels.NewMemQueuePublisher(name)}.PublishAll("test")
+	publishErr, ok := err.(*channels.PublishError)
+	if !ok {
+		t.Fatalf("Expected PublishError, got %v", err)
+	}
+	if publishErr.Total != 2 || len(publishErr.Failed) != 1 || publishErr.Failed[0] != "publisher 0" {
+		t.Errorf("Unexpected failures: %v", publishErr.Error())
+	}
+	if counts, _ := consumerChannel.(channels.Inspector).Counts(); counts[channels.ReadyList] != 1 {
+		t.Errorf("Publishers after a failure should still be published to")
+	}
+}
+
+func TestRelayReturnsOnPublishFailure(t *testing.T) {
+	name := memName("mem_relay_publish_failure")
+	sourceChannel, err := channels.ConsumerFromURI("mem://"+name+"?maxattempts=2", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	destName := memName("mem_relay_publish_failure_dest")
+	failing := &failingPublisher{make(chan string, 2)}
+	relay := channels.NewRelay(sourceChannel, []channels.Publisher{channels.NewMemQueuePublisher(destName), failing}, &channels.IncludeAll{}, 1)
+	relay.Start()
+	defer relay.Stop()
+	sourceChannel.Publisher().Publish("test")
+	inspector := sourceChannel.(channels.Inspector)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if counts, _ := inspector.Counts(); counts[channels.RejectedList] == 1 {
+			if len(failing.attempts) != 2 {
+				t.Errorf("Expected 2 publish attempts, got %v", len(failing.attempts))
+			}
+			destCounts, _ := channels.NewMemQueueConsumerChannel(destName).(channels.Inspector).Counts()
+			if destCounts[channels.ReadyList] != 2 {
+				t.Errorf("Expected message to be published to healthy publisher on each attempt, got %v", destCounts[channels.ReadyList])
+			}
+			return
+		}
+	}
+	t.Errorf("Delivery that failed to publish was not rejected after retries")
+}
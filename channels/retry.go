@@ -0,0 +1,106 @@
+package channels
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy governs what happens when a delivery is Returned. Without a
+// policy a returned delivery goes straight back on the ready list. With one,
+// each return counts as a failed attempt: the delivery is held back for an
+// exponentially increasing delay before being redelivered, and once
+// MaxAttempts have failed it is rejected instead.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a delivery may be attempted before
+	// it is rejected. 0 means there is no limit.
+	MaxAttempts int
+	// Backoff is how long to wait before redelivering after the first failed
+	// attempt. The delay doubles with each subsequent attempt. 0 means
+	// deliveries are returned immediately.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between attempts. 0 means there is no cap.
+	MaxBackoff time.Duration
+}
+
+// Enabled indicates whether the policy changes the default Return behavior
+func (policy RetryPolicy) Enabled() bool {
+	return policy.MaxAttempts > 0 || policy.Backoff > 0
+}
+
+// Exhausted indicates whether a delivery that has failed `attempts` times
+// should be rejected rather than retried
+func (policy RetryPolicy) Exhausted(attempts int) bool {
+	return policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts
+}
+
+// Delay returns how long to wait before redelivering a message that has
+// failed `attempts` times.
+func (policy RetryPolicy) Delay(attempts int) time.Duration {
+	if policy.Backoff <= 0 || attempts < 1 {
+		return 0
+	}
+	delay := policy.Backoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if (policy.MaxBackoff > 0 && delay >= policy.MaxBackoff) || delay <= 0 {
+			// Stop doubling once we hit the cap, or before we overflow
+			return policy.MaxBackoff
+		}
+	}
+	if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+		return policy.MaxBackoff
+	}
+	return delay
+}
+
+// ParseRetryPolicy reads a RetryPolicy from the maxattempts, backoff and
+// maxbackoff parameters of a channel URI's query string.
+func ParseRetryPolicy(query url.Values) (RetryPolicy, error) {
+	policy := RetryPolicy{}
+	var err error
+	if maxAttempts := query.Get("maxattempts"); maxAttempts != "" {
+		if policy.MaxAttempts, err = strconv.Atoi(maxAttempts); err != nil {
+			return policy, err
+		}
+	}
+	if backoff := query.Get("backoff"); backoff != "" {
+		if policy.Backoff, err = time.ParseDuration(backoff); err != nil {
+			return policy, err
+		}
+	}
+	if maxBackoff := query.Get("maxbackoff"); maxBackoff != "" {
+		if policy.MaxBackoff, err = time.ParseDuration(maxBackoff); err != nil {
+			return policy, err
+		}
+	}
+	return policy, nil
+}
+
+// splitURIQuery separates a channel name from any query string that follows
+// it, eg. "name?maxattempts=5" becomes "name" and {maxattempts: 5}
+func splitURIQuery(nameAndQuery string) (string, url.Values, error) {
+	parts := strings.SplitN(nameAndQuery, "?", 2)
+	if len(parts) == 1 {
+		return parts[0], url.Values{}, nil
+	}
+	query, err := url.ParseQuery(parts[1])
+	return parts[0], query, err
+}
+
+// AttemptCounter is implemented by deliveries that know how many times they
+// have been attempted.
+type AttemptCounter interface {
+	// Attempts returns the attempt number of this delivery, starting at 1
+	Attempts() int
+}
+
+// DeliveryAttempts returns the attempt number of a delivery, or 1 if the
+// delivery does not track attempts.
+func DeliveryAttempts(delivery Delivery) int {
+	if counter, ok := delivery.(AttemptCounter); ok {
+		return counter.Attempts()
+	}
+	return 1
+}
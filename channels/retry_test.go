@@ -0,0 +1,103 @@
+package channels_test
+
+import (
+	"github.com/notegio/openrelay/channels"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := channels.RetryPolicy{MaxAttempts: 5, Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	for attempts, expected := range map[int]time.Duration{
+		0: 0,
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 5 * time.Second,
+		100: 5 * time.Second,
+	} {
+		if delay := policy.Delay(attempts); delay != expected {
+			t.Errorf("Attempt %v: expected %v, got %v", attempts, expected, delay)
+		}
+	}
+	if policy.Exhausted(4) || !policy.Exhausted(5) {
+		t.Errorf("Unexpected exhaustion")
+	}
+	if (channels.RetryPolicy{}).Enabled() {
+		t.Errorf("Empty policy should not be enabled")
+	}
+}
+
+func TestMemQueueRetry(t *testing.T) {
+	name := memName("mem_queue_retry")
+	consumerChannel, err := channels.ConsumerFromURI("mem://"+name+"?maxattempts=2&backoff=50ms", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer consumerChannel.StopConsuming()
+	deliveries := make(chan channels.Delivery)
+	consumerChannel.AddConsumer(&deliveryConsumer{deliveries})
+	consumerChannel.StartConsuming()
+	consumerChannel.Publisher().Publish("test")
+	delivery := <-deliveries
+	if attempts := channels.DeliveryAttempts(delivery); attempts != 1 {
+		t.Errorf("Expected attempt 1, got %v", attempts)
+	}
+	returned := time.Now()
+	delivery.Return()
+	delivery = <-deliveries
+	if time.Since(returned) < 50*time.Millisecond {
+		t.Errorf("Delivery was retried before backoff elapsed")
+	}
+	if attempts := channels.DeliveryAttempts(delivery); attempts != 2 {
+		t.Errorf("Expected attempt 2, got %v", attempts)
+	}
+	delivery.Return()
+	if purged := consumerChannel.PurgeRejected(); purged != 1 {
+		t.Errorf("Expected delivery to be rejected after 2 attempts")
+	}
+}
+
+type returnFilter struct {
+	retried chan bool
+}
+
+func (filter *returnFilter) Filter(delivery channels.Delivery) bool {
+	if channels.DeliveryAttempts(delivery) < 2 {
+		delivery.Return()
+		return false
+	}
+	filter.retried <- true
+	return true
+}
+
+func TestRelayReturnRetries(t *testing.T) {
+	name := memName("mem_relay_retry")
+	sourceChannel, err := channels.ConsumerFromURI("mem://"+name+"?backoff=10ms", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	destPublisher, destChannel := channels.MockChannel()
+	testConsumer := testConsumer{make(chan string), make(chan bool), make(chan bool)}
+	destChannel.AddConsumer(&testConsumer)
+	destChannel.StartConsuming()
+	filter := &returnFilter{make(chan bool)}
+	relay := channels.NewRelay(sourceChannel, []channels.Publisher{destPublisher}, &channels.InvertFilter{filter}, 1)
+	relay.Start()
+	defer relay.Stop()
+	sourceChannel.Publisher().Publish("test")
+	select {
+	case <-testConsumer.channel:
+		t.Errorf("Returned delivery should not have been relayed")
+	case <-filter.retried:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Delivery was not retried")
+	}
+	inspector := sourceChannel.(channels.Inspector)
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if counts, _ := inspector.Counts(); counts[channels.UnackedList] == 0 {
+			return
+		}
+	}
+	t.Errorf("Retried delivery was not acked")
+}
@@ -0,0 +1,601 @@
+package channels
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/redis.v3"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	streamPayloadField  = "payload"
+	defaultStreamGroup  = "openrelay"
+	defaultClaimTimeout = 5 * time.Minute
+)
+
+// StreamOptions configures a Redis Streams backed ConsumerChannel.
+type StreamOptions struct {
+	// Group is the consumer group deliveries are shared between. Each message
+	// is delivered to one consumer in the group.
+	Group string
+	// Consumer uniquely identifies this consumer within the group. Pending
+	// deliveries are owned by the consumer they were delivered to.
+	Consumer string
+	// ClaimTimeout is how long a delivery may remain unacknowledged before
+	// another consumer in the group claims it.
+	ClaimTimeout time.Duration
+	// MaxLen approximately caps the length of the stream when publishing. 0
+	// disables trimming.
+	MaxLen int64
+	// Retry governs what happens to returned deliveries, as for queues
+	Retry RetryPolicy
+}
+
+func defaultConsumerName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	return fmt.Sprintf("%v-%v", hostname, os.Getpid())
+}
+
+// ParseStreamURI parses a URI of the form
+//
+//    stream://name?group=fundcheck&consumer=host-1&claim=5m&maxlen=10000
+//
+// into the stream name and its options. Every parameter is optional, and
+// the retry parameters accepted by ParseRetryPolicy may also be given.
+func ParseStreamURI(uri string) (string, StreamOptions, error) {
+	options := StreamOptions{
+		Group:        defaultStreamGroup,
+		Consumer:     defaultConsumerName(),
+		ClaimTimeout: defaultClaimTimeout,
+	}
+	if !strings.HasPrefix(uri, "stream://") {
+		return "", options, errors.New("Must specify uri starting with stream://")
+	}
+	nameAndQuery := strings.SplitN(uri[len("stream://"):], "?", 2)
+	name := nameAndQuery[0]
+	if name == "" {
+		return "", options, errors.New("Stream URI must include a stream name")
+	}
+	if len(nameAndQuery) == 1 {
+		return name, options, nil
+	}
+	query, err := url.ParseQuery(nameAndQuery[1])
+	if err != nil {
+		return "", options, err
+	}
+	if group := query.Get("group"); group != "" {
+		options.Group = group
+	}
+	if consumer := query.Get("consumer"); consumer != "" {
+		options.Consumer = consumer
+	}
+	if claim := query.Get("claim"); claim != "" {
+		if options.ClaimTimeout, err = time.ParseDuration(claim); err != nil {
+			return "", options, err
+		}
+	}
+	if maxLen := query.Get("maxlen"); maxLen != "" {
+		if options.MaxLen, err = strconv.ParseInt(maxLen, 10, 64); err != nil {
+			return "", options, err
+		}
+	}
+	if options.Retry, err = ParseRetryPolicy(query); err != nil {
+		return "", options, err
+	}
+	return name, options, nil
+}
+
+type streamMessage struct {
+	id      string
+	payload string
+}
+
+// parseStreamMessages converts a list of [id, [field, value, ...]] entries
+// into streamMessages. Entries that have been trimmed from the stream come
+// back with nil fields and have an empty payload.
+func parseStreamMessages(val interface{}) []streamMessage {
+	entries, ok := val.([]interface{})
+	if !ok {
+		return nil
+	}
+	messages := []streamMessage{}
+	for _, entry := range entries {
+		pair, ok := entry.([]interface{})
+		if !ok || len(pair) != 2 {
+			continue
+		}
+		id, _ := pair[0].(string)
+		message := streamMessage{id: id}
+		fields, _ := pair[1].([]interface{})
+		for i := 0; i+1 < len(fields); i += 2 {
+			if field, _ := fields[i].(string); field == streamPayloadField {
+				message.payload, _ = fields[i+1].(string)
+			}
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// parseStreamReadReply extracts the messages from an XREADGROUP reply of the
+// form [[stream, [[id, [field, value, ...]], ...]]]
+func parseStreamReadReply(val interface{}) []streamMessage {
+	streams, ok := val.([]interface{})
+	if !ok {
+		return nil
+	}
+	messages := []streamMessage{}
+	for _, stream := range streams {
+		pair, ok := stream.([]interface{})
+		if !ok || len(pair) != 2 {
+			continue
+		}
+		messages = append(messages, parseStreamMessages(pair[1])...)
+	}
+	return messages
+}
+
+func streamAdd(redisClient *redis.Client, key string, maxLen int64, payload string) *redis.Cmd {
+	args := []interface{}{"XADD", key}
+	if maxLen > 0 {
+		args = append(args, "MAXLEN", "~", maxLen)
+	}
+	args = append(args, "*", streamPayloadField, payload)
+	cmd := redis.NewCmd(args...)
+	redisClient.Process(cmd)
+	return cmd
+}
+
+type redisStreamPublisher struct {
+	key         string
+	maxLen      int64
+	redisClient *redis.Client
+}
+
+// NewRedisStreamPublisher returns a Publisher that appends messages to the
+// Redis stream `key`, trimming the stream to approximately `maxLen` entries.
+// A `maxLen` of 0 disables trimming.
+func NewRedisStreamPublisher(key string, maxLen int64, client *redis.Client) Publisher {
+	return &redisStreamPublisher{key, maxLen, client}
+}
+
+func (publisher *redisStreamPublisher) String() string {
+	return "stream://" + publisher.key
+}
+
+func (publisher *redisStreamPublisher) Publish(payload string) bool {
+	if len(payload) == 0 {
+		log.Printf("Trying to publish empty message. Skipping")
+		return false
+	}
+	return countPublish(publisher.String(), redisSucceeded(streamAdd(publisher.redisClient, publisher.key, publisher.maxLen, payload)))
+}
+
+type streamDelivery struct {
+	envelope
+	id       string
+	payload  string
+	channel  *streamConsumerChannel
+	attempts int
+}
+
+func (delivery *streamDelivery) Attempts() int {
+	return delivery.attempts
+}
+
+func (delivery *streamDelivery) Ack() bool {
+	delivery.channel.clearAttempts(delivery.payload)
+	return delivery.channel.ack(delivery.id)
+}
+
+func (delivery *streamDelivery) Reject() bool {
+	delivery.channel.clearAttempts(delivery.payload)
+	recordRejection(delivery.channel.redisClient, delivery.channel.rejectedKey, delivery.payload)
+	if redisErrIsNil(delivery.channel.redisClient.LPush(delivery.channel.rejectedKey, delivery.payload)) {
+		return false
+	}
+	return delivery.channel.ack(delivery.id)
+}
+
+func (delivery *streamDelivery) Return() bool {
+	if delivery.channel.options.Retry.Enabled() {
+		return delivery.retryLater()
+	}
+	return delivery.Release()
+}
+
+// Release puts the delivery back on the stream to be delivered again,
+// without counting it as a failed attempt.
+func (delivery *streamDelivery) Release() bool {
+	return delivery.channel.requeue(delivery.id, delivery.payload)
+}
+
+// retryLater records a failed attempt, and either rejects the delivery if it
+// has run out of attempts or schedules it to be added back to the stream
+// after a backoff, as queueDelivery.retryLater does.
+func (delivery *streamDelivery) retryLater() bool {
+	stream := delivery.channel
+	if stream.options.Retry.Exhausted(delivery.attempts) {
+		log.Printf("Rejecting delivery on %v after %v attempts", stream.streamKey, delivery.attempts)
+		return delivery.Reject()
+	}
+	stream.redisClient.HSet(stream.attemptsKey(), delivery.payload, strconv.Itoa(delivery.attempts))
+	delay := stream.options.Retry.Delay(delivery.attempts)
+	if delay == 0 {
+		return stream.requeue(delivery.id, delivery.payload)
+	}
+	due := float64(time.Now().Add(delay).UnixNano())
+	if !redisSucceeded(stream.redisClient.ZAdd(stream.delayedKey(), redis.Z{Score: due, Member: delivery.payload})) {
+		return false
+	}
+	return stream.ack(delivery.id)
+}
+
+type streamConsumerChannel struct {
+	redisClient      *redis.Client
+	streamKey        string
+	rejectedKey      string
+	options          StreamOptions
+	consumingStopped chan bool
+	deliveryChan     chan Delivery
+}
+
+// NewStreamConsumerChannel returns a ConsumerChannel that reads from a Redis
+// stream as part of a consumer group. Each message is delivered to a single
+// consumer in the group, and remains pending against that consumer until it
+// is acknowledged. If a consumer goes away, its pending deliveries are
+// claimed by another consumer in the group once they have been idle for
+// longer than options.ClaimTimeout.
+func NewStreamConsumerChannel(streamName string, options StreamOptions, redisClient *redis.Client) ConsumerChannel {
+	if options.Group == "" {
+		options.Group = defaultStreamGroup
+	}
+	if options.Consumer == "" {
+		options.Consumer = defaultConsumerName()
+	}
+	if options.ClaimTimeout == 0 {
+		options.ClaimTimeout = defaultClaimTimeout
+	}
+	return &streamConsumerChannel{
+		redisClient,
+		streamName,
+		streamName + "::rejected",
+		options,
+		nil,
+		nil,
+	}
+}
+
+func (stream *streamConsumerChannel) ack(id string) bool {
+	cmd := redis.NewCmd("XACK", stream.streamKey, stream.options.Group, id)
+	stream.redisClient.Process(cmd)
+	if redisErrIsNil(cmd) {
+		return false
+	}
+	acked, _ := cmd.Val().(int64)
+	return acked == 1
+}
+
+func (stream *streamConsumerChannel) attemptsKey() string {
+	return stream.streamKey + "::attempts"
+}
+
+func (stream *streamConsumerChannel) delayedKey() string {
+	return stream.streamKey + "::delayed"
+}
+
+// attempts returns the attempt number for a payload about to be delivered
+func (stream *streamConsumerChannel) attempts(payload string) int {
+	if !stream.options.Retry.Enabled() {
+		return 1
+	}
+	previous, err := stream.redisClient.HGet(stream.attemptsKey(), payload).Int64()
+	if err != nil {
+		return 1
+	}
+	return int(previous) + 1
+}
+
+// clearAttempts forgets the attempt count for a delivery that has been
+// settled
+func (stream *streamConsumerChannel) clearAttempts(payload string) {
+	if stream.options.Retry.Enabled() {
+		stream.redisClient.HDel(stream.attemptsKey(), payload)
+	}
+}
+
+// promoteDelayedStreamScript is promoteDelayedScript for streams. It moves a
+// delayed delivery from the sorted set in KEYS[1] to the stream in KEYS[2],
+// trimming the stream to about ARGV[3] entries unless that is 0.
+var promoteDelayedStreamScript = redis.NewScript(`
+local due = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not due or redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+local added
+if tonumber(ARGV[3]) > 0 then
+	added = redis.pcall('XADD', KEYS[2], 'MAXLEN', '~', ARGV[3], '*', ARGV[2], ARGV[1])
+else
+	added = redis.pcall('XADD', KEYS[2], '*', ARGV[2], ARGV[1])
+end
+if type(added) == 'table' and added.err then
+	redis.call('ZADD', KEYS[1], due, ARGV[1])
+	return added
+end
+return 1
+`)
+
+// promoteDelayed adds delayed deliveries that are due back to the stream
+func (stream *streamConsumerChannel) promoteDelayed() {
+	due, err := stream.redisClient.ZRangeByScore(stream.delayedKey(), redis.ZRangeByScore{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		Count: purgeBatchSize,
+	}).Result()
+	if err != nil {
+		log.Printf("Error checking delayed deliveries on %v: %v", stream.streamKey, err.Error())
+		return
+	}
+	for _, payload := range due {
+		err := promoteDelayedStreamScript.Run(
+			stream.redisClient,
+			[]string{stream.delayedKey(), stream.streamKey},
+			[]string{payload, streamPayloadField, strconv.FormatInt(stream.options.MaxLen, 10)},
+		).Err()
+		if err != nil {
+			log.Printf("Error promoting delayed delivery on %v: %v", stream.streamKey, err.Error())
+			return
+		}
+	}
+}
+
+func (stream *streamConsumerChannel) requeue(id, payload string) bool {
+	if redisErrIsNil(streamAdd(stream.redisClient, stream.streamKey, stream.options.MaxLen, payload)) {
+		return false
+	}
+	return stream.ack(id)
+}
+
+func (stream *streamConsumerChannel) createGroup() error {
+	cmd := redis.NewCmd("XGROUP", "CREATE", stream.streamKey, stream.options.Group, "0", "MKSTREAM")
+	stream.redisClient.Process(cmd)
+	if err := cmd.Err(); err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+// pending returns the IDs of up to `count` deliveries pending in the group.
+// If consumer is not empty, only deliveries owned by that consumer are
+// returned. If minIdle is not zero, only deliveries that have been idle for
+// at least minIdle are returned.
+func (stream *streamConsumerChannel) pending(consumer string, count int, minIdle time.Duration) ([]string, error) {
+	cmd := redis.NewCmd("XPENDING", stream.streamKey, stream.options.Group, "-", "+", count)
+	if consumer != "" {
+		cmd = redis.NewCmd("XPENDING", stream.streamKey, stream.options.Group, "-", "+", count, consumer)
+	}
+	stream.redisClient.Process(cmd)
+	if err := cmd.Err(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	entries, _ := cmd.Val().([]interface{})
+	ids := []string{}
+	for _, entry := range entries {
+		// Each entry is [id, consumer, idle milliseconds, delivery count]
+		fields, ok := entry.([]interface{})
+		if !ok || len(fields) < 3 {
+			continue
+		}
+		id, _ := fields[0].(string)
+		idle, _ := fields[2].(int64)
+		if time.Duration(idle)*time.Millisecond >= minIdle {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// claimStale takes ownership of deliveries that have been pending on any
+// consumer for longer than the claim timeout, and delivers them to this
+// consumer.
+func (stream *streamConsumerChannel) claimStale() {
+	ids, err := stream.pending("", purgeBatchSize, stream.options.ClaimTimeout)
+	if err != nil {
+		log.Printf("Error checking pending deliveries on %v: %v", stream.streamKey, err.Error())
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+	args := []interface{}{
+		"XCLAIM",
+		stream.streamKey,
+		stream.options.Group,
+		stream.options.Consumer,
+		int64(stream.options.ClaimTimeout / time.Millisecond),
+	}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	cmd := redis.NewCmd(args...)
+	stream.redisClient.Process(cmd)
+	if redisErrIsNil(cmd) || cmd.Err() != nil {
+		return
+	}
+	claimed := make(map[string]struct{})
+	for _, message := range parseStreamMessages(cmd.Val()) {
+		claimed[message.id] = struct{}{}
+		if message.payload == "" {
+			// The message was trimmed from the stream before we could claim it, so
+			// there's nothing left to deliver.
+			stream.ack(message.id)
+			continue
+		}
+		log.Printf("Claimed stale delivery %v on %v", message.id, stream.streamKey)
+		stream.deliveryChan <- &streamDelivery{openEnvelope(message.payload), message.id, message.payload, stream, stream.attempts(message.payload)}
+	}
+	for _, id := range ids {
+		if _, ok := claimed[id]; ok {
+			continue
+		}
+		// Before Redis 7, XCLAIM replies with a bare nil for trimmed messages,
+		// which parseStreamMessages drops. Ids can also be missing because
+		// another consumer claimed them first, so only ack them if they are
+		// gone from the stream.
+		if stream.trimmed(id) {
+			stream.ack(id)
+		}
+	}
+}
+
+// trimmed returns true if the message `id` is no longer in the stream
+func (stream *streamConsumerChannel) trimmed(id string) bool {
+	cmd := redis.NewCmd("XRANGE", stream.streamKey, id, id, "COUNT", 1)
+	stream.redisClient.Process(cmd)
+	if cmd.Err() != nil && cmd.Err() != redis.Nil {
+		log.Printf("Error checking for %v on %v: %v", id, stream.streamKey, cmd.Err().Error())
+		return false
+	}
+	entries, _ := cmd.Val().([]interface{})
+	return len(entries) == 0
+}
+
+// ReturnAllUnacked re-publishes every delivery pending on this consumer and
+// acknowledges the originals, returning the number of returned deliveries.
+func (stream *streamConsumerChannel) ReturnAllUnacked() int {
+	count, err := stream.returnPending(stream.options.Consumer)
+	if err != nil {
+		log.Printf("Error returning unacked deliveries on %v: %v", stream.streamKey, err.Error())
+	}
+	return count
+}
+
+// returnPending re-publishes every delivery pending on `consumer` and
+// acknowledges the originals.
+func (stream *streamConsumerChannel) returnPending(consumer string) (int, error) {
+	count := 0
+	for {
+		ids, err := stream.pending(consumer, purgeBatchSize, 0)
+		if err != nil || len(ids) == 0 {
+			return count, err
+		}
+		args := []interface{}{"XCLAIM", stream.streamKey, stream.options.Group, stream.options.Consumer, 0}
+		for _, id := range ids {
+			args = append(args, id)
+		}
+		cmd := redis.NewCmd(args...)
+		stream.redisClient.Process(cmd)
+		if err := cmd.Err(); err != nil {
+			return count, err
+		}
+		for _, message := range parseStreamMessages(cmd.Val()) {
+			if message.payload == "" {
+				stream.ack(message.id)
+			} else if stream.requeue(message.id, message.payload) {
+				count++
+			} else {
+				return count, fmt.Errorf("Failed to return delivery %v", message.id)
+			}
+		}
+	}
+}
+
+// PurgeRejected removes all rejected deliveries from the stream and returns
+// the number of purged deliveries
+func (stream *streamConsumerChannel) PurgeRejected() int {
+	stream.redisClient.Del(rejectedAtKey(stream.rejectedKey))
+	return deleteRedisList(stream.redisClient, stream.rejectedKey)
+}
+
+func (stream *streamConsumerChannel) AddConsumer(consumer Consumer) bool {
+	go func() {
+		for stream.deliveryChan == nil {
+			// StartConsuming hasn't been called yet, so we need to wait until the
+			// deliveryChan appears
+			time.Sleep(100 * time.Millisecond)
+		}
+		for delivery := range stream.deliveryChan {
+			consumer.Consume(countDelivery("stream://"+stream.streamKey, delivery))
+		}
+	}()
+	return true
+}
+
+func (stream *streamConsumerChannel) StartConsuming() bool {
+	if stream.deliveryChan != nil {
+		return false // already consuming
+	}
+	if err := stream.createGroup(); err != nil {
+		log.Printf("Error creating consumer group %v on %v: %v", stream.options.Group, stream.streamKey, err.Error())
+		return false
+	}
+	concurrency, err := strconv.Atoi(os.Getenv("CONCURRENCY"))
+	if err != nil {
+		concurrency = prefetchLimit
+	}
+	stream.deliveryChan = make(chan Delivery, concurrency)
+	watchDepth("stream://"+stream.streamKey, stream)
+	go stream.consume()
+	return true
+}
+
+func (stream *streamConsumerChannel) consume() {
+	claimInterval := stream.options.ClaimTimeout / 2
+	if claimInterval < time.Second {
+		claimInterval = time.Second
+	}
+	// Check for stale deliveries as soon as we start, in case we're replacing a
+	// consumer that crashed.
+	lastClaim := time.Now().Add(-claimInterval)
+	lastPromoted := time.Time{}
+	for {
+		if time.Since(lastClaim) >= claimInterval {
+			stream.claimStale()
+			lastClaim = time.Now()
+		}
+		if stream.options.Retry.Backoff > 0 && time.Since(lastPromoted) >= time.Second {
+			stream.promoteDelayed()
+			lastPromoted = time.Now()
+		}
+		cmd := redis.NewCmd(
+			"XREADGROUP",
+			"GROUP", stream.options.Group, stream.options.Consumer,
+			"COUNT", 1,
+			"BLOCK", int64(time.Second/time.Millisecond),
+			"STREAMS", stream.streamKey, ">",
+		)
+		stream.redisClient.Process(cmd)
+		if !redisErrIsNil(cmd) && cmd.Err() == nil {
+			for _, message := range parseStreamReadReply(cmd.Val()) {
+				stream.deliveryChan <- &streamDelivery{openEnvelope(message.payload), message.id, message.payload, stream, stream.attempts(message.payload)}
+			}
+		}
+		if stream.consumingStopped != nil {
+			stream.consumingStopped <- true
+			return
+		}
+	}
+}
+
+func (stream *streamConsumerChannel) StopConsuming() bool {
+	if stream.deliveryChan != nil && stream.consumingStopped == nil {
+		stream.consumingStopped = make(chan bool)
+		unwatchDepth("stream://" + stream.streamKey)
+		stopped := <-stream.consumingStopped
+		releaseBuffered(stream.deliveryChan)
+		return stopped
+	}
+	return false
+}
+
+func (stream *streamConsumerChannel) Publisher() Publisher {
+	return NewRedisStreamPublisher(stream.streamKey, stream.options.MaxLen, stream.redisClient)
+}
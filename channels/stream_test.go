@@ -0,0 +1,238 @@
+package channels_test
+
+import (
+	"github.com/notegio/openrelay/channels"
+	"gopkg.in/redis.v3"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseStreamURI(t *testing.T) {
+	name, options, err := channels.ParseStreamURI("stream://orders?group=fundcheck&consumer=fc-1&claim=30s&maxlen=1000")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if name != "orders" {
+		t.Errorf("Unexpected stream name '%v'", name)
+	}
+	if options.Group != "fundcheck" {
+		t.Errorf("Unexpected group '%v'", options.Group)
+	}
+	if options.Consumer != "fc-1" {
+		t.Errorf("Unexpected consumer '%v'", options.Consumer)
+	}
+	if options.ClaimTimeout != 30*time.Second {
+		t.Errorf("Unexpected claim timeout '%v'", options.ClaimTimeout)
+	}
+	if options.MaxLen != 1000 {
+		t.Errorf("Unexpected maxlen '%v'", options.MaxLen)
+	}
+}
+
+func TestParseStreamURIDefaults(t *testing.T) {
+	name, options, err := channels.ParseStreamURI("stream://orders")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if name != "orders" {
+		t.Errorf("Unexpected stream name '%v'", name)
+	}
+	if options.Group == "" || options.Consumer == "" || options.ClaimTimeout == 0 {
+		t.Errorf("Expected default options, got %#v", options)
+	}
+	if options.MaxLen != 0 {
+		t.Errorf("Unexpected maxlen '%v'", options.MaxLen)
+	}
+}
+
+func TestParseStreamURIInvalid(t *testing.T) {
+	for _, uri := range []string{"stream://", "stream://orders?claim=soon", "stream://orders?maxlen=lots", "queue://orders"} {
+		if _, _, err := channels.ParseStreamURI(uri); err == nil {
+			t.Errorf("Expected error parsing '%v'", uri)
+		}
+	}
+}
+
+func redisStreamChannel(t *testing.T) (*redis.Client, channels.Publisher, channels.ConsumerChannel) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		t.Errorf("Please set the REDIS_URL environment variable")
+		return nil, nil, nil
+	}
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisURL,
+	})
+	redisClient.Del("test_stream", "test_stream::rejected")
+	consumerChannel, err := channels.ConsumerFromURI("stream://test_stream?group=test&consumer=test", redisClient)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return redisClient, consumerChannel.Publisher(), consumerChannel
+}
+
+func TestRedisStreamChannelSend(t *testing.T) {
+	redisClient, publisher, consumerChannel := redisStreamChannel(t)
+	if redisClient == nil {
+		return
+	}
+	defer consumerChannel.StopConsuming()
+	ChannelSendTest(publisher, consumerChannel, 0, t)
+}
+func TestRedisStreamReturnUnacked(t *testing.T) {
+	redisClient, publisher, consumerChannel := redisStreamChannel(t)
+	if redisClient == nil {
+		return
+	}
+	defer consumerChannel.StopConsuming()
+	ReturnUnackedTest(publisher, consumerChannel, 0, t)
+}
+func TestRedisStreamAck(t *testing.T) {
+	redisClient, publisher, consumerChannel := redisStreamChannel(t)
+	if redisClient == nil {
+		return
+	}
+	defer consumerChannel.StopConsuming()
+	AckTest(publisher, consumerChannel, 0, t)
+}
+func TestRedisStreamReject(t *testing.T) {
+	redisClient, publisher, consumerChannel := redisStreamChannel(t)
+	if redisClient == nil {
+		return
+	}
+	defer consumerChannel.StopConsuming()
+	RejectTest(publisher, consumerChannel, 0, t)
+}
+
+func TestRedisStreamClaimStale(t *testing.T) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		t.Errorf("Please set the REDIS_URL environment variable")
+		return
+	}
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisURL,
+	})
+	redisClient.Del("test_stream_claim")
+	deadChannel := channels.NewStreamConsumerChannel("test_stream_claim", channels.StreamOptions{Group: "test", Consumer: "dead", ClaimTimeout: time.Second}, redisClient)
+	deadDeliveries := make(chan channels.Delivery, 1)
+	deadChannel.AddConsumer(&deliveryConsumer{deadDeliveries})
+	deadChannel.StartConsuming()
+	deadChannel.Publisher().Publish("test")
+	<-deadDeliveries
+	// The dead consumer never acks, so a live consumer should pick the message
+	// up once the claim timeout expires.
+	deadChannel.StopConsuming()
+	liveChannel := channels.NewStreamConsumerChannel("test_stream_claim", channels.StreamOptions{Group: "test", Consumer: "live", ClaimTimeout: time.Second}, redisClient)
+	liveDeliveries := make(chan channels.Delivery, 1)
+	liveChannel.AddConsumer(&deliveryConsumer{liveDeliveries})
+	liveChannel.StartConsuming()
+	defer liveChannel.StopConsuming()
+	select {
+	case delivery := <-liveDeliveries:
+		if delivery.Payload() != "test" {
+			t.Errorf("Unexpected value '%v'", delivery.Payload())
+		}
+		if !delivery.Ack() {
+			t.Errorf("Ack failed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Stale delivery was not claimed")
+	}
+}
+
+func TestRedisStreamClaimTrimmed(t *testing.T) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		t.Errorf("Please set the REDIS_URL environment variable")
+		return
+	}
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisURL,
+	})
+	redisClient.Del("test_stream_claim_trimmed")
+	deadChannel := channels.NewStreamConsumerChannel("test_stream_claim_trimmed", channels.StreamOptions{Group: "test", Consumer: "dead", ClaimTimeout: time.Second}, redisClient)
+	deadDeliveries := make(chan channels.Delivery, 1)
+	deadChannel.AddConsumer(&deliveryConsumer{deadDeliveries})
+	deadChannel.StartConsuming()
+	deadChannel.Publisher().Publish("test")
+	<-deadDeliveries
+	deadChannel.StopConsuming()
+	// Remove the message from the stream while it is still pending, as
+	// trimming would
+	entries := redis.NewCmd("XRANGE", "test_stream_claim_trimmed", "-", "+")
+	redisClient.Process(entries)
+	for _, entry := range entries.Val().([]interface{}) {
+		redisClient.Process(redis.NewCmd("XDEL", "test_stream_claim_trimmed", entry.([]interface{})[0]))
+	}
+	liveChannel := channels.NewStreamConsumerChannel("test_stream_claim_trimmed", channels.StreamOptions{Group: "test", Consumer: "live", ClaimTimeout: time.Second}, redisClient)
+	liveDeliveries := make(chan channels.Delivery, 1)
+	liveChannel.AddConsumer(&deliveryConsumer{liveDeliveries})
+	liveChannel.StartConsuming()
+	defer liveChannel.StopConsuming()
+	select {
+	case delivery := <-liveDeliveries:
+		t.Errorf("Unexpected delivery '%v'", delivery.Payload())
+	case <-time.After(3 * time.Second):
+	}
+	pending := redis.NewCmd("XPENDING", "test_stream_claim_trimmed", "test")
+	redisClient.Process(pending)
+	if summary, ok := pending.Val().([]interface{}); !ok || len(summary) == 0 || summary[0] != int64(0) {
+		t.Errorf("Expected the trimmed message to be acked, got %v", pending.Val())
+	}
+}
+
+func TestParseStreamURIRetry(t *testing.T) {
+	_, options, err := channels.ParseStreamURI("stream://orders?maxattempts=3&backoff=1s&maxbackoff=1m")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if options.Retry != (channels.RetryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: time.Minute}) {
+		t.Errorf("Unexpected retry policy %#v", options.Retry)
+	}
+	if _, _, err := channels.ParseStreamURI("stream://orders?backoff=soon"); err == nil {
+		t.Errorf("Expected error parsing an invalid backoff")
+	}
+}
+
+func TestRedisStreamRetry(t *testing.T) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		t.Errorf("Please set the REDIS_URL environment variable")
+		return
+	}
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisURL,
+	})
+	redisClient.Del("test_stream_retry", "test_stream_retry::rejected", "test_stream_retry::attempts", "test_stream_retry::delayed")
+	consumerChannel, err := channels.ConsumerFromURI("stream://test_stream_retry?group=test&consumer=test&maxattempts=2&backoff=50ms", redisClient)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer consumerChannel.StopConsuming()
+	deliveries := make(chan channels.Delivery)
+	consumerChannel.AddConsumer(&deliveryConsumer{deliveries})
+	consumerChannel.StartConsuming()
+	consumerChannel.Publisher().Publish("test")
+	delivery := <-deliveries
+	if attempts := channels.DeliveryAttempts(delivery); attempts != 1 {
+		t.Errorf("Expected attempt 1, got %v", attempts)
+	}
+	returned := time.Now()
+	delivery.Return()
+	select {
+	case delivery = <-deliveries:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Returned delivery was not redelivered")
+	}
+	if time.Since(returned) < 50*time.Millisecond {
+		t.Errorf("Delivery was retried before backoff elapsed")
+	}
+	if attempts := channels.DeliveryAttempts(delivery); attempts != 2 {
+		t.Errorf("Expected attempt 2, got %v", attempts)
+	}
+	delivery.Return()
+	if purged := consumerChannel.PurgeRejected(); purged != 1 {
+		t.Errorf("Expected delivery to be rejected after 2 attempts")
+	}
+}
@@ -2,9 +2,12 @@ package channels
 
 //TODO: Test channels!
 import (
+	"errors"
 	"gopkg.in/redis.v3"
-	// "time"
-	// "fmt"
+	"log"
+	"net"
+	"strconv"
+	"time"
 )
 
 type topicConsumerChannel struct {
@@ -13,6 +16,10 @@ type topicConsumerChannel struct {
 	channelName      string
 	consumers        []Consumer
 	consumingStopped bool
+	history          HistoryOptions
+	lastSeen         int64
+	replayedThrough  int64
+	resuming         bool
 }
 
 // NewTopicConsumerChannel returns a ConsumerChannel that uses Redis PubSub for
@@ -22,11 +29,24 @@ type topicConsumerChannel struct {
 // undelivered. Consumers may Ack or Reject the messages, but this is a no-op.
 func NewTopicConsumerChannel(channelName string, redisClient *redis.Client) ConsumerChannel {
 	return &topicConsumerChannel{
-		redisClient,
-		nil,
-		channelName,
-		[]Consumer{},
-		false,
+		redisClient: redisClient,
+		channelName: channelName,
+		consumers:   []Consumer{},
+	}
+}
+
+// NewReplayableTopicConsumerChannel returns a ConsumerChannel for a topic
+// published with NewReplayableTopicPublisher. It works like
+// NewTopicConsumerChannel, except that whenever its subscription is
+// re-established it replays the messages it missed from the topic's history,
+// so that messages are only lost if the consumer was away for longer than
+// the history covers. The returned channel also implements TopicHistory.
+func NewReplayableTopicConsumerChannel(channelName string, redisClient *redis.Client, history HistoryOptions) ConsumerChannel {
+	return &topicConsumerChannel{
+		redisClient: redisClient,
+		channelName: channelName,
+		consumers:   []Consumer{},
+		history:     history,
 	}
 }
 
@@ -63,15 +83,125 @@ func (topic *topicConsumerChannel) StartConsuming() bool {
 
 func (topic *topicConsumerChannel) consume() {
 	for {
-		msg, err := topic.pubsub.ReceiveMessage()
-		if err == nil {
-			for _, consumer := range topic.consumers {
-				go consumer.Consume(newTopicDelivery(msg.Payload, topic.redisClient))
+		msgi, err := topic.pubsub.ReceiveTimeout(5 * time.Second)
+		if err != nil {
+			if err.Error() == "redis: client is closed" {
+				return
 			}
-		} else if err.Error() == "redis: client is closed" {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				// Nothing has been published for a while. Make sure the
+				// connection is still alive.
+				topic.pubsub.Ping("")
+			} else {
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+		switch msg := msgi.(type) {
+		case *redis.Subscription:
+			// We get one of these when we first subscribe, and again each time
+			// the connection drops and the subscription is re-established.
+			if msg.Kind == "subscribe" {
+				topic.catchUp()
+			}
+		case *redis.Message:
+			topic.deliver(newTopicDelivery(msg.Payload, topic.redisClient))
+		}
+	}
+}
+
+// deliver passes `delivery` to every consumer, skipping messages that have
+// already been delivered by catchUp
+func (topic *topicConsumerChannel) deliver(delivery *topicDelivery) {
+	if sequence, ok := Sequence(delivery); ok {
+		if sequence <= topic.replayedThrough {
 			return
 		}
+		if sequence > topic.lastSeen {
+			topic.lastSeen = sequence
+		}
+	}
+	for _, consumer := range topic.consumers {
+		go consumer.Consume(countDelivery("topic://"+topic.channelName, delivery))
+	}
+}
+
+// catchUp delivers any messages from the topic's history that were
+// published after the last one this channel saw. The first time a channel
+// without a resume point subscribes it has nothing to catch up on, so it
+// just notes where the topic's sequence stands. The sequence is read after
+// subscribing, so messages up to it may also arrive live; those are still
+// delivered, as nothing has been replayed yet.
+func (topic *topicConsumerChannel) catchUp() {
+	if !topic.history.Enabled() {
+		return
+	}
+	if !topic.resuming {
+		topic.resuming = true
+		sequence, err := topic.redisClient.Get(topic.channelName + "::sequence").Int64()
+		if err != nil && err != redis.Nil {
+			log.Printf("Error reading sequence for topic://%v: %v", topic.channelName, err.Error())
+		}
+		if sequence > topic.lastSeen {
+			topic.lastSeen = sequence
+		}
+		return
+	}
+	deliveries, err := topic.Since(topic.lastSeen)
+	if _, ok := err.(*HistoryTruncatedError); ok {
+		// Replay what's left, but consumers have missed messages
+		log.Printf("Missed messages on topic://%v: %v", topic.channelName, err.Error())
+	} else if err != nil {
+		log.Printf("Error replaying topic://%v: %v", topic.channelName, err.Error())
+		return
+	}
+	for _, delivery := range deliveries {
+		topic.deliver(delivery.(*topicDelivery))
+	}
+	topic.replayedThrough = topic.lastSeen
+}
+
+// Since returns the messages in the topic's history after `sequence`
+func (topic *topicConsumerChannel) Since(sequence int64) ([]Delivery, error) {
+	if !topic.history.Enabled() {
+		return nil, errors.New("topic://" + topic.channelName + " does not keep a history")
+	}
+	// Messages are sequenced and recorded in one step, so every message up to
+	// the sequence read here was in the history before the history is read.
+	latest, err := topic.redisClient.Get(topic.channelName + "::sequence").Int64()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	entries, err := topic.redisClient.ZRangeByScoreWithScores(topic.channelName+"::history", redis.ZRangeByScore{
+		Min: "(" + strconv.FormatInt(sequence, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	first := int64(0)
+	deliveries := make([]Delivery, len(entries))
+	for i, entry := range entries {
+		if i == 0 {
+			first = int64(entry.Score)
+		}
+		entryString, _ := entry.Member.(string)
+		_, payload := parseHistoryEntry(entryString)
+		deliveries[i] = newTopicDelivery(payload, topic.redisClient)
 	}
+	return deliveries, checkTruncated("topic://"+topic.channelName, sequence, first, latest)
+}
+
+// ResumeFrom sets the sequence of the last message this channel has seen, so
+// that it will replay anything published after it once subscribed.
+func (topic *topicConsumerChannel) ResumeFrom(sequence int64) {
+	topic.lastSeen = sequence
+	topic.resuming = true
+}
+
+// HistoryEnabled returns true if the topic keeps a history
+func (topic *topicConsumerChannel) HistoryEnabled() bool {
+	return topic.history.Enabled()
 }
 
 func (topic *topicConsumerChannel) StopConsuming() bool {
@@ -83,5 +213,5 @@ func (topic *topicConsumerChannel) StopConsuming() bool {
 }
 
 func (topic *topicConsumerChannel) Publisher() Publisher {
-	return NewRedisTopicPublisher(topic.channelName, topic.redisClient)
+	return NewReplayableTopicPublisher(topic.channelName, topic.redisClient, topic.history)
 }
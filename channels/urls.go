@@ -7,26 +7,96 @@ import (
 )
 
 func ConsumerFromURI(uri string, redisClient *redis.Client) (ConsumerChannel, error) {
-	if strings.HasPrefix(uri, "topic://") {
-		uriTopic := uri[len("topic://"):]
+	if isMemURI(uri) {
+		return MemConsumerFromURI(uri)
+	} else if isPgURI(uri) {
+		backend, err := defaultPgBackend()
+		if err != nil {
+			return nil, err
+		}
+		return PgConsumerFromURI(uri, backend)
+	} else if strings.HasPrefix(uri, "stream://") {
+		streamName, options, err := ParseStreamURI(uri)
+		if err != nil {
+			return nil, err
+		}
+		return NewStreamConsumerChannel(streamName, options, redisClient), nil
+	} else if strings.HasPrefix(uri, "topic://") {
+		uriTopic, history, err := parseTopicURI(uri[len("topic://"):])
+		if err != nil {
+			return nil, err
+		}
+		if history.Enabled() {
+			return NewReplayableTopicConsumerChannel(uriTopic, redisClient, history), nil
+		}
 		return NewTopicConsumerChannel(uriTopic, redisClient), nil
 	} else if strings.HasPrefix(uri, "queue://") {
-		uriQueue := uri[len("queue://"):]
-		return NewQueueConsumerChannel(uriQueue, redisClient), nil
+		uriQueue, query, err := splitURIQuery(uri[len("queue://"):])
+		if err != nil {
+			return nil, err
+		}
+		retry, err := ParseRetryPolicy(query)
+		if err != nil {
+			return nil, err
+		}
+		return NewRetryQueueConsumerChannel(uriQueue, redisClient, retry), nil
+	} else if strings.HasPrefix(uri, "delay://") {
+		uriQueue, query, err := splitURIQuery(uri[len("delay://"):])
+		if err != nil {
+			return nil, err
+		}
+		if _, err := ParseDelay(query); err != nil {
+			return nil, err
+		}
+		retry, err := ParseRetryPolicy(query)
+		if err != nil {
+			return nil, err
+		}
+		return NewDelayQueueConsumerChannel(uriQueue, redisClient, retry), nil
 	} else {
-		return nil, errors.New("Must specify uri starting with queue:// or topic://")
+		return nil, errors.New("Must specify uri starting with queue://, topic://, stream://, delay://, pg://, pg+topic://, mem://, mem+topic:// or mem+delay://")
 	}
 }
 
 func PublisherFromURI(uri string, redisClient *redis.Client) (Publisher, error) {
-	if strings.HasPrefix(uri, "topic://") {
-		uriTopic := uri[len("topic://"):]
-		return NewRedisTopicPublisher(uriTopic, redisClient), nil
+	if isMemURI(uri) {
+		return MemPublisherFromURI(uri)
+	} else if isPgURI(uri) {
+		backend, err := defaultPgBackend()
+		if err != nil {
+			return nil, err
+		}
+		return PgPublisherFromURI(uri, backend)
+	} else if strings.HasPrefix(uri, "stream://") {
+		streamName, options, err := ParseStreamURI(uri)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisStreamPublisher(streamName, options.MaxLen, redisClient), nil
+	} else if strings.HasPrefix(uri, "topic://") {
+		uriTopic, history, err := parseTopicURI(uri[len("topic://"):])
+		if err != nil {
+			return nil, err
+		}
+		return NewReplayableTopicPublisher(uriTopic, redisClient, history), nil
 	} else if strings.HasPrefix(uri, "queue://") {
-		uriQueue := uri[len("queue://"):]
+		uriQueue, _, err := splitURIQuery(uri[len("queue://"):])
+		if err != nil {
+			return nil, err
+		}
 		return NewRedisQueuePublisher(uriQueue, redisClient), nil
+	} else if strings.HasPrefix(uri, "delay://") {
+		uriQueue, query, err := splitURIQuery(uri[len("delay://"):])
+		if err != nil {
+			return nil, err
+		}
+		after, err := ParseDelay(query)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisDelayQueuePublisher(uriQueue, after, redisClient), nil
 	} else {
-		return nil, errors.New("Must specify uri starting with queue:// or topic://")
+		return nil, errors.New("Must specify uri starting with queue://, topic://, stream://, delay://, pg://, pg+topic://, mem://, mem+topic:// or mem+delay://")
 	}
 }
 
@@ -51,6 +121,34 @@ func NewRedisURITranslator(redisClient *redis.Client) (URITranslator) {
 	return &RedisURITranslator{redisClient}
 }
 
+// PgURITranslator resolves pg:// and pg+topic:// URIs to channels stored in
+// its PgBackend, and any other URI the same way as RedisURITranslator.
+type PgURITranslator struct {
+	backend     *PgBackend
+	redisClient *redis.Client
+}
+
+func (put *PgURITranslator) ConsumerFromURI(uri string) (ConsumerChannel, error) {
+	if isPgURI(uri) {
+		return PgConsumerFromURI(uri, put.backend)
+	}
+	return ConsumerFromURI(uri, put.redisClient)
+}
+
+func (put *PgURITranslator) PublisherFromURI(uri string) (Publisher, error) {
+	if isPgURI(uri) {
+		return PgPublisherFromURI(uri, put.backend)
+	}
+	return PublisherFromURI(uri, put.redisClient)
+}
+
+// NewPgURITranslator returns a URITranslator for pipelines that keep some or
+// all of their channels in Postgres. `redisClient` may be nil if every
+// channel is a pg:// or in-process channel.
+func NewPgURITranslator(backend *PgBackend, redisClient *redis.Client) URITranslator {
+	return &PgURITranslator{backend, redisClient}
+}
+
 type MockURITranslator struct {
 	redisClient *redis.Client
 }
@@ -66,3 +164,179 @@ func (rut *MockURITranslator) PublisherFromURI(uri string) (Publisher, error) {
 func NewMockURITranslator(redisClient *redis.Client) (URITranslator) {
 	return &MockURITranslator{redisClient}
 }
+
+// isMemURI returns true if `uri` refers to an in-process channel
+func isMemURI(uri string) bool {
+	return strings.HasPrefix(uri, "mem://") || strings.HasPrefix(uri, "mem+topic://") || strings.HasPrefix(uri, "mem+delay://")
+}
+
+// isPgURI returns true if `uri` refers to a channel stored in Postgres
+func isPgURI(uri string) bool {
+	return strings.HasPrefix(uri, "pg://") || strings.HasPrefix(uri, "pg+topic://")
+}
+
+// PgConsumerFromURI returns a ConsumerChannel stored in `backend` for pg://
+// (queue) and pg+topic:// (topic) URIs, eg.
+//
+//    pg://ingest?claim=5m&maxattempts=5&backoff=1s
+//    pg+topic://instant-broadcast
+func PgConsumerFromURI(uri string, backend *PgBackend) (ConsumerChannel, error) {
+	if strings.HasPrefix(uri, "pg+topic://") {
+		name, _, err := splitURIQuery(uri[len("pg+topic://"):])
+		if err != nil {
+			return nil, err
+		}
+		return NewPgTopicConsumerChannel(name, backend), nil
+	} else if strings.HasPrefix(uri, "pg://") {
+		name, query, err := splitURIQuery(uri[len("pg://"):])
+		if err != nil {
+			return nil, err
+		}
+		retry, err := ParseRetryPolicy(query)
+		if err != nil {
+			return nil, err
+		}
+		claimTimeout, err := ParsePgClaimTimeout(query)
+		if err != nil {
+			return nil, err
+		}
+		return NewPgQueueConsumerChannel(name, backend, retry, claimTimeout), nil
+	} else {
+		return nil, errors.New("Must specify uri starting with pg:// or pg+topic://")
+	}
+}
+
+// PgPublisherFromURI returns a Publisher stored in `backend` for pg:// (queue)
+// and pg+topic:// (topic) URIs.
+func PgPublisherFromURI(uri string, backend *PgBackend) (Publisher, error) {
+	if strings.HasPrefix(uri, "pg+topic://") {
+		name, _, err := splitURIQuery(uri[len("pg+topic://"):])
+		if err != nil {
+			return nil, err
+		}
+		return NewPgTopicPublisher(name, backend), nil
+	} else if strings.HasPrefix(uri, "pg://") {
+		name, _, err := splitURIQuery(uri[len("pg://"):])
+		if err != nil {
+			return nil, err
+		}
+		return NewPgQueuePublisher(name, backend), nil
+	} else {
+		return nil, errors.New("Must specify uri starting with pg:// or pg+topic://")
+	}
+}
+
+// MemConsumerFromURI returns an in-process ConsumerChannel for mem:// (queue),
+// mem+topic:// (topic) and mem+delay:// (delay queue) URIs.
+func MemConsumerFromURI(uri string) (ConsumerChannel, error) {
+	if strings.HasPrefix(uri, "mem+topic://") {
+		name, history, err := parseTopicURI(uri[len("mem+topic://"):])
+		if err != nil {
+			return nil, err
+		}
+		if history.Enabled() {
+			return NewMemReplayableTopicConsumerChannel(name, history), nil
+		}
+		return NewMemTopicConsumerChannel(name), nil
+	} else if strings.HasPrefix(uri, "mem://") {
+		name, query, err := splitURIQuery(uri[len("mem://"):])
+		if err != nil {
+			return nil, err
+		}
+		retry, err := ParseRetryPolicy(query)
+		if err != nil {
+			return nil, err
+		}
+		return NewMemRetryQueueConsumerChannel(name, retry), nil
+	} else if strings.HasPrefix(uri, "mem+delay://") {
+		name, query, err := splitURIQuery(uri[len("mem+delay://"):])
+		if err != nil {
+			return nil, err
+		}
+		if _, err := ParseDelay(query); err != nil {
+			return nil, err
+		}
+		retry, err := ParseRetryPolicy(query)
+		if err != nil {
+			return nil, err
+		}
+		return NewMemRetryQueueConsumerChannel(name, retry), nil
+	} else {
+		return nil, errors.New("Must specify uri starting with mem://, mem+topic:// or mem+delay://")
+	}
+}
+
+// MemPublisherFromURI returns an in-process Publisher for mem:// (queue),
+// mem+topic:// (topic) and mem+delay:// (delay queue) URIs.
+func MemPublisherFromURI(uri string) (Publisher, error) {
+	if strings.HasPrefix(uri, "mem+topic://") {
+		name, history, err := parseTopicURI(uri[len("mem+topic://"):])
+		if err != nil {
+			return nil, err
+		}
+		return NewMemReplayableTopicPublisher(name, history), nil
+	} else if strings.HasPrefix(uri, "mem://") {
+		name, _, err := splitURIQuery(uri[len("mem://"):])
+		if err != nil {
+			return nil, err
+		}
+		return NewMemQueuePublisher(name), nil
+	} else if strings.HasPrefix(uri, "mem+delay://") {
+		name, query, err := splitURIQuery(uri[len("mem+delay://"):])
+		if err != nil {
+			return nil, err
+		}
+		after, err := ParseDelay(query)
+		if err != nil {
+			return nil, err
+		}
+		return NewMemDelayQueuePublisher(name, after), nil
+	} else {
+		return nil, errors.New("Must specify uri starting with mem://, mem+topic:// or mem+delay://")
+	}
+}
+
+// MemURITranslator resolves every URI to an in-process channel, so a whole
+// pipeline can be wired together in a single binary without Redis. queue://,
+// topic:// and delay:// URIs are treated as mem://, mem+topic:// and
+// mem+delay:// respectively, as are pg:// and pg+topic://, so existing
+// channel strings can be used unchanged.
+type MemURITranslator struct{}
+
+func (mut *MemURITranslator) ConsumerFromURI(uri string) (ConsumerChannel, error) {
+	return MemConsumerFromURI(memURI(uri))
+}
+
+func (mut *MemURITranslator) PublisherFromURI(uri string) (Publisher, error) {
+	return MemPublisherFromURI(memURI(uri))
+}
+
+func NewMemURITranslator() (URITranslator) {
+	return &MemURITranslator{}
+}
+
+func memURI(uri string) string {
+	if strings.HasPrefix(uri, "queue://") {
+		return "mem://" + uri[len("queue://"):]
+	} else if strings.HasPrefix(uri, "topic://") {
+		return "mem+topic://" + uri[len("topic://"):]
+	} else if strings.HasPrefix(uri, "delay://") {
+		return "mem+delay://" + uri[len("delay://"):]
+	} else if strings.HasPrefix(uri, "pg://") {
+		return "mem://" + uri[len("pg://"):]
+	} else if strings.HasPrefix(uri, "pg+topic://") {
+		return "mem+topic://" + uri[len("pg+topic://"):]
+	}
+	return uri
+}
+
+// parseTopicURI splits the part of a topic URI after the scheme into the
+// topic name and its history options
+func parseTopicURI(nameAndQuery string) (string, HistoryOptions, error) {
+	name, query, err := splitURIQuery(nameAndQuery)
+	if err != nil {
+		return "", HistoryOptions{}, err
+	}
+	history, err := ParseHistoryOptions(query)
+	return name, history, err
+}
@@ -20,3 +20,38 @@ func redisErrIsNil(result redis.Cmder) bool {
 		return false
 	}
 }
+
+// redisSucceeded reports whether a command that writes to Redis succeeded,
+// logging the error if it didn't. Unlike redisErrIsNil, any error counts as
+// a failure.
+func redisSucceeded(result redis.Cmder) bool {
+	if err := result.Err(); err != nil {
+		log.Printf("Redis error: %v", err.Error())
+		return false
+	}
+	return true
+}
+
+// return number of deleted list items
+// https://www.redisgreen.net/blog/deleting-large-lists
+func deleteRedisList(redisClient *redis.Client, key string) int {
+	llenResult := redisClient.LLen(key)
+	total := int(llenResult.Val())
+	if total == 0 {
+		return 0 // nothing to do
+	}
+
+	// delete elements without blocking
+	for todo := total; todo > 0; todo -= purgeBatchSize {
+		// minimum of purgeBatchSize and todo
+		batchSize := purgeBatchSize
+		if batchSize > todo {
+			batchSize = todo
+		}
+
+		// remove one batch
+		redisClient.LTrim(key, 0, int64(-1-batchSize))
+	}
+
+	return total
+}
@@ -29,6 +29,11 @@ func (delivery *websocketDelivery) Payload() string {
 	return delivery.payload
 }
 
+func (delivery *websocketDelivery) Headers() channels.Headers {
+	// Messages from websocket clients never carry headers
+	return nil
+}
+
 func (delivery *websocketDelivery) Ack() bool {
 	// websocketDeliveris have no ack, reject, or return, so these are no-ops
 	return true
@@ -1,22 +1,25 @@
 package main
 
 import (
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/monitor/affiliate"
+	"github.com/notegio/openrelay/metrics"
 	"github.com/notegio/openrelay/channels"
-	"gopkg.in/redis.v3"
-	"os/signal"
+	"github.com/notegio/openrelay/cmd/cmdutils"
 	"os"
 	"log"
 )
 
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
 	redisURL := os.Args[1]
 	rpcURL := os.Args[2]
 	src := os.Args[3]
 	affiliateSignupAddress := os.Args[4]
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	consumerChannel, err := channels.ConsumerFromURI(src, redisClient)
 	if err != nil {
 		log.Fatalf("Error constructing consumer: %v", err.Error())
@@ -25,14 +28,13 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error constructing affiliate monitor: %v", err.Error())
 	}
-	consumerChannel.AddConsumer(consumer)
+	drainingConsumer := channels.NewDrainingConsumer(consumer)
+	consumerChannel.AddConsumer(drainingConsumer)
 	consumerChannel.StartConsuming()
 	log.Printf("Started consuming blocks from channel %v for signUp %v", src, affiliateSignupAddress)
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	for _ = range c {
-		break
-	}
-	consumerChannel.StopConsuming()
+	<-cmdutils.SignalContext().Done()
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	channels.Shutdown(drainCtx, consumerChannel, drainingConsumer)
 
 }
@@ -41,6 +41,9 @@ func main() {
 	if err := db.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		log.Fatalf("Error migrating order table: %v", err.Error())
 	}
+	if err := db.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		log.Fatalf("Error migrating order_events table: %v", err.Error())
+	}
 	if err := db.AutoMigrate(&dbModule.Cancellation{}).Error; err != nil {
 		log.Fatalf("Error migrating cancellation table: %v", err.Error())
 	}
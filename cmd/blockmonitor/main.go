@@ -1,10 +1,11 @@
 package main
 
 import (
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/monitor/blocks"
+	"github.com/notegio/openrelay/metrics"
 	"github.com/notegio/openrelay/channels"
-	"gopkg.in/redis.v3"
-	"os/signal"
+	"github.com/notegio/openrelay/cmd/cmdutils"
 	"os"
 	"log"
 	"strconv"
@@ -14,6 +15,7 @@ import (
 )
 
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
 	redisURL := os.Args[1]
 	rpcURL := os.Args[2]
 	dst := os.Args[3]
@@ -33,9 +35,10 @@ func main() {
 		}
 		pollInterval = time.Duration(pollIntervalInt) * time.Second
 	}
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	publisher, err := channels.PublisherFromURI(dst, redisClient)
 	if err != nil {
 		log.Fatalf("Error constructing publisher: %v", err.Error())
@@ -52,11 +55,7 @@ func main() {
 		}
 	}()
 	log.Printf("Block Monitor: Started block monitor. RPC Host: '%v'. Queue: '%v'", rpcURL, dst)
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	for _ = range c {
-		break
-	}
+	<-cmdutils.SignalContext().Done()
 	monitor.Stop()
 
 }
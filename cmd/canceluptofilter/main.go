@@ -1,50 +1,56 @@
 package main
 
 import (
+	"github.com/notegio/openrelay/common"
 	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/metrics"
 	"github.com/notegio/openrelay/channels"
 	"github.com/notegio/openrelay/funds"
 	"github.com/notegio/openrelay/cmd/cmdutils"
-	"gopkg.in/redis.v3"
 
 	"log"
 	"os"
-	"os/signal"
 	"strconv"
 )
 
 
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
 	redisURL := os.Args[1]
 	db, err := dbModule.GetDB(os.Args[2], os.Args[3])
 	if err != nil { log.Fatalf("Error opening database: %v", err.Error()) }
 	if redisURL == "" {
 		log.Fatalf("Please specify redis URL")
 	}
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	lookup:= funds.NewDBCancellationLookup(db)
 	concurrency, err := strconv.Atoi(os.Getenv("CONCURRENCY"))
 	if err != nil {
 		concurrency = 5
 	}
 	consumerChannels := []channels.ConsumerChannel{}
+	drainingConsumers := []*channels.DrainingConsumer{}
 	for _, channelString := range os.Args[4:] {
 		consumerChannel, allPublisher, changePublisher, err := cmdutils.ParseChannels(channelString, redisClient)
 		if err != nil { log.Fatalf(err.Error()) }
 		fillConsumer := funds.NewCancellationConsumer(allPublisher, changePublisher, lookup, concurrency)
+		drainingConsumer := channels.NewDrainingConsumer(&fillConsumer)
 		consumerChannels = append(consumerChannels, consumerChannel)
-		consumerChannel.AddConsumer(&fillConsumer)
+		drainingConsumers = append(drainingConsumers, drainingConsumer)
+		consumerChannel.AddConsumer(drainingConsumer)
 		consumerChannel.StartConsuming()
 		log.Printf("Starting cancellation updater consumer on '%v'", channelString)
 	}
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	for _ = range c {
-		break
-	}
+	<-cmdutils.SignalContext().Done()
 	for _, consumerChannel := range consumerChannels {
 		consumerChannel.StopConsuming()
 	}
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	for _, drainingConsumer := range drainingConsumers {
+		drainingConsumer.Drain(drainCtx)
+	}
 }
@@ -0,0 +1,80 @@
+package cmdutils
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const defaultDrainTimeout = 20 * time.Second
+
+var (
+	signalOnce sync.Once
+	signalCtx  context.Context
+)
+
+// SignalContext returns a context that is cancelled when the process receives
+// SIGINT or SIGTERM, which is how Kubernetes asks a pod to stop. Every call
+// returns the same context, which starts listening for signals on the first
+// call, so a signal received at any point after that stops the whole service.
+func SignalContext() context.Context {
+	signalOnce.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			sig := <-c
+			log.Printf("Received %v, shutting down", sig)
+			signal.Stop(c)
+			cancel()
+		}()
+		signalCtx = ctx
+	})
+	return signalCtx
+}
+
+// DrainTimeout returns how long to wait for in-flight work to finish when
+// shutting down, from the DRAIN_TIMEOUT environment variable (eg. "30s"). It
+// defaults to 20s, leaving some headroom within Kubernetes' default 30s grace
+// period.
+func DrainTimeout() time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv("DRAIN_TIMEOUT"))
+	if err != nil || timeout < 0 {
+		return defaultDrainTimeout
+	}
+	return timeout
+}
+
+// DrainContext returns a context that expires after DrainTimeout(), for
+// bounding how long shutdown can take.
+func DrainContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), DrainTimeout())
+}
+
+// ListenAndServe serves `handler` on `addr` until `ctx` is cancelled, then
+// stops accepting connections and waits up to DrainTimeout() for in-flight
+// requests to complete.
+func ListenAndServe(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{Addr: addr, Handler: handler}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+	}
+	drainCtx, cancel := DrainContext()
+	defer cancel()
+	if err := server.Shutdown(drainCtx); err != nil {
+		log.Printf("Error shutting down server on %v: %v", addr, err.Error())
+		return err
+	}
+	return nil
+}
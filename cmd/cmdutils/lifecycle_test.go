@@ -0,0 +1,30 @@
+package cmdutils_test
+
+import (
+	"github.com/notegio/openrelay/cmd/cmdutils"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignalContextShared(t *testing.T) {
+	first := cmdutils.SignalContext()
+	second := cmdutils.SignalContext()
+	if first != second {
+		t.Fatalf("Expected every call to return the same context")
+	}
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err.Error())
+	}
+	select {
+	case <-second.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected SIGTERM to cancel the context")
+	}
+	// A context requested after the signal must see it too
+	select {
+	case <-cmdutils.SignalContext().Done():
+	default:
+		t.Errorf("Expected later calls to return the cancelled context")
+	}
+}
@@ -1,7 +1,11 @@
 package cmdutils
 
 import (
+	"fmt"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/types"
+	"github.com/notegio/openrelay/wallet"
+	"os"
 	"strings"
 	"gopkg.in/redis.v3"
 )
@@ -33,3 +37,46 @@ func ParseChannels(channelString string, redisClient *redis.Client) (channels.Co
 	}
 	return sourceChannel, publishers, altPublisher, nil
 }
+
+// PartitionKey returns the KeyFunc named by the PARTITION_BY environment
+// variable ("hash" or "maker"), for relays that need deliveries for the same
+// order or maker processed in order. It returns nil if PARTITION_BY is unset.
+func PartitionKey() (channels.KeyFunc, error) {
+	return channels.KeyFuncByName(os.Getenv("PARTITION_BY"))
+}
+
+// ConfigureContractSignatures verifies Wallet, PreSigned and Validator
+// signatures through the Ethereum node at `rpcURL`, accepting Validator
+// signatures from the comma separated list of `validators`. If `rpcURL` is
+// empty, these signatures are not verified, so orders using them are
+// rejected.
+func ConfigureContractSignatures(rpcURL string, validators string) error {
+	if rpcURL == "" {
+		return nil
+	}
+	walletVerifier, err := wallet.NewRpcWalletVerifier(rpcURL)
+	if err != nil {
+		return err
+	}
+	exchangeVerifier, err := wallet.NewRpcExchangeVerifier(rpcURL)
+	if err != nil {
+		return err
+	}
+	validatorAddresses := []*types.Address{}
+	for _, validator := range strings.Split(validators, ",") {
+		if validator = strings.TrimSpace(validator); validator == "" {
+			continue
+		}
+		addressBytes, err := types.HexStringToBytes(validator)
+		if err != nil || len(addressBytes) != 20 {
+			return fmt.Errorf("Invalid validator address '%v'", validator)
+		}
+		address := &types.Address{}
+		copy(address[:], addressBytes)
+		validatorAddresses = append(validatorAddresses, address)
+	}
+	types.SetWalletVerifier(walletVerifier)
+	types.SetExchangeVerifier(exchangeVerifier)
+	types.SetAllowedValidators(validatorAddresses)
+	return nil
+}
@@ -1,32 +1,17 @@
 package main
 
 import (
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/metrics"
 	"github.com/notegio/openrelay/cmd/cmdutils"
-	"gopkg.in/redis.v3"
 	"os"
-	"os/signal"
 	"log"
 	"strings"
 )
 
-// DelayConsumer Flushes the DelayRelay every time it receives a message. If
-// the publisher is specified, it will also pass the message it received to the
-// publisher.
-type DelayConsumer struct {
-	relay *channels.DelayRelay
-	publisher channels.Publisher
-}
-
-func (consumer *DelayConsumer) Consume(delivery channels.Delivery) {
-	consumer.relay.Flush()
-	if consumer.publisher != nil {
-		consumer.publisher.Publish(delivery.Payload())
-	}
-	delivery.Ack()
-}
-
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
 	redisURL := os.Args[1]
 	// src := os.Args[2]
 	// dest := os.Args[3]
@@ -40,9 +25,10 @@ func main() {
 	if redisURL == "" {
 		log.Fatalf("Please specify redis URL")
 	}
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	// if strings.HasPrefix(src, "topic://") {
 	// 	log.Fatal("Delay relay source must be queue, not topic")
 	// }
@@ -77,21 +63,22 @@ func main() {
 		relay := channels.NewDelayRelay(sourceChannel.Publisher(), sourceChannel, publisher, "pause")
 		log.Printf("Starting delayrelay '%v'", arg)
 		relay.Start()
-		signalConsumer.AddConsumer(&DelayConsumer{&relay, signalPublisher})
+		signalConsumer.AddConsumer(channels.NewDelayConsumer(&relay, signalPublisher))
 		signalConsumer.StartConsuming()
 		relays = append(relays, relay)
 		signalConsumers = append(signalConsumers, signalConsumer)
 	}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	for _ = range c {
-		break
+	<-cmdutils.SignalContext().Done()
+	for _, signalConsumer := range signalConsumers {
+		signalConsumer.StopConsuming()
 	}
 	for _, relay := range relays {
 		relay.Stop()
 	}
-	for _, signalConsumer := range signalConsumers {
-		signalConsumer.StopConsuming()
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	for _, relay := range relays {
+		relay.Drain(drainCtx)
 	}
 }
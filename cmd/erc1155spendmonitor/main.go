@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/monitor/erc1155"
+	"github.com/notegio/openrelay/metrics"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/cmd/cmdutils"
+	"os"
+	"log"
+)
+
+func main() {
+	metrics.Serve(cmdutils.SignalContext())
+	redisURL := os.Args[1]
+	rpcURL := os.Args[2]
+	src := os.Args[3]
+	dst := os.Args[4]
+	exchangeAddress := os.Args[5]
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	consumerChannel, err := channels.ConsumerFromURI(src, redisClient)
+	if err != nil {
+		log.Fatalf("Error constructing consumer: %v", err.Error())
+	}
+	publisher, err := channels.PublisherFromURI(dst, redisClient)
+	if err != nil {
+		log.Fatalf("Error constructing publisher: %v", err.Error())
+	}
+	consumer, err := erc1155.NewRPCTransferBlockConsumer(rpcURL, exchangeAddress, publisher)
+	if err != nil {
+		log.Fatalf("Error constructing erc1155 spend monitor: %v", err.Error())
+	}
+	drainingConsumer := channels.NewDrainingConsumer(consumer)
+	consumerChannel.AddConsumer(drainingConsumer)
+	consumerChannel.StartConsuming()
+	log.Printf("Started consuming blocks from channel %v for exchange %v, publishing to %v", src, exchangeAddress, dst)
+	<-cmdutils.SignalContext().Done()
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	channels.Shutdown(drainCtx, consumerChannel, drainingConsumer)
+
+}
@@ -1,23 +1,26 @@
 package main
 
 import(
-	"gopkg.in/redis.v3"
 	"os"
 	// "github.com/notegio/openrelay/types"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/cmd/cmdutils"
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/metrics"
 	"github.com/notegio/openrelay/splitter"
 	"log"
-	"os/signal"
 	"strconv"
 )
 
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
 	redisURL := os.Args[1]
 	src := os.Args[2]
 	suffix := os.Args[3]
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	sourceConsumerChannel, err := channels.ConsumerFromURI(src, redisClient)
 	if err != nil { log.Fatalf(err.Error()) }
 	concurrency, err := strconv.Atoi(os.Getenv("CONCURRENCY"))
@@ -26,13 +29,12 @@ func main() {
 	}
 	translator := channels.NewRedisURITranslator(redisClient)
 	exchangeSplitter := splitter.NewExchangeSplitterConsumer(translator, suffix, concurrency)
-	sourceConsumerChannel.AddConsumer(exchangeSplitter)
+	drainingConsumer := channels.NewDrainingConsumer(exchangeSplitter)
+	sourceConsumerChannel.AddConsumer(drainingConsumer)
 	sourceConsumerChannel.StartConsuming()
 	log.Printf("Consuming on '%v'", src)
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	for _ = range c {
-		break
-	}
-	sourceConsumerChannel.StopConsuming()
+	<-cmdutils.SignalContext().Done()
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	channels.Shutdown(drainCtx, sourceConsumerChannel, drainingConsumer)
 }
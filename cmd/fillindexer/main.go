@@ -1,17 +1,19 @@
 package main
 
 import (
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/cmd/cmdutils"
+	"github.com/notegio/openrelay/metrics"
 	dbModule "github.com/notegio/openrelay/db"
 	// "github.com/notegio/openrelay/funds"
-	"gopkg.in/redis.v3"
 	"log"
 	"os"
-	"os/signal"
 	"strconv"
 )
 
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
 	redisURL := os.Args[1]
 	srcChannel := os.Args[2]
 	db, err := dbModule.GetDB(os.Args[3], os.Args[4])
@@ -19,9 +21,10 @@ func main() {
 		log.Fatalf("Could not open database connection: %v", err.Error())
 	}
 	destChannel := os.Args[5]
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	consumerChannel, err := channels.ConsumerFromURI(srcChannel, redisClient)
 	if err != nil {
 		log.Fatalf("Error establishing consumer channel: %v", err.Error())
@@ -34,13 +37,12 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error establishing publisher channel: %v", err.Error())
 	}
-	consumerChannel.AddConsumer(dbModule.NewRecordFillConsumer(db, concurrency, publisher))
+	drainingConsumer := channels.NewDrainingConsumer(dbModule.NewRecordFillConsumer(db, concurrency, publisher))
+	consumerChannel.AddConsumer(drainingConsumer)
 	consumerChannel.StartConsuming()
 	log.Printf("Starting db fill indexer consumer on '%v'", srcChannel)
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	for _ = range c {
-		break
-	}
-	consumerChannel.StopConsuming()
+	<-cmdutils.SignalContext().Done()
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	channels.Shutdown(drainCtx, consumerChannel, drainingConsumer)
 }
@@ -1,25 +1,28 @@
 package main
 
 import (
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/monitor/fill"
+	"github.com/notegio/openrelay/metrics"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/cmd/cmdutils"
 	"github.com/notegio/openrelay/fillbloom"
-	"gopkg.in/redis.v3"
-	"os/signal"
 	"os"
 	"log"
 )
 
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
 	redisURL := os.Args[1]
 	rpcURL := os.Args[2]
 	src := os.Args[3]
 	dst := os.Args[4]
 	storageURI := os.Args[5]
 	exchangeAddress := os.Args[6]
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	consumerChannel, err := channels.ConsumerFromURI(src, redisClient)
 	if err != nil {
 		log.Fatalf("Error constructing consumer: %v", err.Error())
@@ -36,14 +39,13 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error constructing fill monitor: %v", err.Error())
 	}
-	consumerChannel.AddConsumer(consumer)
+	drainingConsumer := channels.NewDrainingConsumer(consumer)
+	consumerChannel.AddConsumer(drainingConsumer)
 	consumerChannel.StartConsuming()
 	log.Printf("Started consuming blocks from channel %v for exchange %v, publishing to %v", src, exchangeAddress, dst)
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	for _ = range c {
-		break
-	}
-	consumerChannel.StopConsuming()
+	<-cmdutils.SignalContext().Done()
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	channels.Shutdown(drainCtx, consumerChannel, drainingConsumer)
 
 }
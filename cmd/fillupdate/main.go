@@ -1,20 +1,21 @@
 package main
 
 import (
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/metrics"
 	"github.com/notegio/openrelay/funds"
 	"github.com/notegio/openrelay/fillbloom"
 	"github.com/notegio/openrelay/cmd/cmdutils"
-	"gopkg.in/redis.v3"
 
 	"log"
 	"os"
-	"os/signal"
 	"strconv"
 )
 
 
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
 	redisURL := os.Args[1]
 	rpcURL := os.Args[2]
 	fillSrc := os.Args[3]
@@ -31,9 +32,10 @@ func main() {
 	if rpcURL == "" {
 		log.Fatalf("Please specify RPC URL")
 	}
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	// consumerChannel, err := channels.ConsumerFromURI(src, redisClient)
 	// if err != nil { log.Fatalf(err.Error()) }
 	fillConsumerChannel, err := channels.ConsumerFromURI(fillSrc, redisClient)
@@ -47,24 +49,28 @@ func main() {
 		concurrency = 5
 	}
 	consumerChannels := []channels.ConsumerChannel{}
+	drainingConsumers := []*channels.DrainingConsumer{}
 	for _, channelString := range os.Args[5:] {
 		consumerChannel, allPublisher, changePublisher, err := cmdutils.ParseChannels(channelString, redisClient)
 		if err != nil { log.Fatalf(err.Error()) }
 		fillConsumer := funds.NewFillConsumer(allPublisher, changePublisher, lookup, concurrency)
+		drainingConsumer := channels.NewDrainingConsumer(&fillConsumer)
 		consumerChannels = append(consumerChannels, consumerChannel)
-		consumerChannel.AddConsumer(&fillConsumer)
+		drainingConsumers = append(drainingConsumers, drainingConsumer)
+		consumerChannel.AddConsumer(drainingConsumer)
 		consumerChannel.StartConsuming()
 		log.Printf("Starting fillupdate consumer on '%v'", channelString)
 	}
 	fillConsumerChannel.AddConsumer(fillBloom)
 	fillConsumerChannel.StartConsuming()
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	for _ = range c {
-		break
-	}
+	<-cmdutils.SignalContext().Done()
 	for _, consumerChannel := range consumerChannels {
 		consumerChannel.StopConsuming()
 	}
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	for _, drainingConsumer := range drainingConsumers {
+		drainingConsumer.Drain(drainCtx)
+	}
 	fillConsumerChannel.StopConsuming()
 }
@@ -1,44 +1,23 @@
 package main
 
 import (
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/metrics"
 	"github.com/notegio/openrelay/funds"
-	"github.com/notegio/openrelay/types"
 	"github.com/notegio/openrelay/config"
 	"github.com/notegio/openrelay/cmd/cmdutils"
-	"gopkg.in/redis.v3"
 	"os"
-	"os/signal"
-	"encoding/hex"
 	"log"
 	"strings"
 	"strconv"
 )
 
-type FundFilter struct {
-	orderValidator funds.OrderValidator
-}
-
-func (filter *FundFilter) Filter(delivery channels.Delivery) bool {
-	order, err := types.OrderFromBytes([]byte(delivery.Payload()))
-	if err != nil {
-		log.Printf("Invalid order format: %#x", delivery.Payload())
-		return false;
-	}
-	if !order.Signature.Verify(order.Maker, order.Hash()) {
-		log.Printf("Invalid order signature");
-		return false;
-	}
-	valid, _ := filter.orderValidator.ValidateOrder(order)
-	if valid {
-		log.Printf("Order '%v' has funds", hex.EncodeToString(order.Hash()))
-	} else {
-		log.Printf("Order '%v' lacks funds", hex.EncodeToString(order.Hash()))
-	}
-	return valid
-}
-
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
+	if err := cmdutils.ConfigureContractSignatures(os.Getenv("SIGNATURE_RPC_URL"), os.Getenv("SIGNATURE_VALIDATORS")); err != nil {
+		log.Fatalf("Error configuring contract signatures: %v", err.Error())
+	}
 	redisURL := os.Args[1]
 	rpcURL := os.Args[2]
 	// src := os.Args[3]
@@ -48,9 +27,10 @@ func main() {
 	if rpcURL == "" {
 		log.Fatalf("Please specify RPC URL")
 	}
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	feeToken, err := config.NewRpcFeeToken(rpcURL)
 	if err != nil {
 		log.Fatalf("Error creating RpcOrderValidator: '%v'", err.Error())
@@ -63,6 +43,10 @@ func main() {
 	if err != nil {
 		concurrency = 5
 	}
+	partitionKey, err := cmdutils.PartitionKey()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	// publishers := []channels.Publisher{}
 	// consumerChannel, err := channels.ConsumerFromURI(src, redisClient)
 	// if err != nil { log.Fatalf(err.Error()) }
@@ -85,7 +69,7 @@ func main() {
 		log.Fatalf("Error creating RpcOrderValidator: '%v'", err.Error())
 	}
 	var fundFilter channels.RelayFilter
-	fundFilter = &FundFilter{orderValidator}
+	fundFilter = funds.NewFundFilter(orderValidator)
 	if invert {
 		fundFilter = &channels.InvertFilter{fundFilter}
 	}
@@ -93,18 +77,19 @@ func main() {
 	for _, channelString := range channelStrings {
 		consumerChannel, publisher, _, err := cmdutils.ParseChannels(channelString, redisClient)
 		if err != nil { log.Fatalf(err.Error()) }
-		relay := channels.NewRelay(consumerChannel, publisher, fundFilter, concurrency)
+		relay := channels.NewKeyedRelay(consumerChannel, publisher, fundFilter, concurrency, partitionKey)
 		relay.Start()
 		relays = append(relays, relay)
 	}
 
 	log.Printf("Starting fundcheck")
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	for _ = range c {
-		break
-	}
+	<-cmdutils.SignalContext().Done()
 	for _, relay := range relays {
 		relay.Stop()
 	}
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	for _, relay := range relays {
+		relay.Drain(drainCtx)
+	}
 }
@@ -1,17 +1,23 @@
 package main
 
 import (
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/cmd/cmdutils"
+	"github.com/notegio/openrelay/metrics"
 	dbModule "github.com/notegio/openrelay/db"
 	// "github.com/notegio/openrelay/funds"
-	"gopkg.in/redis.v3"
 	"log"
 	"os"
-	"os/signal"
 	"strconv"
+	"time"
 )
 
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
+	if err := cmdutils.ConfigureContractSignatures(os.Getenv("SIGNATURE_RPC_URL"), os.Getenv("SIGNATURE_VALIDATORS")); err != nil {
+		log.Fatalf("Error configuring contract signatures: %v", err.Error())
+	}
 	redisURL := os.Args[1]
 	srcChannel := os.Args[2]
 	db, err := dbModule.GetDB(os.Args[3], os.Args[4])
@@ -25,9 +31,10 @@ func main() {
 			status = dbModule.StatusUnfunded
 		}
 	}
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	consumerChannel, err := channels.ConsumerFromURI(srcChannel, redisClient)
 	if err != nil {
 		log.Fatalf("Error establishing consumer channel: %v", err.Error())
@@ -40,13 +47,25 @@ func main() {
 	if err != nil {
 		concurrency = 5
 	}
-	consumerChannel.AddConsumer(dbModule.NewIndexConsumer(db, status, concurrency, publisher))
+	// BATCH_SIZE enables writing orders to the database in bulk, waiting up to
+	// BATCH_WINDOW (eg. "200ms") to fill each batch.
+	var consumer channels.Consumer
+	if batchSize, err := strconv.Atoi(os.Getenv("BATCH_SIZE")); err == nil && batchSize > 1 {
+		batchWindow, err := time.ParseDuration(os.Getenv("BATCH_WINDOW"))
+		if err != nil {
+			batchWindow = 200 * time.Millisecond
+		}
+		consumer = channels.NewBatchingConsumer(dbModule.NewIndexBatchConsumer(db, status, concurrency, publisher), batchSize, batchWindow)
+		log.Printf("Indexing in batches of up to %v orders every %v", batchSize, batchWindow)
+	} else {
+		consumer = dbModule.NewIndexConsumer(db, status, concurrency, publisher)
+	}
+	drainingConsumer := channels.NewDrainingConsumer(consumer)
+	consumerChannel.AddConsumer(drainingConsumer)
 	consumerChannel.StartConsuming()
 	log.Printf("Starting db indexer consumer on '%v'", srcChannel)
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	for _ = range c {
-		break
-	}
-	consumerChannel.StopConsuming()
+	<-cmdutils.SignalContext().Done()
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	channels.Shutdown(drainCtx, consumerChannel, drainingConsumer)
 }
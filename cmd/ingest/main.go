@@ -1,15 +1,18 @@
 package main
 
 import (
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/config"
 	"github.com/notegio/openrelay/ingest"
+	"github.com/notegio/openrelay/metrics"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/cmd/cmdutils"
 	"github.com/notegio/openrelay/affiliates"
 	"github.com/notegio/openrelay/accounts"
 	"github.com/notegio/openrelay/pool"
 	dbModule "github.com/notegio/openrelay/db"
 	"encoding/hex"
 	"net/http"
-	"gopkg.in/redis.v3"
 	"os"
 	"log"
 	"github.com/rs/cors"
@@ -45,6 +48,10 @@ func (h *regexpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
+	if err := cmdutils.ConfigureContractSignatures(os.Getenv("SIGNATURE_RPC_URL"), os.Getenv("SIGNATURE_VALIDATORS")); err != nil {
+		log.Fatalf("Error configuring contract signatures: %v", err.Error())
+	}
 	db, err := dbModule.GetDB(os.Args[1], os.Args[2])
 	if err != nil {
 		log.Fatalf("Could not open database connection: %v", err.Error())
@@ -61,9 +68,10 @@ func main() {
 	if redisURL == "" {
 		log.Fatalf("Please specify redis URL")
 	}
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	defaultFeeRecipientSlice, err := hex.DecodeString(defaultFeeRecipientString)
 	if err != nil {
 		log.Fatalf(err.Error())
@@ -74,16 +82,36 @@ func main() {
 	accountService := accounts.NewRedisAccountService(redisClient)
 	publisher, err := channels.PublisherFromURI(dstChannel, redisClient)
 	enforceTerms := os.Getenv("OR_ENFORCE_TERMS") != "false"
+	// Consumers from before message envelopes can't read them, so set
+	// OR_MESSAGE_ENVELOPES=false until every downstream service is upgraded.
+	envelopes := os.Getenv("OR_MESSAGE_ENVELOPES") != "false"
 	if err != nil { log.Fatalf(err.Error()) }
 	exchangeLookup := dbModule.NewExchangeLookup(db)
-	handler := pool.PoolDecoratorBaseFee(db, redisClient, ingest.Handler(publisher, accountService, affiliateService, enforceTerms, dbModule.NewTermsManager(db), exchangeLookup))
-	feeHandler := pool.PoolDecoratorBaseFee(db, redisClient, ingest.FeeHandler(publisher, accountService, affiliateService, defaultFeeRecipientBytes, exchangeLookup))
+	// v3 orders name the asset their fees are paid in. Fees only count towards
+	// the relay's minimum when they're paid in FEE_ASSET_DATA.
+	var feeToken config.FeeToken
+	if feeAssetDataHex := os.Getenv("FEE_ASSET_DATA"); feeAssetDataHex != "" {
+		feeAssetData, err := common.HexToAssetData(feeAssetDataHex)
+		if err != nil {
+			log.Fatalf("Invalid FEE_ASSET_DATA: %v", err.Error())
+		}
+		feeToken = config.StaticFeeToken(feeAssetData)
+	}
+	handler := pool.PoolDecoratorBaseFee(db, redisClient, ingest.Handler(publisher, accountService, affiliateService, enforceTerms, dbModule.NewTermsManager(db), exchangeLookup, feeToken, envelopes))
+	feeHandler := pool.PoolDecoratorBaseFee(db, redisClient, ingest.FeeHandler(publisher, accountService, affiliateService, defaultFeeRecipientBytes, exchangeLookup, feeToken))
+	typedDataHandler := pool.PoolDecoratorBaseFee(db, redisClient, ingest.TypedDataHandler(accountService, affiliateService, defaultFeeRecipientBytes, exchangeLookup, feeToken))
 
 	mux := &regexpHandler{[]*route{}}
-	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/order$"), handler)
-	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/order_config$"), feeHandler)
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/order$"), metrics.InstrumentHandler("order", handler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/order_config$"), metrics.InstrumentHandler("order_config", feeHandler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/order_typed_data$"), metrics.InstrumentHandler("order_typed_data", typedDataHandler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/sra/v3/order$"), metrics.InstrumentHandler("order_v3", handler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/sra/v3/order_config$"), metrics.InstrumentHandler("order_config_v3", feeHandler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/sra/v3/order_typed_data$"), metrics.InstrumentHandler("order_typed_data_v3", typedDataHandler))
 	mux.HandleFunc(regexp.MustCompile("^/_hc$"), ingest.HealthCheckHandler(redisClient))
 	corsHandler := cors.Default().Handler(mux)
 	log.Printf("Order Ingest Serving on :%v", port)
-	http.ListenAndServe(":"+port, corsHandler)
+	if err := cmdutils.ListenAndServe(cmdutils.SignalContext(), ":"+port, corsHandler); err != nil {
+		log.Fatal(err.Error())
+	}
 }
@@ -2,11 +2,12 @@ package main
 
 import (
 	"encoding/hex"
-	"gopkg.in/redis.v3"
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/config"
 	"github.com/notegio/openrelay/affiliates"
 	"github.com/notegio/openrelay/types"
 	"math/big"
+	"log"
 	"os"
 	"fmt"
 )
@@ -15,9 +16,10 @@ func main() {
 	redisURL := os.Args[1]
 	baseFeeString := os.Args[2]
 	authorizedAddresses := os.Args[3:]
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	baseFeeService := config.NewBaseFee(redisClient)
 	baseFeeInt := new(big.Int)
 	baseFeeInt.SetString(baseFeeString, 10)
@@ -1,18 +1,20 @@
 package main
 
 import (
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/cmd/cmdutils"
+	"github.com/notegio/openrelay/metrics"
 	dbModule "github.com/notegio/openrelay/db"
 	"github.com/notegio/openrelay/metadata"
 	// "github.com/notegio/openrelay/funds"
-	"gopkg.in/redis.v3"
 	"log"
 	"os"
-	"os/signal"
 	"strconv"
 )
 
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
 	redisURL := os.Args[1]
 	srcChannel := os.Args[2]
 	db, err := dbModule.GetDB(os.Args[3], os.Args[4])
@@ -21,9 +23,10 @@ func main() {
 	}
 	rpcURL := os.Args[5]
 
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	consumerChannel, err := channels.ConsumerFromURI(srcChannel, redisClient)
 	if err != nil {
 		log.Fatalf("Error establishing consumer channel: %v", err.Error())
@@ -36,13 +39,12 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error launching metadata indexer: %v", err.Error())
 	}
-	consumerChannel.AddConsumer(orderMetadtaConsumer)
+	drainingConsumer := channels.NewDrainingConsumer(orderMetadtaConsumer)
+	consumerChannel.AddConsumer(drainingConsumer)
 	consumerChannel.StartConsuming()
 	log.Printf("Starting order metadata indexer consumer on '%v'", srcChannel)
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	for _ = range c {
-		break
-	}
-	consumerChannel.StopConsuming()
+	<-cmdutils.SignalContext().Done()
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	channels.Shutdown(drainCtx, consumerChannel, drainingConsumer)
 }
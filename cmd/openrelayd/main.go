@@ -0,0 +1,88 @@
+package main
+
+import (
+	"github.com/notegio/openrelay/cmd/cmdutils"
+	"github.com/notegio/openrelay/metrics"
+	"github.com/notegio/openrelay/pipeline"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// openrelayd runs stages of a pipeline spec in a single process.
+//
+//   openrelayd pipeline.json [--validate] [stage ...]
+//
+// With no stages listed, every stage in the spec is run. The whole spec is
+// validated either way, so that a subset can't be started against a broken
+// graph. --validate checks the spec and exits without running anything.
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatalf("Usage: %v pipeline.json [--validate] [stage ...]", os.Args[0])
+	}
+	spec, err := pipeline.LoadSpec(os.Args[1])
+	if err != nil {
+		log.Fatalf("Error loading pipeline: %v", err.Error())
+	}
+	validateOnly := false
+	var stageNames []string
+	for _, arg := range os.Args[2:] {
+		if arg == "--validate" {
+			validateOnly = true
+		} else {
+			stageNames = append(stageNames, arg)
+		}
+	}
+	if err := spec.Validate(); err != nil {
+		log.Fatal(err.Error())
+	}
+	stageSpecs := spec.Stages
+	if len(stageNames) > 0 {
+		stageSpecs = []pipeline.StageSpec{}
+		for _, name := range stageNames {
+			stageSpec, ok := spec.Stage(name)
+			if !ok {
+				log.Fatalf("Pipeline has no stage named '%v'", name)
+			}
+			stageSpecs = append(stageSpecs, stageSpec)
+		}
+	}
+	if validateOnly {
+		log.Printf("Pipeline is valid")
+		return
+	}
+	metrics.Serve(cmdutils.SignalContext())
+	if err := cmdutils.ConfigureContractSignatures(spec.RPC, strings.Join(spec.SignatureValidators, ",")); err != nil {
+		log.Fatalf("Error configuring contract signatures: %v", err.Error())
+	}
+	env := pipeline.NewEnv(spec)
+	stages := []pipeline.Stage{}
+	for _, stageSpec := range stageSpecs {
+		stage, err := pipeline.Build(env, stageSpec)
+		if err != nil {
+			log.Fatalf("Error building stage '%v': %v", stageSpec.Name, err.Error())
+		}
+		stages = append(stages, stage)
+	}
+	for i, stage := range stages {
+		if err := stage.Start(); err != nil {
+			log.Fatalf("Error starting stage '%v': %v", stageSpecs[i].Name, err.Error())
+		}
+		log.Printf("Started %v stage '%v'", stageSpecs[i].Kind, stageSpecs[i].Name)
+	}
+	<-cmdutils.SignalContext().Done()
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	wg := &sync.WaitGroup{}
+	for i, stage := range stages {
+		wg.Add(1)
+		go func(name string, stage pipeline.Stage) {
+			defer wg.Done()
+			if returned := stage.Stop(drainCtx); returned > 0 {
+				log.Printf("Stage '%v' returned %v unfinished deliveries", name, returned)
+			}
+		}(stageSpecs[i].Name, stage)
+	}
+	wg.Wait()
+}
@@ -9,12 +9,13 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 )
 
 
 func main() {
-	if len(os.Args) != 9 {
-		log.Fatalf("Usage: poolmgr DB_CONNECTION_STRING DB_PASSWORD POOL_NAME SEARCH_STRING FEE_SHARE SENDER_ADDRESS FILTER_ADDRESS NETWORK_ID")
+	if len(os.Args) != 9 && len(os.Args) != 10 {
+		log.Fatalf("Usage: poolmgr DB_CONNECTION_STRING DB_PASSWORD POOL_NAME SEARCH_STRING FEE_SHARE SENDER_ADDRESS FILTER_ADDRESS NETWORK_ID [SIGNATURE_TYPES]")
 	}
 	db, err := dbModule.GetDB(os.Args[1], os.Args[2])
 	if err != nil {
@@ -37,6 +38,24 @@ func main() {
 		log.Fatalf("Bad network id: %v", err.Error())
 	}
 
+	// SIGNATURE_TYPES is a comma separated list of the signature types to
+	// accept in addition to EIP712 and EthSign
+	signatureTypes := []byte{}
+	if len(os.Args) == 10 && os.Args[9] != "" {
+		for _, name := range strings.Split(os.Args[9], ",") {
+			switch name {
+			case "wallet":
+				signatureTypes = append(signatureTypes, types.SigTypeWallet)
+			case "validator":
+				signatureTypes = append(signatureTypes, types.SigTypeValidator)
+			case "presigned":
+				signatureTypes = append(signatureTypes, types.SigTypePreSigned)
+			default:
+				log.Fatalf("Unsupported signature type '%v'", name)
+			}
+		}
+	}
+
 
 	pool := &poolModule.Pool{
 		SearchTerms: os.Args[4],
@@ -46,6 +65,7 @@ func main() {
 		ID: poolHash.Sum(nil),
 		SenderAddresses: types.NetworkAddressMap{uint(networkID): senderAddress},
 		FilterAddresses: types.NetworkAddressMap{uint(networkID): filterAddress},
+		SignatureTypes: signatureTypes,
 	}
 
 	err = db.Debug().Model(&poolModule.Pool{}).Assign(pool).FirstOrCreate(pool).Error
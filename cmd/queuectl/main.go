@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/common"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/monitor/blocks"
+	"github.com/notegio/openrelay/types"
+	"gopkg.in/redis.v3"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const usage = `Usage: queuectl REDIS_URL COMMAND [ARGS]
+
+Commands:
+  list                                  List queues with unacked or rejected messages
+  peek QUEUE [LIST] [COUNT]             Show and decode messages on LIST (ready, unacked
+                                        or rejected; default rejected)
+  requeue QUEUE all|INDEX...|HASH...    Move rejected messages back to the ready list,
+                                        selected by their index in 'peek' output or by
+                                        order hash
+  rehome QUEUE [CONSUMER]               Return unacked messages to the ready list. For
+                                        stream:// queues, only messages owned by
+                                        CONSUMER are returned
+  purge QUEUE AGE                       Remove rejected messages older than AGE (eg. 24h)
+
+QUEUE may be a channel URI (queue://name, stream://name?group=g) or a bare
+queue name, which is treated as queue://name.`
+
+func queueURI(arg string) string {
+	if strings.Contains(arg, "://") {
+		return arg
+	}
+	return "queue://" + arg
+}
+
+func getInspector(arg string, redisClient *redis.Client) (channels.ConsumerChannel, channels.Inspector) {
+	consumerChannel, err := channels.ConsumerFromURI(queueURI(arg), redisClient)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	inspector, ok := consumerChannel.(channels.Inspector)
+	if !ok {
+		log.Fatalf("'%v' does not support inspection", arg)
+	}
+	return consumerChannel, inspector
+}
+
+// describe decodes a payload into a human readable form. Payloads on
+// OpenRelay queues are encoded orders or JSON records, optionally wrapped
+// in an envelope with headers.
+func describe(payload string) string {
+	headers, payload := channels.Unwrap(payload)
+	if headers != nil {
+		return fmt.Sprintf("%v (origin: %v, correlation id: %v)", describe(payload), headers[channels.HeaderOrigin], headers[channels.HeaderCorrelationID])
+	}
+	if strings.HasPrefix(payload, "{") {
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(payload), &fields); err != nil {
+			return fmt.Sprintf("invalid JSON: %v", err.Error())
+		}
+		if _, ok := fields["orderHash"]; ok {
+			fillRecord := &dbModule.FillRecord{}
+			if err := json.Unmarshal([]byte(payload), fillRecord); err == nil {
+				return fmt.Sprintf("FillRecord %v filled: %v cancel: %v", fillRecord.OrderHash, fillRecord.FilledTakerAssetAmount, fillRecord.Cancel)
+			}
+		}
+		if _, ok := fields["bloom"]; ok {
+			block := &blocks.MiniBlock{}
+			if err := json.Unmarshal([]byte(payload), block); err == nil {
+				return fmt.Sprintf("MiniBlock %v hash: %#x", block.Number, block.Hash[:])
+			}
+		}
+		if _, ok := fields["spenderAddress"]; ok {
+			spendRecord := &dbModule.SpendRecord{}
+			if err := json.Unmarshal([]byte(payload), spendRecord); err == nil {
+				return fmt.Sprintf("SpendRecord spender: %v asset: %v balance: %v", spendRecord.SpenderAddress, spendRecord.AssetData, spendRecord.Balance)
+			}
+		}
+		return fmt.Sprintf("JSON %v", payload)
+	}
+	order, err := types.OrderFromBytes([]byte(payload))
+	if err != nil {
+		return fmt.Sprintf("unknown payload %#x", payload)
+	}
+	orderJSON, err := json.Marshal(order)
+	if err != nil {
+		return fmt.Sprintf("Order %#x", order.Hash())
+	}
+	return fmt.Sprintf("Order %#x %s", order.Hash(), orderJSON)
+}
+
+// payloadHash returns the order hash a payload refers to, if any
+func payloadHash(payload string) string {
+	_, payload = channels.Unwrap(payload)
+	if strings.HasPrefix(payload, "{") {
+		fillRecord := &dbModule.FillRecord{}
+		if err := json.Unmarshal([]byte(payload), fillRecord); err == nil {
+			return strings.ToLower(fillRecord.OrderHash)
+		}
+		return ""
+	}
+	if order, err := types.OrderFromBytes([]byte(payload)); err == nil {
+		return fmt.Sprintf("%#x", order.Hash())
+	}
+	return ""
+}
+
+func list(redisClient *redis.Client) {
+	queues, err := channels.ListQueues(redisClient)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	for _, queue := range queues {
+		_, inspector := getInspector(queue, redisClient)
+		counts, err := inspector.Counts()
+		if err != nil {
+			// Skip keys that look like queues but aren't, rather than giving up
+			fmt.Printf("%v	error: %v\n", queue, err.Error())
+			continue
+		}
+		fmt.Printf("%v\tready: %v\tunacked: %v\trejected: %v\n", queue, counts[channels.ReadyList], counts[channels.UnackedList], counts[channels.RejectedList])
+	}
+}
+
+func peek(redisClient *redis.Client, args []string) {
+	if len(args) < 1 {
+		log.Fatalf(usage)
+	}
+	listName := channels.RejectedList
+	if len(args) > 1 {
+		listName = args[1]
+	}
+	count := int64(10)
+	if len(args) > 2 {
+		var err error
+		if count, err = strconv.ParseInt(args[2], 10, 64); err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+	_, inspector := getInspector(args[0], redisClient)
+	payloads, err := inspector.Peek(listName, count)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	for i, payload := range payloads {
+		fmt.Printf("%v\t%v\n", i, describe(payload))
+	}
+}
+
+func requeue(redisClient *redis.Client, args []string) {
+	if len(args) < 2 {
+		log.Fatalf(usage)
+	}
+	_, inspector := getInspector(args[0], redisClient)
+	var match func(string) bool
+	if args[1] == "all" {
+		match = func(string) bool { return true }
+	} else {
+		counts, err := inspector.Counts()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		rejected, err := inspector.Peek(channels.RejectedList, counts[channels.RejectedList])
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		selected := make(map[string]bool)
+		hashes := make(map[string]bool)
+		for _, arg := range args[1:] {
+			if strings.HasPrefix(arg, "0x") {
+				hashes[strings.ToLower(arg)] = true
+				continue
+			}
+			index, err := strconv.Atoi(arg)
+			if err != nil || index < 0 || index >= len(rejected) {
+				log.Fatalf("Invalid selection '%v'", arg)
+			}
+			selected[rejected[index]] = true
+		}
+		match = func(payload string) bool {
+			return selected[payload] || (len(hashes) > 0 && hashes[payloadHash(payload)])
+		}
+	}
+	count, err := inspector.RequeueRejected(match)
+	if err != nil {
+		log.Fatalf("Requeued %v messages before error: %v", count, err.Error())
+	}
+	log.Printf("Requeued %v messages", count)
+}
+
+func rehome(redisClient *redis.Client, args []string) {
+	if len(args) < 1 {
+		log.Fatalf(usage)
+	}
+	consumerChannel, _ := getInspector(args[0], redisClient)
+	if rehomer, ok := consumerChannel.(channels.Rehomer); ok {
+		if len(args) < 2 {
+			log.Fatalf("Please specify the consumer to rehome messages from")
+		}
+		count, err := rehomer.Rehome(args[1])
+		if err != nil {
+			log.Fatalf("Returned %v messages before error: %v", count, err.Error())
+		}
+		log.Printf("Returned %v messages from %v", count, args[1])
+		return
+	}
+	// Queues don't track which consumer holds an unacked message, so all we
+	// can do is return everything. Make sure no live consumers are working on
+	// the queue first, or their messages will be processed twice.
+	log.Printf("Returned %v messages", consumerChannel.ReturnAllUnacked())
+}
+
+func purge(redisClient *redis.Client, args []string) {
+	if len(args) < 2 {
+		log.Fatalf(usage)
+	}
+	age, err := time.ParseDuration(args[1])
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	_, inspector := getInspector(args[0], redisClient)
+	count, err := inspector.PurgeRejectedBefore(time.Now().Add(-age))
+	if err != nil {
+		log.Fatalf("Purged %v messages before error: %v", count, err.Error())
+	}
+	log.Printf("Purged %v messages", count)
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		log.Fatalf(usage)
+	}
+	redisURL := os.Args[1]
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	args := os.Args[3:]
+	switch os.Args[2] {
+	case "list":
+		list(redisClient)
+	case "peek":
+		peek(redisClient, args)
+	case "requeue":
+		requeue(redisClient, args)
+	case "rehome":
+		rehome(redisClient, args)
+	case "purge":
+		purge(redisClient, args)
+	default:
+		log.Fatalf(usage)
+	}
+}
@@ -1,20 +1,26 @@
 package main
 
 import (
+	"context"
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/metrics"
 	"os"
 	"log"
 	"fmt"
-	"gopkg.in/redis.v3"
 	"strconv"
 	"time"
 )
 
+var queueLength = metrics.NewGauge("openrelay_queue_length", "Length of monitored Redis lists", "key")
+
 func main() {
+	metrics.Serve(context.Background())
 	counts := make(map[string]int64)
 	redisURL := os.Args[1]
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	threshInt, err := strconv.Atoi(os.Args[2])
 	if err != nil {
 		log.Fatalf(err.Error())
@@ -27,6 +33,7 @@ func main() {
 			if err != nil {
 				log.Fatalf(err.Error())
 			}
+			queueLength.Set(float64(counts[k]), k)
 			log.Printf("Initial Queue: %v - %v", k, counts[k])
 		}
 	}
@@ -38,6 +45,7 @@ func main() {
 			if err != nil {
 				log.Fatalf(err.Error())
 			}
+			queueLength.Set(float64(counts[k]), k)
 			if (counts[k] / threshold) > (v / threshold) {
 				log.Printf("Queue Increasing: %v - %v", k, counts[k])
 			} else if (counts[k] / threshold) < (v / threshold) {
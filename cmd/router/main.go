@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/cmd/cmdutils"
+	"github.com/notegio/openrelay/metrics"
+	"github.com/notegio/openrelay/splitter"
+	"log"
+	"os"
+	"strconv"
+)
+
+// router relays orders from a source channel to destinations picked by the
+// rules in a JSON routes file.
+//
+//   router redis:6379 queue://ingest routes.json
+func main() {
+	metrics.Serve(cmdutils.SignalContext())
+	if len(os.Args) < 4 {
+		log.Fatalf("Usage: %v redis_url source_uri routes.json", os.Args[0])
+	}
+	redisURL := os.Args[1]
+	src := os.Args[2]
+	config, err := splitter.LoadRouterConfig(os.Args[3])
+	if err != nil {
+		log.Fatalf("Error loading routes: %v", err.Error())
+	}
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	sourceConsumerChannel, err := channels.ConsumerFromURI(src, redisClient)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	concurrency, err := strconv.Atoi(os.Getenv("CONCURRENCY"))
+	if err != nil {
+		concurrency = 5
+	}
+	router, err := splitter.NewRouterConsumer(config, channels.NewRedisURITranslator(redisClient), concurrency)
+	if err != nil {
+		log.Fatalf("Error loading routes: %v", err.Error())
+	}
+	drainingConsumer := channels.NewDrainingConsumer(router)
+	sourceConsumerChannel.AddConsumer(drainingConsumer)
+	sourceConsumerChannel.StartConsuming()
+	log.Printf("Consuming on '%v'", src)
+	<-cmdutils.SignalContext().Done()
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	channels.Shutdown(drainCtx, sourceConsumerChannel, drainingConsumer)
+}
@@ -1,14 +1,16 @@
 package main
 
 import (
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/search"
+	"github.com/notegio/openrelay/metrics"
 	"github.com/notegio/openrelay/pool"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/cmd/cmdutils"
 	"github.com/notegio/openrelay/blockhash"
 	"github.com/notegio/openrelay/affiliates"
 	dbModule "github.com/notegio/openrelay/db"
 	"net/http"
-	"gopkg.in/redis.v3"
 	"os"
 	"log"
 	// "github.com/rs/cors"
@@ -61,6 +63,7 @@ func (h *regexpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
 	redisURL := os.Args[1]
 	blockChannel := os.Args[2]
 	db, err := dbModule.GetDB(os.Args[3], os.Args[4])
@@ -74,9 +77,10 @@ func main() {
 			port = arg
 		}
 	}
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	blockChannelConsumer, err := channels.ConsumerFromURI(blockChannel, redisClient)
 	if err != nil {
 		log.Fatalf("Error establishing block channel: %v", err.Error())
@@ -84,17 +88,28 @@ func main() {
 	blockHash := blockhash.NewChanneledBlockHash(blockChannelConsumer)
 	searchHandler := corsDecorator(search.BlockHashDecorator(blockHash, pool.PoolDecorator(db, search.SearchHandler(db))))
 	orderHandler := corsDecorator(search.BlockHashDecorator(blockHash, search.OrderHandler(db)))
+	orderHistoryHandler := corsDecorator(search.BlockHashDecorator(blockHash, search.OrderHistoryHandler(db)))
 	orderBookHandler := corsDecorator(search.BlockHashDecorator(blockHash, pool.PoolDecorator(db, search.OrderBookHandler(db))))
 	feeRecipientsHandler := corsDecorator(search.BlockHashDecorator(blockHash, search.FeeRecipientHandler(affiliates.NewRedisAffiliateService(redisClient))))
 	pairHandler := corsDecorator(search.PairHandler(db))
 
 	mux := &regexpHandler{[]*route{}}
-	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/orders$"), searchHandler)
-	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/order/"), orderHandler)
-	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/asset_pairs$"), pairHandler)
-	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/orderbook$"), orderBookHandler)
-	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/fee_recipients$"), feeRecipientsHandler)
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/orders$"), metrics.InstrumentHandler("orders", searchHandler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/order/0x[0-9a-fA-F]+/history$"), metrics.InstrumentHandler("order_history", orderHistoryHandler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/order/"), metrics.InstrumentHandler("order", orderHandler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/asset_pairs$"), metrics.InstrumentHandler("asset_pairs", pairHandler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/orderbook$"), metrics.InstrumentHandler("orderbook", orderBookHandler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/fee_recipients$"), metrics.InstrumentHandler("fee_recipients", feeRecipientsHandler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/sra/v3/orders$"), metrics.InstrumentHandler("orders_v3", searchHandler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/sra/v3/order/0x[0-9a-fA-F]+/history$"), metrics.InstrumentHandler("order_history_v3", orderHistoryHandler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/sra/v3/order/"), metrics.InstrumentHandler("order_v3", orderHandler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/sra/v3/asset_pairs$"), metrics.InstrumentHandler("asset_pairs_v3", pairHandler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/sra/v3/orderbook$"), metrics.InstrumentHandler("orderbook_v3", orderBookHandler))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/sra/v3/fee_recipients$"), metrics.InstrumentHandler("fee_recipients_v3", feeRecipientsHandler))
 	mux.HandleFunc(regexp.MustCompile("^/_hc$"), search.HealthCheckHandler(db, blockHash))
 	log.Printf("Order Search Serving on :%v", port)
-	http.ListenAndServe(":"+port, mux)
+	if err := cmdutils.ListenAndServe(cmdutils.SignalContext(), ":"+port, mux); err != nil {
+		log.Fatal(err.Error())
+	}
+	blockChannelConsumer.StopConsuming()
 }
@@ -1,9 +1,9 @@
 package main
 
 import (
+	"github.com/notegio/openrelay/common"
 	"os"
 	"encoding/hex"
-	"gopkg.in/redis.v3"
 	"github.com/notegio/openrelay/accounts"
 	"github.com/notegio/openrelay/types"
 	"log"
@@ -12,9 +12,10 @@ import (
 
 func main() {
 	redisURL := os.Args[1]
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	addrBytes, err := hex.DecodeString(os.Args[2])
 	if err != nil {
 		log.Fatalf(err.Error())
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/ingest"
+	"github.com/notegio/openrelay/types"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+const usage = `Usage: signorder [OPTIONS]
+
+Builds and signs 0x orders, writing them to stdout ready to POST to a relay's
+order endpoint. The private key is read from -key, or from the PRIVATE_KEY
+environment variable. If -fees is set, the relay's order config endpoint
+is asked for fees, fee recipient, sender and taker before signing.
+
+Options:`
+
+func address(name, value string) *types.Address {
+	if value == "" {
+		return nil
+	}
+	result, err := common.HexToAddress(value)
+	if err != nil {
+		log.Fatalf("Invalid %v '%v': %v", name, value, err.Error())
+	}
+	return result
+}
+
+func assetData(name, value string) types.AssetData {
+	result, err := common.HexToAssetData(value)
+	if err != nil {
+		log.Fatalf("Invalid %v '%v': %v", name, value, err.Error())
+	}
+	return result
+}
+
+func integer(name, value string) *big.Int {
+	result, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		log.Fatalf("Invalid %v '%v'", name, value)
+	}
+	return result
+}
+
+func main() {
+	key := flag.String("key", os.Getenv("PRIVATE_KEY"), "hex encoded private key of the maker")
+	exchange := flag.String("exchange", "", "exchange contract address (required)")
+	makerAssetData := flag.String("maker-asset", "", "maker asset data (required)")
+	takerAssetData := flag.String("taker-asset", "", "taker asset data (required)")
+	makerAssetAmount := flag.String("maker-amount", "1", "maker asset amount")
+	takerAssetAmount := flag.String("taker-amount", "1", "taker asset amount")
+	makerFee := flag.String("maker-fee", "0", "maker fee")
+	takerFee := flag.String("taker-fee", "0", "taker fee")
+	feeRecipient := flag.String("fee-recipient", "", "fee recipient address")
+	taker := flag.String("taker", "", "taker address")
+	sender := flag.String("sender", "", "sender address")
+	expiration := flag.Duration("expires", 24*time.Hour, "time until the order expires")
+	salt := flag.String("salt", "", "order salt (default random)")
+	sigType := flag.String("sig-type", "eip712", "signature type, eip712 or ethsign")
+	chainID := flag.Uint64("chain-id", 0, "create v3 orders for this chain id")
+	makerFeeAssetData := flag.String("maker-fee-asset", "", "maker fee asset data for v3 orders")
+	takerFeeAssetData := flag.String("taker-fee-asset", "", "taker fee asset data for v3 orders")
+	feeURL := flag.String("fees", "", "order config URL to request fees from, eg. http://localhost:8080/v2/order_config")
+	format := flag.String("format", "json", "output format, json or binary")
+	count := flag.Int("count", 1, "number of orders to generate, one JSON order per line")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *exchange == "" || *makerAssetData == "" || *takerAssetData == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *format != "json" && *format != "binary" {
+		log.Fatalf("Unknown format '%v'", *format)
+	}
+	if *format == "binary" && *count != 1 {
+		log.Fatalf("Only one order can be written in binary format")
+	}
+	signatureType := map[string]byte{"eip712": types.SigTypeEIP712, "ethsign": types.SigTypeEthSign}[strings.ToLower(*sigType)]
+	if signatureType == 0 {
+		log.Fatalf("Unsupported signature type '%v'", *sigType)
+	}
+	privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(*key, "0x"))
+	if err != nil {
+		log.Fatalf("Invalid private key: %v", err.Error())
+	}
+	maker := &types.Address{}
+	copy(maker[:], crypto.PubkeyToAddress(privateKey.PublicKey).Bytes())
+
+	for i := 0; i < *count; i++ {
+		orderSalt := new(big.Int)
+		if *salt != "" {
+			orderSalt = integer("salt", *salt)
+			orderSalt.Add(orderSalt, big.NewInt(int64(i)))
+		} else if orderSalt, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 256)); err != nil {
+			log.Fatalf("Error generating salt: %v", err.Error())
+		}
+		order := types.BuildOrder(
+			maker,
+			address("taker", *taker),
+			address("fee-recipient", *feeRecipient),
+			address("exchange", *exchange),
+			address("sender", *sender),
+			assetData("maker-asset", *makerAssetData),
+			assetData("taker-asset", *takerAssetData),
+			integer("maker-amount", *makerAssetAmount),
+			integer("taker-amount", *takerAssetAmount),
+			integer("maker-fee", *makerFee),
+			integer("taker-fee", *takerFee),
+			big.NewInt(time.Now().Add(*expiration).Unix()),
+			orderSalt,
+		)
+		if *chainID != 0 {
+			order.SetV3(*chainID, assetData("maker-fee-asset", *makerFeeAssetData), assetData("taker-fee-asset", *takerFeeAssetData))
+		}
+		if *feeURL != "" {
+			feeResponse, err := ingest.RequestFees(*feeURL, order)
+			if err != nil {
+				log.Fatalf("Error requesting fees: %v", err.Error())
+			}
+			if err := feeResponse.Apply(order); err != nil {
+				log.Fatalf("Invalid fee response: %v", err.Error())
+			}
+		}
+		if err := order.Sign(privateKey, signatureType); err != nil {
+			log.Fatalf("Error signing order: %v", err.Error())
+		}
+		if *format == "binary" {
+			// Relays accept the RLP encoding on their public endpoints
+			os.Stdout.Write(order.RLPBytes())
+			continue
+		}
+		orderJSON, err := json.Marshal(order)
+		if err != nil {
+			log.Fatalf("Error encoding order: %v", err.Error())
+		}
+		fmt.Println(string(orderJSON))
+	}
+}
@@ -1,35 +1,41 @@
 package main
 
 import (
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/metrics"
 	"github.com/notegio/openrelay/cmd/cmdutils"
-	"gopkg.in/redis.v3"
 	"os"
-	"os/signal"
 	"log"
 	"strconv"
 )
 
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
 	redisURL := os.Args[1]
 	// src := os.Args[2]
 	if redisURL == "" {
 		log.Fatalf("Please specify redis URL")
 	}
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	concurrency, err := strconv.Atoi(os.Getenv("CONCURRENCY"))
 	if err != nil {
 		concurrency = 5
 	}
+	partitionKey, err := cmdutils.PartitionKey()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	var relays []channels.Relay
 	for _, channelString := range os.Args[2:] {
 		consumerChannel, publisher, _, err := cmdutils.ParseChannels(channelString, redisClient)
 		if err != nil {
 			log.Fatalf(err.Error())
 		}
-		relay := channels.NewRelay(consumerChannel, publisher, &channels.IncludeAll{}, concurrency)
+		relay := channels.NewKeyedRelay(consumerChannel, publisher, &channels.IncludeAll{}, concurrency, partitionKey)
 		log.Printf("Starting simple relay '%v'", channelString)
 		relay.Start()
 		relays = append(relays, relay)
@@ -45,12 +51,13 @@ func main() {
 	// relay := channels.NewRelay(consumerChannel, publishers, &channels.IncludeAll{}, concurrency)
 	// log.Printf("Starting simple relay '%v' -> '%v'", src, os.Args[3:])
 	// relay.Start()
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	for _ = range c {
-		break
-	}
+	<-cmdutils.SignalContext().Done()
 	for _, relay := range relays {
 		relay.Stop()
 	}
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	for _, relay := range relays {
+		relay.Drain(drainCtx)
+	}
 }
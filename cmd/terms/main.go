@@ -2,6 +2,8 @@ package main
 
 import (
 	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/cmd/cmdutils"
+	"github.com/notegio/openrelay/metrics"
 	"github.com/notegio/openrelay/terms"
 	"github.com/rs/cors"
 	"net/http"
@@ -41,6 +43,7 @@ func (h *regexpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
 	db, err := dbModule.GetDB(os.Args[1], os.Args[2])
 	if err != nil {
 		log.Fatalf("Could not open database connection: %v", err.Error())
@@ -53,10 +56,12 @@ func main() {
 		}
 	}
 	mux := &regexpHandler{[]*route{}}
-	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/_tos/"), terms.TermsCheckHandler(db))
-	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/_tos"), terms.TermsHandler(db))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/_tos/"), metrics.InstrumentHandler("tos_check", terms.TermsCheckHandler(db)))
+	mux.HandleFunc(regexp.MustCompile("^(/[^/]+)?/v2/_tos"), metrics.InstrumentHandler("tos", terms.TermsHandler(db)))
 	mux.HandleFunc(regexp.MustCompile("^/_hc$"), terms.HealthCheckHandler(db))
 	corsHandler := cors.Default().Handler(mux)
 	log.Printf("ToS Serving on :%v", port)
-	http.ListenAndServe(":"+port, corsHandler)
+	if err := cmdutils.ListenAndServe(cmdutils.SignalContext(), ":"+port, corsHandler); err != nil {
+		log.Fatal(err.Error())
+	}
 }
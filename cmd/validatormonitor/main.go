@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/cmd/cmdutils"
+	"github.com/notegio/openrelay/metrics"
+	"github.com/notegio/openrelay/monitor/validators"
+	dbModule "github.com/notegio/openrelay/db"
+	"log"
+	"os"
+)
+
+func main() {
+	metrics.Serve(cmdutils.SignalContext())
+	redisURL := os.Args[1]
+	rpcURL := os.Args[2]
+	src := os.Args[3]
+	db, err := dbModule.GetDB(os.Args[4], os.Args[5])
+	if err != nil {
+		log.Fatalf("Could not open database connection: %v", err.Error())
+	}
+	dst := os.Args[6]
+	exchangeAddress := os.Args[7]
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	consumerChannel, err := channels.ConsumerFromURI(src, redisClient)
+	if err != nil {
+		log.Fatalf("Error constructing consumer: %v", err.Error())
+	}
+	publisher, err := channels.PublisherFromURI(dst, redisClient)
+	if err != nil {
+		log.Fatalf("Error constructing publisher: %v", err.Error())
+	}
+	consumer, err := validators.NewRPCApprovalBlockConsumer(rpcURL, exchangeAddress, db, publisher)
+	if err != nil {
+		log.Fatalf("Error constructing validator approval monitor: %v", err.Error())
+	}
+	drainingConsumer := channels.NewDrainingConsumer(consumer)
+	consumerChannel.AddConsumer(drainingConsumer)
+	consumerChannel.StartConsuming()
+	log.Printf("Started consuming blocks from channel %v for exchange %v, publishing to %v", src, exchangeAddress, dst)
+	<-cmdutils.SignalContext().Done()
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	channels.Shutdown(drainCtx, consumerChannel, drainingConsumer)
+}
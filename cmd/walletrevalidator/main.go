@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/cmd/cmdutils"
+	"github.com/notegio/openrelay/metrics"
+	"github.com/notegio/openrelay/monitor/walletsig"
+	dbModule "github.com/notegio/openrelay/db"
+	"log"
+	"os"
+	"strconv"
+)
+
+func main() {
+	metrics.Serve(cmdutils.SignalContext())
+	redisURL := os.Args[1]
+	rpcURL := os.Args[2]
+	srcChannel := os.Args[3]
+	db, err := dbModule.GetDB(os.Args[4], os.Args[5])
+	if err != nil {
+		log.Fatalf("Could not open database connection: %v", err.Error())
+	}
+	destChannel := os.Args[6]
+	interval := int64(100)
+	if len(os.Args) > 7 {
+		if interval, err = strconv.ParseInt(os.Args[7], 10, 64); err != nil || interval < 1 {
+			log.Fatalf("Invalid block interval: %v", os.Args[7])
+		}
+	}
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	consumerChannel, err := channels.ConsumerFromURI(srcChannel, redisClient)
+	if err != nil {
+		log.Fatalf("Error establishing consumer channel: %v", err.Error())
+	}
+	publisher, err := channels.PublisherFromURI(destChannel, redisClient)
+	if err != nil {
+		log.Fatalf("Error establishing publisher channel: %v", err.Error())
+	}
+	consumer, err := walletsig.NewRPCRevalidationBlockConsumer(db, rpcURL, interval, publisher)
+	if err != nil {
+		log.Fatalf("Error constructing wallet revalidator: %v", err.Error())
+	}
+	drainingConsumer := channels.NewDrainingConsumer(consumer)
+	consumerChannel.AddConsumer(drainingConsumer)
+	consumerChannel.StartConsuming()
+	log.Printf("Revalidating wallet signatures every %v blocks from '%v'", interval, srcChannel)
+	<-cmdutils.SignalContext().Done()
+	drainCtx, cancel := cmdutils.DrainContext()
+	defer cancel()
+	channels.Shutdown(drainCtx, consumerChannel, drainingConsumer)
+}
@@ -1,13 +1,14 @@
 package main
 
 import (
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/subscriptions"
+	"github.com/notegio/openrelay/metrics"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/cmd/cmdutils"
 	dbModule "github.com/notegio/openrelay/db"
 	"net/http"
-	"gopkg.in/redis.v3"
 	"os"
-	"os/signal"
 	"log"
 	"strconv"
 	"regexp"
@@ -43,6 +44,7 @@ func (h *regexpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	metrics.Serve(cmdutils.SignalContext())
 	redisURL := os.Args[1]
 	orderChannel := os.Args[2]
 	db, err := dbModule.GetDB(os.Args[3], os.Args[4])
@@ -56,9 +58,10 @@ func main() {
 			port = uint(portConv)
 		}
 	}
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	redisClient, err := common.NewRedisClient(redisURL)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	orderChannelConsumer, err := channels.ConsumerFromURI(orderChannel, redisClient)
 	if err != nil {
 		log.Fatalf("Error establishing block channel: %v", err.Error())
@@ -68,13 +71,14 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error listening for subscriptions: %v", err.Error())
 	}
+	if history, ok := orderChannelConsumer.(channels.TopicHistory); ok && history.HistoryEnabled() {
+		// The order channel is a replayable topic, so clients can resume
+		// subscriptions from the last update they saw
+		manager.SetHistory(history)
+	}
 	orderChannelConsumer.AddConsumer(manager)
 	orderChannelConsumer.StartConsuming()
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	for _ = range c {
-		break
-	}
+	<-cmdutils.SignalContext().Done()
 	orderChannelConsumer.StopConsuming()
 	quit()
 }
@@ -0,0 +1,130 @@
+package common
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"gopkg.in/redis.v3"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedisConfig is a parsed redis URI. MasterName is only set for sentinel
+// URIs, in which case Addrs lists the sentinels rather than the server.
+type RedisConfig struct {
+	Addrs      []string
+	MasterName string
+	Password   string
+	DB         int64
+	TLS        bool
+}
+
+// ParseRedisURI parses `uri`, which may be a bare host:port, or a URI such as
+//
+//    redis://:password@host:6379/2
+//    rediss://host:6380?password=env://REDIS_PASSWORD
+//    redis://host:6379?tls=true&password=file:///run/secrets/redis
+//    redis-sentinel://:password@sentinel-1:26379,sentinel-2:26379/mymaster/2
+//
+// Passwords are resolved with GetSecret, whether they are given in the URI's
+// user info or its password parameter. The username is ignored, as the
+// client only supports password authentication. The path is the database
+// number, preceded by the master name for sentinel URIs. rediss:// or
+// tls=true connect over TLS, which is not supported with sentinel.
+func ParseRedisURI(uri string) (*RedisConfig, error) {
+	if !strings.Contains(uri, "://") {
+		return &RedisConfig{Addrs: []string{uri}}, nil
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	query := parsed.Query()
+	password := query.Get("password")
+	if parsed.User != nil {
+		if userPassword, ok := parsed.User.Password(); ok {
+			password = userPassword
+		}
+	}
+	config := &RedisConfig{
+		Password: GetSecret(password),
+		TLS:      parsed.Scheme == "rediss" || query.Get("tls") == "true",
+	}
+	path := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	switch parsed.Scheme {
+	case "redis", "rediss":
+		if parsed.Host == "" {
+			return nil, fmt.Errorf("Redis URI '%v' must include a host", uri)
+		}
+		if len(path) > 1 {
+			return nil, fmt.Errorf("Unexpected path in redis URI '%v'", uri)
+		}
+		if config.DB, err = redisDB(path[0]); err != nil {
+			return nil, err
+		}
+		config.Addrs = []string{parsed.Host}
+	case "redis-sentinel":
+		if config.TLS {
+			return nil, errors.New("TLS is not supported with redis-sentinel:// URIs")
+		}
+		if parsed.Host == "" || path[0] == "" || len(path) > 2 {
+			return nil, fmt.Errorf("Sentinel URI '%v' must be of the form redis-sentinel://host:port,.../master[/db]", uri)
+		}
+		if len(path) == 2 {
+			if config.DB, err = redisDB(path[1]); err != nil {
+				return nil, err
+			}
+		}
+		config.MasterName = path[0]
+		config.Addrs = strings.Split(parsed.Host, ",")
+	default:
+		return nil, fmt.Errorf("Unsupported redis URI scheme '%v', expected redis://, rediss:// or redis-sentinel://", parsed.Scheme)
+	}
+	return config, nil
+}
+
+// NewRedisClient returns a redis client for `uri`, in any of the forms
+// accepted by ParseRedisURI.
+func NewRedisClient(uri string) (*redis.Client, error) {
+	config, err := ParseRedisURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	if config.MasterName != "" {
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    config.MasterName,
+			SentinelAddrs: config.Addrs,
+			Password:      config.Password,
+			DB:            config.DB,
+		}), nil
+	}
+	options := &redis.Options{Addr: config.Addrs[0], Password: config.Password, DB: config.DB}
+	if config.TLS {
+		options.Dialer = tlsDialer(config.Addrs[0])
+	}
+	return redis.NewClient(options), nil
+}
+
+func redisDB(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	db, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || db < 0 {
+		return 0, fmt.Errorf("Invalid redis database '%v'", value)
+	}
+	return db, nil
+}
+
+func tlsDialer(addr string) func() (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return func() (net.Conn, error) {
+		return tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", addr, &tls.Config{ServerName: host})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/notegio/openrelay/aws"
 	"github.com/notegio/openrelay/types"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"strings"
 )
@@ -86,6 +87,74 @@ func ToERC721AssetData(address *types.Address, tokenID *types.Uint256) (types.As
 	return assetData
 }
 
+// ToERC1155AssetData encodes ERC1155 asset data trading `values[i]` units of
+// each of `tokenIDs[i]` per unit of the order's asset amount.
+func ToERC1155AssetData(address *types.Address, tokenIDs, values []*big.Int, callbackData []byte) (types.AssetData) {
+	word := func(value int) []byte {
+		return abi.U256(big.NewInt(int64(value)))
+	}
+	uints := func(values []*big.Int) []byte {
+		encoded := word(len(values))
+		for _, value := range values {
+			encoded = append(encoded, abi.U256(new(big.Int).Set(value))...)
+		}
+		return encoded
+	}
+	idsData := uints(tokenIDs)
+	valuesData := uints(values)
+	callback := make([]byte, (len(callbackData)+31)/32*32)
+	copy(callback, callbackData)
+	assetData := append([]byte{}, types.ERC1155ProxyID[:]...)
+	assetData = append(assetData, make([]byte, 12)...)
+	assetData = append(assetData, address[:]...)
+	assetData = append(assetData, word(128)...)
+	assetData = append(assetData, word(128+len(idsData))...)
+	assetData = append(assetData, word(128+len(idsData)+len(valuesData))...)
+	assetData = append(assetData, idsData...)
+	assetData = append(assetData, valuesData...)
+	assetData = append(assetData, word(len(callbackData))...)
+	assetData = append(assetData, callback...)
+	return types.AssetData(assetData)
+}
+
+// ToMultiAssetData encodes a MultiAssetProxy bundle of `components`, each
+// traded `amounts` times per unit of the order's asset amount.
+func ToMultiAssetData(amounts []*big.Int, components []types.AssetData) (types.AssetData) {
+	word := func(value int) []byte {
+		return abi.U256(big.NewInt(int64(value)))
+	}
+	amountsData := word(len(amounts))
+	for _, amount := range amounts {
+		amountsData = append(amountsData, abi.U256(new(big.Int).Set(amount))...)
+	}
+	heads := []byte{}
+	tails := []byte{}
+	for _, component := range components {
+		heads = append(heads, word(32*len(components)+len(tails))...)
+		padded := make([]byte, (len(component)+31)/32*32)
+		copy(padded, component[:])
+		tails = append(tails, word(len(component))...)
+		tails = append(tails, padded...)
+	}
+	assetData := append([]byte{}, types.MultiAssetProxyID[:]...)
+	assetData = append(assetData, word(64)...)
+	assetData = append(assetData, word(64+len(amountsData))...)
+	assetData = append(assetData, amountsData...)
+	assetData = append(assetData, word(len(components))...)
+	assetData = append(assetData, heads...)
+	assetData = append(assetData, tails...)
+	return types.AssetData(assetData)
+}
+
+// RequestVersion returns the 0x protocol version served by the request's
+// route. SRA v3 routes live under /sra/v3/, everything else serves v2.
+func RequestVersion(r *http.Request) uint8 {
+	if strings.Contains(r.URL.Path, "/sra/v3/") {
+		return types.OrderVersion3
+	}
+	return types.OrderVersion2
+}
+
 // GetSecret retrieves a secret from various supported secret stores
 func GetSecret(uri string) string {
 	if strings.HasPrefix(uri, "file://") {
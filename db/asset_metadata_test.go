@@ -233,6 +233,9 @@ func TestPopulateOrderMetadata(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	if err := tx.Model(&dbModule.AssetMetadata{}).Create(asset).Error; err != nil {
 		t.Fatalf(err.Error())
 	}
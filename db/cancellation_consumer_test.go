@@ -22,6 +22,9 @@ func TestCancellationConsumer(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	if err := tx.AutoMigrate(&dbModule.Cancellation{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
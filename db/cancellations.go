@@ -9,6 +9,9 @@ type Cancellation struct {
 	Maker  *types.Address `gorm:"primary_key"`
 	Sender *types.Address `gorm:"primary_key"`
 	Epoch  *types.Uint256
+	// BlockNumber is the block of the CancelUpTo event. It is only used to
+	// record order history, and isn't stored.
+	BlockNumber uint64 `gorm:"-" json:",omitempty"`
 }
 
 func (cancellation *Cancellation) Save(db *gorm.DB) *gorm.DB {
@@ -10,9 +10,37 @@ import (
 	"fmt"
 )
 
+var connectionStringRegex = regexp.MustCompile("([^:]+)://([^@]+)@([^/]*)(/.*)?")
+
+// pgConnectionString builds a lib/pq connection string from the parts of a
+// connection string matched by connectionStringRegex
+func pgConnectionString(match []string, password string) string {
+	dbname := strings.TrimPrefix(match[4], "/")
+	if dbname == "" {
+		dbname = "postgres"
+	}
+	return fmt.Sprintf(
+		"host=%v dbname=%v sslmode=disable user=%v password=%v",
+		match[3],
+		dbname,
+		match[2],
+		password,
+	)
+}
+
+// PostgresConnectionString converts a postgres:// connection string of the
+// form GetDB accepts into one lib/pq accepts, for things that need to talk
+// to Postgres without gorm.
+func PostgresConnectionString(connectionString, passwordURI string) (string, error) {
+	match := connectionStringRegex.FindStringSubmatch(connectionString)
+	if match == nil || match[1] != "postgres" {
+		return "", fmt.Errorf("Parsing postgres connection string '%v' failed", connectionString)
+	}
+	return pgConnectionString(match, common.GetSecret(passwordURI)), nil
+}
+
 func GetDB(connectionString, passwordURI string) (*gorm.DB, error) {
 	password := common.GetSecret(passwordURI)
-	connectionStringRegex := regexp.MustCompile("([^:]+)://([^@]+)@([^/]*)(/.*)?")
 	match := connectionStringRegex.FindStringSubmatch(connectionString)
 	if match == nil {
 		return nil, fmt.Errorf("Parsing connection string '%v' failed", connectionString)
@@ -22,17 +50,7 @@ func GetDB(connectionString, passwordURI string) (*gorm.DB, error) {
 		dbname = cstringDbName
 	}
 	if match[1] == "postgres" {
-		if dbname == "OR_DEFAULT_DB" {
-			dbname = "postgres"
-		}
-		pgConnectionString := fmt.Sprintf(
-			"host=%v dbname=%v sslmode=disable user=%v password=%v",
-			match[3],
-			dbname,
-			match[2],
-			password,
-		)
-		return gorm.Open("postgres", pgConnectionString)
+		return gorm.Open("postgres", pgConnectionString(match, password))
 	} else if match[1] == "mysql" {
 		if dbname == "OR_DEFAULT_DB" {
 			dbname = "mysql"
@@ -30,9 +30,15 @@ func (consumer *IndexConsumer) Consume(msg channels.Delivery) {
 			return
 		}
 		if err := consumer.idx.Index(order); err == nil {
+			if correlationID := msg.Headers()[channels.HeaderCorrelationID]; correlationID != "" {
+				log.Printf("Indexed order %#x (correlation id %v)", order.Hash(), correlationID)
+			}
 			msg.Ack()
+		} else if _, ok := err.(*SignatureCheckError); ok {
+			log.Printf("Returning order: '%#x', '%v' (correlation id %v)", order.Hash(), err.Error(), msg.Headers()[channels.HeaderCorrelationID])
+			msg.Return()
 		} else {
-			log.Printf("Failed to index order: '%v', '%v'", order.Hash(), err.Error())
+			log.Printf("Failed to index order: '%#x', '%v' (correlation id %v)", order.Hash(), err.Error(), msg.Headers()[channels.HeaderCorrelationID])
 			msg.Reject()
 		}
 	}()
@@ -41,3 +47,57 @@ func (consumer *IndexConsumer) Consume(msg channels.Delivery) {
 func NewIndexConsumer(db *gorm.DB, status int64, concurrency int, publisher channels.Publisher) *IndexConsumer {
 	return &IndexConsumer{NewIndexer(db, status, publisher), make(common.Semaphore, concurrency)}
 }
+
+// IndexBatchConsumer indexes batches of orders with Indexer.IndexBatch, for
+// use with a channels.BatchingConsumer.
+type IndexBatchConsumer struct {
+	idx *Indexer
+	s   common.Semaphore
+}
+
+func (consumer *IndexBatchConsumer) ConsumeBatch(msgs []channels.Delivery) {
+	consumer.s.Acquire()
+	go func(){
+		defer consumer.s.Release()
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Failed to index batch: %v", r)
+				// Deliveries that were already settled will ignore this
+				for _, msg := range msgs {
+					msg.Reject()
+				}
+			}
+		}()
+		orders := []*types.Order{}
+		parsed := []channels.Delivery{}
+		for _, msg := range msgs {
+			order, err := types.OrderFromBytes([]byte(msg.Payload()))
+			if err != nil {
+				log.Printf("Error parsing order: %v", err.Error())
+				msg.Reject()
+				continue
+			}
+			orders = append(orders, order)
+			parsed = append(parsed, msg)
+		}
+		for i, err := range consumer.idx.IndexBatch(orders) {
+			msg := parsed[i]
+			if err == nil {
+				if correlationID := msg.Headers()[channels.HeaderCorrelationID]; correlationID != "" {
+					log.Printf("Indexed order %#x (correlation id %v)", orders[i].Hash(), correlationID)
+				}
+				msg.Ack()
+			} else if _, ok := err.(*SignatureCheckError); ok {
+				log.Printf("Returning order: '%#x', '%v' (correlation id %v)", orders[i].Hash(), err.Error(), msg.Headers()[channels.HeaderCorrelationID])
+				msg.Return()
+			} else {
+				log.Printf("Failed to index order: '%#x', '%v' (correlation id %v)", orders[i].Hash(), err.Error(), msg.Headers()[channels.HeaderCorrelationID])
+				msg.Reject()
+			}
+		}
+	}()
+}
+
+func NewIndexBatchConsumer(db *gorm.DB, status int64, concurrency int, publisher channels.Publisher) *IndexBatchConsumer {
+	return &IndexBatchConsumer{NewIndexer(db, status, publisher), make(common.Semaphore, concurrency)}
+}
@@ -1,8 +1,10 @@
 package db_test
 
 import (
+	"errors"
 	"github.com/notegio/openrelay/channels"
 	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/types"
 	"reflect"
 	"testing"
 	"time"
@@ -22,6 +24,9 @@ func IndexConsumerDefaultStatus(status int64, t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	order := sampleOrder(t)
 	publisher, channel := channels.MockChannel()
 	dsPublisher, ch := channels.MockPublisher()
@@ -62,3 +67,39 @@ func TestIndexConsumerOpenStatus(t *testing.T) {
 func TestIndexConsumerUnfundedStatus(t *testing.T) {
 	IndexConsumerDefaultStatus(dbModule.StatusUnfunded, t)
 }
+
+type settleDelivery struct {
+	payload string
+	settled chan string
+}
+
+func (delivery *settleDelivery) Payload() string          { return delivery.payload }
+func (delivery *settleDelivery) Headers() channels.Headers { return nil }
+func (delivery *settleDelivery) Ack() bool                 { delivery.settled <- "ack"; return true }
+func (delivery *settleDelivery) Reject() bool              { delivery.settled <- "reject"; return true }
+func (delivery *settleDelivery) Return() bool              { delivery.settled <- "return"; return true }
+
+type failingWalletVerifier struct{}
+
+func (verifier *failingWalletVerifier) IsValidWalletSignature(wallet *types.Address, hash []byte, signature []byte) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestIndexBatchConsumerReturnsUnverified(t *testing.T) {
+	types.SetWalletVerifier(&failingWalletVerifier{})
+	defer types.SetWalletVerifier(nil)
+	order := sampleOrder(t)
+	order.Signature = types.Signature{0xde, 0xad, types.SigTypeWallet}
+	delivery := &settleDelivery{string(order.Bytes()), make(chan string, 1)}
+	// The signature is checked before the database is used
+	consumer := dbModule.NewIndexBatchConsumer(nil, dbModule.StatusOpen, 1, nil)
+	consumer.ConsumeBatch([]channels.Delivery{delivery})
+	select {
+	case result := <-delivery.settled:
+		if result != "return" {
+			t.Errorf("Expected the order to be returned, got %v", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Delivery was not settled")
+	}
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/jinzhu/gorm"
@@ -17,6 +18,7 @@ type FillRecord struct {
 	OrderHash                 string `json:"orderHash"`
 	FilledTakerAssetAmount    string `json:"filledTakerAssetAmount"`
 	Cancel                    bool   `json:"cancel"`
+	BlockNumber               uint64 `json:"blockNumber,omitempty"`
 }
 
 type Indexer struct {
@@ -32,6 +34,66 @@ func (indexer *Indexer) Index(order *types.Order) error {
 	return dbOrder.Save(indexer.db, indexer.status, indexer.publisher).Error
 }
 
+// IndexBatch saves many orders at once, returning an error for each order
+// that could not be saved, or nil for those that were. Orders are written
+// with a single upsert where the database supports it; if that fails, they
+// are saved one at a time so that one bad order doesn't fail the rest.
+func (indexer *Indexer) IndexBatch(orders []*types.Order) []error {
+	errs := make([]error, len(orders))
+	dbOrders := []*Order{}
+	indices := []int{}
+	for i, order := range orders {
+		if valid, err := order.Signature.VerifyExchange(order.ExchangeAddress, order.Maker, order.Hash()); err != nil {
+			errs[i] = &SignatureCheckError{err}
+			continue
+		} else if !valid {
+			errs[i] = errors.New("Failed to verify signature")
+			continue
+		}
+		dbOrder := &Order{}
+		dbOrder.Order = *order
+		dbOrder.Populate()
+		if dbOrder.Status == StatusOpen {
+			dbOrder.Status = indexer.status
+		}
+		dbOrders = append(dbOrders, dbOrder)
+		indices = append(indices, i)
+	}
+	if len(dbOrders) == 0 {
+		return errs
+	}
+	orderHashes := [][]byte{}
+	for _, dbOrder := range dbOrders {
+		orderHashes = append(orderHashes, dbOrder.OrderHash)
+	}
+	statuses, err := orderStatuses(indexer.db, orderHashes)
+	if err != nil {
+		log.Printf("Error getting status of %v orders: %v", len(dbOrders), err.Error())
+	}
+	if err := upsertOrders(indexer.db, dbOrders); err != nil {
+		log.Printf("Failed to save batch of %v orders, saving individually: %v", len(dbOrders), err.Error())
+		for j, dbOrder := range dbOrders {
+			errs[indices[j]] = dbOrder.Save(indexer.db, indexer.status, indexer.publisher).Error
+		}
+		return errs
+	}
+	if statuses != nil {
+		for _, dbOrder := range dbOrders {
+			if oldStatus, ok := statuses[string(dbOrder.OrderHash)]; ok {
+				recordOrderEvents(indexer.db, newOrderEvent(dbOrder, &oldStatus, EventSourceFundCheck, 0))
+			} else {
+				recordOrderEvents(indexer.db, newOrderEvent(dbOrder, nil, EventSourceFundCheck, 0))
+			}
+		}
+	}
+	if indexer.publisher != nil {
+		for _, dbOrder := range dbOrders {
+			indexer.publisher.Publish(string(dbOrder.Bytes()))
+		}
+	}
+	return errs
+}
+
 // RecordFill takes information about a filled order and updates the corresponding
 // database record, if any exists.
 func (indexer *Indexer) RecordFill(fillRecord *FillRecord) error {
@@ -57,12 +119,17 @@ func (indexer *Indexer) RecordFill(fillRecord *FillRecord) error {
 	totalFilled := dbOrder.TakerAssetAmountFilled.Big()
 	copy(dbOrder.TakerAssetAmountFilled[:], abi.U256(totalFilled.Add(totalFilled, amountFilled)))
 	dbOrder.Cancelled = dbOrder.Cancelled || fillRecord.Cancel
-	return dbOrder.Save(indexer.db, dbOrder.Status, indexer.publisher).Error
+	source := EventSourceFill
+	if fillRecord.Cancel {
+		source = EventSourceCancel
+	}
+	return dbOrder.save(indexer.db, dbOrder.Status, indexer.publisher, source, fillRecord.BlockNumber).Error
 }
 
-// RecordSpend takes information about a token transfer, and updates any
-// orders that might have become unfillable as a result of the transfer.
-func (indexer *Indexer) RecordSpend(makerAddress, tokenAddress, zrxAddress *types.Address, assetData types.AssetData, balance *types.Uint256) error {
+// RecordSpend takes information about a token transfer in block
+// `blockNumber`, and updates any orders that might have become unfillable as
+// a result of the transfer.
+func (indexer *Indexer) RecordSpend(makerAddress, tokenAddress, zrxAddress *types.Address, assetData types.AssetData, balance *types.Uint256, blockNumber uint64) error {
 	// NOTE: Right now we're doing this as a single check/update. Eventually it
 	// might make sense to do a check against a read replica, and the update
 	// against the write node if the check passes. It's more work over-all, but
@@ -77,22 +144,127 @@ func (indexer *Indexer) RecordSpend(makerAddress, tokenAddress, zrxAddress *type
 	if(bytes.Equal(tokenAddress[:], zrxAddress[:])) {
 		query = query.Or("maker = ? AND ? < maker_fee_remaining", makerAddress, balance)
 	}
-	return indexer.UpdateAndPublish(query, "status", indexer.status, true)
+	bundleHashes, err := indexer.overspentBundles(makerAddress, tokenAddress, assetData, balance)
+	if err != nil {
+		return err
+	}
+	if len(bundleHashes) > 0 {
+		query = query.Or("order_hash IN (?)", bundleHashes)
+	}
+	return indexer.updateStatus(query, EventSourceSpend, blockNumber)
+}
+
+// overspentBundles returns the hashes of open MultiAssetProxy and ERC1155 orders by
+// `makerAddress` that include the spent asset, and need more of it than
+// `balance` to be filled. The amount each order needs depends on the amount
+// encoded in its asset data, so candidate orders are found in the database
+// and checked here.
+func (indexer *Indexer) overspentBundles(makerAddress, tokenAddress *types.Address, assetData types.AssetData, balance *types.Uint256) ([][]byte, error) {
+	orders := []Order{}
+	err := indexer.db.Model(&Order{}).Where(
+		"status = ? AND maker = ? AND (POSITION(? IN maker_asset_data) = 1 OR POSITION(? IN maker_asset_data) = 1) AND POSITION(? IN maker_asset_data) > 0",
+		StatusOpen,
+		makerAddress,
+		types.MultiAssetProxyID[:],
+		types.ERC1155ProxyID[:],
+		tokenAddress[:],
+	).Find(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+	hashes := [][]byte{}
+	for _, order := range orders {
+		if bundleOverspent(&order, tokenAddress, assetData, balance.Big()) {
+			hashes = append(hashes, order.OrderHash)
+		}
+	}
+	return hashes, nil
+}
+
+// bundleOverspent indicates whether `order` needs more than `balance` of the
+// asset identified by `assetData`, or of any asset of the token at
+// `tokenAddress` if `assetData` is empty. The order's maker asset is broken
+// down into its components, so this covers MultiAssetProxy bundles and ERC1155
+// assets trading several units or token IDs.
+func bundleOverspent(order *Order, tokenAddress *types.Address, assetData types.AssetData, balance *big.Int) bool {
+	components, err := order.MakerAssetData.Components()
+	if err != nil || order.MakerAssetRemaining == nil {
+		return false
+	}
+	required := new(big.Int)
+	for _, component := range components {
+		if len(assetData) == 0 {
+			if !bytes.Equal(component.AssetData.Address()[:], tokenAddress[:]) {
+				continue
+			}
+		} else if !bytes.Equal(component.AssetData, assetData) {
+			continue
+		}
+		required.Add(required, new(big.Int).Mul(order.MakerAssetRemaining.Big(), component.Amount))
+	}
+	return required.Cmp(balance) > 0
+}
+
+// RecordInvalidSignatures updates open and unfunded orders whose signatures
+// are no longer valid, such as orders signed by a wallet contract that has
+// since revoked them. The orders were checked as of block `blockNumber`.
+func (indexer *Indexer) RecordInvalidSignatures(orderHashes [][]byte, blockNumber uint64) error {
+	if len(orderHashes) == 0 {
+		return nil
+	}
+	return indexer.updateStatus(indexer.db.Model(&Order{}).Where(
+		"status IN (?) AND order_hash IN (?)", []int64{StatusOpen, StatusUnfunded}, orderHashes,
+	), EventSourceSignature, blockNumber)
+}
+
+// RecordValidatorRevocation updates the status of open orders on `exchange`
+// that `signer` signed with `validator`, after the signer has revoked their
+// approval of the validator in block `blockNumber`.
+func (indexer *Indexer) RecordValidatorRevocation(exchange, signer, validator *types.Address, blockNumber uint64) error {
+	return indexer.updateStatus(indexer.db.Model(&Order{}).Where(
+		"status IN (?) AND exchange_address = ? AND maker = ? AND substring(signature from length(signature) for 1) = ? AND substring(signature from length(signature) - 20 for 20) = ?",
+		[]int64{StatusOpen, StatusUnfunded}, exchange, signer, []byte{types.SigTypeValidator}, validator[:],
+	), EventSourceSignature, blockNumber)
 }
 
 func (indexer *Indexer) RecordCancellation(cancellation *Cancellation) error {
 	if err := cancellation.Save(indexer.db).Error; err != nil {
 		return err
 	}
-	return indexer.UpdateAndPublish(indexer.db.Model(&Order{}).Where(
+	return indexer.updateStatus(indexer.db.Model(&Order{}).Where(
 		"status = ? AND maker = ? AND sender_address = ? AND salt < ?", StatusOpen, cancellation.Maker, cancellation.Sender, cancellation.Epoch,
-	), "status", indexer.status, true)
+	), EventSourceCancelUpTo, cancellation.BlockNumber)
+}
+
+// updateStatus sets the orders matched by `query` to the indexer's status,
+// publishes them as unfillable, and records the change in their history.
+func (indexer *Indexer) updateStatus(query *gorm.DB, source string, blockNumber uint64) error {
+	orders, err := indexer.updateAndPublish(query, "status", indexer.status, true)
+	if err != nil {
+		return err
+	}
+	events := []*OrderEvent{}
+	for _, order := range orders {
+		// The orders are still being published, so update a copy
+		oldStatus := order.Status
+		order.Status = indexer.status
+		events = append(events, newOrderEvent(&order, &oldStatus, source, blockNumber))
+	}
+	recordOrderEvents(indexer.db, events...)
+	return nil
 }
 
 func (indexer *Indexer) UpdateAndPublish(query *gorm.DB, key string, value interface{}, unfillable bool) error {
+	_, err := indexer.updateAndPublish(query, key, value, unfillable)
+	return err
+}
+
+// updateAndPublish works like UpdateAndPublish, returning the orders as they
+// were before the update.
+func (indexer *Indexer) updateAndPublish(query *gorm.DB, key string, value interface{}, unfillable bool) ([]Order, error) {
 	orders := []Order{}
 	if err := query.Find(&orders).Error; err != nil {
-		return err
+		return nil, err
 	}
 	if indexer.publisher != nil {
 		go func() {
@@ -104,8 +276,7 @@ func (indexer *Indexer) UpdateAndPublish(query *gorm.DB, key string, value inter
 			}
 		}()
 	}
-	return query.Update(key, value).Error
-
+	return orders, query.Update(key, value).Error
 }
 
 func NewIndexer(db *gorm.DB, status int64, publisher channels.Publisher) *Indexer {
@@ -25,6 +25,9 @@ func TestIndexOrder(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	indexer := dbModule.NewIndexer(tx, dbModule.StatusOpen, nil)
 	order := sampleOrder(t)
 	if !order.Signature.Verify(order.Maker, order.Hash()) {
@@ -35,6 +38,48 @@ func TestIndexOrder(t *testing.T) {
 	}
 }
 
+func TestIndexBatch(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Error(err.Error())
+	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
+	indexer := dbModule.NewIndexer(tx, dbModule.StatusOpen, nil)
+	order := sampleOrder(t)
+	badOrder := sampleOrder(t)
+	badOrder.Salt[0]++
+	// The same order twice should be written once, and indexing it again
+	// should update the existing record.
+	errs := indexer.IndexBatch([]*types.Order{order, badOrder, order})
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	if errs[1] == nil {
+		t.Errorf("Expected signature verification to fail")
+	}
+	if errs := indexer.IndexBatch([]*types.Order{order}); errs[0] != nil {
+		t.Error(errs[0].Error())
+	}
+	count := 0
+	if err := tx.Model(&dbModule.Order{}).Where("order_hash = ?", order.Hash()).Count(&count).Error; err != nil {
+		t.Error(err.Error())
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 record, got %v", count)
+	}
+}
+
 func TestFillIndex(t *testing.T) {
 	db, err := getDb()
 	if err != nil {
@@ -49,6 +94,9 @@ func TestFillIndex(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	indexer := dbModule.NewIndexer(tx, dbModule.StatusOpen, nil)
 	order := sampleOrder(t)
 	if err := indexer.Index(order); err != nil {
@@ -92,6 +140,9 @@ func TestCheckUnfundedSufficient(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	indexer := dbModule.NewIndexer(tx, dbModule.StatusUnfunded, nil)
 	order := sampleOrder(t)
 	dbOrder := &dbModule.Order{}
@@ -101,7 +152,7 @@ func TestCheckUnfundedSufficient(t *testing.T) {
 	}
 	// Checking that the MakerAddress has enough of MakerAssetData.Address(), asserting that they have exactly MakerAssetAmount of the token
 	// This check ignores ZRX, by saying that the TakerAssetData is ZRX, rather than the MakerAssetData.
-	if err := indexer.RecordSpend(dbOrder.Maker, dbOrder.MakerAssetData.Address(), dbOrder.TakerAssetData.Address(), dbOrder.MakerAssetData, dbOrder.MakerAssetAmount); err != nil {
+	if err := indexer.RecordSpend(dbOrder.Maker, dbOrder.MakerAssetData.Address(), dbOrder.TakerAssetData.Address(), dbOrder.MakerAssetData, dbOrder.MakerAssetAmount, 0); err != nil {
 		t.Errorf(err.Error())
 	}
 	dbOrders := []dbModule.Order{}
@@ -126,6 +177,9 @@ func TestCheckUnfundedNotFound(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	indexer := dbModule.NewIndexer(tx, dbModule.StatusUnfunded, nil)
 	order := sampleOrder(t)
 	dbOrder := &dbModule.Order{}
@@ -136,7 +190,7 @@ func TestCheckUnfundedNotFound(t *testing.T) {
 	// Checking that the Taker has enough of MakerAssetData.Address(), asserting that they have exactly MakerAssetAmount of the token
 	// This check ignores ZRX, by saying that the TakerAssetData is ZRX, rather than the MakerAssetData.
 	// This should not change anything, because no orders will match
-	if err := indexer.RecordSpend(dbOrder.Taker, dbOrder.MakerAssetData.Address(), dbOrder.TakerAssetData.Address(), dbOrder.MakerAssetData, dbOrder.MakerAssetAmount); err != nil {
+	if err := indexer.RecordSpend(dbOrder.Taker, dbOrder.MakerAssetData.Address(), dbOrder.TakerAssetData.Address(), dbOrder.MakerAssetData, dbOrder.MakerAssetAmount, 0); err != nil {
 		t.Errorf(err.Error())
 	}
 	dbOrders := []dbModule.Order{}
@@ -161,6 +215,9 @@ func TestCheckUnfundedInsufficient(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	indexer := dbModule.NewIndexer(tx, dbModule.StatusUnfunded, nil)
 	order := sampleOrder(t)
 	dbOrder := &dbModule.Order{}
@@ -172,7 +229,7 @@ func TestCheckUnfundedInsufficient(t *testing.T) {
 	// This check ignores ZRX, by saying that the TakerAssetData is ZRX, rather than the MakerAssetData.
 	// This should not change anything, because no orders will match
 	zero := &types.Uint256{}
-	if err := indexer.RecordSpend(dbOrder.Maker, dbOrder.MakerAssetData.Address(), dbOrder.TakerAssetData.Address(), dbOrder.MakerAssetData, zero); err != nil {
+	if err := indexer.RecordSpend(dbOrder.Maker, dbOrder.MakerAssetData.Address(), dbOrder.TakerAssetData.Address(), dbOrder.MakerAssetData, zero, 0); err != nil {
 		t.Errorf(err.Error())
 	}
 	dbOrders := []dbModule.Order{}
@@ -17,6 +17,7 @@ const (
 	StatusFilled    = int64(1)
 	StatusUnfunded  = int64(2)
 	StatusCancelled = int64(3)
+	StatusInvalid   = int64(4)
 )
 
 func DefaultSha3() []byte {
@@ -24,6 +25,17 @@ func DefaultSha3() []byte {
 	return []byte{167, 216, 239, 244, 2, 111, 37, 45, 181, 185, 12, 120, 228, 61, 209, 145, 223, 230, 229, 95, 203, 152, 84, 138, 95, 56, 250, 240, 212, 227, 235, 57}
 }
 
+// SignatureCheckError is returned when an order's signature could not be
+// checked, usually because a contract call to the Ethereum node failed.
+// Unlike an invalid signature, the order should be tried again later.
+type SignatureCheckError struct {
+	Err error
+}
+
+func (err *SignatureCheckError) Error() string {
+	return fmt.Sprintf("Error verifying signature: %v", err.Err.Error())
+}
+
 type Order struct {
 	types.Order
 	CreatedAt time.Time
@@ -42,6 +54,21 @@ func (order *Order) TableName() string {
 	return "orderv2"
 }
 
+// VersionClause returns a SQL condition matching orders of the given 0x
+// protocol version. v2 orders indexed before v3 support have no version set.
+func VersionClause(version uint8) string {
+	if version == types.OrderVersion3 {
+		return fmt.Sprintf("version = %v", types.OrderVersion3)
+	}
+	return fmt.Sprintf("COALESCE(version, 0) <> %v", types.OrderVersion3)
+}
+
+// FilterByVersion restricts an order query to orders of the given 0x
+// protocol version
+func FilterByVersion(query *gorm.DB, version uint8) *gorm.DB {
+	return query.Where(VersionClause(version))
+}
+
 func (order *Order) Populate() {
 	order.OrderHash = order.Hash()
 	remainingAmount := order.TakerAssetAmount.Big()
@@ -98,7 +125,17 @@ func (order *Order) Populate() {
 // is filled based on order.TakerAssetAmountFilled + order.TakerAssetAmountCancelled
 // the status will be recorded as db.StatusFilled regardless of the specified status.
 func (order *Order) Save(db *gorm.DB, status int64, publisher channels.Publisher) *gorm.DB {
-	if !order.Signature.Verify(order.Maker, order.Hash()) {
+	return order.save(db, status, publisher, EventSourceFundCheck, 0)
+}
+
+// save records the order like Save, along with an OrderEvent attributing any
+// change in status to `source`.
+func (order *Order) save(db *gorm.DB, status int64, publisher channels.Publisher, source string, blockNumber uint64) *gorm.DB {
+	if valid, err := order.Signature.VerifyExchange(order.ExchangeAddress, order.Maker, order.Hash()); err != nil {
+		scope := db.New()
+		scope.AddError(&SignatureCheckError{err})
+		return scope
+	} else if !valid {
 		scope := db.New()
 		scope.AddError(errors.New("Failed to verify signature"))
 		return scope
@@ -111,7 +148,10 @@ func (order *Order) Save(db *gorm.DB, status int64, publisher channels.Publisher
 
 	log.Printf("Attempting to save order %#x", order.Hash())
 
-
+	statuses, err := orderStatuses(db, [][]byte{order.OrderHash})
+	if err != nil {
+		log.Printf("Error getting status of order %#x: %v", order.OrderHash, err.Error())
+	}
 
 	updates := map[string]interface{}{
 		"taker_asset_amount_filled":    order.TakerAssetAmountFilled,
@@ -125,6 +165,9 @@ func (order *Order) Save(db *gorm.DB, status int64, publisher channels.Publisher
 		log.Printf(updateScope.Error.Error())
 	}
 	if updateScope.RowsAffected > 0 {
+		if oldStatus, ok := statuses[string(order.OrderHash)]; ok {
+			recordOrderEvents(db, newOrderEvent(order, &oldStatus, source, blockNumber))
+		}
 		if publisher != nil {
 			publisher.Publish(string(order.Bytes()))
 		}
@@ -134,6 +177,7 @@ func (order *Order) Save(db *gorm.DB, status int64, publisher channels.Publisher
 	if scope.Error != nil {
 		return scope
 	}
+	recordOrderEvents(db, newOrderEvent(order, nil, source, blockNumber))
 	if publisher != nil {
 		publisher.Publish(string(order.Bytes()))
 	}
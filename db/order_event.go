@@ -0,0 +1,109 @@
+package db
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/types"
+	"log"
+	"time"
+)
+
+// Sources of order status changes, recorded on each OrderEvent
+const (
+	EventSourceFundCheck  = "fundcheck"
+	EventSourceFill       = "fill"
+	EventSourceCancel     = "cancel"
+	EventSourceSpend      = "spend"
+	EventSourceCancelUpTo = "cancelUpTo"
+	EventSourceSignature  = "signature"
+)
+
+// OrderEvent records a change in an order's status, so that we can tell a
+// maker why their order left the order book. OldStatus is nil for the event
+// recording an order being indexed for the first time. BlockNumber is the
+// block of the event that triggered the change, or 0 if the change wasn't
+// triggered by an event on the chain.
+type OrderEvent struct {
+	ID                     uint `gorm:"primary_key"`
+	CreatedAt              time.Time
+	OrderHash              []byte `gorm:"index"`
+	OldStatus              *int64
+	NewStatus              int64
+	TakerAssetAmountFilled *types.Uint256
+	Source                 string
+	BlockNumber            uint64
+}
+
+// StatusName returns a human readable name for an order status
+func StatusName(status int64) string {
+	switch status {
+	case StatusOpen:
+		return "open"
+	case StatusFilled:
+		return "filled"
+	case StatusUnfunded:
+		return "unfunded"
+	case StatusCancelled:
+		return "cancelled"
+	case StatusInvalid:
+		return "invalid"
+	}
+	return "unknown"
+}
+
+// newOrderEvent returns an event recording `order` moving from `oldStatus`
+// to its current status, or nil if the status hasn't changed.
+func newOrderEvent(order *Order, oldStatus *int64, source string, blockNumber uint64) *OrderEvent {
+	if oldStatus != nil && *oldStatus == order.Status {
+		return nil
+	}
+	filled := &types.Uint256{}
+	if order.TakerAssetAmountFilled != nil {
+		copy(filled[:], order.TakerAssetAmountFilled[:])
+	}
+	return &OrderEvent{
+		OrderHash:              order.OrderHash,
+		OldStatus:              oldStatus,
+		NewStatus:              order.Status,
+		TakerAssetAmountFilled: filled,
+		Source:                 source,
+		BlockNumber:            blockNumber,
+	}
+}
+
+// orderStatuses returns the current status of each of the orders in
+// `orderHashes` that is in the database, keyed by order hash.
+func orderStatuses(db *gorm.DB, orderHashes [][]byte) (map[string]int64, error) {
+	statuses := make(map[string]int64)
+	if len(orderHashes) == 0 {
+		return statuses, nil
+	}
+	orders := []Order{}
+	if err := db.Model(&Order{}).Select("order_hash, status").Where("order_hash IN (?)", orderHashes).Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		statuses[string(order.OrderHash)] = order.Status
+	}
+	return statuses, nil
+}
+
+// recordOrderEvents saves `events`, skipping nil entries. The history is an
+// audit log, so failing to record it is logged rather than failing the
+// status change.
+func recordOrderEvents(db *gorm.DB, events ...*OrderEvent) {
+	for _, event := range events {
+		if event == nil {
+			continue
+		}
+		if err := db.Create(event).Error; err != nil {
+			log.Printf("Error recording %v event for order %#x: %v", event.Source, event.OrderHash, err.Error())
+		}
+	}
+}
+
+// OrderHistory returns the recorded status changes of an order, oldest first
+func OrderHistory(db *gorm.DB, orderHash []byte) ([]OrderEvent, error) {
+	events := []OrderEvent{}
+	err := db.Model(&OrderEvent{}).Where("order_hash = ?", orderHash).Order("id").Find(&events).Error
+	return events, err
+}
@@ -0,0 +1,110 @@
+package db_test
+
+import (
+	"fmt"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/types"
+	"testing"
+)
+
+func TestOrderHistory(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Error(err.Error())
+	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
+	indexer := dbModule.NewIndexer(tx, dbModule.StatusOpen, nil)
+	order := sampleOrder(t)
+	if err := indexer.Index(order); err != nil {
+		t.Fatal(err.Error())
+	}
+	// Indexing the order again doesn't change its status
+	if err := indexer.Index(order); err != nil {
+		t.Fatal(err.Error())
+	}
+	fillRecord := &dbModule.FillRecord{fmt.Sprintf("%#x", order.Hash()), order.TakerAssetAmount.Big().String(), false, 100}
+	if err := indexer.RecordFill(fillRecord); err != nil {
+		t.Fatal(err.Error())
+	}
+	events, err := dbModule.OrderHistory(tx, order.Hash())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %v", len(events))
+	}
+	if events[0].OldStatus != nil || events[0].NewStatus != dbModule.StatusOpen || events[0].Source != dbModule.EventSourceFundCheck {
+		t.Errorf("Unexpected first event: %v", events[0])
+	}
+	if *events[1].OldStatus != dbModule.StatusOpen || events[1].NewStatus != dbModule.StatusFilled || events[1].Source != dbModule.EventSourceFill || events[1].BlockNumber != 100 {
+		t.Errorf("Unexpected second event: %v", events[1])
+	}
+	if events[1].TakerAssetAmountFilled.Big().Cmp(order.TakerAssetAmount.Big()) != 0 {
+		t.Errorf("Unexpected filled amount: %v", events[1].TakerAssetAmountFilled)
+	}
+}
+
+func TestOrderHistorySpend(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Error(err.Error())
+	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
+	order := sampleOrder(t)
+	dbOrder := &dbModule.Order{}
+	dbOrder.Order = *order
+	if err := dbOrder.Save(tx, dbModule.StatusOpen, nil).Error; err != nil {
+		t.Fatal(err.Error())
+	}
+	indexer := dbModule.NewIndexer(tx, dbModule.StatusUnfunded, nil)
+	zero := &types.Uint256{}
+	if err := indexer.RecordSpend(dbOrder.Maker, dbOrder.MakerAssetData.Address(), dbOrder.TakerAssetData.Address(), dbOrder.MakerAssetData, zero, 200); err != nil {
+		t.Fatal(err.Error())
+	}
+	events, err := dbModule.OrderHistory(tx, order.Hash())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %v", len(events))
+	}
+	if *events[1].OldStatus != dbModule.StatusOpen || events[1].NewStatus != dbModule.StatusUnfunded || events[1].Source != dbModule.EventSourceSpend || events[1].BlockNumber != 200 {
+		t.Errorf("Unexpected event: %v", events[1])
+	}
+}
+
+func TestStatusName(t *testing.T) {
+	names := map[int64]string{
+		dbModule.StatusOpen:      "open",
+		dbModule.StatusFilled:    "filled",
+		dbModule.StatusUnfunded:  "unfunded",
+		dbModule.StatusCancelled: "cancelled",
+		dbModule.StatusInvalid:   "invalid",
+		int64(99):                "unknown",
+	}
+	for status, name := range names {
+		if dbModule.StatusName(status) != name {
+			t.Errorf("Expected status %v to be '%v', got '%v'", status, name, dbModule.StatusName(status))
+		}
+	}
+}
@@ -54,6 +54,9 @@ func TestSaveOrder(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	order := sampleOrder(t)
 	dbOrder := &dbModule.Order{}
 	dbOrder.Order = *order
@@ -82,6 +85,9 @@ func TestFailOnEmptyOrder(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	dbOrder := &dbModule.Order{}
 	dbOrder.Initialize()
 	if err := dbOrder.Save(tx, dbModule.StatusOpen, nil).Error; err == nil {
@@ -103,6 +109,9 @@ func TestQueryOrder(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	order := sampleOrder(t)
 	dbOrder := &dbModule.Order{}
 	dbOrder.Order = *order
@@ -166,6 +175,9 @@ func TestQueryPairs(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	if err := tx.AutoMigrate(&dbModule.Exchange{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
@@ -179,7 +191,7 @@ func TestQueryPairs(t *testing.T) {
 	if err := dbOrder.Save(tx, dbModule.StatusOpen, nil).Error; err != nil {
 		t.Errorf(err.Error())
 	}
-	tokenPairs, _, err := dbModule.GetAllTokenPairs(tx, 0, 10, 1)
+	tokenPairs, _, err := dbModule.GetAllTokenPairs(tx, 0, 10, 1, types.OrderVersion2)
 	if err != nil {
 		t.Errorf(err.Error())
 	}
@@ -200,6 +212,9 @@ func TestQueryPairsTokenAFilter(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	if err := tx.AutoMigrate(&dbModule.Exchange{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
@@ -213,7 +228,7 @@ func TestQueryPairsTokenAFilter(t *testing.T) {
 	if err := dbOrder.Save(tx, dbModule.StatusOpen, nil).Error; err != nil {
 		t.Errorf(err.Error())
 	}
-	tokenPairs, _, err := dbModule.GetTokenAPairs(tx, sOrder.TakerAssetData, 0, 10, 1)
+	tokenPairs, _, err := dbModule.GetTokenAPairs(tx, sOrder.TakerAssetData, 0, 10, 1, types.OrderVersion2)
 	if err != nil {
 		t.Errorf(err.Error())
 	}
@@ -234,6 +249,9 @@ func TestQueryPairsTokenABFilter(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	sOrder := sampleOrder(t)
 	dbOrder := &dbModule.Order{}
 	dbOrder.Order = *sOrder
@@ -247,7 +265,7 @@ func TestQueryPairsTokenABFilter(t *testing.T) {
 	tx.Where(
 		&dbModule.Exchange{Network: 1},
 	).FirstOrCreate(&dbModule.Exchange{Network: 1, Address: sampleAddress })
-	tokenPairs, _, err := dbModule.GetTokenABPairs(tx, sOrder.TakerAssetData, sOrder.MakerAssetData, 1)
+	tokenPairs, _, err := dbModule.GetTokenABPairs(tx, sOrder.TakerAssetData, sOrder.MakerAssetData, 1, types.OrderVersion2)
 	if err != nil {
 		t.Errorf(err.Error())
 	}
@@ -268,6 +286,9 @@ func TestQueryPairsTokenEmptyFilter(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	if err := tx.AutoMigrate(&dbModule.Exchange{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
@@ -281,7 +302,7 @@ func TestQueryPairsTokenEmptyFilter(t *testing.T) {
 	if err := dbOrder.Save(tx, dbModule.StatusOpen, nil).Error; err != nil {
 		t.Errorf(err.Error())
 	}
-	tokenPairs, _, err := dbModule.GetTokenAPairs(tx, types.AssetData{}, 0, 10, 1)
+	tokenPairs, _, err := dbModule.GetTokenAPairs(tx, types.AssetData{}, 0, 10, 1, types.OrderVersion2)
 	if err != nil {
 		t.Errorf(err.Error())
 	}
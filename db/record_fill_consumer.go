@@ -21,6 +21,9 @@ func (consumer *RecordFillConsumer) Consume(msg channels.Delivery) {
 		json.Unmarshal([]byte(msg.Payload()), fillRecord)
 		if err := consumer.idx.RecordFill(fillRecord); err == nil {
 			msg.Ack()
+			} else if _, ok := err.(*SignatureCheckError); ok {
+				log.Printf("Returning fill: '%v', '%v'", fillRecord.OrderHash, err.Error())
+				msg.Return()
 			} else {
 				log.Printf("Failed to record fill: '%v', '%v'", fillRecord.OrderHash, err.Error())
 				msg.Reject()
@@ -24,6 +24,9 @@ func TestFillConsumer(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	indexer := dbModule.NewIndexer(tx, dbModule.StatusOpen, nil)
 	order := sampleOrder(t)
 	if !order.Signature.Verify(order.Maker, order.Hash()) {
@@ -15,6 +15,7 @@ type SpendRecord struct {
 	SpenderAddress  string `json:"spenderAddress"`
 	ZrxToken        string `json:"zrxToken"`
 	Balance         string `json:"balance"`
+	BlockNumber     uint64 `json:"blockNumber,omitempty"`
 }
 
 type RecordSpendConsumer struct {
@@ -58,7 +59,7 @@ func (consumer *RecordSpendConsumer) Consume(msg channels.Delivery) {
 			return
 		}
 		balance, err := types.IntStringToUint256(spendRecord.Balance)
-		if err := consumer.idx.RecordSpend(spenderAddress, tokenAddress, zrxToken, assetData, balance); err == nil {
+		if err := consumer.idx.RecordSpend(spenderAddress, tokenAddress, zrxToken, assetData, balance, spendRecord.BlockNumber); err == nil {
 			msg.Ack()
 		} else {
 			log.Printf("Failed to record spend: '%v', '%v'", msg.Payload(), err.Error())
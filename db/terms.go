@@ -140,9 +140,45 @@ func (terms *Terms) CheckSig(sig *types.Signature, address *types.Address, times
 	if !CheckMask(mask, hash) {
 		return false, fmt.Errorf("Hash must match mask: %#x, got %#x", mask, hash)
 	}
+	if len(*sig) > 0 && sig.Type() == types.SigTypeEIP712 {
+		typedDataHash, err := terms.TypedData(timestamp, nonce).Hash()
+		if err != nil {
+			return false, err
+		}
+		return sig.Verify(address, typedDataHash), nil
+	}
 	return sig.Verify(address, signedMessage), nil
 }
 
+// TypedData returns the terms as an EIP-712 message, so that wallets can
+// sign them with eth_signTypedData instead of signing the plain text message.
+// The hash mask still applies to the plain text message.
+func (terms *Terms) TypedData(timestamp string, nonce []byte) *types.TypedData {
+	return &types.TypedData{
+		Types: map[string][]types.TypedDataField{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+			},
+			"Terms": {
+				{Name: "text", Type: "string"},
+				{Name: "timestamp", Type: "string"},
+				{Name: "nonce", Type: "bytes"},
+			},
+		},
+		PrimaryType: "Terms",
+		Domain: map[string]interface{}{
+			"name":    "OpenRelay",
+			"version": "1",
+		},
+		Message: map[string]interface{}{
+			"text":      terms.Text,
+			"timestamp": timestamp,
+			"nonce":     fmt.Sprintf("0x%x", nonce),
+		},
+	}
+}
+
 // SaveSig verifies that a signature is valid for a given Terms, then saves it
 // to the database
 func (tm *TermsManager) SaveSig(id uint, sig *types.Signature, address *types.Address, timestamp, host_ip string, nonce []byte, mask []byte) (error) {
@@ -224,3 +224,27 @@ func TestCheckAddress(t *testing.T) {
 		t.Errorf("Error checking address")
 	}
 }
+
+func TestTermsTypedDataSig(t *testing.T) {
+	terms := &dbModule.Terms{Text: "Don't break the law"}
+	timestamp := "1543351413"
+	nonce := []byte{1, 2, 3}
+	key, _ := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	signer := &types.Address{}
+	copy(signer[:], address[:])
+	hash, err := terms.TypedData(timestamp, nonce).Hash()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	signature, err := types.SignHash(key, hash, types.SigTypeEIP712)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok, err := terms.CheckSig(&signature, signer, timestamp, nonce, []byte{}); !ok || err != nil {
+		t.Errorf("Typed data signature did not validate: %v", err)
+	}
+	if ok, _ := terms.CheckSig(&signature, signer, "1543351414", nonce, []byte{}); ok {
+		t.Errorf("Signature should not validate for a different timestamp")
+	}
+}
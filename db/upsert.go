@@ -0,0 +1,87 @@
+package db
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"strings"
+)
+
+// upsertColumns are the columns updated when an order being indexed already
+// exists, matching the fields Order.Save updates.
+var upsertColumns = []string{"taker_asset_amount_filled", "maker_asset_remaining", "maker_fee_remaining", "status", "updated_at"}
+
+// maxBindVars keeps each statement under PostgreSQL's limit of 65535
+// parameters.
+const maxBindVars = 60000
+
+// upsertOrders inserts `orders`, updating any that already exist, using as
+// few statements as possible. The orders should already be populated.
+func upsertOrders(db *gorm.DB, orders []*Order) error {
+	var conflict, update string
+	switch db.Dialect().GetName() {
+	case "postgres":
+		conflict, update = "ON CONFLICT (order_hash) DO UPDATE SET", "%v = EXCLUDED.%v"
+	case "mysql":
+		conflict, update = "ON DUPLICATE KEY UPDATE", "%v = VALUES(%v)"
+	default:
+		return fmt.Errorf("Bulk upserts are not supported on %v", db.Dialect().GetName())
+	}
+	scope := db.NewScope(&Order{})
+	updates := []string{}
+	for _, column := range upsertColumns {
+		updates = append(updates, fmt.Sprintf(update, scope.Quote(column), scope.Quote(column)))
+	}
+
+	// A single statement can't insert the same row twice, so if an order
+	// appears more than once only the last copy is kept.
+	unique := []*Order{}
+	positions := make(map[string]int)
+	for _, order := range orders {
+		if i, ok := positions[string(order.OrderHash)]; ok {
+			unique[i] = order
+			continue
+		}
+		positions[string(order.OrderHash)] = len(unique)
+		unique = append(unique, order)
+	}
+
+	columns := []string{}
+	for _, field := range scope.Fields() {
+		if field.IsNormal && !field.IsIgnored {
+			columns = append(columns, scope.Quote(field.DBName))
+		}
+	}
+	now := gorm.NowFunc()
+	perStatement := maxBindVars / len(columns)
+	for start := 0; start < len(unique); start += perStatement {
+		end := start + perStatement
+		if end > len(unique) {
+			end = len(unique)
+		}
+		statement := db.NewScope(&Order{})
+		rows := []string{}
+		for _, order := range unique[start:end] {
+			order.CreatedAt = now
+			order.UpdatedAt = now
+			placeholders := []string{}
+			for _, field := range db.NewScope(order).Fields() {
+				if field.IsNormal && !field.IsIgnored {
+					placeholders = append(placeholders, statement.AddToVars(field.Field.Interface()))
+				}
+			}
+			rows = append(rows, fmt.Sprintf("(%v)", strings.Join(placeholders, ",")))
+		}
+		statement.Raw(fmt.Sprintf(
+			"INSERT INTO %v (%v) VALUES %v %v %v",
+			statement.QuotedTableName(),
+			strings.Join(columns, ","),
+			strings.Join(rows, ","),
+			conflict,
+			strings.Join(updates, ", "),
+		))
+		if err := statement.Exec().DB().Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
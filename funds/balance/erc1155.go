@@ -0,0 +1,76 @@
+package balance
+
+import (
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	gethCommon "github.com/ethereum/go-ethereum/common"
+	orCommon "github.com/notegio/openrelay/common"
+	tokenModule "github.com/notegio/openrelay/token"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"fmt"
+)
+
+// maxUint256 is the allowance reported for approved ERC1155 operators, which
+// may transfer any amount of any token.
+var maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+type rpcERC1155BalanceChecker struct {
+	conn bind.ContractBackend
+}
+
+// GetBalance returns how many units of `tokenAsset` the user could transfer,
+// which for asset data with several token IDs is limited by the token the
+// user has the fewest units of, relative to its value.
+func (funds *rpcERC1155BalanceChecker) GetBalance(tokenAsset types.AssetData, userAddrBytes *types.Address) (*big.Int, error) {
+	asset, err := tokenAsset.ERC1155()
+	if err != nil {
+		return nil, err
+	}
+	token, err := tokenModule.NewERC1155Token(orCommon.ToGethAddress(asset.TokenAddress), funds.conn)
+	if err != nil {
+		return nil, err
+	}
+	owners := make([]gethCommon.Address, len(asset.TokenIDs))
+	for i := range owners {
+		owners[i] = orCommon.ToGethAddress(userAddrBytes)
+	}
+	balances, err := token.BalanceOfBatch(nil, owners, asset.TokenIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(balances) != len(asset.TokenIDs) {
+		return nil, fmt.Errorf("Expected %v balances from %#x, got %v", len(asset.TokenIDs), asset.TokenAddress[:], len(balances))
+	}
+	var units *big.Int
+	for i, balance := range balances {
+		if asset.Values[i].Sign() == 0 {
+			continue
+		}
+		if tokenUnits := new(big.Int).Div(balance, asset.Values[i]); units == nil || tokenUnits.Cmp(units) < 0 {
+			units = tokenUnits
+		}
+	}
+	if units == nil {
+		// Every value is zero, so nothing needs to be transferred
+		return maxUint256, nil
+	}
+	return units, nil
+}
+
+func (funds *rpcERC1155BalanceChecker) GetAllowance(tokenAsset types.AssetData, ownerAddress, spenderAddress *types.Address) (*big.Int, error) {
+	token, err := tokenModule.NewERC1155Token(orCommon.ToGethAddress(tokenAsset.Address()), funds.conn)
+	if err != nil {
+		return nil, err
+	}
+	if approved, err := token.IsApprovedForAll(nil, orCommon.ToGethAddress(ownerAddress), orCommon.ToGethAddress(spenderAddress)); err != nil {
+		return nil, err
+	} else if approved {
+		return maxUint256, nil
+	}
+	return big.NewInt(0), nil
+}
+
+
+func NewRpcERC1155BalanceChecker(conn bind.ContractBackend) (BalanceChecker) {
+	return &rpcERC1155BalanceChecker{conn}
+}
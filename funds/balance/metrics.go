@@ -0,0 +1,39 @@
+package balance
+
+import (
+	"github.com/notegio/openrelay/metrics"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+)
+
+var (
+	rpcCalls  = metrics.NewCounter("openrelay_funds_rpc_calls_total", "RPC lookups made to check balances, allowances and fills, by method", "method")
+	rpcErrors = metrics.NewCounter("openrelay_funds_rpc_errors_total", "RPC lookups that returned an error, by method", "method")
+)
+
+// CountRPC records an RPC lookup made by `method` and whether it failed,
+// returning `err` so callers can wrap their return value.
+func CountRPC(method string, err error) error {
+	rpcCalls.Inc(method)
+	if err != nil {
+		rpcErrors.Inc(method)
+	}
+	return err
+}
+
+// countedBalanceChecker counts the lookups made by a BalanceChecker for a
+// single asset type.
+type countedBalanceChecker struct {
+	assetType string
+	checker   BalanceChecker
+}
+
+func (counted *countedBalanceChecker) GetBalance(asset types.AssetData, userAddress *types.Address) (*big.Int, error) {
+	balance, err := counted.checker.GetBalance(asset, userAddress)
+	return balance, CountRPC(counted.assetType+".balance", err)
+}
+
+func (counted *countedBalanceChecker) GetAllowance(asset types.AssetData, ownerAddress, spenderAddress *types.Address) (*big.Int, error) {
+	allowance, err := counted.checker.GetAllowance(asset, ownerAddress, spenderAddress)
+	return allowance, CountRPC(counted.assetType+".allowance", err)
+}
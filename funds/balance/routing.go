@@ -102,7 +102,8 @@ func NewRpcRoutingBalanceChecker(rpcURL string) (CachedBalanceChecker, error) {
 		return nil, err
 	}
 	checkers := make(map[string]BalanceChecker)
-	checkers["0xf47261b0"] = NewRpcERC20BalanceChecker(conn)
-	checkers["0x02571792"] = NewRpcERC721BalanceChecker(conn)
+	checkers["0xf47261b0"] = &countedBalanceChecker{"erc20", NewRpcERC20BalanceChecker(conn)}
+	checkers["0x02571792"] = &countedBalanceChecker{"erc721", NewRpcERC721BalanceChecker(conn)}
+	checkers["0xa7cb5fb7"] = &countedBalanceChecker{"erc1155", NewRpcERC1155BalanceChecker(conn)}
 	return &routingBalanceChecker{nil, &sync.Mutex{}, checkers}, nil
 }
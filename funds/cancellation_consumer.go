@@ -35,9 +35,9 @@ func (consumer *CancellationConsumer) Consume(msg channels.Delivery) {
 		log.Printf("Order %#x cancelled: %v", order.Hash(), order.Cancelled)
 		payload := string(order.Bytes())
 		if oldCancelled != order.Cancelled && consumer.changePublisher != nil {
-			consumer.changePublisher.Publish(payload)
+			channels.PublishWithHeaders(consumer.changePublisher, msg.Headers(), payload)
 		}
-		consumer.allPublisher.Publish(payload)
+		channels.PublishWithHeaders(consumer.allPublisher, msg.Headers(), payload)
 		msg.Ack()
 	}()
 }
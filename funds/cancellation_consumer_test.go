@@ -18,7 +18,7 @@ func TestCancellationConsumer(t *testing.T) {
 	consumerChannel.StartConsuming()
 	sourcePublisher.Publish(string(orderBytes[:]))
 	updatedPayload := <-allChan
-	if updatedPayload.Payload() != string(orderBytes[:]) {
+	if updatedPayload.Payload() != encodedTestOrder() {
 		t.Errorf("Unexpected change in processing")
 	}
 	select {
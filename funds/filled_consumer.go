@@ -55,9 +55,9 @@ func (consumer *FillConsumer) Consume(msg channels.Delivery) {
 		order.TakerAssetAmountFilled = <-filledChan
 		payload := string(order.Bytes())
 		if (<-changes || <-changes) && consumer.changePublisher != nil {
-			consumer.changePublisher.Publish(payload)
+			channels.PublishWithHeaders(consumer.changePublisher, msg.Headers(), payload)
 		}
-		consumer.allPublisher.Publish(payload)
+		channels.PublishWithHeaders(consumer.allPublisher, msg.Headers(), payload)
 		msg.Ack()
 	}()
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"github.com/notegio/openrelay/channels"
 	"github.com/notegio/openrelay/funds"
+	"github.com/notegio/openrelay/types"
 	"testing"
 )
 
@@ -12,6 +13,12 @@ func getTestOrderBytes() []byte {
 	return testOrderBytes
 }
 
+// encodedTestOrder returns the test order as consumers publish it
+func encodedTestOrder() string {
+	order, _ := types.OrderFromBytes(getTestOrderBytes())
+	return string(order.Bytes())
+}
+
 func TestFilledConsumer(t *testing.T) {
 	sourcePublisher, consumerChannel := channels.MockChannel()
 	changePublisher, changeChan := channels.MockPublisher()
@@ -23,7 +30,7 @@ func TestFilledConsumer(t *testing.T) {
 	consumerChannel.StartConsuming()
 	sourcePublisher.Publish(string(orderBytes[:]))
 	updatedPayload := <-allChan
-	if updatedPayload.Payload() != string(orderBytes[:]) {
+	if updatedPayload.Payload() != encodedTestOrder() {
 		t.Errorf("Unexpected change in processing")
 	}
 	select {
@@ -50,13 +57,13 @@ func TestFilledConsumerChange(t *testing.T) {
 	consumerChannel.StartConsuming()
 	sourcePublisher.Publish(string(orderBytes[:]))
 	updatedPayload := <-allChan
-	if updatedPayload.Payload() == string(orderBytes[:]) {
+	if updatedPayload.Payload() == encodedTestOrder() {
 		t.Errorf("Expected change in processing")
 	}
 	select {
 	case changedPayload, ok := <-changeChan:
 		if ok {
-			if changedPayload.Payload() == string(orderBytes[:]) {
+			if changedPayload.Payload() == encodedTestOrder() {
 				t.Errorf("Expected change in processing")
 			}
 		} else {
@@ -79,13 +86,13 @@ func TestFilledConsumerCancelChange(t *testing.T) {
 	consumerChannel.StartConsuming()
 	sourcePublisher.Publish(string(orderBytes[:]))
 	updatedPayload := <-allChan
-	if updatedPayload.Payload() == string(orderBytes[:]) {
+	if updatedPayload.Payload() == encodedTestOrder() {
 		t.Errorf("Expected change in processing")
 	}
 	select {
 	case changedPayload, ok := <-changeChan:
 		if ok {
-			if changedPayload.Payload() == string(orderBytes[:]) {
+			if changedPayload.Payload() == encodedTestOrder() {
 				t.Errorf("Expected change in processing")
 			}
 		} else {
@@ -10,6 +10,7 @@ import (
 	"github.com/notegio/openrelay/types"
 	// "github.com/notegio/openrelay/channels"
 	"github.com/notegio/openrelay/fillbloom"
+	"github.com/notegio/openrelay/funds/balance"
 	"log"
 	"math/big"
 )
@@ -38,7 +39,7 @@ func (filled *rpcFilledLookup) GetCancelled(order *types.Order) (bool, error) {
 	hash := [32]byte{}
 	copy(hash[:], order.Hash())
 	isCancelled, err = exchange.Cancelled(nil, hash)
-	if err != nil {
+	if balance.CountRPC("exchange.cancelled", err) != nil {
 		orderBytes := order.Bytes()
 		log.Printf("Error getting cancelled amount for order '%v': '%v'", hex.EncodeToString(orderBytes[:]), err.Error())
 		return isCancelled, err
@@ -60,7 +61,7 @@ func (filled *rpcFilledLookup) GetAmountFilled(order *types.Order) (*types.Uint2
 	hash := [32]byte{}
 	copy(hash[:], order.Hash())
 	amount, err := exchange.Filled(nil, hash)
-	if err != nil {
+	if balance.CountRPC("exchange.filled", err) != nil {
 		orderBytes := order.Bytes()
 		log.Printf("Error getting filled amount for order '%v': '%v'", hex.EncodeToString(orderBytes[:]), err.Error())
 		return filledAmount, err
@@ -0,0 +1,49 @@
+package funds
+
+import (
+	"encoding/hex"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/types"
+	"log"
+)
+
+// FundFilter is a RelayFilter that passes orders whose makers have the funds
+// and allowances to fill them.
+type FundFilter struct {
+	orderValidator OrderValidator
+}
+
+// NewFundFilter returns a FundFilter that checks orders with `orderValidator`
+func NewFundFilter(orderValidator OrderValidator) *FundFilter {
+	return &FundFilter{orderValidator}
+}
+
+func (filter *FundFilter) Filter(delivery channels.Delivery) bool {
+	order, err := types.OrderFromBytes([]byte(delivery.Payload()))
+	if err != nil {
+		log.Printf("Invalid order format: %#x", delivery.Payload())
+		return false
+	}
+	if valid, err := order.Signature.VerifyExchange(order.ExchangeAddress, order.Maker, order.Hash()); err != nil {
+		log.Printf("Error verifying signature of order '%v' (attempt %v): %v", hex.EncodeToString(order.Hash()), channels.DeliveryAttempts(delivery), err.Error())
+		delivery.Return()
+		return false
+	} else if !valid {
+		log.Printf("Invalid order signature")
+		return false
+	}
+	valid, err := filter.orderValidator.ValidateOrder(order)
+	if err != nil && err.Error() != "no contract code at given address" {
+		// Most likely an RPC error, so give the order another try later. If the
+		// source channel has a retry policy it will eventually be rejected.
+		log.Printf("Error validating order '%v' (attempt %v): %v", hex.EncodeToString(order.Hash()), channels.DeliveryAttempts(delivery), err.Error())
+		delivery.Return()
+		return false
+	}
+	if valid {
+		log.Printf("Order '%v' has funds (correlation id %v)", hex.EncodeToString(order.Hash()), delivery.Headers()[channels.HeaderCorrelationID])
+	} else {
+		log.Printf("Order '%v' lacks funds (correlation id %v)", hex.EncodeToString(order.Hash()), delivery.Headers()[channels.HeaderCorrelationID])
+	}
+	return valid
+}
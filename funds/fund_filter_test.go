@@ -0,0 +1,52 @@
+package funds_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/funds"
+	"github.com/notegio/openrelay/types"
+	"testing"
+	"time"
+)
+
+// failingValidator fails every check, as if the Ethereum node were down
+type failingValidator struct {
+	calls chan time.Time
+}
+
+func (validator *failingValidator) ValidateOrder(order *types.Order) (bool, error) {
+	validator.calls <- time.Now()
+	return false, errors.New("connection refused")
+}
+
+func TestFundFilterRetryBackoff(t *testing.T) {
+	name := fmt.Sprintf("fundcheck-%v", time.Now().UnixNano())
+	consumerChannel, err := channels.MemConsumerFromURI("mem://" + name + "?maxattempts=2&backoff=200ms")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	validator := &failingValidator{make(chan time.Time, 3)}
+	publisher, _ := channels.MockPublisher()
+	relay := channels.NewRelay(consumerChannel, []channels.Publisher{publisher}, funds.NewFundFilter(validator), 1)
+	relay.Start()
+	defer relay.Stop()
+	channels.NewMemQueuePublisher(name).Publish(encodedTestOrder())
+	calls := []time.Time{}
+	for len(calls) < 2 {
+		select {
+		case call := <-validator.calls:
+			calls = append(calls, call)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected 2 validation attempts, got %v", len(calls))
+		}
+	}
+	if delay := calls[1].Sub(calls[0]); delay < 200*time.Millisecond {
+		t.Errorf("Order was redelivered after %v, expected a backoff", delay)
+	}
+	select {
+	case <-validator.calls:
+		t.Errorf("Order should have been rejected after 2 attempts")
+	case <-time.After(500 * time.Millisecond):
+	}
+}
@@ -71,96 +71,132 @@ func getRemainingAmount(numerator, denominator, target []byte) []byte {
 	return new(big.Int).Sub(targetInt, new(big.Int).Div(mulInt, denomInt)).Bytes()
 }
 
+// makerComponents returns the assets the maker of `order` must hold, with
+// the amount of each needed per unit of the order's maker asset amount.
+// Components of a bundle that appear more than once are combined.
+func makerComponents(order *types.Order) ([]types.AssetComponent, error) {
+	components, err := order.MakerAssetData.Components()
+	if err != nil {
+		return nil, err
+	}
+	merged := []types.AssetComponent{}
+	indexes := make(map[string]int)
+	for _, component := range components {
+		key := string(component.AssetData[:])
+		if i, ok := indexes[key]; ok {
+			merged[i].Amount = new(big.Int).Add(merged[i].Amount, component.Amount)
+			continue
+		}
+		indexes[key] = len(merged)
+		merged = append(merged, types.AssetComponent{Amount: new(big.Int).Set(component.Amount), AssetData: component.AssetData})
+	}
+	return merged, nil
+}
+
+// orderFeeToken returns the asset the maker of `order` pays fees in. v3
+// orders name their own fee asset, while v2 orders always pay fees in the
+// exchange's fee token.
+func (funds *orderValidator) orderFeeToken(order *types.Order) (types.AssetData, error) {
+	if !order.IsV3() {
+		return funds.feeToken.Get(order)
+	}
+	return order.MakerFeeAssetData, nil
+}
+
+type pendingCheck struct {
+	failure string
+	result  chan boolOrErr
+}
+
 // ValidateOrder makes sure that the maker of an order has sufficient funds to
 // fill the order and pay makerFees. This assumes that TakerAmountFilled and
-// TakerAmountCancelled reflect
+// TakerAmountCancelled reflect. For MultiAssetProxy orders, the maker must
+// have sufficient funds of every asset in the bundle, scaled by its amount.
 func (funds *orderValidator) ValidateOrder(order *types.Order) (bool, error) {
-	feeToken, err := funds.feeToken.Get(order)
+	if order.IsV3() && len(order.MakerFeeAssetData) == 0 && order.MakerFee.Big().Sign() != 0 {
+		log.Printf("Order has a maker fee but no makerFeeAssetData")
+		return false, nil
+	}
+	feeToken, err := funds.orderFeeToken(order)
 	if err != nil {
 		log.Printf("Error getting fee token '%v'", err.Error())
 		return false, err
 	}
-	makerProxyAddress, err := funds.tokenProxy.Get(order)
+	components, err := makerComponents(order)
 	if err != nil {
-		log.Printf("Error getting token proxy address '%v'", err.Error())
+		log.Printf("Error decoding maker asset data '%v'", err.Error())
 		return false, err
 	}
-	feeProxyAddress, err := funds.tokenProxy.GetById(order, types.ERC20ProxyID)
+	makerProxyAddresses := make([]*types.Address, len(components))
+	for i, component := range components {
+		if makerProxyAddresses[i], err = funds.tokenProxy.GetById(order, component.AssetData.ProxyId()); err != nil {
+			log.Printf("Error getting token proxy address '%v'", err.Error())
+			return false, err
+		}
+	}
+	feeProxyID := types.ERC20ProxyID
+	if len(feeToken) >= 4 {
+		feeProxyID = feeToken.ProxyId()
+	}
+	feeProxyAddress, err := funds.tokenProxy.GetById(order, feeProxyID)
 	if err != nil {
 		log.Printf("Error getting fee token proxy address '%v'", err.Error())
 		return false, err
 	}
-	makerChan := make(chan boolOrErr)
-	feeChan := make(chan boolOrErr)
-	makerAllowanceChan := make(chan boolOrErr)
-	feeAllowanceChan := make(chan boolOrErr)
 	unavailableAmount := order.TakerAssetAmountFilled.Big()
-	go funds.checkBalance(
-		order.MakerAssetData,
-		order.Maker,
-		getRemainingAmount(unavailableAmount.Bytes(), order.TakerAssetAmount[:], order.MakerAssetAmount[:]),
-		makerChan,
-	)
+	makerRemaining := new(big.Int).SetBytes(getRemainingAmount(unavailableAmount.Bytes(), order.TakerAssetAmount[:], order.MakerAssetAmount[:]))
+	balanceChecks := []pendingCheck{}
+	allowanceChecks := []pendingCheck{}
+	for i, component := range components {
+		required := new(big.Int).Mul(makerRemaining, component.Amount).Bytes()
+		balanceCheck := pendingCheck{"Insufficient maker token funds", make(chan boolOrErr)}
+		allowanceCheck := pendingCheck{"Insufficient makers token allowance", make(chan boolOrErr)}
+		go funds.checkBalance(component.AssetData, order.Maker, required, balanceCheck.result)
+		go funds.checkAllowance(component.AssetData, order.Maker, makerProxyAddresses[i], required, allowanceCheck.result)
+		balanceChecks = append(balanceChecks, balanceCheck)
+		allowanceChecks = append(allowanceChecks, allowanceCheck)
+	}
+	feeCheck := pendingCheck{"Insufficient fee token balance", make(chan boolOrErr)}
+	feeAllowanceCheck := pendingCheck{"Insufficient fee token allowance", make(chan boolOrErr)}
 	go funds.checkBalance(
 		feeToken,
 		order.Maker,
 		getRemainingAmount(unavailableAmount.Bytes(), order.TakerAssetAmount[:], order.MakerFee[:]),
-		feeChan,
-	)
-	go funds.checkAllowance(
-		order.MakerAssetData,
-		order.Maker,
-		makerProxyAddress,
-		getRemainingAmount(unavailableAmount.Bytes(), order.TakerAssetAmount[:], order.MakerAssetAmount[:]),
-		makerAllowanceChan,
+		feeCheck.result,
 	)
 	go funds.checkAllowance(
 		feeToken,
 		order.Maker,
 		feeProxyAddress,
 		getRemainingAmount(unavailableAmount.Bytes(), order.TakerAssetAmount[:], order.MakerFee[:]),
-		feeAllowanceChan,
+		feeAllowanceCheck.result,
 	)
+	checks := append(balanceChecks, feeCheck)
+	checks = append(checks, allowanceChecks...)
+	checks = append(checks, feeAllowanceCheck)
 	result := true
-	if chanResult := <-makerChan; !chanResult.success {
-		log.Printf("Insufficient maker token funds")
-		if chanResult.err != nil {
-			if chanResult.err.Error() == "no contract code at given address" {
-				return false, chanResult.err
-			}
-			panic(fmt.Sprintf("RPC Communication Failed: '%v'", chanResult.err.Error()))
+	var noContractErr error
+	for _, check := range checks {
+		chanResult := <-check.result
+		if chanResult.success {
+			continue
 		}
-		result = false
-	}
-	if chanResult := <-feeChan; !chanResult.success {
-		log.Printf("Insufficient fee token allowance")
+		log.Print(check.failure)
 		if chanResult.err != nil {
-			if chanResult.err.Error() == "no contract code at given address" {
-				return false, chanResult.err
+			if chanResult.err.Error() == "no contract code at given address" || noContractErr != nil {
+				// Keep reading the remaining results, so the goroutines
+				// sending them don't leak.
+				if noContractErr == nil {
+					noContractErr = chanResult.err
+				}
+				continue
 			}
 			panic(fmt.Sprintf("RPC Communication Failed: '%v'", chanResult.err.Error()))
 		}
 		result = false
 	}
-	if chanResult := <-makerAllowanceChan; !chanResult.success {
-		log.Printf("Insufficient makers token allowance")
-		if chanResult.err != nil {
-			if chanResult.err.Error() == "no contract code at given address" {
-				return false, chanResult.err
-			}
-			panic(fmt.Sprintf("RPC Communication Failed: '%v'", chanResult.err.Error()))
-		}
-		result = false
-	}
-	if chanResult := <-feeAllowanceChan; !chanResult.success {
-		log.Printf("Insufficient fee token allowance")
-		if chanResult.err != nil {
-			if chanResult.err.Error() == "no contract code at given address" {
-				return false, chanResult.err
-			}
-			panic(fmt.Sprintf("RPC Communication Failed: '%v'", chanResult.err.Error()))
-		}
-		result = false
+	if noContractErr != nil {
+		return false, noContractErr
 	}
 	return result, nil
 }
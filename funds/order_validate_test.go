@@ -158,3 +158,103 @@ func TestErrorNoContract(t *testing.T) {
 
 	validator.ValidateOrder(newOrder)
 }
+
+func TestOrderValidateMultiAsset(t *testing.T) {
+	tokenA, _ := hexToAssetData("f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba")
+	tokenB, _ := hexToAssetData("f47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c")
+	feeTokenAsset, _ := hexToAssetData("f47261b0000000000000000000000000e41d2489571d322189246dafa5ebde1f4699f498")
+	tokenProxyAddress, _ := hexToAddress("d4fd252d7d2c9479a8d616f510eac6243b5dddf9")
+	maker, _ := hexToAddress("627306090abab3a6e1400e9345bc60c78a8bef57")
+	newOrder, err := types.OrderFromBytes(getTestOrderBytes())
+	if err != nil {
+		t.Fatalf("Error parsing order: %v", err.Error())
+	}
+	// A bundle of 2 units of token A and 3 units of token B, 10 bundles for sale
+	newOrder.MakerAssetData = common.ToMultiAssetData(
+		[]*big.Int{big.NewInt(2), big.NewInt(3)},
+		[]types.AssetData{tokenA, tokenB},
+	)
+	copy(newOrder.MakerAssetAmount[:], gethCommon.LeftPadBytes(big.NewInt(10).Bytes(), 32))
+	for _, testCase := range []struct {
+		balanceA, balanceB int64
+		expected           bool
+	}{
+		{20, 30, true},
+		{19, 30, false},
+		{20, 29, false},
+	} {
+		balanceChecker := balance.NewMockBalanceChecker(map[string]map[types.Address]*big.Int{
+			string(tokenA): {*maker: big.NewInt(testCase.balanceA)},
+			string(tokenB): {*maker: big.NewInt(testCase.balanceB)},
+			string(feeTokenAsset): {*maker: big.NewInt(0)},
+		})
+		validator := funds.NewOrderValidator(balanceChecker, config.StaticFeeToken(feeTokenAsset), config.StaticTokenProxy(tokenProxyAddress))
+		if result, _ := validator.ValidateOrder(newOrder); result != testCase.expected {
+			t.Errorf("Balances %v / %v: expected %v, got %v", testCase.balanceA, testCase.balanceB, testCase.expected, result)
+		}
+	}
+}
+
+func TestOrderValidateERC1155(t *testing.T) {
+	tokenAddress, _ := hexToAddress("1dad4783cf3fe3085c1426157ab175a6119a04ba")
+	feeTokenAsset, _ := hexToAssetData("f47261b0000000000000000000000000e41d2489571d322189246dafa5ebde1f4699f498")
+	tokenProxyAddress, _ := hexToAddress("d4fd252d7d2c9479a8d616f510eac6243b5dddf9")
+	maker, _ := hexToAddress("627306090abab3a6e1400e9345bc60c78a8bef57")
+	newOrder, err := types.OrderFromBytes(getTestOrderBytes())
+	if err != nil {
+		t.Fatalf("Error parsing order: %v", err.Error())
+	}
+	// 4 units of token 1 and 1 unit of token 2 per unit of the order, 10 units for sale
+	newOrder.MakerAssetData = common.ToERC1155AssetData(
+		tokenAddress,
+		[]*big.Int{big.NewInt(1), big.NewInt(2)},
+		[]*big.Int{big.NewInt(4), big.NewInt(1)},
+		[]byte{},
+	)
+	copy(newOrder.MakerAssetAmount[:], gethCommon.LeftPadBytes(big.NewInt(10).Bytes(), 32))
+	token := func(id int64) string {
+		return string(common.ToERC1155AssetData(tokenAddress, []*big.Int{big.NewInt(id)}, []*big.Int{big.NewInt(1)}, []byte{}))
+	}
+	for _, testCase := range []struct {
+		balance1, balance2 int64
+		expected           bool
+	}{
+		{40, 10, true},
+		{39, 10, false},
+		{40, 9, false},
+	} {
+		balanceChecker := balance.NewMockBalanceChecker(map[string]map[types.Address]*big.Int{
+			token(1):              {*maker: big.NewInt(testCase.balance1)},
+			token(2):              {*maker: big.NewInt(testCase.balance2)},
+			string(feeTokenAsset): {*maker: big.NewInt(0)},
+		})
+		validator := funds.NewOrderValidator(balanceChecker, config.StaticFeeToken(feeTokenAsset), config.StaticTokenProxy(tokenProxyAddress))
+		if result, _ := validator.ValidateOrder(newOrder); result != testCase.expected {
+			t.Errorf("Balances %v / %v: expected %v, got %v", testCase.balance1, testCase.balance2, testCase.expected, result)
+		}
+	}
+}
+
+func TestOrderValidateV3FeeAsset(t *testing.T) {
+	balanceChecker := createMockBalanceChecker("f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba", "627306090abab3a6e1400e9345bc60c78a8bef57", "50000000000000000000", "0", t)
+	feeTokenAsset, _ := hexToAssetData("f47261b0000000000000000000000000e41d2489571d322189246dafa5ebde1f4699f498")
+	tokenProxyAddress, _ := hexToAddress("d4fd252d7d2c9479a8d616f510eac6243b5dddf9")
+	validator := funds.NewOrderValidator(balanceChecker, config.StaticFeeToken(feeTokenAsset), config.StaticTokenProxy(tokenProxyAddress))
+	newOrder, err := types.OrderFromBytes(getTestOrderBytes())
+	if err != nil {
+		t.Fatalf("Error parsing order: %v", err.Error())
+	}
+	newOrder.Version = types.OrderVersion3
+	newOrder.ChainID = 1
+	newOrder.MakerFee[31] = 1
+	// The maker has none of the relay's fee token, but v3 orders pay fees in
+	// their own makerFeeAssetData
+	newOrder.MakerFeeAssetData = newOrder.MakerAssetData
+	if result, _ := validator.ValidateOrder(newOrder); !result {
+		t.Errorf("Expected sufficient funds")
+	}
+	newOrder.MakerFeeAssetData = types.AssetData{}
+	if result, err := validator.ValidateOrder(newOrder); result || err != nil {
+		t.Errorf("Expected order without makerFeeAssetData to be invalid, got %v %v", result, err)
+	}
+}
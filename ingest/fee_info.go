@@ -5,9 +5,13 @@ import (
 	accountsModule "github.com/notegio/openrelay/accounts"
 	affiliatesModule "github.com/notegio/openrelay/affiliates"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/config"
 	"github.com/notegio/openrelay/types"
 	poolModule "github.com/notegio/openrelay/pool"
+	"bytes"
 	"io"
+	"io/ioutil"
 	"log"
 	"math/big"
 	"net/http"
@@ -30,10 +34,249 @@ type FeeResponse struct {
 	FeeRecipient   string `json:"feeRecipientAddress"`
 	Sender         string `json:"senderAddress"`
 	TakerToSpecify string `json:"takerToSpecify"`
+	MakerFeeAssetData string `json:"makerFeeAssetData,omitempty"`
+	TakerFeeAssetData string `json:"takerFeeAssetData,omitempty"`
 }
 
-func FeeHandler(publisher channels.Publisher, accounts accountsModule.AccountService, affiliates affiliatesModule.AffiliateService, defaultFeeRecipient [20]byte, exchangeLookup ExchangeLookup) func(http.ResponseWriter, *http.Request, *poolModule.Pool) {
+// FeeInput returns the FeeInputPayload requesting fees for `order`. Unset
+// addresses are left out, so the relay fills in its defaults.
+func FeeInput(order *types.Order) *FeeInputPayload {
+	emptyAddress := &types.Address{}
+	addressString := func(address *types.Address) string {
+		if address == nil || bytes.Equal(address[:], emptyAddress[:]) {
+			return ""
+		}
+		return fmt.Sprintf("%#x", address[:])
+	}
+	return &FeeInputPayload{
+		addressString(order.Maker),
+		addressString(order.ExchangeAddress),
+		addressString(order.FeeRecipient),
+		addressString(order.Taker),
+		addressString(order.SenderAddress),
+	}
+}
+
+// Apply sets the fee fields of `order` to the values in the FeeResponse. Fee
+// asset data is only applied to v3 orders. Orders must be signed after fees
+// are applied.
+func (response *FeeResponse) Apply(order *types.Order) error {
+	makerFee, err := types.IntStringToUint256(response.MakerFee)
+	if err != nil {
+		return err
+	}
+	takerFee, err := types.IntStringToUint256(response.TakerFee)
+	if err != nil {
+		return err
+	}
+	addresses := []*types.Address{&types.Address{}, &types.Address{}, &types.Address{}}
+	for i, value := range []string{response.FeeRecipient, response.Sender, response.TakerToSpecify} {
+		addressBytes, err := types.HexStringToBytes(value)
+		if err != nil {
+			return err
+		}
+		if len(addressBytes) != 20 {
+			return fmt.Errorf("Invalid address '%v'", value)
+		}
+		copy(addresses[i][:], addressBytes)
+	}
+	var makerFeeAssetData, takerFeeAssetData types.AssetData
+	if order.IsV3() {
+		if makerFeeAssetData, err = types.HexStringToBytes(response.MakerFeeAssetData); err != nil {
+			return err
+		}
+		if takerFeeAssetData, err = types.HexStringToBytes(response.TakerFeeAssetData); err != nil {
+			return err
+		}
+	}
+	order.MakerFee = makerFee
+	order.TakerFee = takerFee
+	order.FeeRecipient = addresses[0]
+	order.SenderAddress = addresses[1]
+	order.Taker = addresses[2]
+	if order.IsV3() {
+		order.MakerFeeAssetData = makerFeeAssetData
+		order.TakerFeeAssetData = takerFeeAssetData
+	}
+	return nil
+}
+
+// RequestFees asks the relay's order config endpoint at `url` for the fees
+// required on `order`, following the same rules as FeeHandler.
+func RequestFees(url string, order *types.Order) (*FeeResponse, error) {
+	payload, err := json.Marshal(FeeInput(order))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Fee request failed with status %v: %v", resp.StatusCode, string(body))
+	}
+	feeResponse := &FeeResponse{}
+	if err := json.Unmarshal(body, feeResponse); err != nil {
+		return nil, err
+	}
+	return feeResponse, nil
+}
+
+// feeCalculator applies the relay's fee rules. It is shared by FeeHandler
+// and TypedDataHandler, so both always agree on the fees an order needs.
+type feeCalculator struct {
+	accounts            accountsModule.AccountService
+	affiliates          affiliatesModule.AffiliateService
+	defaultFeeRecipient [20]byte
+	exchangeLookup      ExchangeLookup
+	feeToken            config.FeeToken
+}
+
+// fees returns the fees required for `feeInput` on API `version`. If the fees
+// can't be determined, it returns an IngestError and the HTTP status to
+// respond with.
+func (calc *feeCalculator) fees(feeInput *FeeInputPayload, version uint8, pool *poolModule.Pool) (*FeeResponse, *IngestError, int) {
 	emptyBytes := &types.Address{}
+	makerSlice, err := types.HexStringToBytes(feeInput.Maker)
+	if err != nil && feeInput.Maker != "" {
+		log.Printf("%v: '%v'", err.Error(), feeInput.Maker)
+		return nil, &IngestError{
+			100,
+			"Validation failed",
+			[]ValidationError{ValidationError{
+				"maker",
+				1001,
+				"Invalid address format",
+			},
+			},
+		}, 400
+	}
+	feeRecipientAddressSlice, err := types.HexStringToBytes(feeInput.FeeRecipient)
+	if err != nil && feeInput.FeeRecipient != "" {
+		log.Printf("%v: '%v'", err.Error(), feeInput.FeeRecipient)
+		return nil, &IngestError{
+			100,
+			"Validation failed",
+			[]ValidationError{ValidationError{
+				"feeRecipient",
+				1001,
+				"Invalid address format",
+			},
+			},
+		}, 400
+	}
+	exchangeAddressSlice, err := types.HexStringToBytes(feeInput.Exchange)
+	if err != nil && feeInput.FeeRecipient != "" {
+		log.Printf("%v: '%v'", err.Error(), feeInput.Exchange)
+		return nil, &IngestError{
+			100,
+			"Validation failed",
+			[]ValidationError{ValidationError{
+				"exchangeAddress",
+				1001,
+				"Invalid address format",
+			},
+			},
+		}, 400
+	}
+	exchangeAddress := &types.Address{}
+	copy(exchangeAddress[:], exchangeAddressSlice[:])
+	networkIDChan := calc.exchangeLookup.ExchangeIsKnown(exchangeAddress)
+	makerAddress := &types.Address{}
+	copy(makerAddress[:], makerSlice[:])
+	feeRecipientAddress := &types.Address{}
+	if feeInput.FeeRecipient == "" {
+		copy(feeRecipientAddress[:], calc.defaultFeeRecipient[:])
+	} else {
+		copy(feeRecipientAddress[:], feeRecipientAddressSlice)
+	}
+	makerChan := make(chan accountsModule.Account)
+	affiliateChan := make(chan affiliatesModule.Affiliate)
+	go func() {
+		feeRecipient, err := calc.affiliates.Get(feeRecipientAddress)
+		if err != nil {
+			affiliateChan <- nil
+		} else {
+			affiliateChan <- feeRecipient
+		}
+	}()
+	go func() { makerChan <- calc.accounts.Get(makerAddress) }()
+	feeRecipient := <-affiliateChan
+	if feeRecipient == nil {
+		return nil, &IngestError{
+			100,
+			"Validation Failed",
+			[]ValidationError{ValidationError{
+				"feeRecipient",
+				1002,
+				"Invalid fee recpient",
+			}},
+		}, 402
+	}
+	poolFee, err := pool.Fee()
+	if err != nil {
+		return nil, &IngestError{
+			100,
+			"Validation Failed",
+			[]ValidationError{ValidationError{
+				"pool",
+				1002,
+				"Pool error",
+			}},
+		}, 500
+	}
+	account := <-makerChan
+	minFee := new(big.Int)
+
+	// A fee recipient's Fee() value is the base fee for that recipient. A
+	// maker's Discount() is the discount that recipient gets from the base
+	// fee. Thus, the minimum fee required is pool.Fee() - maker.Discount()
+	minFee.Sub(poolFee, account.Discount())
+	takerToSpecify := fmt.Sprintf("%#x", emptyBytes[:])
+	networkID := <-networkIDChan
+	if networkID == 0 {
+		networkID = 1
+	}
+	var senderToSpecify string
+	senderAddress, ok := pool.SenderAddresses[networkID]
+	if ok {
+		senderToSpecify = fmt.Sprintf("%#x", senderAddress[:])
+	} else {
+		senderToSpecify = fmt.Sprintf("%#x", emptyBytes[:])
+
+	}
+	if feeInput.Taker != "" {
+		takerToSpecify = feeInput.Taker
+	}
+	if feeInput.Sender != "" {
+		senderToSpecify = feeInput.Sender
+	}
+	feeResponse := &FeeResponse{
+		minFee.Text(10),
+		"0",
+		fmt.Sprintf("%#x", feeRecipientAddress[:]),
+		senderToSpecify,
+		takerToSpecify,
+		"",
+		"",
+	}
+	if version == types.OrderVersion3 {
+		// v3 orders specify the asset fees are paid in
+		feeOrder := &types.Order{ExchangeAddress: exchangeAddress, Version: types.OrderVersion3}
+		relayFeeAsset := feeAssetData(feeOrder, calc.feeToken)
+		feeResponse.MakerFeeAssetData = fmt.Sprintf("0x%x", []byte(relayFeeAsset))
+		feeResponse.TakerFeeAssetData = feeResponse.MakerFeeAssetData
+	}
+	return feeResponse, nil, 200
+}
+
+func FeeHandler(publisher channels.Publisher, accounts accountsModule.AccountService, affiliates affiliatesModule.AffiliateService, defaultFeeRecipient [20]byte, exchangeLookup ExchangeLookup, feeToken config.FeeToken) func(http.ResponseWriter, *http.Request, *poolModule.Pool) {
+	calc := &feeCalculator{accounts, affiliates, defaultFeeRecipient, exchangeLookup, feeToken}
 	return func(w http.ResponseWriter, r *http.Request, pool *poolModule.Pool) {
 		var data [1024]byte
 		feeInput := &FeeInputPayload{}
@@ -57,132 +300,11 @@ func FeeHandler(publisher channels.Publisher, accounts accountsModule.AccountSer
 			}, 400)
 			return
 		}
-		makerSlice, err := types.HexStringToBytes(feeInput.Maker)
-		if err != nil && feeInput.Maker != "" {
-			log.Printf("%v: '%v'", err.Error(), string(data[:]))
-			returnError(w, IngestError{
-				100,
-				"Validation failed",
-				[]ValidationError{ValidationError{
-					"maker",
-					1001,
-					"Invalid address format",
-				},
-				},
-			}, 400)
-			return
-		}
-		feeRecipientAddressSlice, err := types.HexStringToBytes(feeInput.FeeRecipient)
-		if err != nil && feeInput.FeeRecipient != "" {
-			log.Printf("%v: '%v'", err.Error(), string(data[:]))
-			returnError(w, IngestError{
-				100,
-				"Validation failed",
-				[]ValidationError{ValidationError{
-					"feeRecipient",
-					1001,
-					"Invalid address format",
-				},
-				},
-			}, 400)
-			return
-		}
-		exchangeAddressSlice, err := types.HexStringToBytes(feeInput.Exchange)
-		if err != nil && feeInput.FeeRecipient != "" {
-			log.Printf("%v: '%v'", err.Error(), string(data[:]))
-			returnError(w, IngestError{
-				100,
-				"Validation failed",
-				[]ValidationError{ValidationError{
-					"exchangeAddress",
-					1001,
-					"Invalid address format",
-				},
-				},
-			}, 400)
-			return
-		}
-		exchangeAddress := &types.Address{}
-		copy(exchangeAddress[:], exchangeAddressSlice[:])
-		networkIDChan := exchangeLookup.ExchangeIsKnown(exchangeAddress)
-		makerAddress := &types.Address{}
-		copy(makerAddress[:], makerSlice[:])
-		feeRecipientAddress := &types.Address{}
-		if feeInput.FeeRecipient == "" {
-			copy(feeRecipientAddress[:], defaultFeeRecipient[:])
-		} else {
-			copy(feeRecipientAddress[:], feeRecipientAddressSlice)
-		}
-		makerChan := make(chan accountsModule.Account)
-		affiliateChan := make(chan affiliatesModule.Affiliate)
-		go func() {
-			feeRecipient, err := affiliates.Get(feeRecipientAddress)
-			if err != nil {
-				affiliateChan <- nil
-			} else {
-				affiliateChan <- feeRecipient
-			}
-		}()
-		go func() { makerChan <- accounts.Get(makerAddress) }()
-		feeRecipient := <-affiliateChan
-		if feeRecipient == nil {
-			returnError(w, IngestError{
-				100,
-				"Validation Failed",
-				[]ValidationError{ValidationError{
-					"feeRecipient",
-					1002,
-					"Invalid fee recpient",
-				}},
-			}, 402)
-			return
-		}
-		poolFee, err := pool.Fee()
-		if err != nil {
-			returnError(w, IngestError{
-				100,
-				"Validation Failed",
-				[]ValidationError{ValidationError{
-					"pool",
-					1002,
-					"Pool error",
-				}},
-			}, 500)
+		feeResponse, ingestErr, status := calc.fees(feeInput, common.RequestVersion(r), pool)
+		if ingestErr != nil {
+			returnError(w, *ingestErr, status)
 			return
 		}
-		account := <-makerChan
-		minFee := new(big.Int)
-
-		// A fee recipient's Fee() value is the base fee for that recipient. A
-		// maker's Discount() is the discount that recipient gets from the base
-		// fee. Thus, the minimum fee required is pool.Fee() - maker.Discount()
-		minFee.Sub(poolFee, account.Discount())
-		takerToSpecify := fmt.Sprintf("%#x", emptyBytes[:])
-		networkID := <-networkIDChan
-		if networkID == 0 {
-			networkID = 1
-		}
-		var senderToSpecify string
-		senderAddress, ok := pool.SenderAddresses[networkID]
-		if ok {
-			senderToSpecify = fmt.Sprintf("%#x", senderAddress[:])
-		} else {
-			senderToSpecify = fmt.Sprintf("%#x", emptyBytes[:])
-
-		}
-		if feeInput.Taker != "" {
-			takerToSpecify = feeInput.Taker
-		}
-		if feeInput.Sender != "" {
-			senderToSpecify = feeInput.Sender
-		}
-		feeResponse := &FeeResponse{
-			minFee.Text(10),
-			"0",
-			fmt.Sprintf("%#x", feeRecipientAddress[:]),
-			senderToSpecify,
-			takerToSpecify,
-		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(200)
 		feeBytes, err := json.Marshal(feeResponse)
@@ -1,14 +1,18 @@
 package ingest_test
 
 import (
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/notegio/openrelay/ingest"
 	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/config"
 	"github.com/notegio/openrelay/types"
 	poolModule "github.com/notegio/openrelay/pool"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 	// "reflect"
 	// "io/ioutil"
 	// "fmt"
@@ -41,7 +45,7 @@ func mockPoolDecorator(fn func(http.ResponseWriter, *http.Request, *poolModule.P
 
 func TestFeeRecipientAndMakerProvided(t *testing.T) {
 	publisher := TestPublisher{}
-	handler := mockPoolDecorator(ingest.FeeHandler(&publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{new(big.Int), nil}, [20]byte{}, &TestExchangeLookup{1}))
+	handler := mockPoolDecorator(ingest.FeeHandler(&publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{new(big.Int), nil}, [20]byte{}, &TestExchangeLookup{1}, nil))
 	reader := TestReader{
 		[]byte("{\"maker\": \"0x0000000000000000000000000000000000000000\", \"feeRecipientAddress\": \"0000000000000000000000000000000000000000\", \"takerTokenAmount\": \"100\", \"makerTokenAmount\": \"100\"}"),
 		nil,
@@ -61,7 +65,7 @@ func TestFeeRecipientAndMakerProvided(t *testing.T) {
 }
 func TestFeeRecipientAndMakerDefault(t *testing.T) {
 	publisher := TestPublisher{}
-	handler := mockPoolDecorator(ingest.FeeHandler(&publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{new(big.Int), nil}, [20]byte{}, &TestExchangeLookup{1}))
+	handler := mockPoolDecorator(ingest.FeeHandler(&publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{new(big.Int), nil}, [20]byte{}, &TestExchangeLookup{1}, nil))
 	reader := TestReader{
 		[]byte("{\"takerTokenAmount\": \"100\", \"makerTokenAmount\": \"100\"}"),
 		nil,
@@ -79,3 +83,91 @@ func TestFeeRecipientAndMakerDefault(t *testing.T) {
 		t.Errorf("Unexpected body: '%v'", body)
 	}
 }
+func TestFeeConfigV3(t *testing.T) {
+	publisher := TestPublisher{}
+	feeAssetData, _ := common.HexToAssetData("0xf47261b0000000000000000000000000e41d2489571d322189246dafa5ebde1f4699f498")
+	handler := mockPoolDecorator(ingest.FeeHandler(&publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{new(big.Int), nil}, [20]byte{}, &TestExchangeLookup{1}, config.StaticFeeToken(feeAssetData)))
+	reader := TestReader{
+		[]byte("{\"takerTokenAmount\": \"100\", \"makerTokenAmount\": \"100\"}"),
+		nil,
+	}
+	request, _ := http.NewRequest("POST", "/sra/v3/order_config", reader)
+	request.Header["Content-Type"] = []string{"application/json"}
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	if recorder.Code != 200 {
+		t.Errorf("Expected error code 200, got '%v'", recorder.Code)
+		t.Errorf("Body: '%v'", recorder.Body.String())
+	}
+	body := recorder.Body.String()
+	if body != "{\"makerFee\":\"0\",\"takerFee\":\"0\",\"feeRecipientAddress\":\"0x0000000000000000000000000000000000000000\",\"senderAddress\":\"0x0000000000000000000000000000000000000000\",\"takerToSpecify\":\"0x0000000000000000000000000000000000000000\",\"makerFeeAssetData\":\"0xf47261b0000000000000000000000000e41d2489571d322189246dafa5ebde1f4699f498\",\"takerFeeAssetData\":\"0xf47261b0000000000000000000000000e41d2489571d322189246dafa5ebde1f4699f498\"}" {
+		t.Errorf("Unexpected body: '%v'", body)
+	}
+}
+func TestFeeInputApply(t *testing.T) {
+	key, err := crypto.HexToECDSA("4f3edf983ac636a65a842ce7c78d9aa706d3b113bce9c46f30d7d21715b23b1d")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	exchangeAddress, _ := common.HexToAddress("0x90fe2af704b34e0224bf2299c838e04d4dcf1364")
+	maker, _ := common.HexToAddress("0x90f8bf6a479f320ead074411a4b0e7944ea8c9c1")
+	makerAssetData, _ := common.HexToAssetData("0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba")
+	takerAssetData, _ := common.HexToAssetData("0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c")
+	order := types.BuildOrder(maker, nil, nil, exchangeAddress, nil, makerAssetData, takerAssetData, big.NewInt(100), big.NewInt(100), nil, nil, big.NewInt(time.Now().Unix() + 3600), big.NewInt(1))
+	feeInput := ingest.FeeInput(order)
+	if feeInput.Maker != "0x90f8bf6a479f320ead074411a4b0e7944ea8c9c1" || feeInput.FeeRecipient != "" || feeInput.Sender != "" {
+		t.Errorf("Unexpected fee input: %v", feeInput)
+	}
+	payload, _ := json.Marshal(feeInput)
+
+	fee := big.NewInt(1000)
+	feeRecipient := [20]byte{}
+	feeRecipient[19] = 1
+	feeHandler := mockPoolDecoratorFee(fee, ingest.FeeHandler(&TestPublisher{}, &TestAccountService{false, big.NewInt(200)}, &TestAffiliateService{fee, nil}, feeRecipient, &TestExchangeLookup{1}, nil))
+	request, _ := http.NewRequest("POST", "/v2/order_config", TestReader{payload, nil})
+	request.Header["Content-Type"] = []string{"application/json"}
+	recorder := httptest.NewRecorder()
+	feeHandler(recorder, request)
+	if recorder.Code != 200 {
+		t.Fatalf("Expected error code 200, got '%v'", recorder.Code)
+	}
+	feeResponse := &ingest.FeeResponse{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), feeResponse); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := feeResponse.Apply(order); err != nil {
+		t.Fatal(err.Error())
+	}
+	if order.MakerFee.Big().Int64() != 800 || order.TakerFee.Big().Int64() != 0 || order.FeeRecipient[19] != 1 {
+		t.Errorf("Unexpected fees: %v %v %v", order.MakerFee, order.TakerFee, order.FeeRecipient)
+	}
+	if err := order.Sign(key, types.SigTypeEthSign); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	publisher := &TestPublisher{}
+	handler := mockPoolDecoratorFee(fee, ingest.Handler(publisher, &TestAccountService{false, big.NewInt(200)}, &TestAffiliateService{fee, nil}, true, &TestTermsManager{true}, &TestExchangeLookup{1}, nil, true))
+	request, _ = http.NewRequest("POST", "/v2/order", TestReader{order.Bytes(), nil})
+	request.Header["Content-Type"] = []string{"application/octet-stream"}
+	recorder = httptest.NewRecorder()
+	handler(recorder, request)
+	if recorder.Code != 202 {
+		t.Errorf("Expected error code 202, got '%v'", recorder.Code)
+		t.Errorf("Body: '%v'", recorder.Body.String())
+	}
+	if len(publisher.messages) != 1 {
+		t.Errorf("Unexpected message count '%v'", len(publisher.messages))
+	}
+}
+
+func TestFeeResponseApplyInvalid(t *testing.T) {
+	order := &types.Order{}
+	order.Initialize()
+	feeResponse := &ingest.FeeResponse{"100", "0", "0x00", "0x0000000000000000000000000000000000000000", "0x0000000000000000000000000000000000000000", "", ""}
+	if err := feeResponse.Apply(order); err == nil {
+		t.Errorf("Expected invalid fee recipient to fail")
+	}
+	if order.MakerFee.Big().Sign() != 0 {
+		t.Errorf("Order should not be modified on error")
+	}
+}
@@ -8,6 +8,8 @@ import (
 	accountsModule "github.com/notegio/openrelay/accounts"
 	affiliatesModule "github.com/notegio/openrelay/affiliates"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/config"
 	"github.com/notegio/openrelay/types"
 	poolModule "github.com/notegio/openrelay/pool"
 	"io"
@@ -49,7 +51,53 @@ func returnError(w http.ResponseWriter, errResp IngestError, status int) {
 	w.Write(errBytes)
 }
 
-func Handler(publisher channels.Publisher, accounts accountsModule.AccountService, affiliates affiliatesModule.AffiliateService, enforceTerms bool, tm TermsManager, exchangeLookup ExchangeLookup) func(http.ResponseWriter, *http.Request, *poolModule.Pool) {
+// feeAssetData returns the asset data of the relay's fee token for v3 orders,
+// or nil if the relay doesn't have one configured.
+func feeAssetData(order *types.Order, feeToken config.FeeToken) types.AssetData {
+	if feeToken == nil {
+		return nil
+	}
+	assetData, err := feeToken.Get(order)
+	if err != nil {
+		log.Printf("Error getting fee token: %v", err.Error())
+		return nil
+	}
+	return assetData
+}
+
+// checkFeeAssets makes sure that the fees of a v3 order are denominated in
+// the relay's fee token, as fees in any other asset can't count towards the
+// relay's minimum fee.
+func checkFeeAssets(order *types.Order, feeToken config.FeeToken) []ValidationError {
+	relayFeeAsset := feeAssetData(order, feeToken)
+	errs := []ValidationError{}
+	fees := []struct {
+		field     string
+		amount    *types.Uint256
+		assetData types.AssetData
+	}{
+		{"makerFeeAssetData", order.MakerFee, order.MakerFeeAssetData},
+		{"takerFeeAssetData", order.TakerFee, order.TakerFeeAssetData},
+	}
+	for _, fee := range fees {
+		if fee.amount.Big().Sign() == 0 {
+			continue
+		}
+		if relayFeeAsset == nil {
+			errs = append(errs, ValidationError{fee.field, 1006, "This relay does not accept fees on v3 orders"})
+		} else if !bytes.Equal(fee.assetData, relayFeeAsset) {
+			errs = append(errs, ValidationError{fee.field, 1006, fmt.Sprintf("Fees must be paid in %#x", relayFeeAsset[:])})
+		}
+	}
+	return errs
+}
+
+// Handler accepts orders and publishes them to `publisher`. If `envelopes` is
+// true, orders are published in envelopes with origin, correlation id and
+// schema version headers. Consumers built before envelopes existed can't read
+// them, so envelopes should only be enabled once every consumer of
+// `publisher` has been upgraded.
+func Handler(publisher channels.Publisher, accounts accountsModule.AccountService, affiliates affiliatesModule.AffiliateService, enforceTerms bool, tm TermsManager, exchangeLookup ExchangeLookup, feeToken config.FeeToken, envelopes bool) func(http.ResponseWriter, *http.Request, *poolModule.Pool) {
 	var contentType string
 	return func(w http.ResponseWriter, r *http.Request, pool *poolModule.Pool) {
 		if r.Method == "GET" {
@@ -115,6 +163,18 @@ func Handler(publisher channels.Publisher, accounts accountsModule.AccountServic
 			}, 415)
 			return
 		}
+		if order.IsV3() != (common.RequestVersion(r) == types.OrderVersion3) {
+			returnError(w, IngestError{
+				100,
+				"Validation Failed",
+				[]ValidationError{ValidationError{
+					"order",
+					1006,
+					"Order version does not match the requested API version",
+				}},
+			}, 400)
+			return
+		}
 		networkIDChan := exchangeLookup.ExchangeIsKnown(order.ExchangeAddress)
 		var signedMaker <-chan bool
 		if(enforceTerms) {
@@ -152,7 +212,7 @@ func Handler(publisher channels.Publisher, accounts accountsModule.AccountServic
 			return
 		}
 		emptyAddress := types.Address{}
-		if !order.Signature.Supported() {
+		if !order.Signature.Supported(pool.SignatureTypes...) {
 			returnError(w, IngestError{
 				100,
 				"Validation Failed",
@@ -164,7 +224,15 @@ func Handler(publisher channels.Publisher, accounts accountsModule.AccountServic
 			}, 400)
 			return
 		}
-		if !order.Signature.Verify(order.Maker, order.Hash()) {
+		if valid, err := order.Signature.VerifyExchange(order.ExchangeAddress, order.Maker, order.Hash()); err != nil {
+			log.Printf("Error verifying signature: %v", err.Error())
+			returnError(w, IngestError{
+				100,
+				"Error verifying signature",
+				nil,
+			}, 500)
+			return
+		} else if !valid {
 			returnError(w, IngestError{
 				100,
 				"Validation Failed",
@@ -265,6 +333,18 @@ func Handler(publisher channels.Publisher, accounts accountsModule.AccountServic
 			}, 400)
 			return
 		}
+		if order.IsV3() && uint64(networkID) != order.ChainID {
+			returnError(w, IngestError{
+				100,
+				"Validation Failed",
+				[]ValidationError{ValidationError{
+					"chainId",
+					1002,
+					"exchangeAddress is not deployed on chainId",
+				}},
+			}, 400)
+			return
+		}
 		if len(pool.SenderAddresses) != 0 && !bytes.Equal(pool.SenderAddresses[networkID][:], emptyAddress[:]) && !bytes.Equal(pool.SenderAddresses[networkID][:], order.SenderAddress[:]) {
 			returnError(w, IngestError{
 				100,
@@ -285,6 +365,16 @@ func Handler(publisher channels.Publisher, accounts accountsModule.AccountServic
 			}, 400)
 			return
 		}
+		if order.IsV3() {
+			if errs := checkFeeAssets(&order, feeToken); len(errs) > 0 {
+				returnError(w, IngestError{
+					100,
+					"Validation Failed",
+					errs,
+				}, 400)
+				return
+			}
+		}
 		makerFee := new(big.Int)
 		takerFee := new(big.Int)
 		totalFee := new(big.Int)
@@ -350,8 +440,18 @@ func Handler(publisher channels.Publisher, accounts accountsModule.AccountServic
 		w.WriteHeader(202)
 		fmt.Fprintf(w, "")
 		orderBytes := order.Bytes()
-		if err := publisher.Publish(string(orderBytes[:])); !err {
-			log.Println("Failed to publish '%v'", hex.EncodeToString(order.Hash()))
+		if !envelopes {
+			if !publisher.Publish(string(orderBytes[:])) {
+				log.Printf("Failed to publish '%v'", hex.EncodeToString(order.Hash()))
+			}
+			return
+		}
+		headers := channels.NewHeaders("ingest")
+		headers[channels.HeaderSchemaVersion] = types.OrderSchemaVersion
+		if err := channels.PublishWithHeaders(publisher, headers, string(orderBytes[:])); !err {
+			log.Printf("Failed to publish '%v'", hex.EncodeToString(order.Hash()))
+		} else {
+			log.Printf("Published order '%v' with correlation id %v", hex.EncodeToString(order.Hash()), headers[channels.HeaderCorrelationID])
 		}
 	}
 }
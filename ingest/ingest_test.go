@@ -1,10 +1,14 @@
 package ingest_test
 
 import (
+	"bytes"
 	"encoding/hex"
+	"github.com/ethereum/go-ethereum/crypto"
 	accountsModule "github.com/notegio/openrelay/accounts"
 	affiliatesModule "github.com/notegio/openrelay/affiliates"
+	"github.com/notegio/openrelay/channels"
 	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/config"
 	"github.com/notegio/openrelay/ingest"
 	"github.com/notegio/openrelay/types"
 	poolModule "github.com/notegio/openrelay/pool"
@@ -12,6 +16,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 	// "reflect"
 	"errors"
 	// "io/ioutil"
@@ -130,7 +135,7 @@ func (tm *TestExchangeLookup) ExchangeIsKnown(*types.Address) (<-chan uint) {
 
 func TestBadRead(t *testing.T) {
 	publisher := TestPublisher{}
-	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{}, &TestAffiliateService{}, true, &TestTermsManager{true}, &TestExchangeLookup{1}))
+	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{}, &TestAffiliateService{}, true, &TestTermsManager{true}, &TestExchangeLookup{1}, nil, true))
 	reader := TestReader{
 		[]byte("00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000"),
 		errors.New("Fail!"),
@@ -155,7 +160,7 @@ func TestBadRead(t *testing.T) {
 }
 func TestBadJSON(t *testing.T) {
 	publisher := TestPublisher{}
-	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{}, &TestAffiliateService{}, true, &TestTermsManager{true}, &TestExchangeLookup{1}))
+	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{}, &TestAffiliateService{}, true, &TestTermsManager{true}, &TestExchangeLookup{1}, nil, true))
 	reader := TestReader{
 		[]byte("bad json"),
 		nil,
@@ -180,7 +185,7 @@ func TestBadJSON(t *testing.T) {
 }
 func TestJSONBadRead(t *testing.T) {
 	publisher := TestPublisher{}
-	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{}, &TestAffiliateService{}, true, &TestTermsManager{true}, &TestExchangeLookup{1}))
+	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{}, &TestAffiliateService{}, true, &TestTermsManager{true}, &TestExchangeLookup{1}, nil, true))
 	reader := TestReader{
 		[]byte("bad json"),
 		errors.New("Sample Error"),
@@ -205,7 +210,7 @@ func TestJSONBadRead(t *testing.T) {
 }
 func TestNoContentType(t *testing.T) {
 	publisher := TestPublisher{}
-	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{}, &TestAffiliateService{}, true, &TestTermsManager{true}, &TestExchangeLookup{1}))
+	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{}, &TestAffiliateService{}, true, &TestTermsManager{true}, &TestExchangeLookup{1}, nil, true))
 	reader := TestReader{
 		[]byte(""),
 		nil,
@@ -229,7 +234,7 @@ func TestNoContentType(t *testing.T) {
 }
 func TestBadSignature(t *testing.T) {
 	publisher := TestPublisher{}
-	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{}, &TestAffiliateService{}, true, &TestTermsManager{true}, &TestExchangeLookup{1}))
+	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{}, &TestAffiliateService{}, true, &TestTermsManager{true}, &TestExchangeLookup{1}, nil, true))
 	data, _ := hex.DecodeString("f9020a94627306090abab3a6e1400e9345bc60c78a8bef57940000000000000000000000000000000000000000941dad4783cf3fe3085c1426157ab175a6119a04ba9405d090b51c40b020eab3bfcb6a2dff130df22e9ca4f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04baa4f47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c9400000000000000000000000000000000000000009490fe2af704b34e0224bf2299c838e04d4dcf1364940000000000000000000000000000000000000000a0000000000000000000000000000000000000000000000002b5e3af16b1880000a00000000000000000000000000000000000000000000000000de0b6b3a7640000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000159938ac4a0000643508ff7019bfb134363a86e98746f6c33262e68daf992b8df064217222bb8421c34f27415dc0177bc4016d48c3ec7eb19ee31124bcf4ca2eb3aba767c24e4712043bf8e49d1e28c6efa5a5e8b6824886700f356a403e0e66c75621e56b184b47b03a000000000000000000000000000000000000000000000000000000000000000008080")
 	reader := TestReader{
 		data,
@@ -257,7 +262,7 @@ func TestInsufficientFee(t *testing.T) {
 	publisher := TestPublisher{}
 	fee := new(big.Int)
 	fee.SetInt64(1000)
-	handler := mockPoolDecoratorFee(fee, ingest.Handler(&publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{fee, nil}, true, &TestTermsManager{true}, &TestExchangeLookup{1}))
+	handler := mockPoolDecoratorFee(fee, ingest.Handler(&publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{fee, nil}, true, &TestTermsManager{true}, &TestExchangeLookup{1}, nil, true))
 	data, _ := hex.DecodeString("f9020a94627306090abab3a6e1400e9345bc60c78a8bef57940000000000000000000000000000000000000000941dad4783cf3fe3085c1426157ab175a6119a04ba9405d090b51c40b020eab3bfcb6a2dff130df22e9ca4f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04baa4f47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c9400000000000000000000000000000000000000009490fe2af704b34e0224bf2299c838e04d4dcf1364940000000000000000000000000000000000000000a0000000000000000000000000000000000000000000000002b5e3af16b1880000a00000000000000000000000000000000000000000000000000de0b6b3a7640000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000159938ac4a0000643508ff7019bfb134363a86e98746f6c33262e68daf992b8df064217222bb8421ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03a000000000000000000000000000000000000000000000000000000000000000008080")
 	reader := TestReader{
 		data,
@@ -283,7 +288,7 @@ func TestInsufficientFee(t *testing.T) {
 }
 func TestBlacklisted(t *testing.T) {
 	publisher := TestPublisher{}
-	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{true, new(big.Int)}, &TestAffiliateService{new(big.Int), nil}, true, &TestTermsManager{true}, &TestExchangeLookup{1}))
+	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{true, new(big.Int)}, &TestAffiliateService{new(big.Int), nil}, true, &TestTermsManager{true}, &TestExchangeLookup{1}, nil, true))
 	data, _ := hex.DecodeString("f9020a94627306090abab3a6e1400e9345bc60c78a8bef57940000000000000000000000000000000000000000941dad4783cf3fe3085c1426157ab175a6119a04ba9405d090b51c40b020eab3bfcb6a2dff130df22e9ca4f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04baa4f47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c9400000000000000000000000000000000000000009490fe2af704b34e0224bf2299c838e04d4dcf1364940000000000000000000000000000000000000000a0000000000000000000000000000000000000000000000002b5e3af16b1880000a00000000000000000000000000000000000000000000000000de0b6b3a7640000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000159938ac4a0000643508ff7019bfb134363a86e98746f6c33262e68daf992b8df064217222bb8421ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03a000000000000000000000000000000000000000000000000000000000000000008080")
 	reader := TestReader{
 		data,
@@ -302,7 +307,7 @@ func TestBlacklisted(t *testing.T) {
 }
 func TestNotFeeRecipient(t *testing.T) {
 	publisher := TestPublisher{}
-	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{true, new(big.Int)}, &TestAffiliateService{nil, errors.New("Fee Recipient must be an authorized address")}, true, &TestTermsManager{true}, &TestExchangeLookup{1}))
+	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{true, new(big.Int)}, &TestAffiliateService{nil, errors.New("Fee Recipient must be an authorized address")}, true, &TestTermsManager{true}, &TestExchangeLookup{1}, nil, true))
 	data, _ := hex.DecodeString("f9020a94627306090abab3a6e1400e9345bc60c78a8bef57940000000000000000000000000000000000000000941dad4783cf3fe3085c1426157ab175a6119a04ba9405d090b51c40b020eab3bfcb6a2dff130df22e9ca4f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04baa4f47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c9400000000000000000000000000000000000000009490fe2af704b34e0224bf2299c838e04d4dcf1364940000000000000000000000000000000000000000a0000000000000000000000000000000000000000000000002b5e3af16b1880000a00000000000000000000000000000000000000000000000000de0b6b3a7640000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000159938ac4a0000643508ff7019bfb134363a86e98746f6c33262e68daf992b8df064217222bb8421ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03a000000000000000000000000000000000000000000000000000000000000000008080")
 	reader := TestReader{
 		data,
@@ -325,7 +330,7 @@ func TestNotFeeRecipient(t *testing.T) {
 }
 func TestValid(t *testing.T) {
 	publisher := TestPublisher{}
-	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{new(big.Int), nil}, true, &TestTermsManager{true}, &TestExchangeLookup{1}))
+	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{new(big.Int), nil}, true, &TestTermsManager{true}, &TestExchangeLookup{1}, nil, true))
 	data, _ := hex.DecodeString("f9021194627306090abab3a6e1400e9345bc60c78a8bef57940000000000000000000000000000000000000000941dad4783cf3fe3085c1426157ab175a6119a04ba9405d090b51c40b020eab3bfcb6a2dff130df22e9ca4f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04baa4f47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c9400000000000000000000000000000000000000009490fe2af704b34e0224bf2299c838e04d4dcf1364940000000000000000000000000000000000000000a0000000000000000000000000000000000000000000000002b5e3af16b1880000a00000000000000000000000000000000000000000000000000de0b6b3a7640000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000159938ac4a0000643508ff7019bfb134363a86e98746f6c33262e68daf992b8df064217222bb8421ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03a00000000000000000000000000000000000000000000000000000000000000000808764656661756c74")
 	reader := TestReader{
 		data,
@@ -343,13 +348,43 @@ func TestValid(t *testing.T) {
 		t.Errorf("Unexpected message count '%v'", len(publisher.messages))
 		return
 	}
-	if publisher.messages[0] != string(data) {
-		t.Errorf("Unexpected message data: %#x", publisher.messages[0])
+	headers, body := channels.Unwrap(publisher.messages[0])
+	order, _ := types.OrderFromBytes(data)
+	if body != string(order.Bytes()) {
+		t.Errorf("Unexpected message data: %#x", body)
+	}
+	if headers[channels.HeaderOrigin] != "ingest" || headers[channels.HeaderSchemaVersion] != types.OrderSchemaVersion {
+		t.Errorf("Unexpected message headers: %v", headers)
+	}
+}
+func TestValidWithoutEnvelopes(t *testing.T) {
+	publisher := TestPublisher{}
+	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{new(big.Int), nil}, true, &TestTermsManager{true}, &TestExchangeLookup{1}, nil, false))
+	data, _ := hex.DecodeString("f9021194627306090abab3a6e1400e9345bc60c78a8bef57940000000000000000000000000000000000000000941dad4783cf3fe3085c1426157ab175a6119a04ba9405d090b51c40b020eab3bfcb6a2dff130df22e9ca4f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04baa4f47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c9400000000000000000000000000000000000000009490fe2af704b34e0224bf2299c838e04d4dcf1364940000000000000000000000000000000000000000a0000000000000000000000000000000000000000000000002b5e3af16b1880000a00000000000000000000000000000000000000000000000000de0b6b3a7640000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000159938ac4a0000643508ff7019bfb134363a86e98746f6c33262e68daf992b8df064217222bb8421ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03a00000000000000000000000000000000000000000000000000000000000000000808764656661756c74")
+	reader := TestReader{
+		data,
+		nil,
+	}
+	request, _ := http.NewRequest("POST", "/", reader)
+	request.Header["Content-Type"] = []string{"application/octet-stream"}
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	if recorder.Code != 202 {
+		t.Errorf("Expected error code 202, got '%v'", recorder.Code)
+		t.Errorf("Body: '%v'", recorder.Body.String())
+	}
+	if len(publisher.messages) != 1 {
+		t.Errorf("Unexpected message count '%v'", len(publisher.messages))
+		return
+	}
+	order, _ := types.OrderFromBytes(data)
+	if publisher.messages[0] != string(order.Bytes()) {
+		t.Errorf("Expected the order without an envelope, got: %#x", publisher.messages[0])
 	}
 }
 func TestBadExchange(t *testing.T) {
 	publisher := TestPublisher{}
-	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{new(big.Int), nil}, true, &TestTermsManager{true}, &TestExchangeLookup{0}))
+	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{new(big.Int), nil}, true, &TestTermsManager{true}, &TestExchangeLookup{0}, nil, true))
 	data, _ := hex.DecodeString("f9020a94627306090abab3a6e1400e9345bc60c78a8bef57940000000000000000000000000000000000000000941dad4783cf3fe3085c1426157ab175a6119a04ba9405d090b51c40b020eab3bfcb6a2dff130df22e9ca4f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04baa4f47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c9400000000000000000000000000000000000000009490fe2af704b34e0224bf2299c838e04d4dcf1364940000000000000000000000000000000000000000a0000000000000000000000000000000000000000000000002b5e3af16b1880000a00000000000000000000000000000000000000000000000000de0b6b3a7640000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000159938ac4a0000643508ff7019bfb134363a86e98746f6c33262e68daf992b8df064217222bb8421ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03a000000000000000000000000000000000000000000000000000000000000000008080")
 	reader := TestReader{
 		data,
@@ -373,7 +408,7 @@ func TestBadExchange(t *testing.T) {
 }
 func TestUnsignedMaker(t *testing.T) {
 	publisher := TestPublisher{}
-	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{new(big.Int), nil}, true, &TestTermsManager{false}, &TestExchangeLookup{1}))
+	handler := mockPoolDecorator(ingest.Handler(&publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{new(big.Int), nil}, true, &TestTermsManager{false}, &TestExchangeLookup{1}, nil, true))
 	data, _ := hex.DecodeString("f9020a94627306090abab3a6e1400e9345bc60c78a8bef57940000000000000000000000000000000000000000941dad4783cf3fe3085c1426157ab175a6119a04ba9405d090b51c40b020eab3bfcb6a2dff130df22e9ca4f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04baa4f47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c9400000000000000000000000000000000000000009490fe2af704b34e0224bf2299c838e04d4dcf1364940000000000000000000000000000000000000000a0000000000000000000000000000000000000000000000002b5e3af16b1880000a00000000000000000000000000000000000000000000000000de0b6b3a7640000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000159938ac4a0000643508ff7019bfb134363a86e98746f6c33262e68daf992b8df064217222bb8421ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03a000000000000000000000000000000000000000000000000000000000000000008080")
 	reader := TestReader{
 		data,
@@ -395,3 +430,90 @@ func TestUnsignedMaker(t *testing.T) {
 		return
 	}
 }
+
+func signedV3Order(t *testing.T, makerFee int64, feeAssetData types.AssetData) *types.Order {
+	key, err := crypto.HexToECDSA("4f3edf983ac636a65a842ce7c78d9aa706d3b113bce9c46f30d7d21715b23b1d")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	exchangeAddress, _ := common.HexToAddress("0x61935cbdd02287b511119ddb11aeb42f1593b7ef")
+	makerAssetData, _ := common.HexToAssetData("0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba")
+	takerAssetData, _ := common.HexToAssetData("0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c")
+	order := types.BuildOrder(nil, nil, nil, exchangeAddress, nil, makerAssetData, takerAssetData, big.NewInt(100), big.NewInt(100), big.NewInt(makerFee), nil, big.NewInt(time.Now().Unix() + 3600), nil)
+	order.SetV3(1, feeAssetData, types.AssetData{})
+	if err := order.Sign(key, types.SigTypeEIP712); err != nil {
+		t.Fatal(err.Error())
+	}
+	return order
+}
+
+func postV3Order(order *types.Order, path string, feeToken config.FeeToken) (*httptest.ResponseRecorder, *TestPublisher) {
+	publisher := &TestPublisher{}
+	handler := mockPoolDecorator(ingest.Handler(publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{new(big.Int), nil}, true, &TestTermsManager{true}, &TestExchangeLookup{1}, feeToken, true))
+	request, _ := http.NewRequest("POST", path, TestReader{order.Bytes(), nil})
+	request.Header["Content-Type"] = []string{"application/octet-stream"}
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	return recorder, publisher
+}
+
+func TestValidV3(t *testing.T) {
+	order := signedV3Order(t, 0, types.AssetData{})
+	recorder, publisher := postV3Order(order, "/sra/v3/order", nil)
+	if recorder.Code != 202 {
+		t.Errorf("Expected error code 202, got '%v'", recorder.Code)
+		t.Errorf("Body: '%v'", recorder.Body.String())
+	}
+	if len(publisher.messages) != 1 {
+		t.Fatalf("Unexpected message count '%v'", len(publisher.messages))
+	}
+	_, body := channels.Unwrap(publisher.messages[0])
+	published, err := types.OrderFromBytes([]byte(body))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !published.IsV3() || !bytes.Equal(published.Hash(), order.Hash()) {
+		t.Errorf("Unexpected published order: %#x", published.Hash())
+	}
+}
+
+func TestV3OrderOnV2Route(t *testing.T) {
+	order := signedV3Order(t, 0, types.AssetData{})
+	recorder, publisher := postV3Order(order, "/v2/order", nil)
+	if recorder.Code != 400 {
+		t.Errorf("Expected error code 400, got '%v'", recorder.Code)
+	}
+	body := recorder.Body.String()
+	if body != "{\"code\":100,\"reason\":\"Validation Failed\",\"validationErrors\":[{\"field\":\"order\",\"code\":1006,\"reason\":\"Order version does not match the requested API version\"}]}" {
+		t.Errorf("Got unexpected body: '%v'", body)
+	}
+	if len(publisher.messages) != 0 {
+		t.Errorf("Unexpected message count '%v'", len(publisher.messages))
+	}
+}
+
+func TestV3FeeAsset(t *testing.T) {
+	feeAssetData, _ := common.HexToAssetData("0xf47261b0000000000000000000000000e41d2489571d322189246dafa5ebde1f4699f498")
+	order := signedV3Order(t, 1, feeAssetData)
+	recorder, publisher := postV3Order(order, "/sra/v3/order", nil)
+	if recorder.Code != 400 {
+		t.Errorf("Expected error code 400, got '%v'", recorder.Code)
+	}
+	if len(publisher.messages) != 0 {
+		t.Errorf("Unexpected message count '%v'", len(publisher.messages))
+	}
+	recorder, publisher = postV3Order(order, "/sra/v3/order", config.StaticFeeToken(feeAssetData))
+	if recorder.Code != 202 {
+		t.Errorf("Expected error code 202, got '%v'", recorder.Code)
+		t.Errorf("Body: '%v'", recorder.Body.String())
+	}
+	otherAssetData, _ := common.HexToAssetData("0xf47261b0000000000000000000000000c02aaa39b223fe8d0a0e5c4f27ead9083c756cc2")
+	recorder, publisher = postV3Order(order, "/sra/v3/order", config.StaticFeeToken(otherAssetData))
+	if recorder.Code != 400 {
+		t.Errorf("Expected error code 400, got '%v'", recorder.Code)
+	}
+	body := recorder.Body.String()
+	if body != "{\"code\":100,\"reason\":\"Validation Failed\",\"validationErrors\":[{\"field\":\"makerFeeAssetData\",\"code\":1006,\"reason\":\"Fees must be paid in 0xf47261b0000000000000000000000000c02aaa39b223fe8d0a0e5c4f27ead9083c756cc2\"}]}" {
+		t.Errorf("Got unexpected body: '%v'", body)
+	}
+}
@@ -0,0 +1,127 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	accountsModule "github.com/notegio/openrelay/accounts"
+	affiliatesModule "github.com/notegio/openrelay/affiliates"
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/config"
+	poolModule "github.com/notegio/openrelay/pool"
+	"github.com/notegio/openrelay/types"
+	"io"
+	"log"
+	"net/http"
+)
+
+// TypedDataResponse holds an order with the relay's fees applied, along with
+// the EIP-712 message and hash the maker needs to sign for it.
+type TypedDataResponse struct {
+	Order     *types.Order     `json:"order"`
+	TypedData *types.TypedData `json:"typedData"`
+	OrderHash string           `json:"orderHash"`
+}
+
+// TypedDataHandler takes an unsigned order as JSON, applies the fees,
+// fee recipient, sender and taker FeeHandler would return for it, and
+// responds with the resulting order and its EIP-712 typed data. Wallets can
+// sign the typed data with eth_signTypedData_v3 or v4, and the signed order
+// can then be submitted to Handler.
+func TypedDataHandler(accounts accountsModule.AccountService, affiliates affiliatesModule.AffiliateService, defaultFeeRecipient [20]byte, exchangeLookup ExchangeLookup, feeToken config.FeeToken) func(http.ResponseWriter, *http.Request, *poolModule.Pool) {
+	calc := &feeCalculator{accounts, affiliates, defaultFeeRecipient, exchangeLookup, feeToken}
+	return func(w http.ResponseWriter, r *http.Request, pool *poolModule.Pool) {
+		var data [4096]byte
+		jsonLength, err := r.Body.Read(data[:])
+		if err != nil && err != io.EOF {
+			log.Print(err.Error())
+			returnError(w, IngestError{
+				100,
+				"Error reading content",
+				nil,
+			}, 500)
+			return
+		}
+		order := &types.Order{}
+		if err := json.Unmarshal(data[:jsonLength], order); err != nil {
+			log.Printf("%v: '%v'", err.Error(), string(data[:]))
+			returnError(w, IngestError{
+				101,
+				"Malformed JSON",
+				nil,
+			}, 400)
+			return
+		}
+		version := common.RequestVersion(r)
+		if order.IsV3() != (version == types.OrderVersion3) {
+			returnError(w, IngestError{
+				100,
+				"Validation Failed",
+				[]ValidationError{ValidationError{
+					"order",
+					1006,
+					"Order version does not match the requested API version",
+				}},
+			}, 400)
+			return
+		}
+		networkID := <-exchangeLookup.ExchangeIsKnown(order.ExchangeAddress)
+		if networkID == 0 {
+			returnError(w, IngestError{
+				100,
+				"Validation Failed",
+				[]ValidationError{ValidationError{
+					"exchangeContractAddress",
+					1002,
+					"Unknown exchangeContractAddress",
+				}},
+			}, 400)
+			return
+		}
+		if order.IsV3() && uint64(networkID) != order.ChainID {
+			returnError(w, IngestError{
+				100,
+				"Validation Failed",
+				[]ValidationError{ValidationError{
+					"chainId",
+					1002,
+					"exchangeAddress is not deployed on chainId",
+				}},
+			}, 400)
+			return
+		}
+		feeResponse, ingestErr, status := calc.fees(FeeInput(order), version, pool)
+		if ingestErr != nil {
+			returnError(w, *ingestErr, status)
+			return
+		}
+		if err := feeResponse.Apply(order); err != nil {
+			log.Printf("Error applying fees: %v", err.Error())
+			returnError(w, IngestError{
+				100,
+				"Error applying fees",
+				nil,
+			}, 500)
+			return
+		}
+		// Any signature the client sent is for different values
+		order.Signature = types.Signature{}
+		response := &TypedDataResponse{
+			order,
+			order.TypedData(),
+			fmt.Sprintf("%#x", order.Hash()),
+		}
+		responseBytes, err := json.Marshal(response)
+		if err != nil {
+			log.Print(err.Error())
+			returnError(w, IngestError{
+				100,
+				"Error encoding response",
+				nil,
+			}, 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write(responseBytes)
+	}
+}
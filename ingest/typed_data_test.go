@@ -0,0 +1,122 @@
+package ingest_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/ingest"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func unsignedOrderJSON(t *testing.T, chainID uint64) []byte {
+	exchangeAddress, _ := common.HexToAddress("0x90fe2af704b34e0224bf2299c838e04d4dcf1364")
+	maker, _ := common.HexToAddress("0x90f8bf6a479f320ead074411a4b0e7944ea8c9c1")
+	makerAssetData, _ := common.HexToAssetData("0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba")
+	takerAssetData, _ := common.HexToAssetData("0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c")
+	order := types.BuildOrder(maker, nil, nil, exchangeAddress, nil, makerAssetData, takerAssetData, big.NewInt(100), big.NewInt(100), nil, nil, big.NewInt(time.Now().Unix()+3600), big.NewInt(1))
+	if chainID != 0 {
+		order.SetV3(chainID, types.AssetData{}, types.AssetData{})
+	}
+	data, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return data
+}
+
+func postTypedData(data []byte, path string, fee *big.Int) *httptest.ResponseRecorder {
+	feeRecipient := [20]byte{}
+	feeRecipient[19] = 1
+	handler := mockPoolDecoratorFee(fee, ingest.TypedDataHandler(&TestAccountService{false, new(big.Int)}, &TestAffiliateService{fee, nil}, feeRecipient, &TestExchangeLookup{1}, nil))
+	request, _ := http.NewRequest("POST", path, TestReader{data, nil})
+	request.Header["Content-Type"] = []string{"application/json"}
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	return recorder
+}
+
+func TestTypedData(t *testing.T) {
+	fee := big.NewInt(1000)
+	recorder := postTypedData(unsignedOrderJSON(t, 0), "/v2/order_typed_data", fee)
+	if recorder.Code != 200 {
+		t.Fatalf("Expected error code 200, got '%v': %v", recorder.Code, recorder.Body.String())
+	}
+	response := &ingest.TypedDataResponse{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), response); err != nil {
+		t.Fatal(err.Error())
+	}
+	order := response.Order
+	if order.MakerFee.Big().Cmp(fee) != 0 || order.FeeRecipient[19] != 1 {
+		t.Errorf("Fees not applied: %v %v", order.MakerFee, order.FeeRecipient)
+	}
+	hash, err := response.TypedData.Hash()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if response.OrderHash != fmt.Sprintf("%#x", hash) || !bytes.Equal(hash, order.Hash()) {
+		t.Errorf("Typed data hash %#x does not match order hash %v", hash, response.OrderHash)
+	}
+
+	// Sign the typed data hash as a wallet would, and submit the order
+	key, err := crypto.HexToECDSA("4f3edf983ac636a65a842ce7c78d9aa706d3b113bce9c46f30d7d21715b23b1d")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	order.Signature, err = types.SignHash(key, hash, types.SigTypeEIP712)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	publisher := &TestPublisher{}
+	handler := mockPoolDecoratorFee(fee, ingest.Handler(publisher, &TestAccountService{false, new(big.Int)}, &TestAffiliateService{fee, nil}, true, &TestTermsManager{true}, &TestExchangeLookup{1}, nil, true))
+	request, _ := http.NewRequest("POST", "/v2/order", TestReader{order.Bytes(), nil})
+	request.Header["Content-Type"] = []string{"application/octet-stream"}
+	recorder = httptest.NewRecorder()
+	handler(recorder, request)
+	if recorder.Code != 202 {
+		t.Errorf("Expected error code 202, got '%v'", recorder.Code)
+		t.Errorf("Body: '%v'", recorder.Body.String())
+	}
+}
+
+func TestTypedDataV3(t *testing.T) {
+	recorder := postTypedData(unsignedOrderJSON(t, 1), "/sra/v3/order_typed_data", new(big.Int))
+	if recorder.Code != 200 {
+		t.Fatalf("Expected error code 200, got '%v': %v", recorder.Code, recorder.Body.String())
+	}
+	response := &ingest.TypedDataResponse{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), response); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !response.Order.IsV3() || response.TypedData.Domain["version"] != "3.0.0" {
+		t.Errorf("Unexpected response: %v", recorder.Body.String())
+	}
+	hash, err := response.TypedData.Hash()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(hash, response.Order.Hash()) {
+		t.Errorf("Typed data hash %#x does not match order hash %#x", hash, response.Order.Hash())
+	}
+}
+
+func TestTypedDataWrongChain(t *testing.T) {
+	recorder := postTypedData(unsignedOrderJSON(t, 3), "/sra/v3/order_typed_data", new(big.Int))
+	if recorder.Code != 400 {
+		t.Errorf("Expected error code 400, got '%v'", recorder.Code)
+	}
+	body := recorder.Body.String()
+	if body != "{\"code\":100,\"reason\":\"Validation Failed\",\"validationErrors\":[{\"field\":\"chainId\",\"code\":1002,\"reason\":\"exchangeAddress is not deployed on chainId\"}]}" {
+		t.Errorf("Got unexpected body: '%v'", body)
+	}
+	recorder = postTypedData(unsignedOrderJSON(t, 0), "/sra/v3/order_typed_data", new(big.Int))
+	if recorder.Code != 400 {
+		t.Errorf("Expected error code 400, got '%v'", recorder.Code)
+	}
+}
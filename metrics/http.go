@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	httpRequests = NewCounter("openrelay_http_requests_total", "HTTP requests served, by route and status code", "route", "status")
+	httpDuration = NewHistogram("openrelay_http_request_duration_seconds", "Time taken to serve HTTP requests, by route and status code", DefBuckets, "route", "status")
+)
+
+// Handler serves the current metrics in the Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		Write(w)
+	})
+}
+
+// Serve exposes metrics at /metrics on the address given by the
+// METRICS_ADDRESS environment variable, or :9100 if it is unset. Setting
+// METRICS_ADDRESS to "none" disables the endpoint. Serve returns immediately;
+// if the endpoint can't be served the error is logged, but the service keeps
+// running. The server is shut down when `ctx` is done.
+func Serve(ctx context.Context) {
+	address := os.Getenv("METRICS_ADDRESS")
+	if address == "" {
+		address = ":9100"
+	}
+	if address == "none" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	server := &http.Server{Addr: address, Handler: mux}
+	go func() {
+		log.Printf("Metrics serving on %v", address)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Error serving metrics: %v", err.Error())
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error shutting down metrics: %v", err.Error())
+		}
+	}()
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	if recorder.status == 0 {
+		recorder.status = status
+	}
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *statusRecorder) Write(data []byte) (int, error) {
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	return recorder.ResponseWriter.Write(data)
+}
+
+// InstrumentHandler wraps an HTTP handler function, counting the requests it
+// serves and how long they take under the label `route`.
+func InstrumentHandler(route string, fn func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{w, 0}
+		fn(recorder, r)
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		httpRequests.Inc(route, strconv.Itoa(status))
+		httpDuration.Since(start, route, strconv.Itoa(status))
+	}
+}
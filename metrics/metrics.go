@@ -0,0 +1,268 @@
+// Package metrics collects counters, gauges and histograms from OpenRelay
+// services and exposes them over HTTP in the Prometheus text format, so that
+// we can monitor and alert on the pipeline.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefBuckets are the default histogram buckets, in seconds, suitable for
+// timing requests and message processing.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+type series struct {
+	labelValues []string
+	value       float64
+	buckets     []uint64
+	count       uint64
+}
+
+// family is a named metric and all of its labelled series
+type family struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	buckets    []float64
+	mu         sync.Mutex
+	series     map[string]*series
+}
+
+// get returns the series for a set of label values, creating it if necessary.
+// The caller must hold family.mu.
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metric %v expects %v label values, got %v", f.name, len(f.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string{}, labelValues...)}
+		if f.kind == "histogram" {
+			s.buckets = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+type registry struct {
+	mu         sync.Mutex
+	families   map[string]*family
+	collectors map[string]func()
+}
+
+var defaultRegistry = &registry{
+	families:   make(map[string]*family),
+	collectors: make(map[string]func()),
+}
+
+func register(name, help, kind string, buckets []float64, labelNames []string) *family {
+	defaultRegistry.mu.Lock()
+	defer defaultRegistry.mu.Unlock()
+	if _, ok := defaultRegistry.families[name]; ok {
+		panic(fmt.Sprintf("metric %v registered twice", name))
+	}
+	f := &family{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+	defaultRegistry.families[name] = f
+	return f
+}
+
+// Counter is a value that only ever increases, such as a number of messages
+// processed.
+type Counter struct {
+	f *family
+}
+
+// NewCounter registers a counter. Label values must be provided in the same
+// order as labelNames each time the counter is incremented.
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{register(name, help, "counter", nil, labelNames)}
+}
+
+// Inc increments the counter for the given label values by 1
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the given label values by `value`, which
+// must not be negative.
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.get(labelValues).value += value
+}
+
+// Gauge is a value that can go up and down, such as a queue length.
+type Gauge struct {
+	f *family
+}
+
+// NewGauge registers a gauge
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{register(name, help, "gauge", nil, labelNames)}
+}
+
+// Set sets the gauge for the given label values
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	g.f.get(labelValues).value = value
+}
+
+// Add adds `value`, which may be negative, to the gauge for the given label
+// values
+func (g *Gauge) Add(value float64, labelValues ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	g.f.get(labelValues).value += value
+}
+
+// Histogram counts observations, such as request latencies, in configurable
+// buckets.
+type Histogram struct {
+	f *family
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds,
+// which must be sorted in increasing order.
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	return &Histogram{register(name, help, "histogram", buckets, labelNames)}
+}
+
+// Observe records a single observation for the given label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	s := h.f.get(labelValues)
+	for i, bound := range h.f.buckets {
+		if value <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.value += value
+	s.count++
+}
+
+// Since observes the number of seconds elapsed since `start`
+func (h *Histogram) Since(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// AddCollector registers a function to be called before metrics are written,
+// for gauges that are cheaper to measure on demand than to keep up to date,
+// such as queue lengths. Registering another collector with the same name
+// replaces the first.
+func AddCollector(name string, collect func()) {
+	defaultRegistry.mu.Lock()
+	defer defaultRegistry.mu.Unlock()
+	defaultRegistry.collectors[name] = collect
+}
+
+// RemoveCollector unregisters a collector added with AddCollector
+func RemoveCollector(name string) {
+	defaultRegistry.mu.Lock()
+	defer defaultRegistry.mu.Unlock()
+	delete(defaultRegistry.collectors, name)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer("\\", `\\`, "\"", `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	pairs := []string{}
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%v=\"%v\"", name, labelEscaper.Replace(values[i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%v=\"%v\"", extraName, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (f *family) write(w io.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.series) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %v %v\n", f.name, strings.Replace(f.help, "\n", " ", -1))
+	fmt.Fprintf(w, "# TYPE %v %v\n", f.name, f.kind)
+	keys := []string{}
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != "histogram" {
+			fmt.Fprintf(w, "%v%v %v\n", f.name, formatLabels(f.labelNames, s.labelValues, "", ""), formatValue(s.value))
+			continue
+		}
+		for i, bound := range f.buckets {
+			fmt.Fprintf(w, "%v_bucket%v %v\n", f.name, formatLabels(f.labelNames, s.labelValues, "le", formatValue(bound)), s.buckets[i])
+		}
+		fmt.Fprintf(w, "%v_bucket%v %v\n", f.name, formatLabels(f.labelNames, s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%v_sum%v %v\n", f.name, formatLabels(f.labelNames, s.labelValues, "", ""), formatValue(s.value))
+		fmt.Fprintf(w, "%v_count%v %v\n", f.name, formatLabels(f.labelNames, s.labelValues, "", ""), s.count)
+	}
+}
+
+// Write runs any registered collectors, then writes every metric that has
+// been recorded to `w` in the Prometheus text exposition format.
+func Write(w io.Writer) {
+	defaultRegistry.mu.Lock()
+	collectors := []func(){}
+	for _, collect := range defaultRegistry.collectors {
+		collectors = append(collectors, collect)
+	}
+	defaultRegistry.mu.Unlock()
+	for _, collect := range collectors {
+		collect()
+	}
+	defaultRegistry.mu.Lock()
+	names := []string{}
+	for name := range defaultRegistry.families {
+		names = append(names, name)
+	}
+	defaultRegistry.mu.Unlock()
+	sort.Strings(names)
+	for _, name := range names {
+		defaultRegistry.mu.Lock()
+		f := defaultRegistry.families[name]
+		defaultRegistry.mu.Unlock()
+		f.write(w)
+	}
+}
@@ -0,0 +1,119 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/notegio/openrelay/metrics"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testCounter   = metrics.NewCounter("test_counter_total", "A test counter", "label")
+	testGauge     = metrics.NewGauge("test_gauge", "A test gauge")
+	testHistogram = metrics.NewHistogram("test_histogram_seconds", "A test histogram", []float64{1, 5}, "label")
+)
+
+func scrape() string {
+	buffer := &bytes.Buffer{}
+	metrics.Write(buffer)
+	return buffer.String()
+}
+
+func expectLines(t *testing.T, output string, lines ...string) {
+	for _, line := range lines {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected line '%v' in output:\n%v", line, output)
+		}
+	}
+}
+
+func TestCounter(t *testing.T) {
+	testCounter.Inc("a")
+	testCounter.Add(2, "a")
+	testCounter.Inc("quote\"d")
+	expectLines(t, scrape(),
+		"# TYPE test_counter_total counter",
+		"test_counter_total{label=\"a\"} 3",
+		"test_counter_total{label=\"quote\\\"d\"} 1",
+	)
+}
+
+func TestGaugeCollector(t *testing.T) {
+	metrics.AddCollector("test", func() { testGauge.Set(42) })
+	defer metrics.RemoveCollector("test")
+	expectLines(t, scrape(),
+		"# TYPE test_gauge gauge",
+		"test_gauge 42",
+	)
+}
+
+func TestHistogram(t *testing.T) {
+	testHistogram.Observe(0.5, "a")
+	testHistogram.Observe(2, "a")
+	testHistogram.Observe(10, "a")
+	expectLines(t, scrape(),
+		"# TYPE test_histogram_seconds histogram",
+		"test_histogram_seconds_bucket{label=\"a\",le=\"1\"} 1",
+		"test_histogram_seconds_bucket{label=\"a\",le=\"5\"} 2",
+		"test_histogram_seconds_bucket{label=\"a\",le=\"+Inf\"} 3",
+		"test_histogram_seconds_sum{label=\"a\"} 12.5",
+		"test_histogram_seconds_count{label=\"a\"} 3",
+	)
+}
+
+func TestInstrumentHandler(t *testing.T) {
+	handler := metrics.InstrumentHandler("test_route", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+	})
+	request, _ := http.NewRequest("GET", "/", nil)
+	handler(httptest.NewRecorder(), request)
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, request)
+	expectLines(t, recorder.Body.String(),
+		"openrelay_http_requests_total{route=\"test_route\",status=\"404\"} 1",
+		"openrelay_http_request_duration_seconds_count{route=\"test_route\",status=\"404\"} 1",
+	)
+}
+
+func TestServeShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	os.Setenv("METRICS_ADDRESS", address)
+	defer os.Unsetenv("METRICS_ADDRESS")
+	ctx, cancel := context.WithCancel(context.Background())
+	metrics.Serve(ctx)
+	url := "http://" + address + "/metrics"
+	var response *http.Response
+	for i := 0; i < 50; i++ {
+		if response, err = http.Get(url); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Metrics were not served: %v", err.Error())
+	}
+	response.Body.Close()
+	if response.StatusCode != 200 {
+		t.Errorf("Unexpected status %v", response.StatusCode)
+	}
+	cancel()
+	for i := 0; i < 50; i++ {
+		if response, err = http.Get(url); err != nil {
+			return
+		}
+		response.Body.Close()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Expected metrics to stop being served after the context was cancelled")
+}
@@ -63,6 +63,7 @@ func (consumer *allowanceBlockConsumer) Consume(delivery channels.Delivery) {
 				SpenderAddress: hexutil.Encode(approvalLog.Topics[1][12:]),
 				ZrxToken: consumer.feeTokenAddress,
 				Balance: balance.String(),
+				BlockNumber: approvalLog.BlockNumber,
 			}
 			msg, err := json.Marshal(sr)
 			if err != nil {
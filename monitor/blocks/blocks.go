@@ -12,9 +12,16 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/metrics"
 	"log"
 )
 
+var (
+	headBlock  = metrics.NewGauge("openrelay_block_monitor_head", "Number of the most recent block published by the block monitor")
+	headLag    = metrics.NewGauge("openrelay_block_monitor_lag_seconds", "Seconds between the most recent block's timestamp and when it was published")
+	reorgCount = metrics.NewCounter("openrelay_block_monitor_reorgs_total", "Chain reorganizations seen by the block monitor")
+)
+
 // MiniBlock is a subset of the Ethereum block header that has the subset of
 // fields we need to monitor for events. The hash is tracked to identify
 // specific blocks in the event of a reorg. The block number is tracked to make
@@ -134,6 +141,9 @@ func (bm *BlockMonitor) Process() error {
 				return err
 			}
 		}
+		if counter > 0 {
+			reorgCount.Inc()
+		}
 		if bm.brb.HashIndex(header.Hash()) != -1 {
 			log.Fatalf("No parents found, but current block already exists. It's likely that block.Hash() is not being computed properly somewhere.")
 		}
@@ -149,6 +159,9 @@ func (bm *BlockMonitor) Process() error {
 		if err := bm.publish(bm.brb.Get(0)); err != nil {
 			return err
 		}
+		if header.Time != nil {
+			headLag.Set(time.Since(time.Unix(header.Time.Int64(), 0)).Seconds())
+		}
 	}
 }
 
@@ -161,6 +174,7 @@ func (bm *BlockMonitor) publish(block *MiniBlock) error {
 	}
 	result := bm.publisher.Publish(string(data))
 	if result {
+		headBlock.Set(float64(block.Number.Int64()))
 		return bm.blockRecorder.Record(block.Number)
 	} else {
 		return errors.New("Failed to publish block")
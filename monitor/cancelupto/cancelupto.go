@@ -51,7 +51,7 @@ func (consumer *cancelBlockConsumer) Consume(delivery channels.Delivery) {
 				log.Printf("Unexpected log data. Skipping.")
 				continue
 			}
-			cancellation := &db.Cancellation{&types.Address{}, &types.Address{}, &types.Uint256{}}
+			cancellation := &db.Cancellation{&types.Address{}, &types.Address{}, &types.Uint256{}, cancelLog.BlockNumber}
 			copy(cancellation.Maker[:], cancelLog.Topics[1][12:])
 			copy(cancellation.Sender[:], cancelLog.Topics[2][12:])
 			copy(cancellation.Epoch[:], cancelLog.Data[:])
@@ -0,0 +1,107 @@
+package erc1155
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	coreTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/monitor/blocks"
+	"github.com/notegio/openrelay/types"
+	"log"
+	"math/big"
+	"strings"
+)
+
+type approvalBlockConsumer struct {
+	tokenProxyAddress *types.Address
+	approveAllTopic   *big.Int
+	feeTokenAddress   string // Needed for the SpendRecord,
+	logFilter         ethereum.LogFilterer
+	publisher         channels.Publisher
+}
+
+func (consumer *approvalBlockConsumer) Consume(delivery channels.Delivery) {
+	block := &blocks.MiniBlock{}
+	err := json.Unmarshal([]byte(delivery.Payload()), block)
+	if err != nil {
+		log.Printf("Error parsing payload: %v\n", err.Error())
+	}
+	proxyTopic := common.BytesToHash(consumer.tokenProxyAddress[:])
+	if coreTypes.BloomLookup(block.Bloom, consumer.approveAllTopic) && coreTypes.BloomLookup(block.Bloom, proxyTopic) {
+		log.Printf("Block %#x bloom filter indicates ERC1155 approval event for %#x", block.Hash, consumer.tokenProxyAddress[:])
+		query := ethereum.FilterQuery{
+			FromBlock: block.Number,
+			ToBlock:   block.Number,
+			Addresses: nil,
+			Topics: [][]common.Hash{
+				[]common.Hash{common.BigToHash(consumer.approveAllTopic)},
+				nil,
+				[]common.Hash{proxyTopic},
+			},
+		}
+		logs, err := consumer.logFilter.FilterLogs(context.Background(), query)
+		if err != nil {
+			delivery.Return()
+			log.Fatalf("Failed to filter logs on block %v - aborting: %v", block.Number, err.Error())
+		}
+		log.Printf("Found %v ERC1155 approval logs", len(logs))
+		for _, approvalLog := range logs {
+			if topicCount := len(approvalLog.Topics); topicCount != 3 {
+				log.Printf("Expected 3 topics, got %v - %v", topicCount, approvalLog.Address.String())
+				continue
+			}
+			if new(big.Int).SetBytes(approvalLog.Data[:]).Cmp(big.NewInt(0)) != 0 {
+				// They've just approved the tokenProxy as an operator. This can't
+				// make an order invalid, so we don't need to send anything.
+				continue
+			}
+			// ERC1155 tokens only support approving operators for all token IDs,
+			// so revoking the tokenProxy makes every order for this token
+			// unfillable.
+			sr := &db.SpendRecord{
+				AssetData:      "",
+				TokenAddress:   strings.ToLower(approvalLog.Address.String()),
+				SpenderAddress: hexutil.Encode(approvalLog.Topics[1][12:]),
+				ZrxToken:       consumer.feeTokenAddress,
+				Balance:        "0",
+				BlockNumber:    approvalLog.BlockNumber,
+			}
+			msg, err := json.Marshal(sr)
+			if err != nil {
+				delivery.Return()
+				log.Fatalf("Failed to encode SpendRecord on block %v", block.Number)
+			}
+			consumer.publisher.Publish(string(msg))
+		}
+	} else {
+		log.Printf("Block %v shows no ERC1155 approval events", block.Hash)
+	}
+	delivery.Ack()
+}
+
+// NewApprovalBlockConsumer returns a consumer that watches blocks for ERC1155
+// ApprovalForAll events revoking the token proxy, and publishes a SpendRecord
+// with a zero balance for the owner's tokens.
+func NewApprovalBlockConsumer(tp *types.Address, feeToken string, lf ethereum.LogFilterer, publisher channels.Publisher) channels.Consumer {
+	approveAllTopic := &big.Int{}
+	approveAllTopic.SetString("17307eab39ab6107e8899845ad3d59bd9653f200f220920489ca2b5937696c31", 16)
+	return &approvalBlockConsumer{tp, approveAllTopic, feeToken, lf, publisher}
+}
+
+func NewRPCApprovalBlockConsumer(rpcURL string, exchangeAddress string, publisher channels.Publisher) (channels.Consumer, error) {
+	client, err := ethclient.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	feeTokenAddress, tokenProxyAddress, err := exchangeInfo(client, exchangeAddress)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("TP: %#x - %v", tokenProxyAddress[:], exchangeAddress)
+	return NewApprovalBlockConsumer(tokenProxyAddress, feeTokenAddress.String(), client, publisher), nil
+}
@@ -0,0 +1,91 @@
+package erc1155_test
+
+import (
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/notegio/openrelay/channels"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/monitor/blocks"
+	"github.com/notegio/openrelay/monitor/blocks/mock"
+	"github.com/notegio/openrelay/monitor/erc1155"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func approveAllLog(operator common.Address, approved int64) *types.Log {
+	topics := []common.Hash{
+		topic("0x17307eab39ab6107e8899845ad3d59bd9653f200f220920489ca2b5937696c31"),
+		common.BytesToHash(senderAddress[:]),
+		common.BytesToHash(operator[:]),
+	}
+	data := common.BigToHash(big.NewInt(approved))
+	return &types.Log{Address: tokenAddress, Topics: topics, Data: data[:]}
+}
+
+func runApprovalBlock(t *testing.T, testLog *types.Log) *db.SpendRecord {
+	mb := &blocks.MiniBlock{
+		Hash:   common.Hash{},
+		Number: big.NewInt(0),
+		Bloom:  types.BytesToBloom(types.LogsBloom([]*types.Log{testLog}).Bytes()),
+	}
+	srcPublisher, consumerChannel := channels.MockChannel()
+	destPublisher, destConsumerChannel := channels.MockChannel()
+	data, err := json.Marshal(mb)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tc := newTestConsumer()
+	destConsumerChannel.AddConsumer(tc)
+	destConsumerChannel.StartConsuming()
+	defer destConsumerChannel.StopConsuming()
+	consumerChannel.AddConsumer(erc1155.NewApprovalBlockConsumer(
+		orCommon.BytesToOrAddress(proxyAddress),
+		"0x4444444444444444444444444444444444444444",
+		mock.NewMockLogFilterer([]types.Log{*testLog}),
+		destPublisher,
+	))
+	consumerChannel.StartConsuming()
+	defer consumerChannel.StopConsuming()
+	srcPublisher.Publish(string(data))
+	select {
+	case payload := <-tc.channel:
+		sr := &db.SpendRecord{}
+		if err := json.Unmarshal([]byte(payload), sr); err != nil {
+			t.Fatal(err.Error())
+		}
+		return sr
+	case <-time.After(100 * time.Millisecond):
+		return nil
+	}
+}
+
+func TestApprovalRevoked(t *testing.T) {
+	sr := runApprovalBlock(t, approveAllLog(proxyAddress, 0))
+	if sr == nil {
+		t.Fatalf("Expected a spend record")
+	}
+	if sr.TokenAddress != "0x1d7022f5b17d2f8b695918fb48fa1089c9f85401" {
+		t.Errorf("Unexpected token address, got '%v'", sr.TokenAddress)
+	}
+	if sr.SpenderAddress != "0x5409ed021d9299bf6814279a6a1411a7e866a631" {
+		t.Errorf("Unexpected spender address, got '%v'", sr.SpenderAddress)
+	}
+	if sr.AssetData != "" || sr.Balance != "0" {
+		t.Errorf("Expected an empty asset data and balance of 0, got '%v' and '%v'", sr.AssetData, sr.Balance)
+	}
+}
+
+func TestApprovalGranted(t *testing.T) {
+	if sr := runApprovalBlock(t, approveAllLog(proxyAddress, 1)); sr != nil {
+		t.Errorf("Expected no spend record when the proxy is approved")
+	}
+}
+
+func TestApprovalOtherOperator(t *testing.T) {
+	if sr := runApprovalBlock(t, approveAllLog(senderAddress, 0)); sr != nil {
+		t.Errorf("Expected no spend record for other operators")
+	}
+}
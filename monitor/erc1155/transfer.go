@@ -0,0 +1,236 @@
+package erc1155
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	coreTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/notegio/openrelay/channels"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/exchangecontract"
+	"github.com/notegio/openrelay/funds/balance"
+	"github.com/notegio/openrelay/monitor/blocks"
+	"github.com/notegio/openrelay/types"
+	"log"
+	"math/big"
+	"strings"
+)
+
+type transferBlockConsumer struct {
+	tokenProxyAddress *types.Address
+	singleTopic       *big.Int
+	batchTopic        *big.Int
+	feeTokenAddress   string // Needed for the SpendRecord,
+	logFilter         ethereum.LogFilterer
+	publisher         channels.Publisher
+	balanceChecker    balance.BalanceChecker
+}
+
+// abiUint reads the 32 byte word at `offset` of `data` as an integer that
+// must fit within `data`.
+func abiUint(data []byte, offset int) (int, error) {
+	if offset < 0 || offset+32 > len(data) {
+		return 0, errors.New("Log data is truncated")
+	}
+	value := new(big.Int).SetBytes(data[offset : offset+32])
+	if value.BitLen() > 62 || value.Int64() > int64(len(data)) {
+		return 0, errors.New("Log data has an invalid offset")
+	}
+	return int(value.Int64()), nil
+}
+
+// transferredIDs returns the token IDs moved by a TransferSingle or
+// TransferBatch log. TransferSingle data is (uint256 id, uint256 value), and
+// TransferBatch data is (uint256[] ids, uint256[] values).
+func (consumer *transferBlockConsumer) transferredIDs(transferLog coreTypes.Log) ([]*big.Int, error) {
+	if new(big.Int).SetBytes(transferLog.Topics[0][:]).Cmp(consumer.singleTopic) == 0 {
+		if len(transferLog.Data) < 64 {
+			return nil, errors.New("Log data is truncated")
+		}
+		return []*big.Int{new(big.Int).SetBytes(transferLog.Data[:32])}, nil
+	}
+	idsOffset, err := abiUint(transferLog.Data, 0)
+	if err != nil {
+		return nil, err
+	}
+	count, err := abiUint(transferLog.Data, idsOffset)
+	if err != nil {
+		return nil, err
+	}
+	if idsOffset+32*(count+1) > len(transferLog.Data) {
+		return nil, errors.New("Log data is truncated")
+	}
+	ids := make([]*big.Int, count)
+	for i := range ids {
+		start := idsOffset + 32*(i+1)
+		ids[i] = new(big.Int).SetBytes(transferLog.Data[start : start+32])
+	}
+	return ids, nil
+}
+
+// spendableBalance returns the lesser of the user's balance of `assetData`
+// and the amount the token proxy may transfer on their behalf.
+func (consumer *transferBlockConsumer) spendableBalance(assetData types.AssetData, senderAddress *types.Address) (*big.Int, error) {
+	allowance, err := consumer.balanceChecker.GetAllowance(assetData, senderAddress, consumer.tokenProxyAddress)
+	if err != nil {
+		return nil, err
+	}
+	if allowance.Cmp(big.NewInt(0)) == 0 {
+		// If the allowance is 0, then the balance we want to report is 0, and
+		// we don't need to get the actual balance.
+		return allowance, nil
+	}
+	balance, err := consumer.balanceChecker.GetBalance(assetData, senderAddress)
+	if err != nil {
+		return nil, err
+	}
+	if allowance.Cmp(balance) < 0 {
+		return allowance, nil
+	}
+	return balance, nil
+}
+
+func (consumer *transferBlockConsumer) Consume(delivery channels.Delivery) {
+	block := &blocks.MiniBlock{}
+	err := json.Unmarshal([]byte(delivery.Payload()), block)
+	if err != nil {
+		log.Printf("Error parsing payload: %v\n", err.Error())
+	}
+	if coreTypes.BloomLookup(block.Bloom, consumer.singleTopic) || coreTypes.BloomLookup(block.Bloom, consumer.batchTopic) {
+		log.Printf("Block %#x bloom filter indicates ERC1155 transfer event", block.Hash)
+		query := ethereum.FilterQuery{
+			FromBlock: block.Number,
+			ToBlock:   block.Number,
+			Addresses: nil,
+			Topics: [][]common.Hash{
+				[]common.Hash{common.BigToHash(consumer.singleTopic), common.BigToHash(consumer.batchTopic)},
+				nil,
+				nil,
+				nil,
+			},
+		}
+		logs, err := consumer.logFilter.FilterLogs(context.Background(), query)
+		if err != nil {
+			delivery.Return()
+			log.Fatalf("Failed to filter logs on block %v - aborting: %v", block.Number, err.Error())
+		}
+		log.Printf("Found %v ERC1155 transfer logs", len(logs))
+		tradedTokens := make(map[string]struct{})
+		for _, transferLog := range logs {
+			if topicCount := len(transferLog.Topics); topicCount != 4 {
+				log.Printf("Expected 4 topics, got %v - %v", topicCount, transferLog.Address.String())
+				continue
+			}
+			senderAddress := &types.Address{}
+			tokenAddress := &types.Address{}
+			copy(senderAddress[:], transferLog.Topics[2][12:])
+			copy(tokenAddress[:], transferLog.Address[:])
+			if *senderAddress == (types.Address{}) {
+				// Tokens were minted, which can't make an order unfillable
+				continue
+			}
+			tokenIDs, err := consumer.transferredIDs(transferLog)
+			if err != nil {
+				log.Printf("Unexpected log data from %v: %v. Skipping.", transferLog.Address.String(), err.Error())
+				continue
+			}
+			for _, tokenID := range tokenIDs {
+				pairKey := fmt.Sprintf("%#x:%#x:%v", senderAddress, tokenAddress, tokenID)
+				if _, ok := tradedTokens[pairKey]; ok {
+					// If the same account sent the same token multiple times in a single
+					// block, we already checked their balance as of the end of the block,
+					// so we don't need to check it again.
+					continue
+				}
+				tradedTokens[pairKey] = struct{}{}
+				tokenAssetData := orCommon.ToERC1155AssetData(tokenAddress, []*big.Int{tokenID}, []*big.Int{big.NewInt(1)}, []byte{})
+				balance, err := consumer.spendableBalance(tokenAssetData, senderAddress)
+				if err != nil {
+					if strings.Contains(err.Error(), "abi") || err.Error() == "no contract code at given address" || err.Error() == "VM Exception while processing transaction: revert" {
+						log.Printf("balance checker gave error: %v -- using 0 balance", err.Error())
+						balance = big.NewInt(0)
+					} else {
+						delivery.Return()
+						log.Fatalf("Failed to get balance for '%v' - '%v': %v", tokenAddress, senderAddress, err.Error())
+					}
+				}
+				sr := &db.SpendRecord{
+					TokenAddress:   strings.ToLower(transferLog.Address.String()),
+					AssetData:      hexutil.Encode(tokenAssetData[:]),
+					SpenderAddress: hexutil.Encode(senderAddress[:]),
+					ZrxToken:       consumer.feeTokenAddress,
+					Balance:        balance.String(),
+					BlockNumber:    transferLog.BlockNumber,
+				}
+				msg, err := json.Marshal(sr)
+				if err != nil {
+					delivery.Return()
+					log.Fatalf("Failed to encode SpendRecord on block %v", block.Number)
+				}
+				consumer.publisher.Publish(string(msg))
+			}
+		}
+	} else {
+		log.Printf("Block %v shows no ERC1155 transfer events", block.Hash)
+	}
+	delivery.Ack()
+}
+
+// NewTransferBlockConsumer returns a consumer that watches blocks for ERC1155
+// TransferSingle and TransferBatch events, and publishes a SpendRecord with
+// the sender's remaining balance of each token ID they transferred.
+func NewTransferBlockConsumer(tp *types.Address, feeToken string, lf ethereum.LogFilterer, publisher channels.Publisher, bc balance.BalanceChecker) channels.Consumer {
+	singleTopic := &big.Int{}
+	batchTopic := &big.Int{}
+	singleTopic.SetString("c3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62", 16)
+	batchTopic.SetString("4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb", 16)
+	return &transferBlockConsumer{tp, singleTopic, batchTopic, feeToken, lf, publisher, bc}
+}
+
+// exchangeInfo looks up the fee token address and ERC1155 proxy address of
+// the exchange at `exchangeAddress`.
+func exchangeInfo(client *ethclient.Client, exchangeAddress string) (*types.Address, *types.Address, error) {
+	exchange, err := exchangecontract.NewExchange(common.HexToAddress(exchangeAddress), client)
+	if err != nil {
+		log.Printf("Error intializing exchange contract '%v': '%v'", exchangeAddress, err.Error())
+		return nil, nil, err
+	}
+	feeTokenAssetData, err := exchange.ZRX_ASSET_DATA(nil)
+	if err != nil {
+		log.Printf("Error getting fee token address for exchange %v", exchangeAddress)
+		return nil, nil, err
+	}
+	feeTokenAsset := make(types.AssetData, len(feeTokenAssetData))
+	copy(feeTokenAsset[:], feeTokenAssetData[:])
+	tokenProxyAddress, err := exchange.GetAssetProxy(nil, types.ERC1155ProxyID)
+	if err != nil {
+		log.Printf("error getting tokenProxyAddress")
+		return nil, nil, err
+	}
+	tokenProxyAddressOr := &types.Address{}
+	copy(tokenProxyAddressOr[:], tokenProxyAddress[:])
+	return feeTokenAsset.Address(), tokenProxyAddressOr, nil
+}
+
+func NewRPCTransferBlockConsumer(rpcURL string, exchangeAddress string, publisher channels.Publisher) (channels.Consumer, error) {
+	client, err := ethclient.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	feeTokenAddress, tokenProxyAddress, err := exchangeInfo(client, exchangeAddress)
+	if err != nil {
+		return nil, err
+	}
+	balanceChecker, err := balance.NewRpcRoutingBalanceChecker(rpcURL)
+	if err != nil {
+		log.Printf("Error getting balance checker")
+		return nil, err
+	}
+	return NewTransferBlockConsumer(tokenProxyAddress, feeTokenAddress.String(), client, publisher, balanceChecker), nil
+}
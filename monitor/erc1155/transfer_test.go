@@ -0,0 +1,161 @@
+package erc1155_test
+
+import (
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/notegio/openrelay/channels"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/funds/balance"
+	"github.com/notegio/openrelay/monitor/blocks"
+	"github.com/notegio/openrelay/monitor/blocks/mock"
+	"github.com/notegio/openrelay/monitor/erc1155"
+	orTypes "github.com/notegio/openrelay/types"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type testConsumer struct {
+	channel chan string
+}
+
+func (consumer *testConsumer) Consume(msg channels.Delivery) {
+	consumer.channel <- msg.Payload()
+}
+
+func newTestConsumer() *testConsumer {
+	return &testConsumer{make(chan string, 5)}
+}
+
+var (
+	tokenAddress  = common.HexToAddress("0x1d7022f5b17d2f8b695918fb48fa1089c9f85401")
+	senderAddress = common.HexToAddress("0x5409ed021d9299bf6814279a6a1411a7e866a631")
+	proxyAddress  = common.HexToAddress("0x3333333333333333333333333333333333333333")
+)
+
+func topic(hexTopic string) common.Hash {
+	return common.HexToHash(hexTopic)
+}
+
+func transferLog(batch bool, from common.Address, ids []*big.Int) *types.Log {
+	topics := []common.Hash{
+		topic("0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62"),
+		common.BytesToHash(senderAddress[:]),
+		common.BytesToHash(from[:]),
+		common.BytesToHash(common.HexToAddress("0x12459c951127e0c374ff9105dda097662a027093").Bytes()),
+	}
+	data := append(abi.U256(ids[0]), abi.U256(big.NewInt(1))...)
+	if batch {
+		topics[0] = topic("0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb")
+		data = append(abi.U256(big.NewInt(64)), abi.U256(big.NewInt(int64(96+32*len(ids))))...)
+		data = append(data, abi.U256(big.NewInt(int64(len(ids))))...)
+		for _, id := range ids {
+			data = append(data, abi.U256(id)...)
+		}
+		data = append(data, abi.U256(big.NewInt(int64(len(ids))))...)
+		for range ids {
+			data = append(data, abi.U256(big.NewInt(1))...)
+		}
+	}
+	return &types.Log{Address: tokenAddress, Topics: topics, Data: data}
+}
+
+func tokenAsset(id int64) orTypes.AssetData {
+	return orCommon.ToERC1155AssetData(orCommon.BytesToOrAddress(tokenAddress), []*big.Int{big.NewInt(id)}, []*big.Int{big.NewInt(1)}, []byte{})
+}
+
+func runTransferBlock(t *testing.T, logs []types.Log, balances map[int64]int64) []*db.SpendRecord {
+	bloomLogs := []*types.Log{}
+	for i := range logs {
+		bloomLogs = append(bloomLogs, &logs[i])
+	}
+	mb := &blocks.MiniBlock{
+		Hash:   common.Hash{},
+		Number: big.NewInt(0),
+		Bloom:  types.BytesToBloom(types.LogsBloom(bloomLogs).Bytes()),
+	}
+	srcPublisher, consumerChannel := channels.MockChannel()
+	destPublisher, destConsumerChannel := channels.MockChannel()
+	data, err := json.Marshal(mb)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tc := newTestConsumer()
+	destConsumerChannel.AddConsumer(tc)
+	destConsumerChannel.StartConsuming()
+	defer destConsumerChannel.StopConsuming()
+	sender := orCommon.BytesToOrAddress(senderAddress)
+	balanceMap := make(map[string]map[orTypes.Address]*big.Int)
+	for id, amount := range balances {
+		balanceMap[string(tokenAsset(id))] = map[orTypes.Address]*big.Int{*sender: big.NewInt(amount)}
+	}
+	consumerChannel.AddConsumer(erc1155.NewTransferBlockConsumer(
+		orCommon.BytesToOrAddress(proxyAddress),
+		"0x4444444444444444444444444444444444444444",
+		mock.NewMockLogFilterer(logs),
+		destPublisher,
+		balance.NewMockBalanceChecker(balanceMap),
+	))
+	consumerChannel.StartConsuming()
+	defer consumerChannel.StopConsuming()
+	srcPublisher.Publish(string(data))
+	records := []*db.SpendRecord{}
+	for {
+		select {
+		case payload := <-tc.channel:
+			sr := &db.SpendRecord{}
+			if err := json.Unmarshal([]byte(payload), sr); err != nil {
+				t.Fatal(err.Error())
+			}
+			records = append(records, sr)
+		case <-time.After(100 * time.Millisecond):
+			return records
+		}
+	}
+}
+
+func TestTransferSingle(t *testing.T) {
+	records := runTransferBlock(t, []types.Log{*transferLog(false, senderAddress, []*big.Int{big.NewInt(7)})}, map[int64]int64{7: 3})
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 spend record, got %v", len(records))
+	}
+	sr := records[0]
+	if sr.TokenAddress != "0x1d7022f5b17d2f8b695918fb48fa1089c9f85401" {
+		t.Errorf("Unexpected token address, got '%v'", sr.TokenAddress)
+	}
+	if sr.SpenderAddress != "0x5409ed021d9299bf6814279a6a1411a7e866a631" {
+		t.Errorf("Unexpected spender address, got '%v'", sr.SpenderAddress)
+	}
+	if sr.AssetData != hexutil.Encode(tokenAsset(7)) {
+		t.Errorf("Unexpected asset data, got '%v'", sr.AssetData)
+	}
+	if sr.Balance != "3" {
+		t.Errorf("Unexpected balance, got '%v'", sr.Balance)
+	}
+}
+
+func TestTransferBatch(t *testing.T) {
+	records := runTransferBlock(t, []types.Log{*transferLog(true, senderAddress, []*big.Int{big.NewInt(7), big.NewInt(8)})}, map[int64]int64{7: 3, 8: 0})
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 spend records, got %v", len(records))
+	}
+	for i, id := range []int64{7, 8} {
+		if records[i].AssetData != hexutil.Encode(tokenAsset(id)) {
+			t.Errorf("Unexpected asset data for token %v, got '%v'", id, records[i].AssetData)
+		}
+	}
+	if records[1].Balance != "0" {
+		t.Errorf("Unexpected balance, got '%v'", records[1].Balance)
+	}
+}
+
+func TestTransferMint(t *testing.T) {
+	records := runTransferBlock(t, []types.Log{*transferLog(false, common.Address{}, []*big.Int{big.NewInt(7)})}, map[int64]int64{})
+	if len(records) != 0 {
+		t.Errorf("Expected no spend records for minted tokens, got %v", len(records))
+	}
+}
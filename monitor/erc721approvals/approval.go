@@ -85,6 +85,7 @@ func (consumer *approvalBlockConsumer) Consume(delivery channels.Delivery) {
 						AssetData: fmt.Sprintf("%#x", orCommon.ToERC721AssetData(orCommon.BytesToOrAddress(approvalLog.Address), orCommon.BytesToUint256(approvalLog.Topics[3]))),
 						ZrxToken: consumer.feeTokenAddress,
 						Balance: "0",
+						BlockNumber: approvalLog.BlockNumber,
 					}
 					msg, err := json.Marshal(sr)
 					if err != nil {
@@ -116,6 +117,7 @@ func (consumer *approvalBlockConsumer) Consume(delivery channels.Delivery) {
 							SpenderAddress: hexutil.Encode(approvalLog.Topics[1][12:]),
 							ZrxToken: consumer.feeTokenAddress,
 							Balance: "0",
+							BlockNumber: approvalLog.BlockNumber,
 						}
 						msg, err := json.Marshal(sr)
 						if err != nil {
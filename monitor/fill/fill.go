@@ -73,6 +73,7 @@ func (consumer *fillBlockConsumer) Consume(delivery channels.Delivery) {
 					OrderHash: fmt.Sprintf("%#x", orderHash),
 					FilledTakerAssetAmount: takerTokenFilled.Text(10),
 					Cancel: false,
+					BlockNumber: fillLog.BlockNumber,
 				}
 				consumer.fillBloom.Add(orderHash)
 			} else {
@@ -81,6 +82,7 @@ func (consumer *fillBlockConsumer) Consume(delivery channels.Delivery) {
 					OrderHash: fmt.Sprintf("%#x", orderHash),
 					FilledTakerAssetAmount: "0",
 					Cancel: true,
+					BlockNumber: fillLog.BlockNumber,
 				}
 				consumer.fillBloom.Add(orderHash)
 			}
@@ -143,6 +143,7 @@ func (consumer *spendBlockConsumer) Consume(delivery channels.Delivery) {
 				SpenderAddress: hexutil.Encode(spendLog.Topics[1][12:]),
 				ZrxToken: consumer.feeTokenAddress,
 				Balance: balance.String(),
+				BlockNumber: spendLog.BlockNumber,
 			}
 			msg, err := json.Marshal(sr)
 			if err != nil {
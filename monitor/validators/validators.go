@@ -0,0 +1,101 @@
+package validators
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	coreTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/channels"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/monitor/blocks"
+	"github.com/notegio/openrelay/types"
+	"log"
+	"math/big"
+)
+
+type approvalBlockConsumer struct {
+	exchangeAddress *types.Address
+	approvalTopic   *big.Int // 0xa8656e308026eeabce8f0bc18048433252318ab80ac79da0b3d3d8697dfba891
+	logFilter       ethereum.LogFilterer
+	indexer         *dbModule.Indexer
+}
+
+func (consumer *approvalBlockConsumer) Consume(delivery channels.Delivery) {
+	block := &blocks.MiniBlock{}
+	err := json.Unmarshal([]byte(delivery.Payload()), block)
+	if err != nil {
+		log.Printf("Error parsing payload: %v\n", err.Error())
+		delivery.Reject()
+		return
+	}
+	exchangeAddress := common.BytesToAddress(consumer.exchangeAddress[:])
+	if coreTypes.BloomLookup(block.Bloom, consumer.approvalTopic) && coreTypes.BloomLookup(block.Bloom, exchangeAddress) {
+		log.Printf("Block %#x bloom filter indicates SignatureValidatorApproval event for %#x", block.Hash, consumer.exchangeAddress[:])
+		query := ethereum.FilterQuery{
+			FromBlock: block.Number,
+			ToBlock:   block.Number,
+			Addresses: []common.Address{exchangeAddress},
+			Topics: [][]common.Hash{
+				[]common.Hash{common.BigToHash(consumer.approvalTopic)},
+				nil,
+				nil,
+			},
+		}
+		logs, err := consumer.logFilter.FilterLogs(context.Background(), query)
+		if err != nil {
+			delivery.Return()
+			log.Fatalf("Failed to filter logs on block %v - aborting: %v", block.Number, err.Error())
+		}
+		log.Printf("Found %v validator approval logs", len(logs))
+		for _, approvalLog := range logs {
+			if len(approvalLog.Topics) != 3 || len(approvalLog.Data) != 32 {
+				log.Printf("Unexpected log data. Skipping.")
+				continue
+			}
+			if new(big.Int).SetBytes(approvalLog.Data[:]).Sign() != 0 {
+				// Approving a validator can't make an order invalid
+				continue
+			}
+			signer := &types.Address{}
+			validator := &types.Address{}
+			copy(signer[:], approvalLog.Topics[1][12:])
+			copy(validator[:], approvalLog.Topics[2][12:])
+			if err := consumer.indexer.RecordValidatorRevocation(consumer.exchangeAddress, signer, validator, approvalLog.BlockNumber); err != nil {
+				delivery.Return()
+				log.Fatalf("Failed to record validator revocation on block %v - aborting: %v", block.Number, err.Error())
+			}
+		}
+	} else {
+		log.Printf("Block %#x shows no SignatureValidatorApproval events", block.Hash)
+	}
+	delivery.Ack()
+}
+
+// NewApprovalBlockConsumer returns a consumer that watches blocks for
+// SignatureValidatorApproval events on the exchange at `exchangeAddress`. When
+// a signer revokes their approval of a validator, their open orders signed
+// with that validator are marked invalid, and published to `publisher` as
+// unfillable.
+func NewApprovalBlockConsumer(exchangeAddress *types.Address, lf ethereum.LogFilterer, db *gorm.DB, publisher channels.Publisher) channels.Consumer {
+	approvalTopic := &big.Int{}
+	approvalTopic.SetString("a8656e308026eeabce8f0bc18048433252318ab80ac79da0b3d3d8697dfba891", 16)
+	return &approvalBlockConsumer{
+		exchangeAddress,
+		approvalTopic,
+		lf,
+		dbModule.NewIndexer(db, dbModule.StatusInvalid, publisher),
+	}
+}
+
+func NewRPCApprovalBlockConsumer(rpcURL string, exchangeAddress string, db *gorm.DB, publisher channels.Publisher) (channels.Consumer, error) {
+	client, err := ethclient.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	address := &types.Address{}
+	copy(address[:], common.HexToAddress(exchangeAddress).Bytes())
+	return NewApprovalBlockConsumer(address, client, db, publisher), nil
+}
@@ -0,0 +1,164 @@
+package validators_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	coreTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/channels"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/monitor/blocks"
+	"github.com/notegio/openrelay/monitor/blocks/mock"
+	"github.com/notegio/openrelay/monitor/validators"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"os"
+	"testing"
+)
+
+var exchangeAddress = common.HexToAddress("0x48bacb9266a570d521063ef5dd96e61686dbe788")
+var signerAddress = common.HexToAddress("0x324454186bb728a3ea55750e0618ff1b18ce6cf8")
+var validatorAddress = common.HexToAddress("0x1dc4c1cefef38a777b15aa20260a54e584b16c48")
+
+func getDb() (*gorm.DB, error) {
+	connectionString := fmt.Sprintf(
+		"postgres://%v@%v",
+		os.Getenv("POSTGRES_USER"),
+		os.Getenv("POSTGRES_HOST"),
+	)
+	return dbModule.GetDB(connectionString, os.Getenv("POSTGRES_PASSWORD"))
+}
+
+type blockDelivery struct {
+	payload  string
+	acked    bool
+	rejected bool
+}
+
+func (delivery *blockDelivery) Payload() string           { return delivery.payload }
+func (delivery *blockDelivery) Headers() channels.Headers { return nil }
+func (delivery *blockDelivery) Ack() bool                 { delivery.acked = true; return true }
+func (delivery *blockDelivery) Reject() bool              { delivery.rejected = true; return true }
+func (delivery *blockDelivery) Return() bool              { return true }
+
+type mockExchangeVerifier struct{}
+
+func (verifier *mockExchangeVerifier) IsPreSigned(exchange *types.Address, hash []byte, signer *types.Address) (bool, error) {
+	return false, nil
+}
+
+func (verifier *mockExchangeVerifier) IsValidValidatorSignature(exchange *types.Address, hash []byte, signer *types.Address, signature types.Signature) (bool, error) {
+	return true, nil
+}
+
+type countingPublisher struct {
+	published int
+}
+
+func (publisher *countingPublisher) Publish(payload string) bool {
+	publisher.published++
+	return true
+}
+
+func approvalLog(approved int64) *coreTypes.Log {
+	topic := &big.Int{}
+	topic.SetString("a8656e308026eeabce8f0bc18048433252318ab80ac79da0b3d3d8697dfba891", 16)
+	data := common.BigToHash(big.NewInt(approved))
+	return &coreTypes.Log{
+		Address: exchangeAddress,
+		Topics: []common.Hash{
+			common.BigToHash(topic),
+			common.BytesToHash(signerAddress[:]),
+			common.BytesToHash(validatorAddress[:]),
+		},
+		Data: data[:],
+	}
+}
+
+func runBlock(t *testing.T, testLog *coreTypes.Log, db *gorm.DB, publisher channels.Publisher) *blockDelivery {
+	data, err := json.Marshal(&blocks.MiniBlock{
+		Hash:   common.Hash{},
+		Number: big.NewInt(0),
+		Bloom:  coreTypes.BytesToBloom(coreTypes.LogsBloom([]*coreTypes.Log{testLog}).Bytes()),
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	exchange := &types.Address{}
+	copy(exchange[:], exchangeAddress[:])
+	consumer := validators.NewApprovalBlockConsumer(exchange, mock.NewMockLogFilterer([]coreTypes.Log{*testLog}), db, publisher)
+	delivery := &blockDelivery{payload: string(data)}
+	consumer.Consume(delivery)
+	if !delivery.acked {
+		t.Errorf("Expected the block to be acked")
+	}
+	return delivery
+}
+
+func TestIgnoreApproval(t *testing.T) {
+	publisher := &countingPublisher{}
+	runBlock(t, approvalLog(1), nil, publisher)
+	if publisher.published != 0 {
+		t.Errorf("Approving a validator should not update any orders")
+	}
+}
+
+func TestRejectBadPayload(t *testing.T) {
+	publisher := &countingPublisher{}
+	exchange := &types.Address{}
+	copy(exchange[:], exchangeAddress[:])
+	consumer := validators.NewApprovalBlockConsumer(exchange, mock.NewMockLogFilterer([]coreTypes.Log{}), nil, publisher)
+	delivery := &blockDelivery{payload: "not a block"}
+	consumer.Consume(delivery)
+	if !delivery.rejected {
+		t.Errorf("Expected an unparseable block to be rejected")
+	}
+	if delivery.acked {
+		t.Errorf("Expected an unparseable block not to be acked")
+	}
+}
+
+func TestRevokeValidator(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Error(err.Error())
+	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
+	order := &types.Order{}
+	order.Initialize()
+	copy(order.ExchangeAddress[:], exchangeAddress[:])
+	copy(order.Maker[:], signerAddress[:])
+	order.Signature = append(append(types.Signature{0xde, 0xad}, validatorAddress[:]...), types.SigTypeValidator)
+	validator := &types.Address{}
+	copy(validator[:], validatorAddress[:])
+	types.SetExchangeVerifier(&mockExchangeVerifier{})
+	types.SetAllowedValidators([]*types.Address{validator})
+	defer func() {
+		types.SetExchangeVerifier(nil)
+		types.SetAllowedValidators(nil)
+	}()
+	dbOrder := &dbModule.Order{}
+	dbOrder.Order = *order
+	if err := dbOrder.Save(tx, dbModule.StatusOpen, nil).Error; err != nil {
+		t.Fatal(err.Error())
+	}
+	publisher := &countingPublisher{}
+	runBlock(t, approvalLog(0), tx, publisher)
+	dbOrders := []dbModule.Order{}
+	tx.Model(&dbModule.Order{}).Where("order_hash = ?", order.Hash()).Find(&dbOrders)
+	if len(dbOrders) != 1 || dbOrders[0].Status != dbModule.StatusInvalid {
+		t.Errorf("Expected the order to be marked invalid, got %v", dbOrders)
+	}
+}
@@ -0,0 +1,95 @@
+package walletsig
+
+import (
+	"encoding/json"
+	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/channels"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/monitor/blocks"
+	"github.com/notegio/openrelay/types"
+	"github.com/notegio/openrelay/wallet"
+	"log"
+	"math/big"
+)
+
+type revalidationBlockConsumer struct {
+	db       *gorm.DB
+	indexer  *dbModule.Indexer
+	verifier types.WalletVerifier
+	interval *big.Int
+}
+
+// revalidate checks every open or unfunded order with a Wallet signature,
+// and returns the hashes of those the wallet no longer considers valid.
+func (consumer *revalidationBlockConsumer) revalidate() ([][]byte, error) {
+	orders := []dbModule.Order{}
+	err := consumer.db.Model(&dbModule.Order{}).Where(
+		"status IN (?) AND substring(signature from length(signature) for 1) = ?",
+		[]int64{dbModule.StatusOpen, dbModule.StatusUnfunded},
+		[]byte{types.SigTypeWallet},
+	).Find(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+	invalid := [][]byte{}
+	for _, order := range orders {
+		if len(order.Signature) == 0 {
+			continue
+		}
+		valid, err := consumer.verifier.IsValidWalletSignature(order.Maker, order.Hash(), order.Signature[:len(order.Signature)-1])
+		if err != nil {
+			return nil, err
+		}
+		if !valid {
+			log.Printf("Wallet %#x no longer accepts the signature for order %#x", order.Maker[:], order.Hash())
+			invalid = append(invalid, order.Hash())
+		}
+	}
+	log.Printf("Revalidated %v wallet signed orders, %v are no longer valid", len(orders), len(invalid))
+	return invalid, nil
+}
+
+func (consumer *revalidationBlockConsumer) Consume(delivery channels.Delivery) {
+	block := &blocks.MiniBlock{}
+	err := json.Unmarshal([]byte(delivery.Payload()), block)
+	if err != nil {
+		log.Printf("Error parsing payload: %v\n", err.Error())
+		delivery.Reject()
+		return
+	}
+	if block.Number == nil || new(big.Int).Mod(block.Number, consumer.interval).Sign() != 0 {
+		delivery.Ack()
+		return
+	}
+	invalid, err := consumer.revalidate()
+	if err != nil {
+		delivery.Return()
+		log.Fatalf("Failed to revalidate wallet signatures on block %v - aborting: %v", block.Number, err.Error())
+	}
+	if err := consumer.indexer.RecordInvalidSignatures(invalid, block.Number.Uint64()); err != nil {
+		delivery.Return()
+		log.Fatalf("Failed to record invalid signatures on block %v - aborting: %v", block.Number, err.Error())
+	}
+	delivery.Ack()
+}
+
+// NewRevalidationBlockConsumer returns a consumer of blocks from the block
+// monitor that, every `interval` blocks, checks each open order with a Wallet
+// signature against its wallet contract. Orders the wallet has revoked are
+// marked invalid, and published to `publisher` as unfillable.
+func NewRevalidationBlockConsumer(db *gorm.DB, verifier types.WalletVerifier, interval int64, publisher channels.Publisher) channels.Consumer {
+	return &revalidationBlockConsumer{
+		db,
+		dbModule.NewIndexer(db, dbModule.StatusInvalid, publisher),
+		verifier,
+		big.NewInt(interval),
+	}
+}
+
+func NewRPCRevalidationBlockConsumer(db *gorm.DB, rpcURL string, interval int64, publisher channels.Publisher) (channels.Consumer, error) {
+	verifier, err := wallet.NewRpcWalletVerifier(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewRevalidationBlockConsumer(db, verifier, interval, publisher), nil
+}
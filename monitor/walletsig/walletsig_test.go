@@ -0,0 +1,128 @@
+package walletsig_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/channels"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/monitor/blocks"
+	"github.com/notegio/openrelay/monitor/walletsig"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"os"
+	"testing"
+)
+
+func getDb() (*gorm.DB, error) {
+	connectionString := fmt.Sprintf(
+		"postgres://%v@%v",
+		os.Getenv("POSTGRES_USER"),
+		os.Getenv("POSTGRES_HOST"),
+	)
+	return dbModule.GetDB(connectionString, os.Getenv("POSTGRES_PASSWORD"))
+}
+
+type mockVerifier struct {
+	valid bool
+	calls int
+}
+
+func (verifier *mockVerifier) IsValidWalletSignature(wallet *types.Address, hash []byte, signature []byte) (bool, error) {
+	verifier.calls++
+	return verifier.valid, nil
+}
+
+type countingPublisher struct {
+	published int
+}
+
+func (publisher *countingPublisher) Publish(payload string) bool {
+	publisher.published++
+	return true
+}
+
+type blockDelivery struct {
+	payload string
+	acked   bool
+}
+
+func (delivery *blockDelivery) Payload() string           { return delivery.payload }
+func (delivery *blockDelivery) Headers() channels.Headers { return nil }
+func (delivery *blockDelivery) Ack() bool                 { delivery.acked = true; return true }
+func (delivery *blockDelivery) Reject() bool              { return true }
+func (delivery *blockDelivery) Return() bool              { return true }
+
+func newBlockDelivery(t *testing.T, number int64) *blockDelivery {
+	data, err := json.Marshal(&blocks.MiniBlock{Hash: common.Hash{}, Number: big.NewInt(number)})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return &blockDelivery{payload: string(data)}
+}
+
+func TestSkipBlocksBetweenIntervals(t *testing.T) {
+	verifier := &mockVerifier{}
+	consumer := walletsig.NewRevalidationBlockConsumer(nil, verifier, 10, nil)
+	delivery := newBlockDelivery(t, 11)
+	consumer.Consume(delivery)
+	if !delivery.acked {
+		t.Errorf("Expected the block to be acked")
+	}
+	if verifier.calls != 0 {
+		t.Errorf("Orders should not be revalidated between intervals")
+	}
+}
+
+func TestRevalidateWalletOrders(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Error(err.Error())
+	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
+	order := &types.Order{}
+	order.Initialize()
+	order.Maker[19] = 1
+	order.Signature = types.Signature{0xde, 0xad, types.SigTypeWallet}
+	verifier := &mockVerifier{valid: true}
+	types.SetWalletVerifier(verifier)
+	defer types.SetWalletVerifier(nil)
+	dbOrder := &dbModule.Order{}
+	dbOrder.Order = *order
+	if err := dbOrder.Save(tx, dbModule.StatusOpen, nil).Error; err != nil {
+		t.Fatal(err.Error())
+	}
+	verifier.calls = 0
+	publisher := &countingPublisher{}
+	consumer := walletsig.NewRevalidationBlockConsumer(tx, verifier, 10, publisher)
+	consumer.Consume(newBlockDelivery(t, 20))
+	if verifier.calls != 1 {
+		t.Fatalf("Expected 1 wallet call, got %v", verifier.calls)
+	}
+	verifier.valid = false
+	consumer.Consume(newBlockDelivery(t, 30))
+	dbOrders := []dbModule.Order{}
+	tx.Model(&dbModule.Order{}).Where("order_hash = ?", order.Hash()).Find(&dbOrders)
+	if len(dbOrders) != 1 || dbOrders[0].Status != dbModule.StatusInvalid {
+		t.Errorf("Expected the order to be marked invalid, got %v", dbOrders)
+	}
+	if publisher.published != 1 {
+		t.Errorf("Expected 1 published update, got %v", publisher.published)
+	}
+	consumer.Consume(newBlockDelivery(t, 40))
+	if verifier.calls != 2 {
+		t.Errorf("Invalid orders should not be revalidated, got %v calls", verifier.calls)
+	}
+}
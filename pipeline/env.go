@@ -0,0 +1,184 @@
+package pipeline
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/common"
+	dbModule "github.com/notegio/openrelay/db"
+	"gopkg.in/redis.v3"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Env holds the connections shared by the stages of a pipeline, opening them
+// the first time a stage asks for them.
+type Env struct {
+	spec        *Spec
+	mu          sync.Mutex
+	redisClient *redis.Client
+	dbs         map[string]*gorm.DB
+	pgBackend   *channels.PgBackend
+}
+
+// NewEnv returns an Env for running stages of `spec`
+func NewEnv(spec *Spec) *Env {
+	return &Env{spec: spec, dbs: make(map[string]*gorm.DB)}
+}
+
+// Redis returns the pipeline's redis client
+func (env *Env) Redis() (*redis.Client, error) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if env.spec.Redis == "" {
+		return nil, fmt.Errorf("Please specify a redis address")
+	}
+	if env.redisClient == nil {
+		redisClient, err := common.NewRedisClient(env.spec.Redis)
+		if err != nil {
+			return nil, err
+		}
+		env.redisClient = redisClient
+	}
+	return env.redisClient, nil
+}
+
+// RPC returns the URL of the pipeline's Ethereum node
+func (env *Env) RPC() (string, error) {
+	if env.spec.RPC == "" {
+		return "", fmt.Errorf("Please specify an RPC URL")
+	}
+	return env.spec.RPC, nil
+}
+
+// DB returns a database connection for `stage`. Stages using the same
+// connection string share a connection.
+func (env *Env) DB(stage StageSpec) (*gorm.DB, error) {
+	database := env.spec.Database
+	if stage.Database != nil {
+		database = *stage.Database
+	}
+	if database.Connection == "" {
+		return nil, fmt.Errorf("Please specify a database for stage '%v'", stage.Name)
+	}
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if db, ok := env.dbs[database.Connection]; ok {
+		return db, nil
+	}
+	db, err := dbModule.GetDB(database.Connection, database.Password)
+	if err != nil {
+		return nil, err
+	}
+	env.dbs[database.Connection] = db
+	return db, nil
+}
+
+// PgBackend returns the backend pg:// channels are stored in
+func (env *Env) PgBackend() (*channels.PgBackend, error) {
+	database := env.spec.Database
+	if env.spec.ChannelDatabase != nil {
+		database = *env.spec.ChannelDatabase
+	}
+	if database.Connection == "" {
+		return nil, fmt.Errorf("Please specify a database for pg:// channels")
+	}
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if env.pgBackend == nil {
+		connectionString, err := dbModule.PostgresConnectionString(database.Connection, database.Password)
+		if err != nil {
+			return nil, err
+		}
+		if env.pgBackend, err = channels.NewPgBackend(connectionString); err != nil {
+			return nil, err
+		}
+	}
+	return env.pgBackend, nil
+}
+
+func isPgURI(uri string) bool {
+	return strings.HasPrefix(uri, "pg://") || strings.HasPrefix(uri, "pg+topic://")
+}
+
+// redisFor returns the redis client if `uri` refers to a redis backed
+// channel. In-memory channels don't need one.
+func (env *Env) redisFor(uri string) (*redis.Client, error) {
+	if strings.HasPrefix(uri, "mem://") || strings.HasPrefix(uri, "mem+topic://") || strings.HasPrefix(uri, "mem+delay://") {
+		return nil, nil
+	}
+	return env.Redis()
+}
+
+// Consumer returns a ConsumerChannel for `uri`
+func (env *Env) Consumer(uri string) (channels.ConsumerChannel, error) {
+	if isPgURI(uri) {
+		backend, err := env.PgBackend()
+		if err != nil {
+			return nil, err
+		}
+		return channels.PgConsumerFromURI(uri, backend)
+	}
+	redisClient, err := env.redisFor(uri)
+	if err != nil {
+		return nil, err
+	}
+	return channels.ConsumerFromURI(uri, redisClient)
+}
+
+// Publisher returns a Publisher for `uri`
+func (env *Env) Publisher(uri string) (channels.Publisher, error) {
+	if isPgURI(uri) {
+		backend, err := env.PgBackend()
+		if err != nil {
+			return nil, err
+		}
+		return channels.PgPublisherFromURI(uri, backend)
+	}
+	redisClient, err := env.redisFor(uri)
+	if err != nil {
+		return nil, err
+	}
+	return channels.PublisherFromURI(uri, redisClient)
+}
+
+// ConsumerFromURI is the same as Consumer, so that an Env can be used as a
+// channels.URITranslator
+func (env *Env) ConsumerFromURI(uri string) (channels.ConsumerChannel, error) {
+	return env.Consumer(uri)
+}
+
+// PublisherFromURI is the same as Publisher, so that an Env can be used as a
+// channels.URITranslator
+func (env *Env) PublisherFromURI(uri string) (channels.Publisher, error) {
+	return env.Publisher(uri)
+}
+
+// Publishers returns a MultiPublisher that publishes to each of `uris`
+func (env *Env) Publishers(uris []string) (channels.MultiPublisher, error) {
+	publishers := channels.MultiPublisher{}
+	for _, uri := range uris {
+		publisher, err := env.Publisher(uri)
+		if err != nil {
+			return nil, err
+		}
+		publishers = append(publishers, publisher)
+	}
+	return publishers, nil
+}
+
+// concurrency returns how many deliveries `stage` should process at once,
+// falling back to the CONCURRENCY environment variable and then 5, as the
+// individual services do.
+func concurrency(stage StageSpec) int {
+	if stage.Concurrency > 0 {
+		return stage.Concurrency
+	}
+	concurrency, err := strconv.Atoi(os.Getenv("CONCURRENCY"))
+	if err != nil {
+		return 5
+	}
+	return concurrency
+}
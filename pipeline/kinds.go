@@ -0,0 +1,773 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/config"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/fillbloom"
+	"github.com/notegio/openrelay/funds"
+	"github.com/notegio/openrelay/metadata"
+	"github.com/notegio/openrelay/monitor/affiliate"
+	"github.com/notegio/openrelay/monitor/allowance"
+	"github.com/notegio/openrelay/monitor/blocks"
+	"github.com/notegio/openrelay/monitor/cancelupto"
+	"github.com/notegio/openrelay/monitor/erc1155"
+	"github.com/notegio/openrelay/monitor/erc721approvals"
+	"github.com/notegio/openrelay/monitor/fill"
+	"github.com/notegio/openrelay/monitor/multisig"
+	"github.com/notegio/openrelay/monitor/spend"
+	"github.com/notegio/openrelay/monitor/validators"
+	"github.com/notegio/openrelay/monitor/walletsig"
+	poolModule "github.com/notegio/openrelay/pool"
+	"github.com/notegio/openrelay/splitter"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// arity is the number of inputs or outputs a kind of stage accepts. A
+// negative max means there is no upper limit.
+type arity struct {
+	min, max int
+}
+
+var (
+	noChannels   = arity{0, 0}
+	oneChannel   = arity{1, 1}
+	someChannels = arity{1, -1}
+	anyChannels  = arity{0, -1}
+)
+
+func (a arity) allows(count int) bool {
+	return count >= a.min && (a.max < 0 || count <= a.max)
+}
+
+func (a arity) String() string {
+	switch {
+	case a.max < 0:
+		return fmt.Sprintf("at least %v", a.min)
+	case a.min == a.max:
+		return strconv.Itoa(a.min)
+	default:
+		return fmt.Sprintf("%v to %v", a.min, a.max)
+	}
+}
+
+// kind describes a type of stage: the channels and options it takes, and how
+// to build it. Options listed in consumeOptions and publishOptions hold
+// channel URIs, and are included when checking the pipeline's graph.
+type kind struct {
+	inputs         arity
+	outputs        arity
+	required       []string
+	optional       []string
+	consumeOptions []string
+	publishOptions []string
+	build          func(env *Env, stage StageSpec) (Stage, error)
+}
+
+var kinds = map[string]kind{
+	"relay": {
+		inputs: someChannels, outputs: someChannels,
+		optional: []string{"partitionby"},
+		build:    buildRelay,
+	},
+	"delayrelay": {
+		inputs: oneChannel, outputs: someChannels,
+		required: []string{"signal"}, optional: []string{"signalout"},
+		consumeOptions: []string{"signal"}, publishOptions: []string{"signalout"},
+		build: buildDelayRelay,
+	},
+	"fundcheck": {
+		inputs: someChannels, outputs: someChannels,
+		optional:       []string{"invalidation", "invert", "partitionby"},
+		consumeOptions: []string{"invalidation"},
+		build:          buildFundCheck,
+	},
+	"poolfilter": {
+		inputs: someChannels, outputs: someChannels,
+		optional: []string{"partitionby"},
+		build:    buildPoolFilter,
+	},
+	"fillupdate": {
+		inputs: someChannels, outputs: someChannels,
+		required: []string{"fills", "bloom"}, optional: []string{"changes"},
+		consumeOptions: []string{"fills"}, publishOptions: []string{"changes"},
+		build: buildFillUpdate,
+	},
+	"canceluptofilter": {
+		inputs: someChannels, outputs: someChannels,
+		optional: []string{"changes"}, publishOptions: []string{"changes"},
+		build: buildCancelUpToFilter,
+	},
+	// The exchange splitter works out where to publish each order from its
+	// exchange address, so its outputs are only used to check the graph.
+	"exchangesplitter": {
+		inputs: oneChannel, outputs: anyChannels,
+		required: []string{"suffix"},
+		build:    buildExchangeSplitter,
+	},
+	// The router's outputs must list every destination in its routes file,
+	// apart from templated destinations, which depend on the order.
+	"router": {
+		inputs: oneChannel, outputs: someChannels,
+		required: []string{"routes"},
+		build:    buildRouter,
+	},
+	"indexer": {
+		inputs: oneChannel, outputs: oneChannel,
+		optional: []string{"status", "batchsize", "batchwindow"},
+		build:    buildIndexer,
+	},
+	"fillindexer": {
+		inputs: oneChannel, outputs: oneChannel,
+		build: buildFillIndexer,
+	},
+	"spendrecorder": {
+		inputs: oneChannel, outputs: oneChannel,
+		build: buildSpendRecorder,
+	},
+	"walletrevalidator": {
+		inputs: oneChannel, outputs: oneChannel,
+		optional: []string{"interval"},
+		build:    buildWalletRevalidator,
+	},
+	"canceluptoindexer": {
+		inputs: oneChannel, outputs: oneChannel,
+		build: buildCancelUpToIndexer,
+	},
+	"metadataindexer": {
+		inputs: oneChannel, outputs: noChannels,
+		build: buildMetadataIndexer,
+	},
+	"blockmonitor": {
+		inputs: noChannels, outputs: oneChannel,
+		optional: []string{"buffer", "interval"},
+		build:    buildBlockMonitor,
+	},
+	"fillmonitor": {
+		inputs: oneChannel, outputs: oneChannel,
+		required: []string{"exchange", "bloom"},
+		build:    buildFillMonitor,
+	},
+	"spendmonitor": {
+		inputs: oneChannel, outputs: oneChannel,
+		required: []string{"exchange"},
+		build:    buildSpendMonitor,
+	},
+	"allowancemonitor": {
+		inputs: oneChannel, outputs: oneChannel,
+		required: []string{"exchange"},
+		build:    buildAllowanceMonitor,
+	},
+	"erc721approvalmonitor": {
+		inputs: oneChannel, outputs: oneChannel,
+		required: []string{"exchange"},
+		build:    buildERC721ApprovalMonitor,
+	},
+	"erc1155spendmonitor": {
+		inputs: oneChannel, outputs: oneChannel,
+		required: []string{"exchange"},
+		build:    buildERC1155SpendMonitor,
+	},
+	"erc1155approvalmonitor": {
+		inputs: oneChannel, outputs: oneChannel,
+		required: []string{"exchange"},
+		build:    buildERC1155ApprovalMonitor,
+	},
+	"validatormonitor": {
+		inputs: oneChannel, outputs: oneChannel,
+		required: []string{"exchange"},
+		build:    buildValidatorMonitor,
+	},
+	"canceluptomonitor": {
+		inputs: oneChannel, outputs: oneChannel,
+		required: []string{"exchange"},
+		build:    buildCancelUpToMonitor,
+	},
+	"affiliatemonitor": {
+		inputs: oneChannel, outputs: noChannels,
+		required: []string{"signup"},
+		build:    buildAffiliateMonitor,
+	},
+	"multisigmonitor": {
+		inputs: oneChannel, outputs: noChannels,
+		required: []string{"address"},
+		build:    buildMultisigMonitor,
+	},
+}
+
+// Kinds returns the names of the kinds of stage a pipeline can contain
+func Kinds() []string {
+	names := []string{}
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var channelSchemes = []string{"queue://", "topic://", "stream://", "delay://", "pg://", "pg+topic://", "mem://", "mem+topic://", "mem+delay://"}
+
+func validChannelURI(uri string) bool {
+	for _, scheme := range channelSchemes {
+		if strings.HasPrefix(uri, scheme) && len(uri) > len(scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, item string) bool {
+	for _, value := range list {
+		if value == item {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns the problems with `stage`, which is referred to as `label`
+func (k kind) check(label string, stage StageSpec) []string {
+	problems := []string{}
+	if !k.inputs.allows(len(stage.Inputs)) {
+		problems = append(problems, fmt.Sprintf("%v needs %v inputs, but has %v", label, k.inputs, len(stage.Inputs)))
+	}
+	if !k.outputs.allows(len(stage.Outputs)) {
+		problems = append(problems, fmt.Sprintf("%v needs %v outputs, but has %v", label, k.outputs, len(stage.Outputs)))
+	}
+	for _, option := range k.required {
+		if stage.Options[option] == "" {
+			problems = append(problems, fmt.Sprintf("%v is missing option '%v'", label, option))
+		}
+	}
+	options := []string{}
+	for option := range stage.Options {
+		options = append(options, option)
+	}
+	sort.Strings(options)
+	for _, option := range options {
+		if !contains(k.required, option) && !contains(k.optional, option) {
+			problems = append(problems, fmt.Sprintf("%v has unknown option '%v'", label, option))
+		}
+	}
+	for _, uri := range append(k.consumes(stage), k.publishes(stage)...) {
+		if !validChannelURI(uri) {
+			problems = append(problems, fmt.Sprintf("%v has invalid channel '%v'", label, uri))
+		}
+	}
+	return problems
+}
+
+// consumes returns the URIs of the channels `stage` consumes from
+func (k kind) consumes(stage StageSpec) []string {
+	uris := append([]string{}, stage.Inputs...)
+	for _, option := range k.consumeOptions {
+		if uri := stage.Options[option]; uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
+// publishes returns the URIs of the channels `stage` publishes to
+func (k kind) publishes(stage StageSpec) []string {
+	uris := append([]string{}, stage.Outputs...)
+	for _, option := range k.publishOptions {
+		if uri := stage.Options[option]; uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
+// optionalPublisher returns a publisher for the channel in option `name`, or
+// nil if it isn't set.
+func optionalPublisher(env *Env, stage StageSpec, name string) (channels.Publisher, error) {
+	if stage.Options[name] == "" {
+		return nil, nil
+	}
+	return env.Publisher(stage.Options[name])
+}
+
+// relayStage relays each of the stage's inputs to all of its outputs,
+// passing only messages that match `filter`. If the stage has a partitionby
+// option, deliveries with the same key are relayed in order.
+func relayStage(env *Env, stage StageSpec, filter channels.RelayFilter) (Stage, error) {
+	publishers, err := env.Publishers(stage.Outputs)
+	if err != nil {
+		return nil, err
+	}
+	key, err := channels.KeyFuncByName(stage.Options["partitionby"])
+	if err != nil {
+		return nil, err
+	}
+	result := &consumerStage{}
+	for _, input := range stage.Inputs {
+		consumerChannel, err := env.Consumer(input)
+		if err != nil {
+			return nil, err
+		}
+		relay := channels.NewKeyedRelay(consumerChannel, publishers, filter, concurrency(stage), key)
+		result.relays = append(result.relays, &relay)
+	}
+	return result, nil
+}
+
+// singleConsumerStage consumes the stage's input with the consumer returned
+// by `makeConsumer`, which is passed a publisher for the stage's output, if
+// it has one.
+func singleConsumerStage(env *Env, stage StageSpec, makeConsumer func(publisher channels.Publisher) (channels.Consumer, error)) (Stage, error) {
+	consumerChannel, err := env.Consumer(stage.Inputs[0])
+	if err != nil {
+		return nil, err
+	}
+	var publisher channels.Publisher
+	if len(stage.Outputs) > 0 {
+		if publisher, err = env.Publisher(stage.Outputs[0]); err != nil {
+			return nil, err
+		}
+	}
+	consumer, err := makeConsumer(publisher)
+	if err != nil {
+		return nil, err
+	}
+	result := &consumerStage{}
+	result.bind(consumerChannel, consumer)
+	return result, nil
+}
+
+func buildRelay(env *Env, stage StageSpec) (Stage, error) {
+	return relayStage(env, stage, &channels.IncludeAll{})
+}
+
+func buildDelayRelay(env *Env, stage StageSpec) (Stage, error) {
+	if strings.HasPrefix(stage.Inputs[0], "topic://") {
+		return nil, fmt.Errorf("Delay relay source must be queue, not topic")
+	}
+	sourceChannel, err := env.Consumer(stage.Inputs[0])
+	if err != nil {
+		return nil, err
+	}
+	publishers, err := env.Publishers(stage.Outputs)
+	if err != nil {
+		return nil, err
+	}
+	signalChannel, err := env.Consumer(stage.Options["signal"])
+	if err != nil {
+		return nil, err
+	}
+	signalPublisher, err := optionalPublisher(env, stage, "signalout")
+	if err != nil {
+		return nil, err
+	}
+	relay := channels.NewDelayRelay(sourceChannel.Publisher(), sourceChannel, publishers, "pause")
+	result := &consumerStage{relays: []*channels.Relay{relay.Relay}}
+	result.bindUndrained(signalChannel, channels.NewDelayConsumer(&relay, signalPublisher))
+	return result, nil
+}
+
+func buildFundCheck(env *Env, stage StageSpec) (Stage, error) {
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	feeToken, err := config.NewRpcFeeToken(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	tokenProxy, err := config.NewRpcTokenProxy(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	var invalidationChannel channels.ConsumerChannel
+	if uri := stage.Options["invalidation"]; uri != "" {
+		if invalidationChannel, err = env.Consumer(uri); err != nil {
+			return nil, err
+		}
+	}
+	orderValidator, err := funds.NewRpcOrderValidator(rpcURL, feeToken, tokenProxy, invalidationChannel)
+	if err != nil {
+		return nil, err
+	}
+	var fundFilter channels.RelayFilter = funds.NewFundFilter(orderValidator)
+	if invert := stage.Options["invert"]; invert != "" {
+		inverted, err := strconv.ParseBool(invert)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value for option 'invert': %v", invert)
+		}
+		if inverted {
+			fundFilter = &channels.InvertFilter{Subfilter: fundFilter}
+		}
+	}
+	return relayStage(env, stage, fundFilter)
+}
+
+func buildPoolFilter(env *Env, stage StageSpec) (Stage, error) {
+	db, err := env.DB(stage)
+	if err != nil {
+		return nil, err
+	}
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := ethclient.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	networkID, err := conn.NetworkID(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return relayStage(env, stage, poolModule.NewPoolFilter(db, conn, uint(networkID.Uint64())))
+}
+
+func buildFillUpdate(env *Env, stage StageSpec) (Stage, error) {
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	fillChannel, err := env.Consumer(stage.Options["fills"])
+	if err != nil {
+		return nil, err
+	}
+	fillBloom, err := fillbloom.NewFillBloom(stage.Options["bloom"])
+	if err != nil {
+		return nil, err
+	}
+	lookup, err := funds.NewRPCFilledLookup(rpcURL, fillBloom)
+	if err != nil {
+		return nil, err
+	}
+	allPublisher, err := env.Publishers(stage.Outputs)
+	if err != nil {
+		return nil, err
+	}
+	changePublisher, err := optionalPublisher(env, stage, "changes")
+	if err != nil {
+		return nil, err
+	}
+	result := &consumerStage{}
+	for _, input := range stage.Inputs {
+		consumerChannel, err := env.Consumer(input)
+		if err != nil {
+			return nil, err
+		}
+		fillConsumer := funds.NewFillConsumer(allPublisher, changePublisher, lookup, concurrency(stage))
+		result.bind(consumerChannel, &fillConsumer)
+	}
+	result.bindUndrained(fillChannel, fillBloom)
+	return result, nil
+}
+
+func buildCancelUpToFilter(env *Env, stage StageSpec) (Stage, error) {
+	db, err := env.DB(stage)
+	if err != nil {
+		return nil, err
+	}
+	lookup := funds.NewDBCancellationLookup(db)
+	allPublisher, err := env.Publishers(stage.Outputs)
+	if err != nil {
+		return nil, err
+	}
+	changePublisher, err := optionalPublisher(env, stage, "changes")
+	if err != nil {
+		return nil, err
+	}
+	result := &consumerStage{}
+	for _, input := range stage.Inputs {
+		consumerChannel, err := env.Consumer(input)
+		if err != nil {
+			return nil, err
+		}
+		cancellationConsumer := funds.NewCancellationConsumer(allPublisher, changePublisher, lookup, concurrency(stage))
+		result.bind(consumerChannel, &cancellationConsumer)
+	}
+	return result, nil
+}
+
+func buildExchangeSplitter(env *Env, stage StageSpec) (Stage, error) {
+	consumerChannel, err := env.Consumer(stage.Inputs[0])
+	if err != nil {
+		return nil, err
+	}
+	result := &consumerStage{}
+	result.bind(consumerChannel, splitter.NewExchangeSplitterConsumer(env, stage.Options["suffix"], concurrency(stage)))
+	return result, nil
+}
+
+func buildRouter(env *Env, stage StageSpec) (Stage, error) {
+	config, err := splitter.LoadRouterConfig(stage.Options["routes"])
+	if err != nil {
+		return nil, err
+	}
+	outputs := make(map[string]bool)
+	for _, output := range stage.Outputs {
+		outputs[channelKey(output)] = true
+	}
+	for _, destination := range config.Destinations() {
+		if !splitter.IsTemplate(destination) && !outputs[channelKey(destination)] {
+			return nil, fmt.Errorf("Route destination %v is not listed in the stage's outputs", destination)
+		}
+	}
+	consumerChannel, err := env.Consumer(stage.Inputs[0])
+	if err != nil {
+		return nil, err
+	}
+	router, err := splitter.NewRouterConsumer(config, env, concurrency(stage))
+	if err != nil {
+		return nil, err
+	}
+	result := &consumerStage{}
+	result.bind(consumerChannel, router)
+	return result, nil
+}
+
+func buildIndexer(env *Env, stage StageSpec) (Stage, error) {
+	var status int64
+	switch stage.Options["status"] {
+	case "", "open":
+		status = dbModule.StatusOpen
+	case "unfunded":
+		status = dbModule.StatusUnfunded
+	default:
+		return nil, fmt.Errorf("Invalid value for option 'status': %v", stage.Options["status"])
+	}
+	db, err := env.DB(stage)
+	if err != nil {
+		return nil, err
+	}
+	batchSize := 1
+	if value := stage.Options["batchsize"]; value != "" {
+		if batchSize, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("Invalid value for option 'batchsize': %v", value)
+		}
+	}
+	batchWindow := 200 * time.Millisecond
+	if value := stage.Options["batchwindow"]; value != "" {
+		if batchWindow, err = time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("Invalid value for option 'batchwindow': %v", value)
+		}
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		if batchSize > 1 {
+			return channels.NewBatchingConsumer(dbModule.NewIndexBatchConsumer(db, status, concurrency(stage), publisher), batchSize, batchWindow), nil
+		}
+		return dbModule.NewIndexConsumer(db, status, concurrency(stage), publisher), nil
+	})
+}
+
+func buildFillIndexer(env *Env, stage StageSpec) (Stage, error) {
+	db, err := env.DB(stage)
+	if err != nil {
+		return nil, err
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		return dbModule.NewRecordFillConsumer(db, concurrency(stage), publisher), nil
+	})
+}
+
+func buildSpendRecorder(env *Env, stage StageSpec) (Stage, error) {
+	db, err := env.DB(stage)
+	if err != nil {
+		return nil, err
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		return dbModule.NewRecordSpendConsumer(db, concurrency(stage), publisher), nil
+	})
+}
+
+func buildWalletRevalidator(env *Env, stage StageSpec) (Stage, error) {
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	db, err := env.DB(stage)
+	if err != nil {
+		return nil, err
+	}
+	interval := int64(100)
+	if value := stage.Options["interval"]; value != "" {
+		if interval, err = strconv.ParseInt(value, 10, 64); err != nil || interval < 1 {
+			return nil, fmt.Errorf("Invalid value for option 'interval': %v", value)
+		}
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		return walletsig.NewRPCRevalidationBlockConsumer(db, rpcURL, interval, publisher)
+	})
+}
+
+func buildCancelUpToIndexer(env *Env, stage StageSpec) (Stage, error) {
+	db, err := env.DB(stage)
+	if err != nil {
+		return nil, err
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		return dbModule.NewRecordCancellationConsumer(db, concurrency(stage), publisher), nil
+	})
+}
+
+func buildMetadataIndexer(env *Env, stage StageSpec) (Stage, error) {
+	db, err := env.DB(stage)
+	if err != nil {
+		return nil, err
+	}
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		return metadata.NewOrderMetadataConsumer(rpcURL, db, concurrency(stage))
+	})
+}
+
+func buildBlockMonitor(env *Env, stage StageSpec) (Stage, error) {
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	redisClient, err := env.Redis()
+	if err != nil {
+		return nil, err
+	}
+	brbSize := 200
+	if value := stage.Options["buffer"]; value != "" {
+		if brbSize, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("Invalid value for option 'buffer': %v", value)
+		}
+	}
+	pollInterval := 3 * time.Second
+	if value := stage.Options["interval"]; value != "" {
+		if pollInterval, err = time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("Invalid value for option 'interval': %v", value)
+		}
+	}
+	dst := stage.Outputs[0]
+	publisher, err := env.Publisher(dst)
+	if err != nil {
+		return nil, err
+	}
+	blockRecorder := blocks.NewRedisBlockRecorder(redisClient, fmt.Sprintf("%v::blocknumber", strings.Split(dst, "://")[1]))
+	monitor, err := blocks.NewRPCBlockMonitor(rpcURL, publisher, pollInterval, blockRecorder, brbSize)
+	if err != nil {
+		return nil, err
+	}
+	return &blockMonitorStage{monitor}, nil
+}
+
+func buildFillMonitor(env *Env, stage StageSpec) (Stage, error) {
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	fillBloom, err := fillbloom.NewFillBloom(stage.Options["bloom"])
+	if err != nil {
+		return nil, err
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		return fill.NewRPCFillBlockConsumer(rpcURL, stage.Options["exchange"], publisher, fillBloom)
+	})
+}
+
+func buildSpendMonitor(env *Env, stage StageSpec) (Stage, error) {
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		return spend.NewRPCSpendBlockConsumer(rpcURL, stage.Options["exchange"], publisher)
+	})
+}
+
+func buildAllowanceMonitor(env *Env, stage StageSpec) (Stage, error) {
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		return allowance.NewRPCAllowanceBlockConsumer(rpcURL, stage.Options["exchange"], publisher)
+	})
+}
+
+func buildERC721ApprovalMonitor(env *Env, stage StageSpec) (Stage, error) {
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		return erc721approvals.NewRPCAllowanceBlockConsumer(rpcURL, stage.Options["exchange"], publisher)
+	})
+}
+
+func buildERC1155SpendMonitor(env *Env, stage StageSpec) (Stage, error) {
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		return erc1155.NewRPCTransferBlockConsumer(rpcURL, stage.Options["exchange"], publisher)
+	})
+}
+
+func buildERC1155ApprovalMonitor(env *Env, stage StageSpec) (Stage, error) {
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		return erc1155.NewRPCApprovalBlockConsumer(rpcURL, stage.Options["exchange"], publisher)
+	})
+}
+
+func buildValidatorMonitor(env *Env, stage StageSpec) (Stage, error) {
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	db, err := env.DB(stage)
+	if err != nil {
+		return nil, err
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		return validators.NewRPCApprovalBlockConsumer(rpcURL, stage.Options["exchange"], db, publisher)
+	})
+}
+
+func buildCancelUpToMonitor(env *Env, stage StageSpec) (Stage, error) {
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		return cancelupto.NewRPCCancelUpToBlockConsumer(rpcURL, stage.Options["exchange"], publisher)
+	})
+}
+
+func buildAffiliateMonitor(env *Env, stage StageSpec) (Stage, error) {
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	redisClient, err := env.Redis()
+	if err != nil {
+		return nil, err
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		return affiliate.NewRPCAffiliateBlockConsumer(rpcURL, stage.Options["signup"], redisClient)
+	})
+}
+
+func buildMultisigMonitor(env *Env, stage StageSpec) (Stage, error) {
+	rpcURL, err := env.RPC()
+	if err != nil {
+		return nil, err
+	}
+	return singleConsumerStage(env, stage, func(publisher channels.Publisher) (channels.Consumer, error) {
+		return multisig.NewRPCMultisigBlockConsumer(rpcURL, stage.Options["address"])
+	})
+}
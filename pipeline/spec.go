@@ -0,0 +1,161 @@
+// Package pipeline describes the services that make up an OpenRelay
+// deployment as a graph of stages connected by channels, so that any subset
+// of them can be validated and run together in a single process.
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DatabaseSpec holds the arguments to db.GetDB, for stages that need the
+// order database.
+type DatabaseSpec struct {
+	Connection string `json:"connection"`
+	Password   string `json:"password"`
+}
+
+// StageSpec describes one stage of a pipeline. Inputs are the channels the
+// stage consumes, and Outputs the channels it publishes to, both given as
+// channel URIs. Options holds settings specific to the stage's kind. If
+// Database is set, it is used instead of the pipeline's database, so that
+// stages can connect as users with only the permissions they need.
+type StageSpec struct {
+	Name        string            `json:"name"`
+	Kind        string            `json:"kind"`
+	Inputs      []string          `json:"inputs"`
+	Outputs     []string          `json:"outputs"`
+	Concurrency int               `json:"concurrency"`
+	Options     map[string]string `json:"options"`
+	Database    *DatabaseSpec     `json:"database"`
+}
+
+// Spec describes a pipeline. External lists channels that are published to
+// or consumed by services outside of the pipeline, such as the ingest API,
+// so that they aren't reported as dangling. pg:// channels are stored in
+// ChannelDatabase if it is set, or the pipeline's database otherwise.
+// SignatureValidators lists the validator contracts accepted for Validator
+// signatures.
+type Spec struct {
+	Redis               string        `json:"redis"`
+	RPC                 string        `json:"rpc"`
+	Database            DatabaseSpec  `json:"database"`
+	ChannelDatabase     *DatabaseSpec `json:"channelDatabase"`
+	SignatureValidators []string      `json:"signatureValidators"`
+	External            []string      `json:"external"`
+	Stages              []StageSpec   `json:"stages"`
+}
+
+// ParseSpec reads a pipeline spec from JSON. Environment variables
+// referenced as $VAR or ${VAR} are expanded before parsing.
+func ParseSpec(data []byte) (*Spec, error) {
+	spec := &Spec{}
+	if err := json.Unmarshal([]byte(os.ExpandEnv(string(data))), spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// LoadSpec reads a pipeline spec from a JSON file
+func LoadSpec(path string) (*Spec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSpec(data)
+}
+
+// Stage returns the stage called `name`
+func (spec *Spec) Stage(name string) (StageSpec, bool) {
+	for _, stage := range spec.Stages {
+		if stage.Name == name {
+			return stage, true
+		}
+	}
+	return StageSpec{}, false
+}
+
+// ValidationError lists every problem found with a spec
+type ValidationError struct {
+	Problems []string
+}
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("Invalid pipeline:\n  %v", strings.Join(err.Problems, "\n  "))
+}
+
+// channelKey identifies the channel a URI refers to, ignoring any options in
+// its query string.
+func channelKey(uri string) string {
+	return strings.SplitN(uri, "?", 2)[0]
+}
+
+// isBroadcast reports whether messages on `key` are delivered to whoever is
+// subscribed at the time, in which case it doesn't matter if nothing is.
+func isBroadcast(key string) bool {
+	return strings.HasPrefix(key, "topic://") || strings.HasPrefix(key, "mem+topic://") || strings.HasPrefix(key, "pg+topic://")
+}
+
+// Validate checks that every stage has a known kind with the inputs, outputs
+// and options it requires, and that the stages form a closed graph: every
+// channel consumed has something publishing to it, and every queue published
+// to has something consuming it, unless it is listed as external.
+func (spec *Spec) Validate() error {
+	problems := []string{}
+	names := make(map[string]bool)
+	producers := make(map[string][]string)
+	consumers := make(map[string][]string)
+	for i, stage := range spec.Stages {
+		label := stage.Name
+		if stage.Name == "" {
+			label = fmt.Sprintf("stage %v", i)
+			problems = append(problems, fmt.Sprintf("%v has no name", label))
+		} else if names[stage.Name] {
+			problems = append(problems, fmt.Sprintf("Stage name '%v' is used more than once", stage.Name))
+		}
+		names[stage.Name] = true
+		kind, ok := kinds[stage.Kind]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%v has unknown kind '%v'", label, stage.Kind))
+			continue
+		}
+		problems = append(problems, kind.check(label, stage)...)
+		for _, uri := range kind.consumes(stage) {
+			consumers[channelKey(uri)] = append(consumers[channelKey(uri)], label)
+		}
+		for _, uri := range kind.publishes(stage) {
+			producers[channelKey(uri)] = append(producers[channelKey(uri)], label)
+		}
+	}
+	external := make(map[string]bool)
+	for _, uri := range spec.External {
+		external[channelKey(uri)] = true
+	}
+	for _, key := range sortedKeys(consumers) {
+		if len(producers[key]) == 0 && !external[key] {
+			problems = append(problems, fmt.Sprintf("%v consumes %v, but nothing publishes to it", strings.Join(consumers[key], ", "), key))
+		}
+	}
+	for _, key := range sortedKeys(producers) {
+		if len(consumers[key]) == 0 && !external[key] && !isBroadcast(key) {
+			problems = append(problems, fmt.Sprintf("%v publishes to %v, but nothing consumes it", strings.Join(producers[key], ", "), key))
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{problems}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,182 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/pipeline"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func expectProblems(t *testing.T, spec *pipeline.Spec, expected ...string) {
+	err := spec.Validate()
+	if len(expected) == 0 {
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err.Error())
+		}
+		return
+	}
+	validationErr, ok := err.(*pipeline.ValidationError)
+	if !ok {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+	if len(validationErr.Problems) != len(expected) {
+		t.Errorf("Expected %v problems, got %v", len(expected), validationErr.Problems)
+	}
+	for _, problem := range expected {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected problem '%v' in '%v'", problem, err.Error())
+		}
+	}
+}
+
+func TestExamplePipeline(t *testing.T) {
+	spec, err := pipeline.LoadSpec("../pipeline.json")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectProblems(t, spec)
+}
+
+func TestValidateDangling(t *testing.T) {
+	spec, err := pipeline.ParseSpec([]byte(`{
+		"external": ["queue://ingest"],
+		"stages": [
+			{"name": "a", "kind": "relay", "inputs": ["queue://ingest"], "outputs": ["queue://b?maxattempts=3"]},
+			{"name": "b", "kind": "relay", "inputs": ["queue://b"], "outputs": ["queue://c", "topic://d", "pg+topic://f", "pg://g"]},
+			{"name": "e", "kind": "fundcheck", "inputs": ["queue://missing"], "outputs": ["topic://d"], "options": {"invalidation": "topic://blocks"}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectProblems(t, spec,
+		"b publishes to pg://g, but nothing consumes it",
+		"b publishes to queue://c, but nothing consumes it",
+		"e consumes queue://missing, but nothing publishes to it",
+		"e consumes topic://blocks, but nothing publishes to it",
+	)
+}
+
+func TestValidateStages(t *testing.T) {
+	spec, err := pipeline.ParseSpec([]byte(`{
+		"stages": [
+			{"name": "a", "kind": "unknown"},
+			{"name": "a", "kind": "blockmonitor", "inputs": ["queue://x"], "outputs": ["mem://y"], "options": {"intreval": "1s"}},
+			{"name": "m", "kind": "multisigmonitor", "inputs": ["mem://y"]},
+			{"kind": "relay", "inputs": ["mem://y"], "outputs": ["redis://z"]}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectProblems(t, spec,
+		"a has unknown kind 'unknown'",
+		"Stage name 'a' is used more than once",
+		"a needs 0 inputs, but has 1",
+		"a has unknown option 'intreval'",
+		"m is missing option 'address'",
+		"stage 3 has no name",
+		"stage 3 has invalid channel 'redis://z'",
+		"stage 3 publishes to redis://z, but nothing consumes it",
+		"a consumes queue://x, but nothing publishes to it",
+	)
+}
+
+func TestRelayStage(t *testing.T) {
+	suffix := time.Now().UnixNano()
+	spec, err := pipeline.ParseSpec([]byte(fmt.Sprintf(`{
+		"external": ["mem://source-%[1]v", "mem://dest-%[1]v"],
+		"stages": [
+			{"name": "relay", "kind": "relay", "inputs": ["mem://source-%[1]v"], "outputs": ["mem://dest-%[1]v"], "concurrency": 1}
+		]
+	}`, suffix)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectProblems(t, spec)
+	stage, err := pipeline.Build(pipeline.NewEnv(spec), spec.Stages[0])
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := stage.Start(); err != nil {
+		t.Fatal(err.Error())
+	}
+	destChannel := channels.NewMemQueueConsumerChannel(fmt.Sprintf("dest-%v", suffix))
+	defer destChannel.StopConsuming()
+	deliveries := make(chan channels.Delivery)
+	destChannel.AddConsumer(&deliveryConsumer{deliveries})
+	destChannel.StartConsuming()
+	channels.NewMemQueuePublisher(fmt.Sprintf("source-%v", suffix)).Publish("test")
+	select {
+	case delivery := <-deliveries:
+		if delivery.Payload() != "test" {
+			t.Errorf("Unexpected payload '%v'", delivery.Payload())
+		}
+		delivery.Ack()
+	case <-time.After(time.Second):
+		t.Errorf("Message was not relayed")
+	}
+	if returned := stage.Stop(context.Background()); returned != 0 {
+		t.Errorf("Expected no returned deliveries, got %v", returned)
+	}
+}
+
+func TestBuildRequiresRedis(t *testing.T) {
+	spec := &pipeline.Spec{}
+	stage := pipeline.StageSpec{Name: "relay", Kind: "relay", Inputs: []string{"queue://a"}, Outputs: []string{"queue://b"}}
+	if _, err := pipeline.Build(pipeline.NewEnv(spec), stage); err == nil {
+		t.Errorf("Expected an error building a redis stage without a redis address")
+	}
+}
+
+type deliveryConsumer struct {
+	deliveries chan channels.Delivery
+}
+
+func (consumer *deliveryConsumer) Consume(delivery channels.Delivery) {
+	consumer.deliveries <- delivery
+}
+
+func TestRouterStage(t *testing.T) {
+	routes, err := ioutil.TempFile("", "routes")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(routes.Name())
+	routes.Write([]byte(`{"routes": [{"match": {"assetProxyId": "0x02571792"}, "to": ["mem://nft"]}], "default": ["mem://other"]}`))
+	routes.Close()
+	stage := pipeline.StageSpec{
+		Name:    "router",
+		Kind:    "router",
+		Inputs:  []string{"mem://orders"},
+		Outputs: []string{"mem://nft"},
+		Options: map[string]string{"routes": routes.Name()},
+	}
+	env := pipeline.NewEnv(&pipeline.Spec{})
+	if _, err := pipeline.Build(env, stage); err == nil {
+		t.Errorf("Expected an error for a route destination missing from the outputs")
+	}
+	stage.Outputs = append(stage.Outputs, "mem://other")
+	if _, err := pipeline.Build(env, stage); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestExchangeSplitterStage(t *testing.T) {
+	stage := pipeline.StageSpec{
+		Name:    "splitter",
+		Kind:    "exchangesplitter",
+		Inputs:  []string{"mem://orders"},
+		Options: map[string]string{"suffix": "fundcheck"},
+	}
+	// Outputs are created through the environment, so building the stage
+	// shouldn't need redis unless its channels do.
+	if _, err := pipeline.Build(pipeline.NewEnv(&pipeline.Spec{}), stage); err != nil {
+		t.Error(err.Error())
+	}
+}
@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/monitor/blocks"
+	"log"
+)
+
+// Stage is a running piece of a pipeline
+type Stage interface {
+	// Start begins processing messages
+	Start() error
+	// Stop stops taking on new messages and waits until `ctx` is done for
+	// messages in progress to finish. It returns the number of deliveries that
+	// had to be returned to their channels.
+	Stop(ctx context.Context) int
+}
+
+// Build constructs the stage described by `stage`
+func Build(env *Env, stage StageSpec) (Stage, error) {
+	kind, ok := kinds[stage.Kind]
+	if !ok {
+		return nil, fmt.Errorf("Unknown stage kind '%v'", stage.Kind)
+	}
+	return kind.build(env, stage)
+}
+
+// binding attaches a consumer to a channel. If the consumer is a Drainer it
+// is drained when the stage stops.
+type binding struct {
+	channel  channels.ConsumerChannel
+	consumer channels.Consumer
+}
+
+// consumerStage runs a set of relays and consumers, which between them cover
+// all of the services that process messages from channels.
+type consumerStage struct {
+	relays   []*channels.Relay
+	bindings []binding
+}
+
+// bind attaches `consumer` to `channel`, tracking its deliveries so that they
+// can be drained on shutdown.
+func (stage *consumerStage) bind(channel channels.ConsumerChannel, consumer channels.Consumer) {
+	stage.bindings = append(stage.bindings, binding{channel, channels.NewDrainingConsumer(consumer)})
+}
+
+// bindUndrained attaches `consumer` to `channel` without tracking its
+// deliveries, for consumers that settle each delivery as soon as they get it.
+func (stage *consumerStage) bindUndrained(channel channels.ConsumerChannel, consumer channels.Consumer) {
+	stage.bindings = append(stage.bindings, binding{channel, consumer})
+}
+
+func (stage *consumerStage) Start() error {
+	for _, relay := range stage.relays {
+		relay.Start()
+	}
+	for _, binding := range stage.bindings {
+		binding.channel.AddConsumer(binding.consumer)
+		binding.channel.StartConsuming()
+	}
+	return nil
+}
+
+func (stage *consumerStage) Stop(ctx context.Context) int {
+	for _, binding := range stage.bindings {
+		binding.channel.StopConsuming()
+	}
+	for _, relay := range stage.relays {
+		relay.Stop()
+	}
+	returned := 0
+	for _, binding := range stage.bindings {
+		if drainer, ok := binding.consumer.(channels.Drainer); ok {
+			returned += drainer.Drain(ctx)
+		}
+	}
+	for _, relay := range stage.relays {
+		returned += relay.Drain(ctx)
+	}
+	return returned
+}
+
+// blockMonitorStage polls for new blocks. It has no deliveries to drain.
+type blockMonitorStage struct {
+	monitor *blocks.BlockMonitor
+}
+
+func (stage *blockMonitorStage) Start() error {
+	go func() {
+		if err := stage.monitor.Process(); err != nil {
+			log.Fatalf("Processing error: %v", err.Error())
+		}
+	}()
+	return nil
+}
+
+func (stage *blockMonitorStage) Stop(ctx context.Context) int {
+	stage.monitor.Stop()
+	return 0
+}
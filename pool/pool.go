@@ -29,6 +29,9 @@ type Pool struct {
 	Limit           uint
 	SenderAddresses types.NetworkAddressMap
 	FilterAddresses types.NetworkAddressMap
+	// SignatureTypes lists the signature types accepted by this pool in
+	// addition to EIP712 and EthSign, such as types.SigTypeWallet
+	SignatureTypes  []byte
 	conn            bind.ContractCaller
 	baseFee         config.BaseFee
 }
@@ -100,7 +103,7 @@ func (pool Pool) QueryString() string {
 	return pool.SearchTerms
 }
 
-var poolRegex = regexp.MustCompile("^(/[^/]*)?/v2/")
+var poolRegex = regexp.MustCompile("^(/[^/]*)?/(?:v2|sra/v3)/")
 
 func PoolDecorator(db *gorm.DB, fn func(http.ResponseWriter, *http.Request, types.Pool)) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
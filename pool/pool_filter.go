@@ -0,0 +1,57 @@
+package pool
+
+import (
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/types"
+	"log"
+)
+
+// PoolFilter is a RelayFilter that passes orders which satisfy the filter
+// contract of the pool they were submitted to.
+type PoolFilter struct {
+	db        *gorm.DB
+	conn      bind.ContractCaller
+	networkID uint
+	poolCache map[string]*Pool
+}
+
+// NewPoolFilter returns a PoolFilter that looks pools up in `db` and calls
+// their filter contracts through `conn`
+func NewPoolFilter(db *gorm.DB, conn bind.ContractCaller, networkID uint) *PoolFilter {
+	return &PoolFilter{db, conn, networkID, make(map[string]*Pool)}
+}
+
+func (filter *PoolFilter) Filter(delivery channels.Delivery) bool {
+	order, err := types.OrderFromBytes([]byte(delivery.Payload()))
+	if err != nil {
+		log.Printf("Invalid order format: %#x", delivery.Payload())
+		return false
+	}
+	if valid, err := order.Signature.VerifyExchange(order.ExchangeAddress, order.Maker, order.Hash()); err != nil {
+		log.Printf("Error verifying signature of order %#x: %v", order.Hash(), err.Error())
+		delivery.Return()
+		return false
+	} else if !valid {
+		log.Printf("Invalid order signature")
+		return false
+	}
+	pool, ok := filter.poolCache[fmt.Sprintf("%#x", order.PoolID)]
+	if !ok {
+		pool = &Pool{}
+		if err := filter.db.Model(&Pool{}).Where("ID = ?", order.PoolID).First(pool).Error; err != nil {
+			log.Fatalf("Error getting pool: %#x - Error: %v", order.PoolID, err.Error())
+		}
+		pool.SetConn(filter.conn)
+		filter.poolCache[fmt.Sprintf("%#x", order.PoolID)] = pool
+	}
+	valid, err := pool.CheckFilter(order, filter.networkID)
+	if err != nil {
+		delivery.Return()
+		log.Fatalf("Error filtering order: %v", err.Error())
+	}
+	log.Printf("Order %#x is %v valid for target pool %#x (correlation id %v)", order.Hash(), valid, pool.ID, delivery.Headers()[channels.HeaderCorrelationID])
+	return valid
+}
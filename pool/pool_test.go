@@ -103,6 +103,9 @@ func TestPoolFilter(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	if err := tx.AutoMigrate(&dbModule.Exchange{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
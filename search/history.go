@@ -0,0 +1,90 @@
+package search
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	dbModule "github.com/notegio/openrelay/db"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// HistoryEvent is a change in an order's status, as returned by the order
+// history API. OldStatus is omitted for the event recording the order being
+// indexed.
+type HistoryEvent struct {
+	Timestamp              time.Time `json:"timestamp"`
+	OldStatus              string    `json:"oldStatus,omitempty"`
+	NewStatus              string    `json:"newStatus"`
+	TakerAssetAmountFilled string    `json:"takerAssetAmountFilled"`
+	Source                 string    `json:"source"`
+	BlockNumber            uint64    `json:"blockNumber,omitempty"`
+}
+
+type historyResponse struct {
+	OrderHash string         `json:"orderHash"`
+	History   []HistoryEvent `json:"history"`
+}
+
+// OrderHistoryHandler serves the status changes recorded for an order, so
+// makers can see why an order left the order book.
+func OrderHistoryHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request) {
+	historyRegex := regexp.MustCompile(".*/order/0x([0-9a-fA-F]+)/history$")
+	return func(w http.ResponseWriter, r *http.Request) {
+		pathMatch := historyRegex.FindStringSubmatch(r.URL.Path)
+		if len(pathMatch) == 0 {
+			returnError(w, errors.New("Malformed order hash"), 404)
+			return
+		}
+		hashBytes, err := hex.DecodeString(pathMatch[1])
+		if err != nil {
+			returnError(w, err, 400)
+			return
+		}
+		events, err := dbModule.OrderHistory(db, hashBytes)
+		if err != nil {
+			returnError(w, err, 500)
+			return
+		}
+		if len(events) == 0 {
+			// Orders indexed before history was recorded have no events
+			count := 0
+			if err := db.Model(&dbModule.Order{}).Where("order_hash = ?", hashBytes).Count(&count).Error; err != nil {
+				returnError(w, err, 500)
+				return
+			}
+			if count == 0 {
+				returnError(w, errors.New("record not found"), 404)
+				return
+			}
+		}
+		response := &historyResponse{fmt.Sprintf("%#x", hashBytes), []HistoryEvent{}}
+		for _, event := range events {
+			historyEvent := HistoryEvent{
+				Timestamp:              event.CreatedAt.UTC(),
+				NewStatus:              dbModule.StatusName(event.NewStatus),
+				TakerAssetAmountFilled: "0",
+				Source:                 event.Source,
+				BlockNumber:            event.BlockNumber,
+			}
+			if event.OldStatus != nil {
+				historyEvent.OldStatus = dbModule.StatusName(*event.OldStatus)
+			}
+			if event.TakerAssetAmountFilled != nil {
+				historyEvent.TakerAssetAmountFilled = event.TakerAssetAmountFilled.Big().String()
+			}
+			response.History = append(response.History, historyEvent)
+		}
+		data, err := json.Marshal(response)
+		if err != nil {
+			returnError(w, err, 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write(data)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/common"
 	dbModule "github.com/notegio/openrelay/db"
 	"net/http"
 	"regexp"
@@ -25,7 +26,7 @@ func OrderHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request) {
 			return
 		}
 		order := &dbModule.Order{}
-		query := db.Model(&dbModule.Order{}).Where("order_hash = ?", hashBytes).First(order)
+		query := dbModule.FilterByVersion(db.Model(&dbModule.Order{}), common.RequestVersion(r)).Where("order_hash = ?", hashBytes).First(order)
 		if query.Error != nil {
 			if query.Error.Error() == "record not found" {
 				returnError(w, query.Error, 404)
@@ -39,7 +39,12 @@ func OrderBookHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request, type
 			errs = append(errs, ValidationError{err.Error(), 1001, "quoteAssetData"})
 		}
 		currentTime := getExpTime(queryObject)
-		baseQuery, err := pool.Filter(db.Model(&dbModule.Order{}).Where("status = ?", dbModule.StatusOpen).Where("expiration_timestamp_in_sec > ?", currentTime))
+		version := common.RequestVersion(r)
+		versionQuery := dbModule.FilterByVersion(db.Model(&dbModule.Order{}), version)
+		if version == types.OrderVersion3 {
+			versionQuery = versionQuery.Where("chain_id = ?", getNetworkID(queryObject, version))
+		}
+		baseQuery, err := pool.Filter(versionQuery.Where("status = ?", dbModule.StatusOpen).Where("expiration_timestamp_in_sec > ?", currentTime))
 		if err != nil {
 			errs = append(errs, ValidationError{err.Error(), 1001, "pool"})
 		}
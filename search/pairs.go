@@ -6,7 +6,6 @@ import (
 	"github.com/notegio/openrelay/common"
 	dbModule "github.com/notegio/openrelay/db"
 	"net/http"
-	"strconv"
 )
 
 func PairHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request) {
@@ -14,10 +13,8 @@ func PairHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request) {
 		queryObject := r.URL.Query()
 		tokenAString := queryObject.Get("assetDataA")
 		tokenBString := queryObject.Get("assetDataB")
-		networkID, err := strconv.Atoi(queryObject.Get("networkId"))
-		if err != nil {
-			networkID = 1
-		}
+		version := common.RequestVersion(r)
+		networkID := getNetworkID(queryObject, version)
 		if tokenAString == "" && tokenBString != "" {
 			tokenAString, tokenBString = tokenBString, ""
 		}
@@ -30,7 +27,7 @@ func PairHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request) {
 		var pairs []dbModule.Pair
 		var count int
 		if tokenAString == "" {
-			pairs, count, err = dbModule.GetAllTokenPairs(db, offset, perPageInt, networkID)
+			pairs, count, err = dbModule.GetAllTokenPairs(db, offset, perPageInt, networkID, version)
 			if err != nil {
 				returnError(w, err, 400)
 				return
@@ -42,14 +39,14 @@ func PairHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request) {
 				return
 			}
 			if tokenBString == "" {
-				pairs, count, err = dbModule.GetTokenAPairs(db, assetDataA, offset, perPageInt, networkID)
+				pairs, count, err = dbModule.GetTokenAPairs(db, assetDataA, offset, perPageInt, networkID, version)
 			} else {
 				assetDataB, err := common.HexToAssetData(tokenBString)
 				if err != nil {
 					returnError(w, err, 400)
 					return
 				}
-				pairs, count, err = dbModule.GetTokenABPairs(db, assetDataA, assetDataB, networkID)
+				pairs, count, err = dbModule.GetTokenABPairs(db, assetDataA, assetDataB, networkID, version)
 			}
 			if err != nil {
 				returnError(w, err, 400)
@@ -22,7 +22,7 @@ func FormatResponse(orders []dbModule.Order, format string, total, page, perPage
 	if format == "application/octet-stream" {
 		result := []byte{}
 		for _, order := range orders {
-			orderBytes := order.Bytes()
+			orderBytes := order.RLPBytes()
 			result = append(result, orderBytes[:]...)
 		}
 		return result, "application/octet-stream", nil
@@ -38,7 +38,7 @@ func FormatResponse(orders []dbModule.Order, format string, total, page, perPage
 
 func FormatSingleResponse(order *dbModule.Order, format string) ([]byte, string, error) {
 	if format == "application/octet-stream" {
-		result := order.Bytes()
+		result := order.RLPBytes()
 		return result[:], "application/octet-stream", nil
 	}
 	result, err := json.Marshal(GetFormattedOrder(*order))
@@ -58,14 +58,21 @@ func applyAddressFilter(query *gorm.DB, queryField, dbField string, queryObject
 	return query, nil
 }
 
+// componentClause matches rows where `field` equals the first argument, or
+// `dataField` holds MultiAssetProxy asset data (the second argument) that
+// contains the third argument, so that filters on an asset also find
+// bundles that include it.
+func componentClause(field, dataField string) string {
+	return fmt.Sprintf("(%v = ? or (POSITION(? IN %v) = 1 AND POSITION(? IN %v) > 0))", field, dataField, dataField)
+}
+
 func applyAssetDataFilter(query *gorm.DB, queryField, dbField string, queryObject urlModule.Values) (*gorm.DB, error) {
 	if assetData := queryObject.Get(queryField); assetData != "" {
 		assetDataBytes, err := common.HexToAssetData(assetData)
 		if err != nil {
 			return query, err
 		}
-		whereClause := fmt.Sprintf("%v = ?", dbField)
-		filteredQuery := query.Where(whereClause, &assetDataBytes)
+		filteredQuery := query.Where(componentClause(dbField, dbField), &assetDataBytes, types.MultiAssetProxyID[:], []byte(assetDataBytes))
 		return filteredQuery, filteredQuery.Error
 	}
 	return query, nil
@@ -76,8 +83,32 @@ func applyAssetDataOrFilter(query *gorm.DB, queryField, dbField1, dbField2 strin
 		if err != nil {
 			return query, err
 		}
-		whereClause := fmt.Sprintf("%v = ? or %v = ?", dbField1, dbField2)
-		filteredQuery := query.Where(whereClause, &assetDataBytes, &assetDataBytes)
+		whereClause := fmt.Sprintf("%v or %v", componentClause(dbField1, dbField1), componentClause(dbField2, dbField2))
+		filteredQuery := query.Where(
+			whereClause,
+			&assetDataBytes, types.MultiAssetProxyID[:], []byte(assetDataBytes),
+			&assetDataBytes, types.MultiAssetProxyID[:], []byte(assetDataBytes),
+		)
+		return filteredQuery, filteredQuery.Error
+	}
+	return query, nil
+}
+
+// applyAssetAddressFilter filters on the token address of an asset, matching
+// bundles that include the token as well as orders for the token itself.
+func applyAssetAddressFilter(query *gorm.DB, queryField string, addressFields, dataFields []string, queryObject urlModule.Values) (*gorm.DB, error) {
+	if address := queryObject.Get(queryField); address != "" {
+		addressBytes, err := common.HexToBytes(address)
+		if err != nil {
+			return query, err
+		}
+		clauses := []string{}
+		args := []interface{}{}
+		for i := range addressFields {
+			clauses = append(clauses, componentClause(addressFields[i], dataFields[i]))
+			args = append(args, common.BytesToOrAddress(addressBytes), types.MultiAssetProxyID[:], addressBytes[:])
+		}
+		filteredQuery := query.Where(strings.Join(clauses, " or "), args...)
 		return filteredQuery, filteredQuery.Error
 	}
 	return query, nil
@@ -209,10 +240,11 @@ func BlockHashDecorator(blockHash blockhash.BlockHash, fn func(http.ResponseWrit
 	}
 }
 
-func filterByNetworkId(query *gorm.DB, queryObject urlModule.Values, exchangeLookup *dbModule.ExchangeLookup) (*gorm.DB, error) {
-	networkID, err := strconv.Atoi(queryObject.Get("networkId"))
-	if err != nil {
-		networkID = 1
+func filterByNetworkId(query *gorm.DB, queryObject urlModule.Values, exchangeLookup *dbModule.ExchangeLookup, version uint8) (*gorm.DB, error) {
+	networkID := getNetworkID(queryObject, version)
+	query = dbModule.FilterByVersion(query, version)
+	if version == types.OrderVersion3 {
+		query = query.Where("chain_id = ?", networkID)
 	}
 	exchanges, err := exchangeLookup.GetExchangesByNetwork(int64(networkID))
 	if err != nil {
@@ -245,11 +277,11 @@ func QueryFilter(query *gorm.DB, queryObject urlModule.Values) (*gorm.DB, []Vali
 	if err != nil {
 		errs = append(errs, ValidationError{err.Error(), 1003, "exchangeContractAddress"})
 	}
-	query, err = applyAddressFilter(query, "makerAssetAddress", "maker_asset_address", queryObject)
+	query, err = applyAssetAddressFilter(query, "makerAssetAddress", []string{"maker_asset_address"}, []string{"maker_asset_data"}, queryObject)
 	if err != nil {
 		errs = append(errs, ValidationError{err.Error(), 1003, "makerAssetAddress"})
 	}
-	query, err = applyAddressFilter(query, "takerAssetAddress", "taker_asset_address", queryObject)
+	query, err = applyAssetAddressFilter(query, "takerAssetAddress", []string{"taker_asset_address"}, []string{"taker_asset_data"}, queryObject)
 	if err != nil {
 		errs = append(errs, ValidationError{err.Error(), 1003, "takerAssetAddress"})
 	}
@@ -281,7 +313,7 @@ func QueryFilter(query *gorm.DB, queryObject urlModule.Values) (*gorm.DB, []Vali
 	if err != nil {
 		errs = append(errs, ValidationError{err.Error(), 1003, "takerAssetProxyId"})
 	}
-	query, err = applyOrFilter(query, "assetAddress", "maker_asset_address", "taker_asset_address", queryObject)
+	query, err = applyAssetAddressFilter(query, "assetAddress", []string{"maker_asset_address", "taker_asset_address"}, []string{"maker_asset_data", "taker_asset_data"}, queryObject)
 	if err != nil {
 		errs = append(errs, ValidationError{err.Error(), 1003, "assetAddress"})
 	}
@@ -326,9 +358,10 @@ func SearchHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request, types.P
 			return
 		}
 
-		query, err = filterByNetworkId(query, queryObject, exchangeLookup)
+		version := common.RequestVersion(r)
+		query, err = filterByNetworkId(query, queryObject, exchangeLookup, version)
 		if err != nil {
-			errs = append(errs, ValidationError{err.Error(), 1006, "networkId"})
+			errs = append(errs, ValidationError{err.Error(), 1006, networkParam(version)})
 		}
 
 		pageInt, perPageInt, err := getPages(queryObject)
@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"encoding/json"
 	"os"
+	"strings"
 	// "reflect"
 	"testing"
 	// "log"
@@ -269,6 +270,9 @@ func filterContractRequest(queryString, emptyQueryString string, t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	if err := tx.AutoMigrate(&dbModule.AssetMetadata{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
@@ -369,6 +373,48 @@ func TestFilterTakerFee(t *testing.T) {
 	filterContractRequest("_takerFee=0", "_takerFee=1000", t)
 }
 
+func TestSearchVersionRoutes(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Error(err.Error())
+	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
+	if err := tx.AutoMigrate(&dbModule.Exchange{}).Error; err != nil {
+		t.Error(err.Error())
+	}
+	sampleAddress, _ := common.HexToAddress("0x90fe2af704b34e0224bf2299c838e04d4dcf1364")
+	tx.Where(
+		&dbModule.Exchange{Network: 1},
+	).FirstOrCreate(&dbModule.Exchange{Network: 1, Address: sampleAddress })
+	if err := sampleOrder(t).Save(tx, 0, nil).Error; err != nil {
+		t.Fatal(err.Error())
+	}
+	handler := getTestSearchHandler(tx)
+	// The sample order is a v2 order, so it should only be served by v2 routes
+	for path, expectedTotal := range map[string]string{"/v2/orders": "\"total\":1,", "/sra/v3/orders": "\"total\":0,"} {
+		request, _ := http.NewRequest("GET", path+"?blockhash=x&_expTime=0", nil)
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		if recorder.Code != 200 {
+			t.Errorf("Unexpected response code '%v'", recorder.Code)
+		}
+		if !strings.Contains(recorder.Body.String(), expectedTotal) {
+			t.Errorf("%v: Got '%v'", path, recorder.Body.String())
+		}
+	}
+}
+
 func TestPagination(t *testing.T) {
 	db, err := getDb()
 	if err != nil {
@@ -383,6 +429,9 @@ func TestPagination(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	if err := tx.AutoMigrate(&dbModule.AssetMetadata{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
@@ -456,6 +505,9 @@ func TestOrderLookup(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	if err := tx.AutoMigrate(&dbModule.AssetMetadata{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
@@ -503,6 +555,9 @@ func TestPairLookup(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	if err := tx.AutoMigrate(&dbModule.AssetMetadata{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
@@ -548,6 +603,9 @@ func TestOrderBookLookup(t *testing.T) {
 	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
 	if err := tx.AutoMigrate(&dbModule.AssetMetadata{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
@@ -580,3 +638,42 @@ func TestOrderBookLookup(t *testing.T) {
 		t.Errorf("Got '%v'", string(response))
 	}
 }
+
+func TestOrderHistory(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Error(err.Error())
+	}
+	if err := tx.AutoMigrate(&dbModule.OrderEvent{}).Error; err != nil {
+		t.Error(err.Error())
+	}
+	order := sampleOrder(t)
+	if err := order.Save(tx, dbModule.StatusOpen, nil).Error; err != nil {
+		t.Fatal(err.Error())
+	}
+	handler := search.OrderHistoryHandler(tx)
+	request, _ := http.NewRequest("GET", fmt.Sprintf("/v2/order/%#x/history", order.Hash()), nil)
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	if recorder.Code != 200 {
+		t.Fatalf("Unexpected response code '%v'", recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), "\"newStatus\":\"open\",\"takerAssetAmountFilled\":\"0\",\"source\":\"fundcheck\"") {
+		t.Errorf("Unexpected history: %v", recorder.Body.String())
+	}
+	request, _ = http.NewRequest("GET", "/v2/order/0x0000000000000000000000000000000000000000000000000000000000000000/history", nil)
+	recorder = httptest.NewRecorder()
+	handler(recorder, request)
+	if recorder.Code != 404 {
+		t.Errorf("Expected 404 for unknown order, got '%v'", recorder.Code)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/notegio/openrelay/types"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	urlModule "net/url"
+	"strconv"
 	"time"
 )
 
@@ -23,3 +24,20 @@ func getExpTime(queryObject urlModule.Values) (*types.Uint256) {
 	copy(currentTimeBytes[:], abi.U256(currentTime))
 	return currentTimeBytes
 }
+
+// networkParam returns the name of the query parameter identifying the
+// network. SRA v3 replaced networkId with chainId.
+func networkParam(version uint8) string {
+	if version == types.OrderVersion3 {
+		return "chainId"
+	}
+	return "networkId"
+}
+
+func getNetworkID(queryObject urlModule.Values, version uint8) int {
+	networkID, err := strconv.Atoi(queryObject.Get(networkParam(version)))
+	if err != nil {
+		return 1
+	}
+	return networkID
+}
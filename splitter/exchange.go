@@ -39,7 +39,7 @@ func (consumer *AddressSplitterConsumer) Consume(delivery channels.Delivery) {
 			delivery.Reject()
 			return
 		}
-		publisher.Publish(payload)
+		channels.Republish(publisher, delivery)
 		delivery.Ack()
 	}()
 }
@@ -0,0 +1,395 @@
+package splitter
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/common"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/types"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"strings"
+)
+
+// RouteMatch describes the orders a route applies to. Every field that is
+// set must match for the route to apply, and fields that are left empty
+// match any order. Addresses, asset data and pool IDs are hex strings, and
+// amounts are decimal strings in base units.
+type RouteMatch struct {
+	MakerAssetProxyID   string `json:"makerAssetProxyId"`
+	TakerAssetProxyID   string `json:"takerAssetProxyId"`
+	AssetProxyID        string `json:"assetProxyId"`
+	MakerAssetAddress   string `json:"makerAssetAddress"`
+	TakerAssetAddress   string `json:"takerAssetAddress"`
+	AssetAddress        string `json:"assetAddress"`
+	ExchangeAddress     string `json:"exchangeAddress"`
+	MakerAddress        string `json:"makerAddress"`
+	FeeRecipientAddress string `json:"feeRecipientAddress"`
+	SenderAddress       string `json:"senderAddress"`
+	PoolID              string `json:"poolId"`
+	PoolName            string `json:"poolName"`
+	MinMakerAssetAmount string `json:"minMakerAssetAmount"`
+	MaxMakerAssetAmount string `json:"maxMakerAssetAmount"`
+	MinTakerAssetAmount string `json:"minTakerAssetAmount"`
+	MaxTakerAssetAmount string `json:"maxTakerAssetAmount"`
+}
+
+// Route sends the orders it matches to each of the channels in To. A
+// destination may include {exchangeAddress} or {makerAddress}, which are
+// replaced with the order's address, so that orders can be split by address
+// the way the exchange and maker splitters do.
+type Route struct {
+	Name  string     `json:"name"`
+	Match RouteMatch `json:"match"`
+	To    []string   `json:"to"`
+}
+
+// RouterConfig is an ordered list of routes. Each order is sent to the
+// destinations of the first route that matches it, or to Default if none do.
+// Orders that match no route and have no default are rejected.
+type RouterConfig struct {
+	Routes  []Route  `json:"routes"`
+	Default []string `json:"default"`
+}
+
+// ParseRouterConfig reads a RouterConfig from JSON
+func ParseRouterConfig(data []byte) (*RouterConfig, error) {
+	config := &RouterConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// LoadRouterConfig reads a RouterConfig from a JSON file
+func LoadRouterConfig(path string) (*RouterConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRouterConfig(data)
+}
+
+// Destinations returns every destination in the config, with any templates
+// left unexpanded
+func (config *RouterConfig) Destinations() []string {
+	destinations := []string{}
+	for _, route := range config.Routes {
+		destinations = append(destinations, route.To...)
+	}
+	return append(destinations, config.Default...)
+}
+
+// IsTemplate returns true if `destination` depends on the order being routed
+func IsTemplate(destination string) bool {
+	return strings.Contains(destination, "{")
+}
+
+func hexBytes(value string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(value, "0x"))
+}
+
+func proxyID(value string) ([4]byte, error) {
+	result := [4]byte{}
+	raw, err := hexBytes(value)
+	if err != nil {
+		return result, err
+	}
+	if len(raw) != 4 {
+		return result, fmt.Errorf("Asset proxy ID must be 4 bytes: %v", value)
+	}
+	copy(result[:], raw)
+	return result, nil
+}
+
+func amount(value string) (*big.Int, error) {
+	result, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return nil, fmt.Errorf("Invalid amount: %v", value)
+	}
+	return result, nil
+}
+
+func addressesEqual(a, b *types.Address) bool {
+	return a != nil && b != nil && bytes.Equal(a[:], b[:])
+}
+
+// orderPoolID returns the pool an order was submitted to, treating orders
+// without a pool as belonging to the default pool, as the indexer does.
+func orderPoolID(order *types.Order) []byte {
+	if len(order.PoolID) == 0 {
+		return dbModule.DefaultSha3()
+	}
+	return order.PoolID
+}
+
+// compile produces a function that tests whether an order matches. Like
+// OrderFilter.GetFilter, only the fields that are set are checked.
+func (match *RouteMatch) compile() (func(*types.Order) bool, error) {
+	predicates := []func(*types.Order) bool{}
+	for _, check := range []struct {
+		value  string
+		assets func(*types.Order) []types.AssetData
+	}{
+		{match.MakerAssetProxyID, func(order *types.Order) []types.AssetData { return []types.AssetData{order.MakerAssetData} }},
+		{match.TakerAssetProxyID, func(order *types.Order) []types.AssetData { return []types.AssetData{order.TakerAssetData} }},
+		{match.AssetProxyID, func(order *types.Order) []types.AssetData {
+			return []types.AssetData{order.MakerAssetData, order.TakerAssetData}
+		}},
+	} {
+		if check.value == "" {
+			continue
+		}
+		id, err := proxyID(check.value)
+		if err != nil {
+			return nil, err
+		}
+		assets := check.assets
+		predicates = append(predicates, func(order *types.Order) bool {
+			for _, asset := range assets(order) {
+				if len(asset) >= 4 && asset.IsType(id) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	for _, check := range []struct {
+		value     string
+		addresses func(*types.Order) []*types.Address
+	}{
+		{match.MakerAssetAddress, func(order *types.Order) []*types.Address { return []*types.Address{order.MakerAssetAddress} }},
+		{match.TakerAssetAddress, func(order *types.Order) []*types.Address { return []*types.Address{order.TakerAssetAddress} }},
+		{match.AssetAddress, func(order *types.Order) []*types.Address {
+			return []*types.Address{order.MakerAssetAddress, order.TakerAssetAddress}
+		}},
+		{match.ExchangeAddress, func(order *types.Order) []*types.Address { return []*types.Address{order.ExchangeAddress} }},
+		{match.MakerAddress, func(order *types.Order) []*types.Address { return []*types.Address{order.Maker} }},
+		{match.FeeRecipientAddress, func(order *types.Order) []*types.Address { return []*types.Address{order.FeeRecipient} }},
+		{match.SenderAddress, func(order *types.Order) []*types.Address { return []*types.Address{order.SenderAddress} }},
+	} {
+		if check.value == "" {
+			continue
+		}
+		address, err := common.HexToAddress(check.value)
+		if err != nil {
+			return nil, err
+		}
+		addresses := check.addresses
+		predicates = append(predicates, func(order *types.Order) bool {
+			for _, candidate := range addresses(order) {
+				if addressesEqual(candidate, address) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	if match.PoolID != "" {
+		poolID, err := hexBytes(match.PoolID)
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, func(order *types.Order) bool { return bytes.Equal(orderPoolID(order), poolID) })
+	}
+	if match.PoolName != "" {
+		dataSha := sha3.NewKeccak256()
+		dataSha.Write([]byte(match.PoolName))
+		poolID := dataSha.Sum(nil)
+		predicates = append(predicates, func(order *types.Order) bool { return bytes.Equal(orderPoolID(order), poolID) })
+	}
+	for _, check := range []struct {
+		value  string
+		min    bool
+		amount func(*types.Order) *types.Uint256
+	}{
+		{match.MinMakerAssetAmount, true, func(order *types.Order) *types.Uint256 { return order.MakerAssetAmount }},
+		{match.MaxMakerAssetAmount, false, func(order *types.Order) *types.Uint256 { return order.MakerAssetAmount }},
+		{match.MinTakerAssetAmount, true, func(order *types.Order) *types.Uint256 { return order.TakerAssetAmount }},
+		{match.MaxTakerAssetAmount, false, func(order *types.Order) *types.Uint256 { return order.TakerAssetAmount }},
+	} {
+		if check.value == "" {
+			continue
+		}
+		threshold, err := amount(check.value)
+		if err != nil {
+			return nil, err
+		}
+		min, getAmount := check.min, check.amount
+		predicates = append(predicates, func(order *types.Order) bool {
+			value := getAmount(order)
+			if value == nil {
+				return false
+			}
+			comparison := value.Big().Cmp(threshold)
+			if min {
+				return comparison >= 0
+			}
+			return comparison <= 0
+		})
+	}
+	return func(order *types.Order) bool {
+		for _, predicate := range predicates {
+			if !predicate(order) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// compiledRoute is a Route ready to test orders against
+type compiledRoute struct {
+	name    string
+	matches func(*types.Order) bool
+	to      []string
+}
+
+// Router picks the destinations for orders according to a RouterConfig
+type Router struct {
+	routes     []compiledRoute
+	defaultTo  []string
+	translator channels.URITranslator
+	publishers map[string]channels.Publisher
+}
+
+// NewRouter compiles `config`, returning an error if any of its routes are
+// invalid or any destination that doesn't depend on the order is not a
+// valid channel URI.
+func NewRouter(config *RouterConfig, translator channels.URITranslator) (*Router, error) {
+	router := &Router{
+		defaultTo:  config.Default,
+		translator: translator,
+		publishers: make(map[string]channels.Publisher),
+	}
+	for i, route := range config.Routes {
+		name := route.Name
+		if name == "" {
+			name = fmt.Sprintf("route %v", i)
+		}
+		if len(route.To) == 0 {
+			return nil, fmt.Errorf("%v has no destinations", name)
+		}
+		matches, err := route.Match.compile()
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", name, err.Error())
+		}
+		router.routes = append(router.routes, compiledRoute{name, matches, route.To})
+	}
+	for _, destination := range config.Destinations() {
+		if IsTemplate(destination) {
+			continue
+		}
+		if _, ok := router.publishers[destination]; ok {
+			continue
+		}
+		publisher, err := translator.PublisherFromURI(destination)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid destination '%v': %v", destination, err.Error())
+		}
+		router.publishers[destination] = publisher
+	}
+	return router, nil
+}
+
+// Route returns the name of the route `order` matched, or "default", and the
+// destinations it should be sent to, with templates expanded.
+func (router *Router) Route(order *types.Order) (string, []string) {
+	name, destinations := "default", router.defaultTo
+	for _, route := range router.routes {
+		if route.matches(order) {
+			name, destinations = route.name, route.to
+			break
+		}
+	}
+	expanded := make([]string, len(destinations))
+	for i, destination := range destinations {
+		expanded[i] = expandDestination(destination, order)
+	}
+	return name, expanded
+}
+
+func expandDestination(destination string, order *types.Order) string {
+	if !IsTemplate(destination) {
+		return destination
+	}
+	return strings.NewReplacer(
+		"{exchangeAddress}", fmt.Sprintf("%v", order.ExchangeAddress),
+		"{makerAddress}", fmt.Sprintf("%v", order.Maker),
+	).Replace(destination)
+}
+
+// publishersFor returns publishers for `destinations`
+func (router *Router) publishersFor(destinations []string) (channels.MultiPublisher, error) {
+	publishers := channels.MultiPublisher{}
+	for _, destination := range destinations {
+		publisher, ok := router.publishers[destination]
+		if !ok {
+			var err error
+			if publisher, err = router.translator.PublisherFromURI(destination); err != nil {
+				return nil, err
+			}
+		}
+		publishers = append(publishers, publisher)
+	}
+	return publishers, nil
+}
+
+// RouterConsumer relays orders to the destinations picked by a Router. As
+// with relays, a delivery is only acked once every destination has it, and
+// is returned to be retried if any publish fails.
+type RouterConsumer struct {
+	router *Router
+	s      common.Semaphore
+}
+
+// NewRouterConsumer returns a RouterConsumer for `config`
+func NewRouterConsumer(config *RouterConfig, translator channels.URITranslator, concurrency int) (*RouterConsumer, error) {
+	router, err := NewRouter(config, translator)
+	if err != nil {
+		return nil, err
+	}
+	return &RouterConsumer{router, make(common.Semaphore, concurrency)}, nil
+}
+
+func (consumer *RouterConsumer) Consume(delivery channels.Delivery) {
+	consumer.s.Acquire()
+	go func() {
+		defer consumer.s.Release()
+		payload := delivery.Payload()
+		if len(payload) == 0 {
+			// Sometimes we get the odd empty message
+			delivery.Ack()
+			return
+		}
+		order, err := types.OrderFromBytes([]byte(payload))
+		if err != nil {
+			log.Printf("Error parsing order: %v", err.Error())
+			delivery.Reject()
+			return
+		}
+		name, destinations := consumer.router.Route(order)
+		if len(destinations) == 0 {
+			log.Printf("No route for order %#x", order.Hash())
+			delivery.Reject()
+			return
+		}
+		publishers, err := consumer.router.publishersFor(destinations)
+		if err != nil {
+			log.Printf("Error producing publishers for %v: %v", name, err.Error())
+			delivery.Reject()
+			return
+		}
+		if err := publishers.PublishAll(channels.Wrap(delivery.Headers(), payload)); err != nil {
+			log.Printf("Returning delivery (attempt %v): %v", channels.DeliveryAttempts(delivery), err.Error())
+			delivery.Return()
+			return
+		}
+		delivery.Ack()
+	}()
+}
@@ -0,0 +1,103 @@
+package splitter_test
+
+import (
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/splitter"
+	"github.com/notegio/openrelay/types"
+	"testing"
+)
+
+func testOrder() *types.Order {
+	order := &types.Order{}
+	order.Initialize()
+	order.MakerAssetData, _ = common.HexToAssetData("0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba")
+	order.TakerAssetData, _ = common.HexToAssetData("0x025717920000000000000000000000002222222222222222222222222222222222222222000000000000000000000000000000000000000000000000000000000000002a")
+	order.MakerAssetAddress = order.MakerAssetData.Address()
+	order.TakerAssetAddress = order.TakerAssetData.Address()
+	order.ExchangeAddress, _ = common.HexToAddress("0x90fe2af704b34e0224bf2299c838e04d4dcf1364")
+	order.MakerAssetAmount = common.Int64ToUint256(1000)
+	return order
+}
+
+func TestRouterRoute(t *testing.T) {
+	config, err := splitter.ParseRouterConfig([]byte(`{
+		"routes": [
+			{"name": "big", "match": {"minMakerAssetAmount": "5000"}, "to": ["mem://big"]},
+			{"name": "pool", "match": {"poolName": "special"}, "to": ["mem://special"]},
+			{"name": "nft", "match": {"assetProxyId": "0x02571792", "makerAssetAddress": "0x1dad4783cf3fe3085c1426157ab175a6119a04ba"}, "to": ["mem://nft", "mem://{exchangeAddress}-nft"]}
+		],
+		"default": ["mem://default"]
+	}`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	router, err := splitter.NewRouter(config, channels.NewMemURITranslator())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	order := testOrder()
+	name, destinations := router.Route(order)
+	if name != "nft" || len(destinations) != 2 || destinations[1] != "mem://0x90fe2af704b34e0224bf2299c838e04d4dcf1364-nft" {
+		t.Errorf("Unexpected route %v: %v", name, destinations)
+	}
+	order.MakerAssetAmount = common.Int64ToUint256(5000)
+	if name, _ := router.Route(order); name != "big" {
+		t.Errorf("Expected route 'big', got %v", name)
+	}
+	order = testOrder()
+	order.TakerAssetData = order.MakerAssetData
+	if name, destinations := router.Route(order); name != "default" || destinations[0] != "mem://default" {
+		t.Errorf("Unexpected route %v: %v", name, destinations)
+	}
+}
+
+func TestRouterInvalidConfig(t *testing.T) {
+	for _, invalid := range []string{
+		`{"routes": [{"match": {"makerAssetProxyId": "0x0257"}, "to": ["mem://a"]}]}`,
+		`{"routes": [{"match": {"minTakerAssetAmount": "1e18"}, "to": ["mem://a"]}]}`,
+		`{"routes": [{"match": {"makerAddress": "0xzz"}, "to": ["mem://a"]}]}`,
+		`{"routes": [{"match": {}}]}`,
+		`{"default": ["redis://a"]}`,
+	} {
+		config, err := splitter.ParseRouterConfig([]byte(invalid))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, err := splitter.NewRouter(config, channels.NewMemURITranslator()); err == nil {
+			t.Errorf("Expected an error for %v", invalid)
+		}
+	}
+}
+
+func TestRouterConsumer(t *testing.T) {
+	config := &splitter.RouterConfig{Default: []string{"mem://router-default"}}
+	consumer, err := splitter.NewRouterConsumer(config, channels.NewMemURITranslator(), 1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sourcePublisher, sourceChannel := channels.MockChannel()
+	sourceChannel.AddConsumer(consumer)
+	sourceChannel.StartConsuming()
+	defer sourceChannel.StopConsuming()
+	destChannel := channels.NewMemQueueConsumerChannel("router-default")
+	deliveries := make(chan channels.Delivery, 1)
+	destChannel.AddConsumer(&deliveryConsumer{deliveries})
+	destChannel.StartConsuming()
+	defer destChannel.StopConsuming()
+	order := testOrder()
+	sourcePublisher.Publish(string(order.Bytes()))
+	delivery := <-deliveries
+	if delivery.Payload() != string(order.Bytes()) {
+		t.Errorf("Unexpected payload")
+	}
+	delivery.Ack()
+}
+
+type deliveryConsumer struct {
+	deliveries chan channels.Delivery
+}
+
+func (consumer *deliveryConsumer) Consume(delivery channels.Delivery) {
+	consumer.deliveries <- delivery
+}
@@ -8,12 +8,28 @@ import (
 
 type SubscriptionManager struct {
 	subscriptions []Subscription
+	history channels.TopicHistory
+}
+
+// SetHistory provides the history of the topic orders are published from,
+// which lets clients resume their subscriptions from the last sequence they
+// saw. Topics that don't keep a history are ignored, so clients are told
+// resuming isn't supported.
+func (manager *SubscriptionManager) SetHistory(history channels.TopicHistory) {
+	if history != nil && !history.HistoryEnabled() {
+		history = nil
+	}
+	manager.history = history
 }
 
 func (manager *SubscriptionManager) Publish(order *db.Order) {
+	manager.PublishWithSequence(order, 0)
+}
+
+func (manager *SubscriptionManager) PublishWithSequence(order *db.Order, sequence int64) {
 	failures := []int{}
 	for i, subscription := range manager.subscriptions {
-		if !subscription.Publish(order) {
+		if !subscription.PublishWithSequence(order, sequence) {
 			failures = append(failures, i)
 		}
 	}
@@ -5,6 +5,7 @@ import (
 	"github.com/notegio/openrelay/db"
 	"github.com/notegio/openrelay/channels"
 	"github.com/notegio/openrelay/search"
+	"github.com/notegio/openrelay/types"
 	"log"
 )
 
@@ -20,10 +21,18 @@ type SubscriptionUpdate struct {
 	Channel   string `json:"channel"`
 	RequestID string `json:"requestId"`
 	Payload   []interface{} `json:"payload"`
+	Sequence  int64 `json:"sequence,omitempty"`
 }
 
 
 func (subscription *Subscription) Publish(order *db.Order) (bool) {
+	return subscription.PublishWithSequence(order, 0)
+}
+
+// PublishWithSequence publishes `order` if it matches the subscription,
+// tagged with the sequence it had on a replayable topic so that clients can
+// resume from it after reconnecting. A sequence of 0 is left out.
+func (subscription *Subscription) PublishWithSequence(order *db.Order, sequence int64) (bool) {
 	if subscription.filter(order) {
 		formatted := search.GetFormattedOrder(*order)
 		message := &SubscriptionUpdate{
@@ -31,6 +40,7 @@ func (subscription *Subscription) Publish(order *db.Order) (bool) {
 			Channel: "orders",
 			RequestID: subscription.requestID,
 			Payload: []interface{}{formatted},
+			Sequence: sequence,
 		}
 		data, err := json.Marshal(message)
 		if err != nil {
@@ -40,3 +50,16 @@ func (subscription *Subscription) Publish(order *db.Order) (bool) {
 	}
 	return true
 }
+
+// orderFromDelivery decodes the order carried by a delivery from the order
+// broadcast topic
+func orderFromDelivery(delivery channels.Delivery) (*db.Order, error) {
+	order, err := types.OrderFromBytes([]byte(delivery.Payload()))
+	if err != nil {
+		return nil, err
+	}
+	dbOrder := &db.Order{}
+	dbOrder.Order = *order
+	dbOrder.Populate()
+	return dbOrder, nil
+}
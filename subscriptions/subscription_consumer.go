@@ -9,6 +9,10 @@ import (
 )
 
 
+// errHistoryTruncated tells a client resuming a subscription that updates it
+// missed are no longer available, so it has to fetch the orderbook again.
+var errHistoryTruncated = errors.New("History truncated, resync")
+
 type SubscriptionConsumer struct {
 	manager *SubscriptionManager
 	publisher channels.Publisher
@@ -25,6 +29,9 @@ type SubscriptionMessage struct {
 	Channel   string `json:"channel"`
 	RequestID string `json:"requestId"`
 	Payload   *OrderFilter
+	// ResumeFrom is the sequence of the last update a client received before
+	// disconnecting. Updates after it are replayed from the topic's history.
+	ResumeFrom int64 `json:"resumeFrom"`
 }
 
 func sendError(publisher channels.Publisher, err error) {
@@ -88,5 +95,53 @@ func (consumer *SubscriptionConsumer) Consume(delivery channels.Delivery) {
 		requestID: incoming.RequestID,
 		filter: func(order *db.Order) (bool) { return baseFilterFn(order) && payloadFilterFn(order) },
 	}
+	if incoming.ResumeFrom > 0 && consumer.manager.history == nil {
+		sendError(consumer.publisher, errors.New("Resuming subscriptions is not supported"))
+		return
+	}
+	if incoming.ResumeFrom <= 0 {
+		consumer.manager.Add(subscription)
+		return
+	}
+	// Replay before adding the subscription, so that a client whose
+	// subscription can't be resumed isn't left subscribed.
+	replayedThrough, err := consumer.replay(subscription, incoming.ResumeFrom)
+	if err != nil {
+		return
+	}
 	consumer.manager.Add(subscription)
+	// Replay anything published while the first replay ran. Clients may see
+	// an update twice, but can tell from its sequence.
+	consumer.replay(subscription, replayedThrough)
+}
+
+// replay publishes the updates in the history after `sequence` to
+// `subscription`, and returns the sequence of the last one. If they can't all
+// be replayed, nothing is published and the client is sent an error.
+func (consumer *SubscriptionConsumer) replay(subscription Subscription, sequence int64) (int64, error) {
+	deliveries, err := consumer.manager.history.Since(sequence)
+	if _, ok := err.(*channels.HistoryTruncatedError); ok {
+		// Replaying part of what the client missed would leave it out of
+		// sync without knowing it.
+		log.Printf("Can't resume subscription: %v", err.Error())
+		sendError(consumer.publisher, errHistoryTruncated)
+		return sequence, err
+	} else if err != nil {
+		log.Printf("Error reading history: %v", err.Error())
+		sendError(consumer.publisher, err)
+		return sequence, err
+	}
+	for _, delivery := range deliveries {
+		deliverySequence, _ := channels.Sequence(delivery)
+		if deliverySequence > sequence {
+			sequence = deliverySequence
+		}
+		order, err := orderFromDelivery(delivery)
+		if err != nil {
+			log.Printf("Error on order: %v", err.Error())
+			continue
+		}
+		subscription.PublishWithSequence(order, deliverySequence)
+	}
+	return sequence, nil
 }
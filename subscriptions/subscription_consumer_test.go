@@ -1,7 +1,11 @@
 package subscriptions_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/channels"
 	dbModule "github.com/notegio/openrelay/db"
@@ -41,3 +45,132 @@ func TestSubscriptionConsumer(t *testing.T) {
 		t.Errorf("Unexpected valud: %v", d.Payload())
 	}
 }
+
+func TestSubscriptionConsumerResume(t *testing.T) {
+	uri := fmt.Sprintf("mem+topic://subscription-resume-%v?history=10", time.Now().UnixNano())
+	orderPublisher, _ := channels.MemPublisherFromURI(uri)
+	orderChannel, _ := channels.MemConsumerFromURI(uri)
+	incomingPublisher, incomingConsumerChannel := channels.MockChannel()
+	outgoingPublisher, deliveries := channels.MockPublisher()
+	manager := &subscriptions.SubscriptionManager{}
+	manager.SetHistory(orderChannel.(channels.TopicHistory))
+	consumer := subscriptions.NewSubscriptionConsumer(manager, outgoingPublisher, nil, &MockExchangeLookup{})
+	incomingConsumerChannel.AddConsumer(consumer)
+	incomingConsumerChannel.StartConsuming()
+	defer incomingConsumerChannel.StopConsuming()
+	for i := int64(1); i <= 2; i++ {
+		torder := &types.Order{}
+		torder.Initialize()
+		torder.Salt = common.Int64ToUint256(i)
+		orderPublisher.Publish(string(torder.Bytes()))
+	}
+	incomingPublisher.Publish(`{
+	    "type": "subscribe",
+	    "channel": "orders",
+	    "requestId": "123e4567-e89b-12d3-a456-426655440000",
+	    "resumeFrom": 1
+	}`)
+	channels.MockFinish(incomingConsumerChannel, 1)
+	select {
+	case d := <-deliveries:
+		update := &subscriptions.SubscriptionUpdate{}
+		if err := json.Unmarshal([]byte(d.Payload()), update); err != nil {
+			t.Fatal(err.Error())
+		}
+		if update.Sequence != 2 {
+			t.Errorf("Expected sequence 2, got %v", update.Sequence)
+		}
+	default:
+		t.Fatalf("Expected the missed update to be replayed")
+	}
+	select {
+	case d := <-deliveries:
+		t.Errorf("Unexpected update: %v", d.Payload())
+	default:
+	}
+}
+
+func TestSubscriptionConsumerResumeTruncated(t *testing.T) {
+	uri := fmt.Sprintf("mem+topic://subscription-truncated-%v?history=1", time.Now().UnixNano())
+	orderPublisher, _ := channels.MemPublisherFromURI(uri)
+	orderChannel, _ := channels.MemConsumerFromURI(uri)
+	incomingPublisher, incomingConsumerChannel := channels.MockChannel()
+	outgoingPublisher, deliveries := channels.MockPublisher()
+	manager := &subscriptions.SubscriptionManager{}
+	manager.SetHistory(orderChannel.(channels.TopicHistory))
+	consumer := subscriptions.NewSubscriptionConsumer(manager, outgoingPublisher, nil, &MockExchangeLookup{})
+	incomingConsumerChannel.AddConsumer(consumer)
+	incomingConsumerChannel.StartConsuming()
+	defer incomingConsumerChannel.StopConsuming()
+	for i := int64(1); i <= 3; i++ {
+		torder := &types.Order{}
+		torder.Initialize()
+		torder.Salt = common.Int64ToUint256(i)
+		orderPublisher.Publish(string(torder.Bytes()))
+	}
+	// Only sequence 3 is left in the history, so sequence 2 can't be replayed
+	incomingPublisher.Publish(`{
+	    "type": "subscribe",
+	    "channel": "orders",
+	    "requestId": "123e4567-e89b-12d3-a456-426655440000",
+	    "resumeFrom": 1
+	}`)
+	channels.MockFinish(incomingConsumerChannel, 1)
+	select {
+	case d := <-deliveries:
+		update := &subscriptions.SubscriptionUpdate{}
+		if err := json.Unmarshal([]byte(d.Payload()), update); err != nil {
+			t.Fatal(err.Error())
+		}
+		if update.Type != "error" {
+			t.Errorf("Expected an error, got %v", d.Payload())
+		}
+	default:
+		t.Fatalf("Expected the client to be told to resync")
+	}
+	// The subscription can't be resumed, so it shouldn't have been added
+	torder := &types.Order{}
+	torder.Initialize()
+	manager.Publish(&dbModule.Order{Order: *torder})
+	select {
+	case d := <-deliveries:
+		t.Errorf("Unexpected update: %v", d.Payload())
+	default:
+	}
+}
+
+func TestSubscriptionConsumerResumeWithoutHistory(t *testing.T) {
+	uri := fmt.Sprintf("mem+topic://subscription-no-history-%v", time.Now().UnixNano())
+	orderChannel, _ := channels.MemConsumerFromURI(uri)
+	incomingPublisher, incomingConsumerChannel := channels.MockChannel()
+	outgoingPublisher, deliveries := channels.MockPublisher()
+	manager := &subscriptions.SubscriptionManager{}
+	manager.SetHistory(orderChannel.(channels.TopicHistory))
+	consumer := subscriptions.NewSubscriptionConsumer(manager, outgoingPublisher, nil, &MockExchangeLookup{})
+	incomingConsumerChannel.AddConsumer(consumer)
+	incomingConsumerChannel.StartConsuming()
+	defer incomingConsumerChannel.StopConsuming()
+	incomingPublisher.Publish(`{
+	    "type": "subscribe",
+	    "channel": "orders",
+	    "requestId": "123e4567-e89b-12d3-a456-426655440000",
+	    "resumeFrom": 1
+	}`)
+	channels.MockFinish(incomingConsumerChannel, 1)
+	select {
+	case d := <-deliveries:
+		if !strings.Contains(d.Payload(), "Resuming subscriptions is not supported") {
+			t.Errorf("Unexpected message: %v", d.Payload())
+		}
+	default:
+		t.Fatalf("Expected the client to be told resuming isn't supported")
+	}
+	torder := &types.Order{}
+	torder.Initialize()
+	manager.Publish(&dbModule.Order{Order: *torder})
+	select {
+	case d := <-deliveries:
+		t.Errorf("Unexpected update: %v", d.Payload())
+	default:
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"github.com/notegio/openrelay/channels"
 	"github.com/notegio/openrelay/channels/ws"
 	dbModule "github.com/notegio/openrelay/db"
-	"github.com/notegio/openrelay/types"
 	"github.com/jinzhu/gorm"
 	"log"
 )
@@ -37,13 +36,18 @@ func (subs *WebsocketSubscriptionManager) ListenForSubscriptions(port uint, db *
 	return quit, nil
 }
 
+// SetHistory provides the history of the topic orders are consumed from, so
+// that clients can resume their subscriptions after a disconnect.
+func (subs *WebsocketSubscriptionManager) SetHistory(history channels.TopicHistory) {
+	subs.manager.SetHistory(history)
+}
+
 func (subs *WebsocketSubscriptionManager) Consume(delivery channels.Delivery) {
-	order, err := types.OrderFromBytes([]byte(delivery.Payload()))
+	order, err := orderFromDelivery(delivery)
 	if err != nil {
 		log.Printf("Error on order: %v", err.Error())
+		return
 	}
-	dbOrder := &dbModule.Order{}
-	dbOrder.Order = *order
-	dbOrder.Populate()
-	subs.manager.Publish(dbOrder)
+	sequence, _ := channels.Sequence(delivery)
+	subs.manager.PublishWithSequence(order, sequence)
 }
@@ -17,10 +17,27 @@ import (
 )
 
 type TermsFormat struct {
-	Text    string         `json:"text"`
-	ID      uint           `json:"id"`
-	Mask    *types.Uint256 `json:"mask"`
-	MaskID  uint           `json:"maskId"`
+	Text      string           `json:"text"`
+	ID        uint             `json:"id"`
+	Mask      *types.Uint256   `json:"mask"`
+	MaskID    uint             `json:"maskId"`
+	TypedData *types.TypedData `json:"typedData"`
+}
+
+// NewTermsFormat returns the response body for `terms` with the given hash
+// mask. TypedData is the EIP-712 message for the terms with an empty
+// timestamp and nonce; clients fill in the timestamp and nonce they chose
+// before signing it with eth_signTypedData.
+func NewTermsFormat(terms *dbModule.Terms, mask []byte, maskID uint) *TermsFormat {
+	tf := &TermsFormat{
+		Text: terms.Text,
+		ID: terms.ID,
+		Mask: &types.Uint256{},
+		MaskID: maskID,
+		TypedData: terms.TypedData("", nil),
+	}
+	copy(tf.Mask[32 - len(mask):], mask[:])
+	return tf
 }
 
 type TermsSigPayload struct {
@@ -68,13 +85,7 @@ func TermsHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request) {
 				returnError(w, IngestError{101, err.Error()}, 500)
 				return
 			}
-			tf := &TermsFormat{
-				Text: terms.Text,
-				ID: terms.ID,
-				Mask: &types.Uint256{},
-				MaskID: mask_id,
-			}
-			copy(tf.Mask[32 - len(mask):], mask[:])
+			tf := NewTermsFormat(terms, mask, mask_id)
 			data, err := json.Marshal(tf)
 			if err != nil {
 				returnError(w, IngestError{101, err.Error()}, 500)
@@ -0,0 +1,50 @@
+package terms_test
+
+import (
+	"encoding/json"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/terms"
+	"testing"
+)
+
+func TestTermsFormatTypedData(t *testing.T) {
+	dbTerms := &dbModule.Terms{Text: "Don't break the law"}
+	dbTerms.ID = 3
+	tf := terms.NewTermsFormat(dbTerms, []byte{0x01, 0x02}, 7)
+	if tf.Mask[30] != 0x01 || tf.Mask[31] != 0x02 {
+		t.Errorf("Unexpected mask: %#x", tf.Mask[:])
+	}
+	if tf.TypedData == nil || tf.TypedData.PrimaryType != "Terms" {
+		t.Fatalf("Expected Terms typed data, got %v", tf.TypedData)
+	}
+	if tf.TypedData.Message["text"] != "Don't break the law" {
+		t.Errorf("Unexpected typed data text: %v", tf.TypedData.Message["text"])
+	}
+	// Filling in the timestamp and nonce must give the message CheckSig
+	// verifies
+	nonce := []byte{0xde, 0xad}
+	tf.TypedData.Message["timestamp"] = "1530000000"
+	tf.TypedData.Message["nonce"] = "0xdead"
+	hash, err := tf.TypedData.Hash()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected, err := dbTerms.TypedData("1530000000", nonce).Hash()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(hash) != string(expected) {
+		t.Errorf("Unexpected typed data hash %#x, expected %#x", hash, expected)
+	}
+	data, err := json.Marshal(tf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, ok := decoded["typedData"]; !ok {
+		t.Errorf("Expected typedData in %v", string(data))
+	}
+}
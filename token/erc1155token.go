@@ -0,0 +1,264 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package token
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ERC1155TokenABI is the input ABI used to generate the binding from.
+const ERC1155TokenABI = "[{\"constant\":true,\"inputs\":[{\"name\":\"_owner\",\"type\":\"address\"},{\"name\":\"_id\",\"type\":\"uint256\"}],\"name\":\"balanceOf\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"_owners\",\"type\":\"address[]\"},{\"name\":\"_ids\",\"type\":\"uint256[]\"}],\"name\":\"balanceOfBatch\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256[]\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"_owner\",\"type\":\"address\"},{\"name\":\"_operator\",\"type\":\"address\"}],\"name\":\"isApprovedForAll\",\"outputs\":[{\"name\":\"\",\"type\":\"bool\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"_operator\",\"type\":\"address\"},{\"name\":\"_approved\",\"type\":\"bool\"}],\"name\":\"setApprovalForAll\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"_from\",\"type\":\"address\"},{\"name\":\"_to\",\"type\":\"address\"},{\"name\":\"_id\",\"type\":\"uint256\"},{\"name\":\"_value\",\"type\":\"uint256\"},{\"name\":\"_data\",\"type\":\"bytes\"}],\"name\":\"safeTransferFrom\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"_operator\",\"type\":\"address\"},{\"indexed\":true,\"name\":\"_from\",\"type\":\"address\"},{\"indexed\":true,\"name\":\"_to\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"_id\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"_value\",\"type\":\"uint256\"}],\"name\":\"TransferSingle\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"_operator\",\"type\":\"address\"},{\"indexed\":true,\"name\":\"_from\",\"type\":\"address\"},{\"indexed\":true,\"name\":\"_to\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"_ids\",\"type\":\"uint256[]\"},{\"indexed\":false,\"name\":\"_values\",\"type\":\"uint256[]\"}],\"name\":\"TransferBatch\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"_owner\",\"type\":\"address\"},{\"indexed\":true,\"name\":\"_operator\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"_approved\",\"type\":\"bool\"}],\"name\":\"ApprovalForAll\",\"type\":\"event\"}]"
+
+// ERC1155Token is an auto generated Go binding around an Ethereum contract.
+type ERC1155Token struct {
+	ERC1155TokenCaller     // Read-only binding to the contract
+	ERC1155TokenTransactor // Write-only binding to the contract
+}
+
+// ERC1155TokenCaller is an auto generated read-only Go binding around an Ethereum contract.
+type ERC1155TokenCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// ERC1155TokenTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type ERC1155TokenTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// ERC1155TokenSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type ERC1155TokenSession struct {
+	Contract     *ERC1155Token     // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// ERC1155TokenCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type ERC1155TokenCallerSession struct {
+	Contract *ERC1155TokenCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts       // Call options to use throughout this session
+}
+
+// ERC1155TokenTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type ERC1155TokenTransactorSession struct {
+	Contract     *ERC1155TokenTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts       // Transaction auth options to use throughout this session
+}
+
+// ERC1155TokenRaw is an auto generated low-level Go binding around an Ethereum contract.
+type ERC1155TokenRaw struct {
+	Contract *ERC1155Token // Generic contract binding to access the raw methods on
+}
+
+// ERC1155TokenCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type ERC1155TokenCallerRaw struct {
+	Contract *ERC1155TokenCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// ERC1155TokenTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type ERC1155TokenTransactorRaw struct {
+	Contract *ERC1155TokenTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewERC1155Token creates a new instance of ERC1155Token, bound to a specific deployed contract.
+func NewERC1155Token(address common.Address, backend bind.ContractBackend) (*ERC1155Token, error) {
+	contract, err := bindERC1155Token(address, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &ERC1155Token{ERC1155TokenCaller: ERC1155TokenCaller{contract: contract}, ERC1155TokenTransactor: ERC1155TokenTransactor{contract: contract}}, nil
+}
+
+// NewERC1155TokenCaller creates a new read-only instance of ERC1155Token, bound to a specific deployed contract.
+func NewERC1155TokenCaller(address common.Address, caller bind.ContractCaller) (*ERC1155TokenCaller, error) {
+	contract, err := bindERC1155Token(address, caller, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ERC1155TokenCaller{contract: contract}, nil
+}
+
+// NewERC1155TokenTransactor creates a new write-only instance of ERC1155Token, bound to a specific deployed contract.
+func NewERC1155TokenTransactor(address common.Address, transactor bind.ContractTransactor) (*ERC1155TokenTransactor, error) {
+	contract, err := bindERC1155Token(address, nil, transactor)
+	if err != nil {
+		return nil, err
+	}
+	return &ERC1155TokenTransactor{contract: contract}, nil
+}
+
+// bindERC1155Token binds a generic wrapper to an already deployed contract.
+func bindERC1155Token(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(ERC1155TokenABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_ERC1155Token *ERC1155TokenRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _ERC1155Token.Contract.ERC1155TokenCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_ERC1155Token *ERC1155TokenRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _ERC1155Token.Contract.ERC1155TokenTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_ERC1155Token *ERC1155TokenRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _ERC1155Token.Contract.ERC1155TokenTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_ERC1155Token *ERC1155TokenCallerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _ERC1155Token.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_ERC1155Token *ERC1155TokenTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _ERC1155Token.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_ERC1155Token *ERC1155TokenTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _ERC1155Token.Contract.contract.Transact(opts, method, params...)
+}
+
+// BalanceOf is a free data retrieval call binding the contract method 0x00fdd58e.
+//
+// Solidity: function balanceOf(_owner address, _id uint256) constant returns(uint256)
+func (_ERC1155Token *ERC1155TokenCaller) BalanceOf(opts *bind.CallOpts, _owner common.Address, _id *big.Int) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _ERC1155Token.contract.Call(opts, out, "balanceOf", _owner, _id)
+	return *ret0, err
+}
+
+// BalanceOf is a free data retrieval call binding the contract method 0x00fdd58e.
+//
+// Solidity: function balanceOf(_owner address, _id uint256) constant returns(uint256)
+func (_ERC1155Token *ERC1155TokenSession) BalanceOf(_owner common.Address, _id *big.Int) (*big.Int, error) {
+	return _ERC1155Token.Contract.BalanceOf(&_ERC1155Token.CallOpts, _owner, _id)
+}
+
+// BalanceOf is a free data retrieval call binding the contract method 0x00fdd58e.
+//
+// Solidity: function balanceOf(_owner address, _id uint256) constant returns(uint256)
+func (_ERC1155Token *ERC1155TokenCallerSession) BalanceOf(_owner common.Address, _id *big.Int) (*big.Int, error) {
+	return _ERC1155Token.Contract.BalanceOf(&_ERC1155Token.CallOpts, _owner, _id)
+}
+
+// BalanceOfBatch is a free data retrieval call binding the contract method 0x4e1273f4.
+//
+// Solidity: function balanceOfBatch(_owners address[], _ids uint256[]) constant returns(uint256[])
+func (_ERC1155Token *ERC1155TokenCaller) BalanceOfBatch(opts *bind.CallOpts, _owners []common.Address, _ids []*big.Int) ([]*big.Int, error) {
+	var (
+		ret0 = new([]*big.Int)
+	)
+	out := ret0
+	err := _ERC1155Token.contract.Call(opts, out, "balanceOfBatch", _owners, _ids)
+	return *ret0, err
+}
+
+// BalanceOfBatch is a free data retrieval call binding the contract method 0x4e1273f4.
+//
+// Solidity: function balanceOfBatch(_owners address[], _ids uint256[]) constant returns(uint256[])
+func (_ERC1155Token *ERC1155TokenSession) BalanceOfBatch(_owners []common.Address, _ids []*big.Int) ([]*big.Int, error) {
+	return _ERC1155Token.Contract.BalanceOfBatch(&_ERC1155Token.CallOpts, _owners, _ids)
+}
+
+// BalanceOfBatch is a free data retrieval call binding the contract method 0x4e1273f4.
+//
+// Solidity: function balanceOfBatch(_owners address[], _ids uint256[]) constant returns(uint256[])
+func (_ERC1155Token *ERC1155TokenCallerSession) BalanceOfBatch(_owners []common.Address, _ids []*big.Int) ([]*big.Int, error) {
+	return _ERC1155Token.Contract.BalanceOfBatch(&_ERC1155Token.CallOpts, _owners, _ids)
+}
+
+// IsApprovedForAll is a free data retrieval call binding the contract method 0xe985e9c5.
+//
+// Solidity: function isApprovedForAll(_owner address, _operator address) constant returns(bool)
+func (_ERC1155Token *ERC1155TokenCaller) IsApprovedForAll(opts *bind.CallOpts, _owner common.Address, _operator common.Address) (bool, error) {
+	var (
+		ret0 = new(bool)
+	)
+	out := ret0
+	err := _ERC1155Token.contract.Call(opts, out, "isApprovedForAll", _owner, _operator)
+	return *ret0, err
+}
+
+// IsApprovedForAll is a free data retrieval call binding the contract method 0xe985e9c5.
+//
+// Solidity: function isApprovedForAll(_owner address, _operator address) constant returns(bool)
+func (_ERC1155Token *ERC1155TokenSession) IsApprovedForAll(_owner common.Address, _operator common.Address) (bool, error) {
+	return _ERC1155Token.Contract.IsApprovedForAll(&_ERC1155Token.CallOpts, _owner, _operator)
+}
+
+// IsApprovedForAll is a free data retrieval call binding the contract method 0xe985e9c5.
+//
+// Solidity: function isApprovedForAll(_owner address, _operator address) constant returns(bool)
+func (_ERC1155Token *ERC1155TokenCallerSession) IsApprovedForAll(_owner common.Address, _operator common.Address) (bool, error) {
+	return _ERC1155Token.Contract.IsApprovedForAll(&_ERC1155Token.CallOpts, _owner, _operator)
+}
+
+// SafeTransferFrom is a paid mutator transaction binding the contract method 0xf242432a.
+//
+// Solidity: function safeTransferFrom(_from address, _to address, _id uint256, _value uint256, _data bytes) returns()
+func (_ERC1155Token *ERC1155TokenTransactor) SafeTransferFrom(opts *bind.TransactOpts, _from common.Address, _to common.Address, _id *big.Int, _value *big.Int, _data []byte) (*types.Transaction, error) {
+	return _ERC1155Token.contract.Transact(opts, "safeTransferFrom", _from, _to, _id, _value, _data)
+}
+
+// SafeTransferFrom is a paid mutator transaction binding the contract method 0xf242432a.
+//
+// Solidity: function safeTransferFrom(_from address, _to address, _id uint256, _value uint256, _data bytes) returns()
+func (_ERC1155Token *ERC1155TokenSession) SafeTransferFrom(_from common.Address, _to common.Address, _id *big.Int, _value *big.Int, _data []byte) (*types.Transaction, error) {
+	return _ERC1155Token.Contract.SafeTransferFrom(&_ERC1155Token.TransactOpts, _from, _to, _id, _value, _data)
+}
+
+// SafeTransferFrom is a paid mutator transaction binding the contract method 0xf242432a.
+//
+// Solidity: function safeTransferFrom(_from address, _to address, _id uint256, _value uint256, _data bytes) returns()
+func (_ERC1155Token *ERC1155TokenTransactorSession) SafeTransferFrom(_from common.Address, _to common.Address, _id *big.Int, _value *big.Int, _data []byte) (*types.Transaction, error) {
+	return _ERC1155Token.Contract.SafeTransferFrom(&_ERC1155Token.TransactOpts, _from, _to, _id, _value, _data)
+}
+
+// SetApprovalForAll is a paid mutator transaction binding the contract method 0xa22cb465.
+//
+// Solidity: function setApprovalForAll(_operator address, _approved bool) returns()
+func (_ERC1155Token *ERC1155TokenTransactor) SetApprovalForAll(opts *bind.TransactOpts, _operator common.Address, _approved bool) (*types.Transaction, error) {
+	return _ERC1155Token.contract.Transact(opts, "setApprovalForAll", _operator, _approved)
+}
+
+// SetApprovalForAll is a paid mutator transaction binding the contract method 0xa22cb465.
+//
+// Solidity: function setApprovalForAll(_operator address, _approved bool) returns()
+func (_ERC1155Token *ERC1155TokenSession) SetApprovalForAll(_operator common.Address, _approved bool) (*types.Transaction, error) {
+	return _ERC1155Token.Contract.SetApprovalForAll(&_ERC1155Token.TransactOpts, _operator, _approved)
+}
+
+// SetApprovalForAll is a paid mutator transaction binding the contract method 0xa22cb465.
+//
+// Solidity: function setApprovalForAll(_operator address, _approved bool) returns()
+func (_ERC1155Token *ERC1155TokenTransactorSession) SetApprovalForAll(_operator common.Address, _approved bool) (*types.Transaction, error) {
+	return _ERC1155Token.Contract.SetApprovalForAll(&_ERC1155Token.TransactOpts, _operator, _approved)
+}
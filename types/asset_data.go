@@ -3,8 +3,10 @@ package types
 import (
 	// "encoding/hex"
 	"bytes"
+	"errors"
 	"fmt"
 	"database/sql/driver"
+	"math/big"
 	// "strings"
 	// "log"
 )
@@ -13,6 +15,27 @@ type AssetData []byte
 
 var ERC20ProxyID = [4]byte{244, 114, 97, 176} // 0xf47261b0
 var ERC721ProxyID = [4]byte{2, 87, 23, 146} // 0x02571792
+var MultiAssetProxyID = [4]byte{148, 207, 205, 215} // 0x94cfcdd7
+var ERC1155ProxyID = [4]byte{167, 203, 95, 183} // 0xa7cb5fb7
+
+// AssetComponent is one of the assets traded by an order. Amount is how many
+// units of the asset are traded per unit of the order's asset amount, which
+// is 1 except for the components of MultiAssetProxy bundles and ERC1155
+// assets.
+type AssetComponent struct {
+	Amount    *big.Int
+	AssetData AssetData
+}
+
+// ERC1155Asset is the decoded form of ERC1155 asset data. Values[i] units of
+// TokenIDs[i] are traded per unit of the order's asset amount, and
+// CallbackData is passed to the receiver of the tokens.
+type ERC1155Asset struct {
+	TokenAddress *Address
+	TokenIDs     []*big.Int
+	Values       []*big.Int
+	CallbackData []byte
+}
 
 func (data AssetData) ProxyId() ([4]byte) {
 	result := [4]byte{}
@@ -20,20 +43,258 @@ func (data AssetData) ProxyId() ([4]byte) {
 	return result
 }
 
+// Address returns the token address of ERC20, ERC721 and ERC1155 asset data. Bundles
+// trade several tokens, so for MultiAssetProxy asset data the address is
+// empty, and Addresses lists the tokens in the bundle.
 func (data AssetData) Address() (*Address) {
 	address := &Address{}
-	if data.IsType(ERC20ProxyID) || data.IsType(ERC721ProxyID) {
+	if (data.IsType(ERC20ProxyID) || data.IsType(ERC721ProxyID) || data.IsType(ERC1155ProxyID)) && len(data) >= 36 {
 		copy(address[:], data[16:36])
 	}
 	return address
 }
 
+// Addresses returns the token address of each component of the asset data
+func (data AssetData) Addresses() ([]*Address) {
+	components, err := data.Components()
+	if err != nil {
+		return []*Address{}
+	}
+	addresses := []*Address{}
+	for _, component := range components {
+		addresses = append(addresses, component.AssetData.Address())
+	}
+	return addresses
+}
+
 func (data AssetData) IsType(proxyId [4]byte) (bool) {
-	return bytes.Equal(data[0:4], proxyId[:])
+	return len(data) >= 4 && bytes.Equal(data[0:4], proxyId[:])
 }
 
+// SupportedType returns true for ERC20, ERC721 and well formed ERC1155 asset
+// data, and for MultiAssetProxy bundles made up of those assets.
 func (data AssetData) SupportedType() (bool) {
-	return data.IsType(ERC20ProxyID) || data.IsType(ERC721ProxyID)
+	if data.IsType(ERC20ProxyID) || data.IsType(ERC721ProxyID) {
+		return true
+	}
+	if data.IsType(ERC1155ProxyID) {
+		_, err := data.ERC1155()
+		return err == nil
+	}
+	if !data.IsType(MultiAssetProxyID) {
+		return false
+	}
+	components, err := data.MultiAsset()
+	if err != nil {
+		return false
+	}
+	for _, component := range components {
+		if component.AssetData.IsType(MultiAssetProxyID) || !component.AssetData.SupportedType() {
+			return false
+		}
+	}
+	return true
+}
+
+// Components returns the assets traded by the asset data. For
+// MultiAssetProxy asset data these are the assets in the bundle, and for
+// ERC1155 asset data they are the individual token IDs, each as single token
+// ERC1155 asset data with a value of 1 and no callback data. ERC1155 assets
+// in a bundle are broken down the same way. For any other type it is the
+// asset itself, with an amount of 1.
+func (data AssetData) Components() ([]AssetComponent, error) {
+	if data.IsType(ERC1155ProxyID) {
+		asset, err := data.ERC1155()
+		if err != nil {
+			return nil, err
+		}
+		components := []AssetComponent{}
+		for i, tokenID := range asset.TokenIDs {
+			components = append(components, AssetComponent{asset.Values[i], erc1155TokenAssetData(asset.TokenAddress, tokenID)})
+		}
+		return components, nil
+	}
+	if !data.IsType(MultiAssetProxyID) {
+		return []AssetComponent{AssetComponent{big.NewInt(1), data}}, nil
+	}
+	bundle, err := data.MultiAsset()
+	if err != nil {
+		return nil, err
+	}
+	components := []AssetComponent{}
+	for _, component := range bundle {
+		if !component.AssetData.IsType(ERC1155ProxyID) {
+			components = append(components, component)
+			continue
+		}
+		tokens, err := component.AssetData.Components()
+		if err != nil {
+			return nil, err
+		}
+		for _, token := range tokens {
+			components = append(components, AssetComponent{new(big.Int).Mul(component.Amount, token.Amount), token.AssetData})
+		}
+	}
+	return components, nil
+}
+
+// abiWord reads the 32 byte word at `offset` of `data` as an integer offset
+// or length, making sure it fits within `data`.
+func abiWord(data []byte, offset int) (int, error) {
+	if offset < 0 || offset+32 > len(data) {
+		return 0, errors.New("Asset data is truncated")
+	}
+	value := new(big.Int).SetBytes(data[offset : offset+32])
+	if value.BitLen() > 62 || value.Int64() > int64(len(data)) {
+		return 0, errors.New("Asset data has an invalid offset")
+	}
+	return int(value.Int64()), nil
+}
+
+// abiUints reads the uint256[] whose length is at `offset` of `data`.
+func abiUints(data []byte, offset int) ([]*big.Int, error) {
+	count, err := abiWord(data, offset)
+	if err != nil {
+		return nil, err
+	}
+	if offset+32*(count+1) > len(data) {
+		return nil, errors.New("Asset data is truncated")
+	}
+	values := make([]*big.Int, count)
+	for i := range values {
+		start := offset + 32*(i+1)
+		values[i] = new(big.Int).SetBytes(data[start : start+32])
+	}
+	return values, nil
+}
+
+// ERC1155 decodes ERC1155 asset data, which is the ABI encoding of
+// (address tokenAddress, uint256[] tokenIds, uint256[] tokenValues, bytes
+// callbackData) following the proxy ID.
+func (data AssetData) ERC1155() (*ERC1155Asset, error) {
+	if !data.IsType(ERC1155ProxyID) {
+		return nil, fmt.Errorf("Asset data is not ERC1155: %#x", data.ProxyId())
+	}
+	body := []byte(data[4:])
+	if len(body) < 128 {
+		return nil, errors.New("ERC1155 asset data is truncated")
+	}
+	asset := &ERC1155Asset{TokenAddress: &Address{}}
+	copy(asset.TokenAddress[:], body[12:32])
+	idsOffset, err := abiWord(body, 32)
+	if err != nil {
+		return nil, err
+	}
+	valuesOffset, err := abiWord(body, 64)
+	if err != nil {
+		return nil, err
+	}
+	callbackOffset, err := abiWord(body, 96)
+	if err != nil {
+		return nil, err
+	}
+	if asset.TokenIDs, err = abiUints(body, idsOffset); err != nil {
+		return nil, err
+	}
+	if asset.Values, err = abiUints(body, valuesOffset); err != nil {
+		return nil, err
+	}
+	if len(asset.TokenIDs) != len(asset.Values) {
+		return nil, errors.New("ERC1155 asset data has mismatched token IDs and values")
+	}
+	if len(asset.TokenIDs) == 0 {
+		return nil, errors.New("ERC1155 asset data has no token IDs")
+	}
+	callbackLength, err := abiWord(body, callbackOffset)
+	if err != nil {
+		return nil, err
+	}
+	if callbackOffset+32+callbackLength > len(body) {
+		return nil, errors.New("ERC1155 asset data is truncated")
+	}
+	asset.CallbackData = make([]byte, callbackLength)
+	copy(asset.CallbackData, body[callbackOffset+32:callbackOffset+32+callbackLength])
+	return asset, nil
+}
+
+// erc1155TokenAssetData encodes ERC1155 asset data trading a single unit of
+// `tokenID` with no callback data. This is the form used for the components
+// of ERC1155 assets, and in spend records for ERC1155 tokens.
+func erc1155TokenAssetData(tokenAddress *Address, tokenID *big.Int) (AssetData) {
+	words := []*big.Int{
+		new(big.Int).SetBytes(tokenAddress[:]),
+		big.NewInt(128), // Offset of tokenIds
+		big.NewInt(192), // Offset of tokenValues
+		big.NewInt(256), // Offset of callbackData
+		big.NewInt(1), tokenID, // tokenIds
+		big.NewInt(1), big.NewInt(1), // tokenValues
+		big.NewInt(0), // callbackData
+	}
+	data := make(AssetData, 4+32*len(words))
+	copy(data[0:4], ERC1155ProxyID[:])
+	for i, word := range words {
+		wordBytes := word.Bytes()
+		copy(data[4+32*(i+1)-len(wordBytes):4+32*(i+1)], wordBytes)
+	}
+	return data
+}
+
+// MultiAsset decodes MultiAssetProxy asset data, which is the ABI encoding of
+// (uint256[] amounts, bytes[] nestedAssetData) following the proxy ID.
+func (data AssetData) MultiAsset() ([]AssetComponent, error) {
+	if !data.IsType(MultiAssetProxyID) {
+		return nil, fmt.Errorf("Asset data is not a MultiAsset: %#x", data.ProxyId())
+	}
+	body := []byte(data[4:])
+	amountsOffset, err := abiWord(body, 0)
+	if err != nil {
+		return nil, err
+	}
+	nestedOffset, err := abiWord(body, 32)
+	if err != nil {
+		return nil, err
+	}
+	count, err := abiWord(body, amountsOffset)
+	if err != nil {
+		return nil, err
+	}
+	if nestedCount, err := abiWord(body, nestedOffset); err != nil {
+		return nil, err
+	} else if nestedCount != count {
+		return nil, errors.New("MultiAsset data has mismatched amounts and nestedAssetData")
+	}
+	if count == 0 {
+		return nil, errors.New("MultiAsset data has no components")
+	}
+	components := make([]AssetComponent, count)
+	for i := range components {
+		amountStart := amountsOffset + 32*(i+1)
+		if amountStart+32 > len(body) {
+			return nil, errors.New("MultiAsset data is truncated")
+		}
+		components[i].Amount = new(big.Int).SetBytes(body[amountStart : amountStart+32])
+		// Offsets of the nested asset data are relative to the start of the
+		// array's contents, after its length.
+		headStart := nestedOffset + 32
+		elementOffset, err := abiWord(body, headStart+32*i)
+		if err != nil {
+			return nil, err
+		}
+		elementStart := headStart + elementOffset
+		length, err := abiWord(body, elementStart)
+		if err != nil {
+			return nil, err
+		}
+		if elementStart+32+length > len(body) {
+			return nil, errors.New("MultiAsset data is truncated")
+		}
+		components[i].AssetData = make(AssetData, length)
+		copy(components[i].AssetData[:], body[elementStart+32:elementStart+32+length])
+		if len(components[i].AssetData) < 4 {
+			return nil, errors.New("MultiAsset data has an invalid nested asset")
+		}
+	}
+	return components, nil
 }
 
 func (data AssetData) TokenID() (*Uint256) {
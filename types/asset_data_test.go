@@ -3,7 +3,9 @@ package types_test
 import (
 	"encoding/hex"
 	"encoding/json"
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/types"
+	"math/big"
 	"testing"
 	"bytes"
 )
@@ -41,3 +43,94 @@ func TestAssetDataJSONEncode(t *testing.T) {
 // 		t.Errorf("Unexpected ProxyId: %#x", proxyId)
 // 	}
 // }
+
+func TestMultiAssetData(t *testing.T) {
+	erc20, _ := hex.DecodeString("f47261b00000000000000000000000006dfff22588be9b3ef8cf0ad6dc9b84796f9fb45f")
+	erc721, _ := hex.DecodeString("02571792000000000000000000000000e41d2489571d322189246dafa5ebde1f4699f4980000000000000000000000000000000000000000000000000000000000000007")
+	multiAsset := common.ToMultiAssetData(
+		[]*big.Int{big.NewInt(2), big.NewInt(1)},
+		[]types.AssetData{types.AssetData(erc20), types.AssetData(erc721)},
+	)
+	if !multiAsset.IsType(types.MultiAssetProxyID) {
+		t.Fatalf("Unexpected ProxyId: %#x", multiAsset.ProxyId())
+	}
+	if !multiAsset.SupportedType() {
+		t.Errorf("Expected bundle of ERC20 and ERC721 assets to be supported")
+	}
+	components, err := multiAsset.MultiAsset()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(components) != 2 {
+		t.Fatalf("Expected 2 components, got %v", len(components))
+	}
+	if components[0].Amount.Int64() != 2 || !bytes.Equal(components[0].AssetData, erc20) {
+		t.Errorf("Unexpected first component: %v %#x", components[0].Amount, components[0].AssetData)
+	}
+	if components[1].Amount.Int64() != 1 || !bytes.Equal(components[1].AssetData, erc721) {
+		t.Errorf("Unexpected second component: %v %#x", components[1].Amount, components[1].AssetData)
+	}
+	if addresses := multiAsset.Addresses(); len(addresses) != 2 || !bytes.Equal(addresses[1][:], erc721[16:36]) {
+		t.Errorf("Unexpected addresses: %v", addresses)
+	}
+	if _, err := multiAsset[:len(multiAsset)-40].MultiAsset(); err == nil {
+		t.Errorf("Expected an error decoding truncated asset data")
+	}
+	unsupported := common.ToMultiAssetData(
+		[]*big.Int{big.NewInt(1)},
+		[]types.AssetData{multiAsset},
+	)
+	if unsupported.SupportedType() {
+		t.Errorf("Expected nested bundles to be unsupported")
+	}
+}
+
+func TestERC1155AssetData(t *testing.T) {
+	tokenAddress := &types.Address{}
+	tokenAddress[19] = 1
+	assetData := common.ToERC1155AssetData(
+		tokenAddress,
+		[]*big.Int{big.NewInt(7), big.NewInt(8)},
+		[]*big.Int{big.NewInt(2), big.NewInt(5)},
+		[]byte("callback"),
+	)
+	if !assetData.SupportedType() {
+		t.Errorf("Expected ERC1155 asset data to be supported")
+	}
+	if !bytes.Equal(assetData.Address()[:], tokenAddress[:]) {
+		t.Errorf("Unexpected address: %#x", assetData.Address()[:])
+	}
+	asset, err := assetData.ERC1155()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(asset.TokenIDs) != 2 || asset.TokenIDs[1].Int64() != 8 || asset.Values[1].Int64() != 5 {
+		t.Errorf("Unexpected token IDs %v and values %v", asset.TokenIDs, asset.Values)
+	}
+	if string(asset.CallbackData) != "callback" {
+		t.Errorf("Unexpected callback data: %#x", asset.CallbackData)
+	}
+	components, err := assetData.Components()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// Each token ID is its own component, without the callback data
+	expected := common.ToERC1155AssetData(tokenAddress, []*big.Int{big.NewInt(8)}, []*big.Int{big.NewInt(1)}, []byte{})
+	if len(components) != 2 || components[1].Amount.Int64() != 5 || !bytes.Equal(components[1].AssetData, expected) {
+		t.Errorf("Unexpected components: %v", components)
+	}
+	// ERC1155 components of bundles are scaled by the bundle amount
+	bundle := common.ToMultiAssetData([]*big.Int{big.NewInt(3)}, []types.AssetData{assetData})
+	if !bundle.SupportedType() {
+		t.Errorf("Expected bundle of ERC1155 assets to be supported")
+	}
+	if components, err = bundle.Components(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(components) != 2 || components[1].Amount.Int64() != 15 || !bytes.Equal(components[1].AssetData, expected) {
+		t.Errorf("Unexpected bundle components: %v", components)
+	}
+	if assetData[:len(assetData)-40].SupportedType() {
+		t.Errorf("Expected truncated ERC1155 asset data to be unsupported")
+	}
+}
@@ -2,16 +2,26 @@ package types
 
 import (
 	"encoding/json"
+	"errors"
 	// "encoding/hex"
 
 	"fmt"
 	"github.com/ethereum/go-ethereum/crypto/sha3"
 	"github.com/ethereum/go-ethereum/rlp"
-	// "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"math/big"
 	// "strconv"
 )
 
+const (
+	// OrderVersion2 is the Version of 0x v2 orders. Orders with a zero Version
+	// are also v2 orders.
+	OrderVersion2 = uint8(2)
+	// OrderVersion3 is the Version of 0x v3 orders, which carry fee asset data
+	// and are hashed with a domain that includes the chain id.
+	OrderVersion3 = uint8(3)
+)
+
 // Order represents an 0x order object
 type Order struct {
 	Maker                     *Address  `gorm:"index"`
@@ -33,6 +43,15 @@ type Order struct {
 	TakerAssetAmountFilled    *Uint256
 	Cancelled                 bool
 	PoolID                    []byte    `gorm:"index"`
+	Version                   uint8     `gorm:"index" rlp:"-"`
+	ChainID                   uint64    `rlp:"-"`
+	MakerFeeAssetData         AssetData `rlp:"-"`
+	TakerFeeAssetData         AssetData `rlp:"-"`
+}
+
+// IsV3 returns true for 0x v3 orders
+func (order *Order) IsV3() bool {
+	return order.Version == OrderVersion3
 }
 
 func (order *Order) Initialize() {
@@ -54,6 +73,10 @@ func (order *Order) Initialize() {
 	order.TakerAssetAmountFilled = &Uint256{}
 	order.Cancelled = false
 	order.Signature = Signature{}
+	order.Version = 0
+	order.ChainID = 0
+	order.MakerFeeAssetData = AssetData{}
+	order.TakerFeeAssetData = AssetData{}
 }
 
 // NewOrder takes string representations of values and converts them into an Order object
@@ -153,6 +176,9 @@ func (order *Order) fromStrings(maker, taker, makerToken, takerToken, feeRecipie
 }
 
 func (order *Order) Hash() []byte {
+	if order.IsV3() {
+		return order.hashV3()
+	}
 
 	eip191Header := []byte{25, 1}
 	twelveNullBytes := [12]byte{}  // Addresses are 20 bytes, but the hashes expect 32, so we'll just add twelveNullBytes before each address
@@ -204,13 +230,58 @@ func (order *Order) Hash() []byte {
 	return sha.Sum(nil)
 }
 
+func (order *Order) hashV3() []byte {
+	eip191Header := []byte{25, 1}
+	twelveNullBytes := [12]byte{}
+	domainSchemaSha := sha3.NewKeccak256()
+	domainSchemaSha.Write([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+	nameSha := sha3.NewKeccak256()
+	nameSha.Write([]byte("0x Protocol"))
+	versionSha := sha3.NewKeccak256()
+	versionSha.Write([]byte("3.0.0"))
+	chainID := abi.U256(new(big.Int).SetUint64(order.ChainID))
+	domainSha := sha3.NewKeccak256()
+	domainSha.Write(domainSchemaSha.Sum(nil))
+	domainSha.Write(nameSha.Sum(nil))
+	domainSha.Write(versionSha.Sum(nil))
+	domainSha.Write(chainID[:])
+	domainSha.Write(twelveNullBytes[:])
+	domainSha.Write(order.ExchangeAddress[:])
+
+	orderSchemaSha := sha3.NewKeccak256()
+	orderSchemaSha.Write([]byte("Order(address makerAddress,address takerAddress,address feeRecipientAddress,address senderAddress,uint256 makerAssetAmount,uint256 takerAssetAmount,uint256 makerFee,uint256 takerFee,uint256 expirationTimeSeconds,uint256 salt,bytes makerAssetData,bytes takerAssetData,bytes makerFeeAssetData,bytes takerFeeAssetData)"))
+	orderSha := sha3.NewKeccak256()
+	orderSha.Write(orderSchemaSha.Sum(nil))
+	for _, address := range []*Address{order.Maker, order.Taker, order.FeeRecipient, order.SenderAddress} {
+		orderSha.Write(twelveNullBytes[:])
+		orderSha.Write(address[:])
+	}
+	for _, value := range []*Uint256{order.MakerAssetAmount, order.TakerAssetAmount, order.MakerFee, order.TakerFee, order.ExpirationTimestampInSec, order.Salt} {
+		orderSha.Write(value[:])
+	}
+	for _, data := range []AssetData{order.MakerAssetData, order.TakerAssetData, order.MakerFeeAssetData, order.TakerFeeAssetData} {
+		dataSha := sha3.NewKeccak256()
+		dataSha.Write(data[:])
+		orderSha.Write(dataSha.Sum(nil))
+	}
+
+	sha := sha3.NewKeccak256()
+	sha.Write(eip191Header)
+	sha.Write(domainSha.Sum(nil))
+	sha.Write(orderSha.Sum(nil))
+	return sha.Sum(nil)
+}
+
 type jsonOrder struct {
 	Maker                     string  `json:"makerAddress"`
 	Taker                     string  `json:"takerAddress"`
 	MakerAssetData            string  `json:"makerAssetData"`
 	TakerAssetData            string  `json:"takerAssetData"`
+	MakerFeeAssetData         *string `json:"makerFeeAssetData,omitempty"`
+	TakerFeeAssetData         *string `json:"takerFeeAssetData,omitempty"`
 	FeeRecipient              string  `json:"feeRecipientAddress"`
 	ExchangeAddress           string  `json:"exchangeAddress"`
+	ChainID                   *uint64 `json:"chainId,omitempty"`
 	SenderAddress             string  `json:"senderAddress"`
 	MakerAssetAmount          string  `json:"makerAssetAmount"`
 	TakerAssetAmount          string  `json:"takerAssetAmount"`
@@ -234,7 +305,7 @@ func (order *Order) UnmarshalJSON(b []byte) error {
 	if jOrder.Cancelled == "" {
 		jOrder.Cancelled = "false"
 	}
-	return order.fromStrings(
+	if err := order.fromStrings(
 		jOrder.Maker,
 		jOrder.Taker,
 		jOrder.MakerAssetData,
@@ -251,7 +322,30 @@ func (order *Order) UnmarshalJSON(b []byte) error {
 		jOrder.Signature,
 		jOrder.TakerAssetAmountFilled,
 		jOrder.Cancelled,
-	)
+	); err != nil {
+		return err
+	}
+	// v3 orders are recognized by their chain id and fee asset data, which v2
+	// orders don't have
+	if jOrder.ChainID == nil && jOrder.MakerFeeAssetData == nil && jOrder.TakerFeeAssetData == nil {
+		return nil
+	}
+	if jOrder.ChainID == nil {
+		return errors.New("chainId is required for v3 orders")
+	}
+	makerFeeAssetData, err := optionalHexString(jOrder.MakerFeeAssetData)
+	if err != nil {
+		return err
+	}
+	takerFeeAssetData, err := optionalHexString(jOrder.TakerFeeAssetData)
+	if err != nil {
+		return err
+	}
+	order.Version = OrderVersion3
+	order.ChainID = *jOrder.ChainID
+	order.MakerFeeAssetData = makerFeeAssetData
+	order.TakerFeeAssetData = takerFeeAssetData
+	return nil
 }
 
 func (order *Order) MarshalJSON() ([]byte, error) {
@@ -276,19 +370,126 @@ func (order *Order) MarshalJSON() ([]byte, error) {
 	} else {
 		jsonOrder.Cancelled = "false"
 	}
+	if order.IsV3() {
+		chainID := order.ChainID
+		makerFeeAssetData := fmt.Sprintf("0x%x", []byte(order.MakerFeeAssetData))
+		takerFeeAssetData := fmt.Sprintf("0x%x", []byte(order.TakerFeeAssetData))
+		jsonOrder.ChainID = &chainID
+		jsonOrder.MakerFeeAssetData = &makerFeeAssetData
+		jsonOrder.TakerFeeAssetData = &takerFeeAssetData
+	}
 	return json.Marshal(jsonOrder)
 }
 
-func (order *Order) Bytes() []byte {
-	data, _ := rlp.EncodeToBytes(order)
-	return data
+func optionalHexString(value *string) (AssetData, error) {
+	if value == nil {
+		return AssetData{}, nil
+	}
+	data, err := HexStringToBytes(*value)
+	if err != nil {
+		return nil, err
+	}
+	return AssetData(data), nil
 }
 
-func (order *Order) FromBytes(data []byte) (error) {
-	return rlp.DecodeBytes(data, order)
+// orderExtension holds the v3 fields. It is appended to the RLP list of v3
+// orders only, so v2 orders keep their original encoding.
+type orderExtension struct {
+	Version           uint8
+	ChainID           uint64
+	MakerFeeAssetData AssetData
+	TakerFeeAssetData AssetData
 }
 
-func OrderFromBytes(data []byte) (*Order, error)  {
+// legacyOrderFieldCount is the number of RLP list elements in a v2 order
+var legacyOrderFieldCount = func() int {
 	order := Order{}
-	return &order, order.FromBytes(data)
+	order.Initialize()
+	data, _ := rlp.EncodeToBytes(&order)
+	content, _, _ := rlp.SplitList(data)
+	count, _ := rlp.CountValues(content)
+	return count
+}()
+
+// RLPBytes returns the legacy RLP encoding of the order. It is still used
+// where orders leave the relay, such as binary API responses, so existing
+// clients can decode them. v3 orders have their extra fields appended.
+func (order *Order) RLPBytes() []byte {
+	data, _ := rlp.EncodeToBytes(order)
+	if !order.IsV3() {
+		return data
+	}
+	content, _, err := rlp.SplitList(data)
+	if err != nil {
+		return data
+	}
+	extension, err := rlp.EncodeToBytes(&orderExtension{
+		order.Version,
+		order.ChainID,
+		order.MakerFeeAssetData,
+		order.TakerFeeAssetData,
+	})
+	if err != nil {
+		return data
+	}
+	extended, err := wrapList(content, extension)
+	if err != nil {
+		return data
+	}
+	return extended
+}
+
+// wrapList encodes the concatenation of already encoded values as an RLP list
+func wrapList(values ...[]byte) ([]byte, error) {
+	raw := make([]rlp.RawValue, len(values))
+	for i, value := range values {
+		raw[i] = rlp.RawValue(value)
+	}
+	return rlp.EncodeToBytes(raw)
+}
+
+// fromRLP decodes the legacy RLP encoding produced by RLPBytes
+func (order *Order) fromRLP(data []byte) (error) {
+	content, _, err := rlp.SplitList(data)
+	if err != nil {
+		return err
+	}
+	count, err := rlp.CountValues(content)
+	if err != nil {
+		return err
+	}
+	if count != legacyOrderFieldCount+1 {
+		if err := rlp.DecodeBytes(data, order); err != nil {
+			return err
+		}
+		order.Version = 0
+		order.ChainID = 0
+		order.MakerFeeAssetData = AssetData{}
+		order.TakerFeeAssetData = AssetData{}
+		return nil
+	}
+	extension := content
+	for i := 0; i < legacyOrderFieldCount; i++ {
+		_, _, rest, err := rlp.Split(extension)
+		if err != nil {
+			return err
+		}
+		extension = rest
+	}
+	legacy, err := wrapList(content[:len(content)-len(extension)])
+	if err != nil {
+		return err
+	}
+	if err := rlp.DecodeBytes(legacy, order); err != nil {
+		return err
+	}
+	ext := orderExtension{}
+	if err := rlp.DecodeBytes(extension, &ext); err != nil {
+		return err
+	}
+	order.Version = ext.Version
+	order.ChainID = ext.ChainID
+	order.MakerFeeAssetData = ext.MakerFeeAssetData
+	order.TakerFeeAssetData = ext.TakerFeeAssetData
+	return nil
 }
@@ -0,0 +1,207 @@
+package types
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// OrderEncodingVersion is the first byte of orders encoded by Order.Bytes().
+// Legacy RLP encoded orders always start with an RLP list prefix (0xc0 or
+// above), so the two encodings can't be confused.
+const OrderEncodingVersion = byte(1)
+
+// OrderSchemaVersion identifies the encoding produced by Order.Bytes(), so
+// that consumers can tell which schema a queued order was written with.
+const OrderSchemaVersion = "order-1"
+
+// rlpListPrefix is the smallest first byte of an RLP encoded list
+const rlpListPrefix = byte(0xc0)
+
+// orderField pairs a field of an Order with the tag identifying it in the
+// encoding. Tags must never be reused or renumbered; new fields get new tags,
+// and decoders skip tags they don't know, so services running different
+// builds can exchange orders.
+type orderField struct {
+	tag   uint64
+	value interface{}
+}
+
+func (order *Order) fields() []orderField {
+	return []orderField{
+		{1, &order.Maker},
+		{2, &order.Taker},
+		{3, &order.MakerAssetAddress},
+		{4, &order.TakerAssetAddress},
+		{5, &order.MakerAssetData},
+		{6, &order.TakerAssetData},
+		{7, &order.FeeRecipient},
+		{8, &order.ExchangeAddress},
+		{9, &order.SenderAddress},
+		{10, &order.MakerAssetAmount},
+		{11, &order.TakerAssetAmount},
+		{12, &order.MakerFee},
+		{13, &order.TakerFee},
+		{14, &order.ExpirationTimestampInSec},
+		{15, &order.Salt},
+		{16, &order.Signature},
+		{17, &order.TakerAssetAmountFilled},
+		{18, &order.Cancelled},
+		{19, &order.PoolID},
+		{20, &order.Version},
+		{21, &order.ChainID},
+		{22, &order.MakerFeeAssetData},
+		{23, &order.TakerFeeAssetData},
+	}
+}
+
+// Bytes encodes the order as a version byte followed by a (tag, length,
+// value) triple for each field, with tags and lengths as uvarints.
+func (order *Order) Bytes() []byte {
+	data := []byte{OrderEncodingVersion}
+	for _, field := range order.fields() {
+		value, ok := encodeOrderField(field.value)
+		if !ok {
+			continue
+		}
+		data = appendUvarint(data, field.tag)
+		data = appendUvarint(data, uint64(len(value)))
+		data = append(data, value...)
+	}
+	return data
+}
+
+// FromBytes decodes orders produced by Bytes(), as well as orders in the
+// legacy RLP encoding.
+func (order *Order) FromBytes(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("Empty order data")
+	}
+	if data[0] >= rlpListPrefix {
+		return order.fromRLP(data)
+	}
+	if data[0] != OrderEncodingVersion {
+		return fmt.Errorf("Unsupported order encoding version %v", data[0])
+	}
+	order.Initialize()
+	order.PoolID = []byte{}
+	fields := make(map[uint64]interface{})
+	for _, field := range order.fields() {
+		fields[field.tag] = field.value
+	}
+	data = data[1:]
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("Malformed order field tag")
+		}
+		data = data[n:]
+		length, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < length {
+			return fmt.Errorf("Malformed length for order field %v", tag)
+		}
+		value := data[n : n+int(length)]
+		data = data[n+int(length):]
+		field, ok := fields[tag]
+		if !ok {
+			// Written by a newer build; skip it
+			continue
+		}
+		if err := decodeOrderField(field, value); err != nil {
+			return fmt.Errorf("Order field %v: %v", tag, err.Error())
+		}
+	}
+	return nil
+}
+
+func OrderFromBytes(data []byte) (*Order, error) {
+	order := Order{}
+	return &order, order.FromBytes(data)
+}
+
+func appendUvarint(data []byte, value uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(data, buf[:binary.PutUvarint(buf, value)]...)
+}
+
+// encodeOrderField returns the encoded value of a field, and false if the
+// field is unset and should be left out.
+func encodeOrderField(field interface{}) ([]byte, bool) {
+	switch value := field.(type) {
+	case **Address:
+		if *value == nil {
+			return nil, false
+		}
+		return (*value)[:], true
+	case **Uint256:
+		if *value == nil {
+			return nil, false
+		}
+		// Leading zeros are dropped, as most amounts are much smaller than
+		// 32 bytes
+		i := 0
+		for i < len(*value) && (*value)[i] == 0 {
+			i++
+		}
+		return (*value)[i:], true
+	case *AssetData:
+		return []byte(*value), true
+	case *Signature:
+		return []byte(*value), true
+	case *[]byte:
+		return *value, true
+	case *bool:
+		if *value {
+			return []byte{1}, true
+		}
+		return []byte{0}, true
+	case *uint8:
+		return []byte{*value}, true
+	case *uint64:
+		return appendUvarint(nil, *value), true
+	}
+	return nil, false
+}
+
+func decodeOrderField(field interface{}, data []byte) error {
+	switch value := field.(type) {
+	case **Address:
+		if len(data) != 20 {
+			return fmt.Errorf("Invalid address length %v", len(data))
+		}
+		*value = &Address{}
+		copy((*value)[:], data)
+	case **Uint256:
+		if len(data) > 32 {
+			return fmt.Errorf("Invalid uint256 length %v", len(data))
+		}
+		*value = &Uint256{}
+		copy((*value)[32-len(data):], data)
+	case *AssetData:
+		*value = make(AssetData, len(data))
+		copy(*value, data)
+	case *Signature:
+		*value = make(Signature, len(data))
+		copy(*value, data)
+	case *[]byte:
+		*value = make([]byte, len(data))
+		copy(*value, data)
+	case *bool:
+		if len(data) != 1 {
+			return fmt.Errorf("Invalid bool length %v", len(data))
+		}
+		*value = data[0] != 0
+	case *uint8:
+		if len(data) != 1 {
+			return fmt.Errorf("Invalid uint8 length %v", len(data))
+		}
+		*value = data[0]
+	case *uint64:
+		decoded, n := binary.Uvarint(data)
+		if n != len(data) {
+			return errors.New("Invalid uvarint")
+		}
+		*value = decoded
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package types_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/notegio/openrelay/types"
+	"testing"
+)
+
+func getLegacyOrderBytes(t *testing.T) []byte {
+	orderBytes, err := hex.DecodeString("f9020a94627306090abab3a6e1400e9345bc60c78a8bef57940000000000000000000000000000000000000000941dad4783cf3fe3085c1426157ab175a6119a04ba9405d090b51c40b020eab3bfcb6a2dff130df22e9ca4f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04baa4f47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c9400000000000000000000000000000000000000009490fe2af704b34e0224bf2299c838e04d4dcf1364940000000000000000000000000000000000000000a0000000000000000000000000000000000000000000000002b5e3af16b1880000a00000000000000000000000000000000000000000000000000de0b6b3a7640000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000000000000a00000000000000000000000000000000000000000000000000000000159938ac4a0000643508ff7019bfb134363a86e98746f6c33262e68daf992b8df064217222bb8421ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03a000000000000000000000000000000000000000000000000000000000000000008080")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return orderBytes
+}
+
+func TestOrderEncodingFromLegacy(t *testing.T) {
+	legacyOrder, err := types.OrderFromBytes(getLegacyOrderBytes(t))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	data := legacyOrder.Bytes()
+	if data[0] != types.OrderEncodingVersion {
+		t.Errorf("Unexpected encoding version %v", data[0])
+	}
+	order, err := types.OrderFromBytes(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(order.Hash(), legacyOrder.Hash()) {
+		t.Errorf("Unequal hashes: %#x != %#x", order.Hash(), legacyOrder.Hash())
+	}
+	if !bytes.Equal(order.Signature, legacyOrder.Signature) || !bytes.Equal(order.PoolID, legacyOrder.PoolID) {
+		t.Errorf("Unexpected order: %#x %#x", order.Signature[:], order.PoolID)
+	}
+	if !order.Signature.Verify(order.Maker, order.Hash()) {
+		t.Errorf("Invalid signature")
+	}
+	if !bytes.Equal(order.RLPBytes(), getLegacyOrderBytes(t)) {
+		t.Errorf("Legacy encoding changed in round trip")
+	}
+}
+
+func TestOrderEncodingV3(t *testing.T) {
+	order := getV3Order(t)
+	order.Cancelled = true
+	order.PoolID = []byte("pool")
+	order2, err := types.OrderFromBytes(order.Bytes())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !order2.IsV3() || order2.ChainID != order.ChainID || !order2.Cancelled || string(order2.PoolID) != "pool" {
+		t.Errorf("Unexpected order: %v %v %v %v", order2.Version, order2.ChainID, order2.Cancelled, order2.PoolID)
+	}
+	if !bytes.Equal(order.Hash(), order2.Hash()) {
+		t.Errorf("Unequal hashes: %#x != %#x", order.Hash(), order2.Hash())
+	}
+}
+
+func TestOrderEncodingUnknownField(t *testing.T) {
+	order := getV3Order(t)
+	// A field added by a newer build, with tag 200 (encoded as a two byte
+	// uvarint) and a three byte value
+	data := append(order.Bytes(), 0xc8, 0x01, 0x03, 0xaa, 0xbb, 0xcc)
+	order2, err := types.OrderFromBytes(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(order.Hash(), order2.Hash()) {
+		t.Errorf("Unequal hashes: %#x != %#x", order.Hash(), order2.Hash())
+	}
+}
+
+func TestOrderEncodingMalformed(t *testing.T) {
+	data := getV3Order(t).Bytes()
+	if _, err := types.OrderFromBytes(data[:len(data)-1]); err == nil {
+		t.Errorf("Expected truncated order to fail")
+	}
+	if _, err := types.OrderFromBytes(append([]byte{2}, data[1:]...)); err == nil {
+		t.Errorf("Expected unknown encoding version to fail")
+	}
+	if _, err := types.OrderFromBytes([]byte{}); err == nil {
+		t.Errorf("Expected empty order to fail")
+	}
+}
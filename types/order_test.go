@@ -5,6 +5,8 @@ import (
 	"database/sql/driver"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/notegio/openrelay/types"
 	"io/ioutil"
 	"reflect"
@@ -95,7 +97,7 @@ func TestOrderFromRawbytes(t *testing.T) {
 	if !order.Signature.Verify(order.Maker, order.Hash()) {
 		t.Errorf("Invalid signature")
 	}
-	if !bytes.Equal(orderBytes, order.Bytes()) {
+	if !bytes.Equal(orderBytes, order.RLPBytes()) {
 		t.Errorf("Bytes no longer match")
 	}
 }
@@ -233,3 +235,144 @@ func TestJsonUnmarshal(t *testing.T) {
 		t.Errorf("Failed to verify order with signature: %#x", newOrder.Signature)
 	}
 }
+
+func getV3Order(t *testing.T) *types.Order {
+	order := &types.Order{}
+	order.Initialize()
+	exchangeAddressBytes, err := types.HexStringToBytes("61935cbdd02287b511119ddb11aeb42f1593b7ef")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	copy(order.ExchangeAddress[:], exchangeAddressBytes)
+	feeAssetData, err := types.HexStringToBytes("f47261b0000000000000000000000000c02aaa39b223fe8d0a0e5c4f27ead9083c756cc2")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	order.Version = types.OrderVersion3
+	order.ChainID = 1
+	order.MakerFeeAssetData = feeAssetData
+	order.TakerFeeAssetData = types.AssetData{}
+	order.MakerFee[31] = 1
+	return order
+}
+
+// The order schema and domain schema hashes published as
+// _EIP712_ORDER_SCHEMA_HASH in the v3 LibOrder contract and
+// _EIP712_DOMAIN_SEPARATOR_SCHEMA_HASH in the v3 LibEIP712 contract.
+const (
+	v3OrderSchemaHash  = "f80322eb8376aafb64eadf8f0d7623f22130fd9491a221e902b713cb984a7534"
+	v3DomainSchemaHash = "8b73c3c69bb8fe3d512ecc4cf759cc79239f7b179b0ffacaa9a75d522b39400f"
+)
+
+// getV3NullOrder returns the all-null order the 0x order-utils hashing tests
+// use, on their fake exchange address and chain 50.
+func getV3NullOrder(t *testing.T) *types.Order {
+	order := &types.Order{}
+	order.Initialize()
+	exchangeAddressBytes, err := types.HexStringToBytes("1dc4c1cefef38a777b15aa20260a54e584b16c48")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	copy(order.ExchangeAddress[:], exchangeAddressBytes)
+	order.Version = types.OrderVersion3
+	order.ChainID = 50
+	order.MakerFeeAssetData = types.AssetData(make([]byte, 20))
+	order.TakerFeeAssetData = types.AssetData(make([]byte, 20))
+	return order
+}
+
+func TestOrderV3SchemaHashes(t *testing.T) {
+	domainSchema := hex.EncodeToString(crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)")))
+	if domainSchema != v3DomainSchemaHash {
+		t.Errorf("Unexpected domain schema hash %v", domainSchema)
+	}
+	orderSchema := hex.EncodeToString(crypto.Keccak256([]byte("Order(address makerAddress,address takerAddress,address feeRecipientAddress,address senderAddress,uint256 makerAssetAmount,uint256 takerAssetAmount,uint256 makerFee,uint256 takerFee,uint256 expirationTimeSeconds,uint256 salt,bytes makerAssetData,bytes takerAssetData,bytes makerFeeAssetData,bytes takerFeeAssetData)")))
+	if orderSchema != v3OrderSchemaHash {
+		t.Errorf("Unexpected order schema hash %v", orderSchema)
+	}
+}
+
+func TestOrderV3Hash(t *testing.T) {
+	// Spell out the EIP-712 encoding of the null order word by word, starting
+	// from the published schema hashes, rather than trusting Order.Hash().
+	word := func(hexString string) []byte {
+		data, err := hex.DecodeString(fmt.Sprintf("%064v", hexString))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return data
+	}
+	nullAssetDataHash := crypto.Keccak256(make([]byte, 20))
+	domainHash := crypto.Keccak256(
+		word(v3DomainSchemaHash),
+		crypto.Keccak256([]byte("0x Protocol")),
+		crypto.Keccak256([]byte("3.0.0")),
+		word("32"),
+		word("1dc4c1cefef38a777b15aa20260a54e584b16c48"),
+	)
+	orderFields := [][]byte{word(v3OrderSchemaHash)}
+	for i := 0; i < 10; i++ {
+		// Four addresses and six uint256 values, all zero
+		orderFields = append(orderFields, word("0"))
+	}
+	for i := 0; i < 4; i++ {
+		orderFields = append(orderFields, nullAssetDataHash)
+	}
+	orderHash := crypto.Keccak256(orderFields...)
+	expected := hex.EncodeToString(crypto.Keccak256([]byte{0x19, 0x01}, domainHash, orderHash))
+
+	order := getV3NullOrder(t)
+	if hex.EncodeToString(order.Hash()) != expected {
+		t.Errorf("Unexpected v3 hash %x, expected %v", order.Hash(), expected)
+	}
+	order.ChainID = 3
+	if hex.EncodeToString(order.Hash()) == expected {
+		t.Errorf("Expected chain id to change the hash")
+	}
+	v2Order := getV3NullOrder(t)
+	v2Order.Version = types.OrderVersion2
+	if hex.EncodeToString(v2Order.Hash()) == expected {
+		t.Errorf("Expected version to change the hash")
+	}
+}
+
+func TestOrderV3ToFromBytes(t *testing.T) {
+	order := getV3Order(t)
+	order2, err := types.OrderFromBytes(order.Bytes())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !order2.IsV3() || order2.ChainID != 1 {
+		t.Errorf("Expected v3 order on chain 1, got version %v chain %v", order2.Version, order2.ChainID)
+	}
+	if !bytes.Equal(order.MakerFeeAssetData, order2.MakerFeeAssetData) {
+		t.Errorf("Unexpected MakerFeeAssetData: %#x", order2.MakerFeeAssetData[:])
+	}
+	if !bytes.Equal(order.Hash(), order2.Hash()) {
+		t.Errorf("Unequal hashes: %#x != %#x", order.Hash(), order2.Hash())
+	}
+}
+
+func TestOrderV3Json(t *testing.T) {
+	order := getV3Order(t)
+	data, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Contains(data, []byte(`"chainId":1`)) || !bytes.Contains(data, []byte(`"makerFeeAssetData":"0xf47261b0000000000000000000000000c02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"`)) || !bytes.Contains(data, []byte(`"takerFeeAssetData":"0x"`)) {
+		t.Errorf("Got unexpected JSON value: %v", string(data))
+	}
+	order2 := &types.Order{}
+	if err := json.Unmarshal(data, order2); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !order2.IsV3() {
+		t.Errorf("Expected v3 order")
+	}
+	if !bytes.Equal(order.Hash(), order2.Hash()) {
+		t.Errorf("Unequal hashes: %#x != %#x", order.Hash(), order2.Hash())
+	}
+	if err := json.Unmarshal([]byte(`{"makerFeeAssetData":"0x"}`), order2); err == nil {
+		t.Errorf("Expected v3 order without chainId to be rejected")
+	}
+}
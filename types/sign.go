@@ -0,0 +1,89 @@
+package types
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+)
+
+// BuildOrder returns an unsigned v2 order with the given values. Nil amounts
+// are left at zero, and the asset addresses are derived from the asset data.
+// Use SetV3 to turn it into a v3 order before signing.
+func BuildOrder(maker, taker, feeRecipient, exchangeAddress, senderAddress *Address, makerAssetData, takerAssetData AssetData, makerAssetAmount, takerAssetAmount, makerFee, takerFee, expirationTimestampInSec, salt *big.Int) *Order {
+	order := &Order{}
+	order.Initialize()
+	for dst, src := range map[*Address]*Address{
+		order.Maker:           maker,
+		order.Taker:           taker,
+		order.FeeRecipient:    feeRecipient,
+		order.ExchangeAddress: exchangeAddress,
+		order.SenderAddress:   senderAddress,
+	} {
+		if src != nil {
+			copy(dst[:], src[:])
+		}
+	}
+	order.MakerAssetData = append(AssetData{}, makerAssetData...)
+	order.TakerAssetData = append(AssetData{}, takerAssetData...)
+	order.MakerAssetAddress = order.MakerAssetData.Address()
+	order.TakerAssetAddress = order.TakerAssetData.Address()
+	for dst, src := range map[*Uint256]*big.Int{
+		order.MakerAssetAmount:         makerAssetAmount,
+		order.TakerAssetAmount:         takerAssetAmount,
+		order.MakerFee:                 makerFee,
+		order.TakerFee:                 takerFee,
+		order.ExpirationTimestampInSec: expirationTimestampInSec,
+		order.Salt:                     salt,
+	} {
+		if src != nil {
+			copy(dst[:], abi.U256(new(big.Int).Set(src)))
+		}
+	}
+	return order
+}
+
+// SetV3 makes `order` a v3 order for the given chain, with fees paid in the
+// given assets. Any existing signature is invalidated, as the hash changes.
+func (order *Order) SetV3(chainID uint64, makerFeeAssetData, takerFeeAssetData AssetData) {
+	order.Version = OrderVersion3
+	order.ChainID = chainID
+	order.MakerFeeAssetData = append(AssetData{}, makerFeeAssetData...)
+	order.TakerFeeAssetData = append(AssetData{}, takerFeeAssetData...)
+}
+
+// SignHash signs `hash` with `key`, returning a Signature of type `sigType`.
+// Only EIP712 and EthSign signatures can be produced from a private key.
+func SignHash(key *ecdsa.PrivateKey, hash []byte, sigType byte) (Signature, error) {
+	signedBytes := hash
+	switch sigType {
+	case SigTypeEIP712:
+	case SigTypeEthSign:
+		signedBytes = crypto.Keccak256(append([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%v", len(hash[:]))), hash[:]...))
+	default:
+		return nil, fmt.Errorf("Unsupported signature type %v", sigType)
+	}
+	sig, err := crypto.Sign(signedBytes, key)
+	if err != nil {
+		return nil, err
+	}
+	// go-ethereum returns [r, s, v] with v as 0 or 1, while 0x expects
+	// [v, r, s, type] with v as 27 or 28
+	return Signature(append(append([]byte{sig[64] + 27}, sig[:64]...), sigType)), nil
+}
+
+// Sign sets the maker of `order` to the address of `key` and signs it. Any
+// changes to the order after signing will invalidate the signature.
+func (order *Order) Sign(key *ecdsa.PrivateKey, sigType byte) error {
+	if order.Maker == nil {
+		order.Maker = &Address{}
+	}
+	copy(order.Maker[:], crypto.PubkeyToAddress(key.PublicKey).Bytes())
+	sig, err := SignHash(key, order.Hash(), sigType)
+	if err != nil {
+		return err
+	}
+	order.Signature = sig
+	return nil
+}
@@ -0,0 +1,105 @@
+package types_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"testing"
+)
+
+func buildTestOrder(t *testing.T) *types.Order {
+	exchange := &types.Address{}
+	exchangeBytes, _ := hex.DecodeString("90fe2af704b34e0224bf2299c838e04d4dcf1364")
+	copy(exchange[:], exchangeBytes)
+	makerAssetData, _ := types.HexStringToBytes("0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba")
+	takerAssetData, _ := types.HexStringToBytes("0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c")
+	return types.BuildOrder(
+		nil,
+		nil,
+		nil,
+		exchange,
+		nil,
+		makerAssetData,
+		takerAssetData,
+		big.NewInt(50000000000000000),
+		big.NewInt(1000000000000000000),
+		nil,
+		nil,
+		big.NewInt(1528383932),
+		big.NewInt(12345),
+	)
+}
+
+func TestBuildOrder(t *testing.T) {
+	order := buildTestOrder(t)
+	if order.MakerAssetAddress.String() != "0x1dad4783cf3fe3085c1426157ab175a6119a04ba" {
+		t.Errorf("Unexpected maker asset address: %v", order.MakerAssetAddress)
+	}
+	if order.MakerAssetAmount.Big().Cmp(big.NewInt(50000000000000000)) != 0 || order.Salt.Big().Cmp(big.NewInt(12345)) != 0 {
+		t.Errorf("Unexpected amounts: %v %v", order.MakerAssetAmount, order.Salt)
+	}
+	if order.MakerFee.Big().Sign() != 0 || !bytes.Equal(order.Taker[:], make([]byte, 20)) {
+		t.Errorf("Expected unset values to be zero")
+	}
+	order2, err := types.OrderFromBytes(order.Bytes())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(order.Hash(), order2.Hash()) {
+		t.Errorf("Unequal hashes: %#x != %#x", order.Hash(), order2.Hash())
+	}
+}
+
+func TestSignOrder(t *testing.T) {
+	key, err := crypto.HexToECDSA("4f3edf983ac636a65a842ce7c78d9aa706d3b113bce9c46f30d7d21715b23b1d")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, sigType := range []byte{types.SigTypeEIP712, types.SigTypeEthSign} {
+		order := buildTestOrder(t)
+		if err := order.Sign(key, sigType); err != nil {
+			t.Fatal(err.Error())
+		}
+		if order.Maker.String() != "0x90f8bf6a479f320ead074411a4b0e7944ea8c9c1" {
+			t.Errorf("Unexpected maker: %v", order.Maker)
+		}
+		if order.Signature.Type() != sigType || !order.Signature.Verify(order.Maker, order.Hash()) {
+			t.Errorf("Invalid signature of type %v: %#x", sigType, order.Signature[:])
+		}
+	}
+}
+
+func TestSignOrderV3(t *testing.T) {
+	key, err := crypto.HexToECDSA("4f3edf983ac636a65a842ce7c78d9aa706d3b113bce9c46f30d7d21715b23b1d")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	order := buildTestOrder(t)
+	v2Hash := order.Hash()
+	order.SetV3(1, order.TakerAssetData, types.AssetData{})
+	if err := order.Sign(key, types.SigTypeEIP712); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !order.IsV3() || bytes.Equal(order.Hash(), v2Hash) {
+		t.Errorf("Expected a v3 order")
+	}
+	if !order.Signature.Verify(order.Maker, order.Hash()) {
+		t.Errorf("Invalid signature: %#x", order.Signature[:])
+	}
+}
+
+func TestSignOrderUnsupportedType(t *testing.T) {
+	key, err := crypto.HexToECDSA("4f3edf983ac636a65a842ce7c78d9aa706d3b113bce9c46f30d7d21715b23b1d")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	order := buildTestOrder(t)
+	if err := order.Sign(key, types.SigTypeWallet); err == nil {
+		t.Errorf("Expected wallet signatures to be unsupported")
+	}
+	if len(order.Signature) != 0 {
+		t.Errorf("Unexpected signature: %#x", order.Signature[:])
+	}
+}
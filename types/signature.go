@@ -27,37 +27,122 @@ const (
 
 type Signature []byte
 
+// WalletVerifier checks Wallet signatures by asking the signing contract
+// whether `signature` is valid for `hash`, as described by EIP-1271.
+type WalletVerifier interface {
+	IsValidWalletSignature(wallet *Address, hash []byte, signature []byte) (bool, error)
+}
+
+var walletVerifier WalletVerifier
+
+// SetWalletVerifier sets the WalletVerifier used to check Wallet signatures.
+// Until one is set, Wallet signatures are neither supported nor valid.
+func SetWalletVerifier(verifier WalletVerifier) {
+	walletVerifier = verifier
+}
+
+// ExchangeVerifier checks signatures whose validity is recorded by the 0x
+// exchange contract at `exchange`, rather than in the signature itself.
+type ExchangeVerifier interface {
+	// IsPreSigned returns true if `signer` has approved `hash` with the
+	// exchange's preSign function
+	IsPreSigned(exchange *Address, hash []byte, signer *Address) (bool, error)
+	// IsValidValidatorSignature returns true if `signer` has approved the
+	// validator named in `signature`, and the validator accepts it
+	IsValidValidatorSignature(exchange *Address, hash []byte, signer *Address, signature Signature) (bool, error)
+}
+
+var exchangeVerifier ExchangeVerifier
+
+var allowedValidators = make(map[Address]bool)
+
+// SetExchangeVerifier sets the ExchangeVerifier used to check PreSigned and
+// Validator signatures. Until one is set, neither type is supported.
+func SetExchangeVerifier(verifier ExchangeVerifier) {
+	exchangeVerifier = verifier
+}
+
+// SetAllowedValidators sets the validator contracts whose signatures are
+// supported. Validators can't be trusted in general, as they could invalidate
+// orders without any event we could watch for, so only whitelisted validators
+// are accepted.
+func SetAllowedValidators(validators []*Address) {
+	allowed := make(map[Address]bool)
+	for _, validator := range validators {
+		allowed[*validator] = true
+	}
+	allowedValidators = allowed
+}
+
 func (sig Signature) Type() (byte) {
 	return sig[len(sig[:])-1]
 }
 
+// Verify returns true if `sig` is a valid signature of `hash` by `address`.
+// PreSigned and Validator signatures depend on the exchange contract, so they
+// are only valid when checked with VerifyExchange. Signatures that can't be
+// checked because a contract call failed are treated as invalid.
 func (sig Signature) Verify(address *Address, hash []byte) bool {
+	valid, err := sig.VerifyExchange(nil, address, hash)
+	if err != nil {
+		log.Printf("Error verifying signature for %#x: %v", address[:], err.Error())
+	}
+	return valid
+}
+
+// VerifyExchange returns true if `sig` is a valid signature of `hash` by
+// `address` for orders on the exchange contract at `exchange`. Wallet,
+// Validator and PreSigned signatures are checked with contract calls; if
+// one fails, an error is returned and the signature's validity is unknown,
+// so the order should be checked again later rather than discarded.
+func (sig Signature) VerifyExchange(exchange *Address, address *Address, hash []byte) (bool, error) {
 	if len(sig[:]) < 1 {
-		return false
+		return false, nil
 	}
 	switch sigType := sig.Type(); sigType {
 	case SigTypeEIP712:
-		return sig.verifyEIP712(address, hash)
+		return sig.verifyEIP712(address, hash), nil
 	case SigTypeEthSign:
-		return sig.verifyEthSign(address, hash)
+		return sig.verifyEthSign(address, hash), nil
 	case SigTypeWallet:
 		return sig.verifyWallet(address, hash)
 	case SigTypeValidator:
-		return sig.verifyValidator(address, hash)
+		return sig.verifyValidator(exchange, address, hash)
+	case SigTypePreSigned:
+		return sig.verifyPreSigned(exchange, address, hash)
 	default:
-		return false
+		return false, nil
 	}
 }
 
-func (sig Signature) Supported() bool {
+// Supported returns true for EIP712 and EthSign signatures, which can be
+// verified without contract calls. Signature types listed in `allowed` are
+// also supported, if the relay is able to verify them.
+func (sig Signature) Supported(allowed ...byte) bool {
+	if len(sig[:]) < 1 {
+		return false
+	}
 	switch sigType := sig.Type(); sigType {
 	case SigTypeEIP712:
 		return true
 	case SigTypeEthSign:
 		return true
-	default:
-		return false
 	}
+	for _, sigType := range allowed {
+		if sigType != sig.Type() {
+			continue
+		}
+		switch sigType {
+		case SigTypeWallet:
+			return walletVerifier != nil
+		case SigTypePreSigned:
+			return exchangeVerifier != nil && len(sig[:]) == 1
+		case SigTypeValidator:
+			validator := sig.Validator()
+			return exchangeVerifier != nil && validator != nil && allowedValidators[*validator]
+		}
+	}
+	return false
 }
 
 func (sig Signature) verifyEIP712(address *Address, hash []byte) bool {
@@ -106,22 +191,46 @@ func (sig Signature) verifyEthSign(address *Address, hash []byte) bool {
 	return bytes.Equal(address[:], recoverAddress[:])
 }
 
-func (sig Signature) verifyWallet(address *Address, hash []byte) bool {
-	// We may never support Wallet verification. Even if we check that an order
-	// is verified as we ingest the order, each contract could have custom logic
-	// that invalidates orders later, without any way for us to determine that
-	// aside from checking every order on a repeating basis. That doesn't scale
-	// well.
-	return false
+func (sig Signature) verifyWallet(address *Address, hash []byte) (bool, error) {
+	// Each wallet contract could have custom logic that invalidates orders
+	// later, without any event we could watch for, so open orders with Wallet
+	// signatures need to be checked again periodically.
+	if walletVerifier == nil {
+		return false, nil
+	}
+	return walletVerifier.IsValidWalletSignature(address, hash, sig[:len(sig)-1])
 }
 
-func (sig Signature) verifyValidator(address *Address, hash []byte) bool {
-	// We don't currently support any validators, but we might support a
-	// whitelist of validators in the future. To support a given validator, we
-	// need to be sure that the validator provides adequate information that we
-	// can monitor for events that would invalidate an order in a scalable
-	// manner.
-	return false
+// Validator returns the address of the validator contract for Validator
+// signatures, which is stored just before the signature type. It returns nil
+// for other signature types.
+func (sig Signature) Validator() *Address {
+	if len(sig[:]) < 21 || sig.Type() != SigTypeValidator {
+		return nil
+	}
+	validator := &Address{}
+	copy(validator[:], sig[len(sig)-21:len(sig)-1])
+	return validator
+}
+
+func (sig Signature) verifyValidator(exchange *Address, address *Address, hash []byte) (bool, error) {
+	// We only support a whitelist of validators, which we trust to provide
+	// enough information to monitor for events that would invalidate an order
+	// in a scalable manner.
+	validator := sig.Validator()
+	if exchange == nil || exchangeVerifier == nil || validator == nil || !allowedValidators[*validator] {
+		return false, nil
+	}
+	return exchangeVerifier.IsValidValidatorSignature(exchange, hash, address, sig)
+}
+
+func (sig Signature) verifyPreSigned(exchange *Address, address *Address, hash []byte) (bool, error) {
+	// Once an order has been presigned it can only be invalidated by
+	// cancelling it, so we don't need to check it again later.
+	if exchange == nil || exchangeVerifier == nil || len(sig[:]) != 1 {
+		return false, nil
+	}
+	return exchangeVerifier.IsPreSigned(exchange, hash, address)
 }
 
 func (sig Signature) MarshalJSON() ([]byte, error) {
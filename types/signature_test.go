@@ -1,6 +1,7 @@
 package types_test
 
 import (
+	"errors"
 	"github.com/notegio/openrelay/types"
 	"testing"
 	"log"
@@ -50,3 +51,137 @@ func TestVerifyEIP712Sig(t *testing.T) {
 		t.Fatalf("Signature invalid: %#x", signature[:])
 	}
 }
+
+type mockWalletVerifier struct {
+	valid bool
+	err   error
+	hash  []byte
+	sig   []byte
+}
+
+func (verifier *mockWalletVerifier) IsValidWalletSignature(wallet *types.Address, hash []byte, signature []byte) (bool, error) {
+	verifier.hash = hash
+	verifier.sig = signature
+	return verifier.valid, verifier.err
+}
+
+func TestVerifyWalletSig(t *testing.T) {
+	defer types.SetWalletVerifier(nil)
+	signature := types.Signature{0xde, 0xad, types.SigTypeWallet}
+	wallet := &types.Address{}
+	hash := []byte{1, 2, 3}
+	if signature.Supported(types.SigTypeWallet) {
+		t.Errorf("Wallet signatures should not be supported without a verifier")
+	}
+	if signature.Verify(wallet, hash) {
+		t.Errorf("Wallet signatures should not verify without a verifier")
+	}
+	verifier := &mockWalletVerifier{valid: true}
+	types.SetWalletVerifier(verifier)
+	if signature.Supported() {
+		t.Errorf("Wallet signatures should only be supported when allowed")
+	}
+	if !signature.Supported(types.SigTypeWallet) {
+		t.Errorf("Wallet signatures should be supported when allowed")
+	}
+	if !signature.Verify(wallet, hash) {
+		t.Errorf("Expected the wallet to accept the signature")
+	}
+	if string(verifier.sig) != string([]byte{0xde, 0xad}) || string(verifier.hash) != string(hash) {
+		t.Errorf("Unexpected wallet call: %#x, %#x", verifier.hash, verifier.sig)
+	}
+	verifier.valid = false
+	if signature.Verify(wallet, hash) {
+		t.Errorf("Expected the wallet to reject the signature")
+	}
+	verifier.err = errors.New("connection refused")
+	if valid, err := signature.VerifyExchange(nil, wallet, hash); valid || err == nil {
+		t.Errorf("Expected a failed wallet call to return an error")
+	}
+}
+
+type mockExchangeVerifier struct {
+	preSigned bool
+	valid     bool
+	err       error
+	exchange  *types.Address
+}
+
+func (verifier *mockExchangeVerifier) IsPreSigned(exchange *types.Address, hash []byte, signer *types.Address) (bool, error) {
+	verifier.exchange = exchange
+	return verifier.preSigned, verifier.err
+}
+
+func (verifier *mockExchangeVerifier) IsValidValidatorSignature(exchange *types.Address, hash []byte, signer *types.Address, signature types.Signature) (bool, error) {
+	verifier.exchange = exchange
+	return verifier.valid, verifier.err
+}
+
+func TestVerifyPreSignedSig(t *testing.T) {
+	defer types.SetExchangeVerifier(nil)
+	signature := types.Signature{types.SigTypePreSigned}
+	exchange := &types.Address{}
+	exchange[19] = 1
+	maker := &types.Address{}
+	hash := []byte{1, 2, 3}
+	if signature.Supported(types.SigTypePreSigned) {
+		t.Errorf("PreSigned signatures should not be supported without a verifier")
+	}
+	verifier := &mockExchangeVerifier{preSigned: true}
+	types.SetExchangeVerifier(verifier)
+	if signature.Supported() || !signature.Supported(types.SigTypePreSigned) {
+		t.Errorf("PreSigned signatures should only be supported when allowed")
+	}
+	if signature.Verify(maker, hash) {
+		t.Errorf("PreSigned signatures should not verify without an exchange")
+	}
+	if valid, err := signature.VerifyExchange(exchange, maker, hash); !valid || err != nil {
+		t.Errorf("Expected the presigned order to verify")
+	}
+	if *verifier.exchange != *exchange {
+		t.Errorf("Unexpected exchange %#x", verifier.exchange[:])
+	}
+	verifier.preSigned = false
+	if valid, _ := signature.VerifyExchange(exchange, maker, hash); valid {
+		t.Errorf("Expected orders that weren't presigned to be rejected")
+	}
+	verifier.err = errors.New("connection refused")
+	if _, err := signature.VerifyExchange(exchange, maker, hash); err == nil {
+		t.Errorf("Expected a failed presigned check to return an error")
+	}
+}
+
+func TestVerifyValidatorSig(t *testing.T) {
+	defer func() {
+		types.SetExchangeVerifier(nil)
+		types.SetAllowedValidators(nil)
+	}()
+	validator := &types.Address{}
+	validator[0] = 0xff
+	signature := append(append(types.Signature{0xde, 0xad}, validator[:]...), types.SigTypeValidator)
+	if *signature.Validator() != *validator {
+		t.Fatalf("Unexpected validator %#x", signature.Validator()[:])
+	}
+	if (types.Signature{0xde, types.SigTypeValidator}).Validator() != nil {
+		t.Errorf("Expected no validator for a truncated signature")
+	}
+	exchange := &types.Address{}
+	maker := &types.Address{}
+	hash := []byte{1, 2, 3}
+	verifier := &mockExchangeVerifier{valid: true}
+	types.SetExchangeVerifier(verifier)
+	if valid, _ := signature.VerifyExchange(exchange, maker, hash); signature.Supported(types.SigTypeValidator) || valid {
+		t.Errorf("Validators should only be supported when whitelisted")
+	}
+	types.SetAllowedValidators([]*types.Address{validator})
+	if !signature.Supported(types.SigTypeValidator) {
+		t.Errorf("Whitelisted validators should be supported")
+	}
+	if valid, err := signature.VerifyExchange(exchange, maker, hash); !valid || err != nil {
+		t.Errorf("Expected the validator signature to verify")
+	}
+	verifier.err = errors.New("connection refused")
+	if _, err := signature.VerifyExchange(exchange, maker, hash); err == nil {
+		t.Errorf("Expected a failed validator call to return an error")
+	}
+}
@@ -0,0 +1,164 @@
+package types
+
+import (
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"strings"
+)
+
+// TypedDataField is a member of an EIP-712 struct type
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TypedData is an EIP-712 message in the JSON format accepted by the
+// eth_signTypedData_v3 and eth_signTypedData_v4 RPC methods. Wallets hash
+// it the same way Hash() does, so an EIP712 signature of the hash is valid
+// for the message.
+type TypedData struct {
+	Types       map[string][]TypedDataField `json:"types"`
+	PrimaryType string                      `json:"primaryType"`
+	Domain      map[string]interface{}      `json:"domain"`
+	Message     map[string]interface{}      `json:"message"`
+}
+
+var orderTypedDataFields = []TypedDataField{
+	{"makerAddress", "address"},
+	{"takerAddress", "address"},
+	{"feeRecipientAddress", "address"},
+	{"senderAddress", "address"},
+	{"makerAssetAmount", "uint256"},
+	{"takerAssetAmount", "uint256"},
+	{"makerFee", "uint256"},
+	{"takerFee", "uint256"},
+	{"expirationTimeSeconds", "uint256"},
+	{"salt", "uint256"},
+	{"makerAssetData", "bytes"},
+	{"takerAssetData", "bytes"},
+}
+
+var orderTypedDataFieldsV3 = append(append([]TypedDataField{}, orderTypedDataFields...), TypedDataField{"makerFeeAssetData", "bytes"}, TypedDataField{"takerFeeAssetData", "bytes"})
+
+// TypedData returns the EIP-712 message for `order`, whose hash is
+// order.Hash().
+func (order *Order) TypedData() *TypedData {
+	domainFields := []TypedDataField{{"name", "string"}, {"version", "string"}, {"verifyingContract", "address"}}
+	domain := map[string]interface{}{
+		"name":              "0x Protocol",
+		"version":           "2",
+		"verifyingContract": fmt.Sprintf("%#x", order.ExchangeAddress[:]),
+	}
+	orderFields := orderTypedDataFields
+	message := map[string]interface{}{
+		"makerAddress":          fmt.Sprintf("%#x", order.Maker[:]),
+		"takerAddress":          fmt.Sprintf("%#x", order.Taker[:]),
+		"feeRecipientAddress":   fmt.Sprintf("%#x", order.FeeRecipient[:]),
+		"senderAddress":         fmt.Sprintf("%#x", order.SenderAddress[:]),
+		"makerAssetAmount":      order.MakerAssetAmount.Big().String(),
+		"takerAssetAmount":      order.TakerAssetAmount.Big().String(),
+		"makerFee":              order.MakerFee.Big().String(),
+		"takerFee":              order.TakerFee.Big().String(),
+		"expirationTimeSeconds": order.ExpirationTimestampInSec.Big().String(),
+		"salt":                  order.Salt.Big().String(),
+		"makerAssetData":        fmt.Sprintf("0x%x", []byte(order.MakerAssetData)),
+		"takerAssetData":        fmt.Sprintf("0x%x", []byte(order.TakerAssetData)),
+	}
+	if order.IsV3() {
+		domainFields = []TypedDataField{{"name", "string"}, {"version", "string"}, {"chainId", "uint256"}, {"verifyingContract", "address"}}
+		domain["version"] = "3.0.0"
+		domain["chainId"] = order.ChainID
+		orderFields = orderTypedDataFieldsV3
+		message["makerFeeAssetData"] = fmt.Sprintf("0x%x", []byte(order.MakerFeeAssetData))
+		message["takerFeeAssetData"] = fmt.Sprintf("0x%x", []byte(order.TakerFeeAssetData))
+	}
+	return &TypedData{
+		Types: map[string][]TypedDataField{
+			"EIP712Domain": domainFields,
+			"Order":        orderFields,
+		},
+		PrimaryType: "Order",
+		Domain:      domain,
+		Message:     message,
+	}
+}
+
+// Hash returns the EIP-712 hash of the message. Only atomic types, strings
+// and bytes are supported as members; nested structs and arrays are not.
+func (td *TypedData) Hash() ([]byte, error) {
+	domainHash, err := td.hashStruct("EIP712Domain", td.Domain)
+	if err != nil {
+		return nil, err
+	}
+	messageHash, err := td.hashStruct(td.PrimaryType, td.Message)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256([]byte{25, 1}, domainHash, messageHash), nil
+}
+
+func (td *TypedData) hashStruct(typeName string, values map[string]interface{}) ([]byte, error) {
+	fields, ok := td.Types[typeName]
+	if !ok {
+		return nil, fmt.Errorf("Unknown type '%v'", typeName)
+	}
+	members := make([]string, len(fields))
+	for i, field := range fields {
+		members[i] = field.Type + " " + field.Name
+	}
+	encoded := crypto.Keccak256([]byte(fmt.Sprintf("%v(%v)", typeName, strings.Join(members, ","))))
+	for _, field := range fields {
+		value, err := encodeTypedValue(field.Type, values[field.Name])
+		if err != nil {
+			return nil, fmt.Errorf("%v.%v: %v", typeName, field.Name, err.Error())
+		}
+		encoded = append(encoded, value...)
+	}
+	return crypto.Keccak256(encoded), nil
+}
+
+func encodeTypedValue(fieldType string, value interface{}) ([]byte, error) {
+	if fieldType == "uint256" {
+		number := new(big.Int)
+		switch v := value.(type) {
+		case uint64:
+			number.SetUint64(v)
+		case float64:
+			// JSON numbers decode as float64
+			number.SetInt64(int64(v))
+		case string:
+			if _, ok := number.SetString(v, 0); !ok {
+				return nil, fmt.Errorf("Invalid integer '%v'", v)
+			}
+		default:
+			return nil, fmt.Errorf("Unsupported value for uint256: %v", value)
+		}
+		return abi.U256(number), nil
+	}
+	str, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("Unsupported value for %v: %v", fieldType, value)
+	}
+	switch fieldType {
+	case "string":
+		return crypto.Keccak256([]byte(str)), nil
+	case "bytes":
+		data, err := HexStringToBytes(str)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(data), nil
+	case "address":
+		data, err := HexStringToBytes(str)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) != 20 {
+			return nil, fmt.Errorf("Invalid address '%v'", str)
+		}
+		return append(make([]byte, 12), data...), nil
+	}
+	return nil, fmt.Errorf("Unsupported type '%v'", fieldType)
+}
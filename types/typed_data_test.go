@@ -0,0 +1,56 @@
+package types_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/notegio/openrelay/types"
+	"testing"
+)
+
+func checkTypedDataHash(t *testing.T, order *types.Order) {
+	hash, err := order.TypedData().Hash()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(hash, order.Hash()) {
+		t.Errorf("Typed data hash %#x does not match order hash %#x", hash, order.Hash())
+	}
+	// Wallets receive the typed data as JSON
+	data, err := json.Marshal(order.TypedData())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	typedData := &types.TypedData{}
+	if err := json.Unmarshal(data, typedData); err != nil {
+		t.Fatal(err.Error())
+	}
+	hash, err = typedData.Hash()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(hash, order.Hash()) {
+		t.Errorf("Decoded typed data hash %#x does not match order hash %#x", hash, order.Hash())
+	}
+}
+
+func TestOrderTypedData(t *testing.T) {
+	checkTypedDataHash(t, buildTestOrder(t))
+}
+
+func TestOrderTypedDataV3(t *testing.T) {
+	order := getV3Order(t)
+	typedData := order.TypedData()
+	if typedData.Domain["version"] != "3.0.0" || typedData.Domain["chainId"] != order.ChainID || len(typedData.Types["Order"]) != 14 {
+		t.Errorf("Unexpected typed data: %v %v", typedData.Domain, typedData.Types)
+	}
+	checkTypedDataHash(t, order)
+}
+
+func TestTypedDataUnsupportedType(t *testing.T) {
+	typedData := buildTestOrder(t).TypedData()
+	typedData.Types["Order"] = append(typedData.Types["Order"], types.TypedDataField{Name: "tags", Type: "string[]"})
+	typedData.Message["tags"] = []string{"a"}
+	if _, err := typedData.Hash(); err == nil {
+		t.Errorf("Expected arrays to be unsupported")
+	}
+}
@@ -0,0 +1,66 @@
+package wallet
+
+import (
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/ethclient"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/exchangecontract"
+	"github.com/notegio/openrelay/types"
+	"strings"
+)
+
+type rpcExchangeVerifier struct {
+	conn bind.ContractCaller
+}
+
+func (verifier *rpcExchangeVerifier) IsPreSigned(exchange *types.Address, hash []byte, signer *types.Address) (bool, error) {
+	caller, err := exchangecontract.NewExchangeCaller(orCommon.ToGethAddress(exchange), verifier.conn)
+	if err != nil {
+		return false, err
+	}
+	hashArray := [32]byte{}
+	copy(hashArray[:], hash)
+	return caller.PreSigned(nil, hashArray, orCommon.ToGethAddress(signer))
+}
+
+// IsValidValidatorSignature checks that the signer has approved the
+// validator with the exchange before asking the exchange to check the
+// signature, as the exchange reverts for validators that aren't approved.
+func (verifier *rpcExchangeVerifier) IsValidValidatorSignature(exchange *types.Address, hash []byte, signer *types.Address, signature types.Signature) (bool, error) {
+	validator := signature.Validator()
+	if validator == nil {
+		return false, nil
+	}
+	caller, err := exchangecontract.NewExchangeCaller(orCommon.ToGethAddress(exchange), verifier.conn)
+	if err != nil {
+		return false, err
+	}
+	approved, err := caller.AllowedValidators(nil, orCommon.ToGethAddress(signer), orCommon.ToGethAddress(validator))
+	if err != nil || !approved {
+		return false, err
+	}
+	hashArray := [32]byte{}
+	copy(hashArray[:], hash)
+	valid, err := caller.IsValidSignature(nil, hashArray, orCommon.ToGethAddress(signer), signature[:])
+	if err != nil && strings.Contains(err.Error(), "revert") {
+		// A validator that reverts does not consider the signature valid
+		return false, nil
+	}
+	return valid, err
+}
+
+// NewExchangeVerifier returns an ExchangeVerifier that calls exchange
+// contracts through `conn`
+func NewExchangeVerifier(conn bind.ContractCaller) types.ExchangeVerifier {
+	return &rpcExchangeVerifier{conn}
+}
+
+// NewRpcExchangeVerifier returns an ExchangeVerifier that calls exchange
+// contracts through the Ethereum node at `rpcURL`
+func NewRpcExchangeVerifier(rpcURL string) (types.ExchangeVerifier, error) {
+	conn, err := ethclient.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewExchangeVerifier(conn), nil
+}
@@ -0,0 +1,79 @@
+package wallet_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/notegio/openrelay/types"
+	"github.com/notegio/openrelay/wallet"
+	"math/big"
+	"testing"
+)
+
+// selectorCaller answers contract calls by function selector
+type selectorCaller struct {
+	results map[string][]byte
+	called  []string
+}
+
+func (caller *selectorCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (caller *selectorCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	selector := string(call.Data[:4])
+	caller.called = append(caller.called, selector)
+	return caller.results[selector], nil
+}
+
+var (
+	preSignedSelector         = string([]byte{0x82, 0xc1, 0x74, 0xd0})
+	allowedValidatorsSelector = string([]byte{0x7b, 0x8e, 0x35, 0x14})
+	isValidSignatureSelector  = string([]byte{0x93, 0x63, 0x47, 0x02})
+)
+
+func boolWord(value bool) []byte {
+	result := make([]byte, 32)
+	if value {
+		result[31] = 1
+	}
+	return result
+}
+
+func TestIsPreSigned(t *testing.T) {
+	caller := &selectorCaller{results: map[string][]byte{preSignedSelector: boolWord(true)}}
+	preSigned, err := wallet.NewExchangeVerifier(caller).IsPreSigned(&types.Address{}, word(0xff), &types.Address{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !preSigned {
+		t.Errorf("Expected the order to be presigned")
+	}
+}
+
+func TestIsValidValidatorSignature(t *testing.T) {
+	validator := &types.Address{}
+	validator[0] = 0xff
+	signature := append(append(types.Signature{0xde, 0xad}, validator[:]...), types.SigTypeValidator)
+	caller := &selectorCaller{results: map[string][]byte{
+		allowedValidatorsSelector: boolWord(false),
+		isValidSignatureSelector:  boolWord(true),
+	}}
+	verifier := wallet.NewExchangeVerifier(caller)
+	valid, err := verifier.IsValidValidatorSignature(&types.Address{}, word(0xff), &types.Address{}, signature)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if valid || len(caller.called) != 1 {
+		t.Errorf("Signatures for unapproved validators should be rejected without checking them")
+	}
+	caller.results[allowedValidatorsSelector] = boolWord(true)
+	valid, err = verifier.IsValidValidatorSignature(&types.Address{}, word(0xff), &types.Address{}, signature)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !valid || !bytes.Equal([]byte(caller.called[len(caller.called)-1]), []byte(isValidSignatureSelector)) {
+		t.Errorf("Expected the exchange to accept the signature, called %#v", caller.called)
+	}
+}
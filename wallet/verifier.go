@@ -0,0 +1,67 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/ethclient"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"strings"
+)
+
+// isValidSignatureSelector is the selector of isValidSignature(bytes32,bytes)
+var isValidSignatureSelector = []byte{0x16, 0x26, 0xba, 0x7e}
+
+type rpcWalletVerifier struct {
+	conn bind.ContractCaller
+}
+
+// IsValidWalletSignature calls isValidSignature(bytes32 hash, bytes signature)
+// on the wallet contract. EIP-1271 wallets return the function's selector if
+// the signature is valid, while 0x v2 wallets return true, so either is
+// accepted.
+func (verifier *rpcWalletVerifier) IsValidWalletSignature(wallet *types.Address, hash []byte, signature []byte) (bool, error) {
+	data := append([]byte{}, isValidSignatureSelector...)
+	data = append(data, abi.U256(new(big.Int).SetBytes(hash))...)
+	data = append(data, abi.U256(big.NewInt(64))...)
+	data = append(data, abi.U256(big.NewInt(int64(len(signature))))...)
+	padded := make([]byte, (len(signature)+31)/32*32)
+	copy(padded, signature)
+	data = append(data, padded...)
+	walletAddress := orCommon.ToGethAddress(wallet)
+	result, err := verifier.conn.CallContract(context.Background(), ethereum.CallMsg{To: &walletAddress, Data: data}, nil)
+	if err != nil && strings.Contains(err.Error(), "revert") {
+		// A wallet that reverts does not consider the signature valid
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if len(result) < 32 {
+		// Not a wallet contract, or the call reverted
+		return false, nil
+	}
+	if bytes.Equal(result[:4], isValidSignatureSelector) {
+		return true, nil
+	}
+	return new(big.Int).SetBytes(result[:32]).Cmp(big.NewInt(1)) == 0, nil
+}
+
+// NewWalletVerifier returns a WalletVerifier that calls wallet contracts
+// through `conn`
+func NewWalletVerifier(conn bind.ContractCaller) types.WalletVerifier {
+	return &rpcWalletVerifier{conn}
+}
+
+// NewRpcWalletVerifier returns a WalletVerifier that calls wallet contracts
+// through the Ethereum node at `rpcURL`
+func NewRpcWalletVerifier(rpcURL string) (types.WalletVerifier, error) {
+	conn, err := ethclient.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewWalletVerifier(conn), nil
+}
@@ -0,0 +1,67 @@
+package wallet_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/notegio/openrelay/types"
+	"github.com/notegio/openrelay/wallet"
+	"math/big"
+	"testing"
+)
+
+type mockCaller struct {
+	result []byte
+	err    error
+	data   []byte
+}
+
+func (caller *mockCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (caller *mockCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	caller.data = call.Data
+	return caller.result, caller.err
+}
+
+func word(prefix ...byte) []byte {
+	result := make([]byte, 32)
+	copy(result, prefix)
+	return result
+}
+
+func TestIsValidWalletSignature(t *testing.T) {
+	trueWord := make([]byte, 32)
+	trueWord[31] = 1
+	for _, test := range []struct {
+		name     string
+		result   []byte
+		err      error
+		expected bool
+	}{
+		{"magic value", word(0x16, 0x26, 0xba, 0x7e), nil, true},
+		{"true", trueWord, nil, true},
+		{"false", word(), nil, false},
+		{"empty", []byte{}, nil, false},
+		{"revert", nil, errors.New("execution reverted"), false},
+	} {
+		caller := &mockCaller{result: test.result, err: test.err}
+		valid, err := wallet.NewWalletVerifier(caller).IsValidWalletSignature(&types.Address{}, word(0xff), []byte{1, 2, 3})
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", test.name, err.Error())
+		}
+		if valid != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, valid)
+		}
+		// selector + hash + offset + length + one padded word of signature
+		if len(caller.data) != 4+32*4 {
+			t.Errorf("%v: unexpected call data %#x", test.name, caller.data)
+		}
+	}
+	caller := &mockCaller{err: errors.New("connection refused")}
+	if _, err := wallet.NewWalletVerifier(caller).IsValidWalletSignature(&types.Address{}, word(), []byte{}); err == nil {
+		t.Errorf("Expected connection errors to be returned")
+	}
+}